@@ -0,0 +1,76 @@
+// Filename: cmd/api/auditHandlers.go
+
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+)
+
+// listAuditEventsHandler handles GET /v1/admin/audit.  Events can be filtered
+// by actor_id, action, target_type, target_id and a from/to time range.
+func (a *app) listAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	var input struct {
+		data.AuditFilter
+		data.Filters
+	}
+
+	v := validator.New()
+
+	input.AuditFilter.ActorID = int64(a.getSingleIntegerParameter(qs, "actor_id", 0, v))
+	input.AuditFilter.Action = a.getSingleQueryParameter(qs, "action", "")
+	input.AuditFilter.TargetType = a.getSingleQueryParameter(qs, "target_type", "")
+	input.AuditFilter.TargetID = int64(a.getSingleIntegerParameter(qs, "target_id", 0, v))
+	input.AuditFilter.From = a.getTimeParameter(qs, "from", v)
+	input.AuditFilter.To = a.getTimeParameter(qs, "to", v)
+
+	input.Filters.Page = a.getSingleIntegerParameter(qs, "page", 1, v)
+	input.Filters.PageSize = a.getSingleIntegerParameter(qs, "page_size", 50, v)
+	input.Filters.Sort = a.getSingleQueryParameter(qs, "sort", "-created_at")
+	input.Filters.Cursor = a.getSingleQueryParameter(qs, "cursor", "")
+
+	if !input.AuditFilter.From.IsZero() && !input.AuditFilter.To.IsZero() {
+		v.Check(input.AuditFilter.From.Before(input.AuditFilter.To), "from", "must be before to")
+	}
+
+	if data.ValidateQuery(v, input.Filters, data.AuditFields); !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, metadata, err := a.models.Audit.GetAll(r.Context(), input.AuditFilter, input.Filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"audit_events": events, "metadata": metadata}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// getTimeParameter reads an RFC 3339 timestamp or a YYYY-MM-DD date from the
+// query string, returning the zero time if it is absent
+func (a *app) getTimeParameter(qs url.Values, key string, v *validator.Validator) time.Time {
+	value := qs.Get(key)
+	if value == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t
+		}
+	}
+
+	v.AddError(key, "must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+	return time.Time{}
+}
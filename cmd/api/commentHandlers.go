@@ -43,9 +43,21 @@ func (a *app) createCommentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = a.models.ResourceComments.Insert(comment)
+	// Comments can only be posted as yourself
+	if comment.UserID != a.contextGetUser(r).ID {
+		a.policyDeniedResponse(w, r, reasonNotCommentAuthor)
+		return
+	}
+
+	err = a.models.ResourceComments.Insert(r.Context(), comment)
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("parent_comment_id", "must be a comment on this resource that has not been deleted")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -66,7 +78,7 @@ func (a *app) getCommentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	comment, err := a.models.ResourceComments.Get(id)
+	comment, err := a.models.ResourceComments.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -86,7 +98,8 @@ func (a *app) getCommentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// getResourceCommentsHandler retrieves all comments for a resource
+// getResourceCommentsHandler retrieves the comments for a resource, oldest
+// first, a page at a time
 func (a *app) getResourceCommentsHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := a.readIDParam(r)
 	if err != nil {
@@ -94,7 +107,24 @@ func (a *app) getResourceCommentsHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	comments, err := a.models.ResourceComments.GetByResource(id)
+	qs := r.URL.Query()
+	v := validator.New()
+
+	var input struct {
+		data.Filters
+	}
+
+	input.Filters.Page = a.getSingleIntegerParameter(qs, "page", 1, v)
+	input.Filters.PageSize = a.getSingleIntegerParameter(qs, "page_size", 100, v)
+	input.Filters.Sort = a.getSingleQueryParameter(qs, "sort", "created_at")
+	input.Filters.Cursor = a.getSingleQueryParameter(qs, "cursor", "")
+
+	if data.ValidateQuery(v, input.Filters, data.CommentFields); !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	comments, metadata, err := a.models.ResourceComments.GetByResource(r.Context(), id, input.Filters)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -102,6 +132,7 @@ func (a *app) getResourceCommentsHandler(w http.ResponseWriter, r *http.Request)
 
 	response := envelope{
 		"comments": comments,
+		"metadata": metadata,
 	}
 	err = a.writeJSON(w, http.StatusOK, response, nil)
 	if err != nil {
@@ -117,7 +148,7 @@ func (a *app) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	comment, err := a.models.ResourceComments.Get(id)
+	comment, err := a.models.ResourceComments.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -128,6 +159,16 @@ func (a *app) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	reason, err := a.authorizeComment(r.Context(), a.contextGetUser(r), comment)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if reason != "" {
+		a.policyDeniedResponse(w, r, reason)
+		return
+	}
+
 	var input struct {
 		Content *string `json:"content"`
 	}
@@ -151,7 +192,7 @@ func (a *app) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = a.models.ResourceComments.Update(comment)
+	err = a.models.ResourceComments.Update(r.Context(), comment)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -174,7 +215,28 @@ func (a *app) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = a.models.ResourceComments.Delete(id)
+	comment, err := a.models.ResourceComments.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	reason, err := a.authorizeComment(r.Context(), a.contextGetUser(r), comment)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if reason != "" {
+		a.policyDeniedResponse(w, r, reason)
+		return
+	}
+
+	err = a.models.ResourceComments.Delete(r.Context(), comment.ID, a.contextGetUser(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
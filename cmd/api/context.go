@@ -10,6 +10,8 @@ import (
 type contextKey string
 
 const userContextKey = contextKey("user")
+const tokenContextKey = contextKey("token")
+const requestIDContextKey = contextKey("request_id")
 
 func (a *app) contextSetUser(r *http.Request, user *data.User) *http.Request {
 	// WithValue() expects the original context along with the new
@@ -26,3 +28,28 @@ func (a *app) contextGetUser(r *http.Request) *data.User {
 
     return user
 }
+
+// contextSetToken stores the bearer token the request was authenticated with
+func (a *app) contextSetToken(r *http.Request, token string) *http.Request {
+	ctx := context.WithValue(r.Context(), tokenContextKey, token)
+	return r.WithContext(ctx)
+}
+
+// contextGetToken returns the bearer token of the current request, or "" for
+// anonymous requests
+func (a *app) contextGetToken(r *http.Request) string {
+	token, _ := r.Context().Value(tokenContextKey).(string)
+	return token
+}
+
+// contextSetRequestID stores the ID used to correlate logs and audit events
+func (a *app) contextSetRequestID(r *http.Request, requestID string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, requestID)
+	return r.WithContext(ctx)
+}
+
+// contextGetRequestID returns the current request's ID, or "" if none was set
+func (a *app) contextGetRequestID(r *http.Request) string {
+	requestID, _ := r.Context().Value(requestIDContextKey).(string)
+	return requestID
+}
@@ -38,7 +38,7 @@ func (a *app) createContributionHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = a.models.Contributions.Insert(contribution)
+	err = a.models.Contributions.Insert(r.Context(), contribution)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -61,7 +61,7 @@ func (a *app) getContributionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	contribution, err := a.models.Contributions.Get(id)
+	contribution, err := a.models.Contributions.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -96,7 +96,7 @@ func (a *app) getContributionByResourceIDHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	contribution, err := a.models.Contributions.GetByResourceID(int64(id))
+	contribution, err := a.models.Contributions.GetByResourceID(r.Context(), int64(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -147,7 +147,7 @@ func (a *app) getAllContributionsHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	contributions, metadata, err := a.models.Contributions.GetAll(input.Filters)
+	contributions, metadata, err := a.models.Contributions.GetAll(r.Context(), input.Filters)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -171,7 +171,7 @@ func (a *app) updateContributionHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	contribution, err := a.models.Contributions.Get(id)
+	contribution, err := a.models.Contributions.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -206,7 +206,7 @@ func (a *app) updateContributionHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = a.models.Contributions.Update(contribution)
+	err = a.models.Contributions.Update(r.Context(), contribution)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -234,7 +234,7 @@ func (a *app) deleteContributionHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = a.models.Contributions.Delete(id)
+	err = a.models.Contributions.Delete(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
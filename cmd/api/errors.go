@@ -2,7 +2,13 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
 )
 
 // log an error message
@@ -76,12 +82,80 @@ func (a *app) editConflictResponse(w http.ResponseWriter, r *http.Request) {
 	a.errorResponseJSON(w, r, http.StatusConflict, message)
 }
 
+// send a 412 when an If-Match header names a version of the record that is
+// no longer current
+func (a *app) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the record has changed since you fetched it, please reload it and try again"
+	a.errorResponseJSON(w, r, http.StatusPreconditionFailed, message)
+}
+
+// send a 409 when a resource status change breaks the lifecycle rules,
+// listing the statuses the client may move to instead
+func (a *app) invalidStatusTransitionResponse(w http.ResponseWriter, r *http.Request, err *data.StatusTransitionError) {
+	a.errorResponseJSON(w, r, http.StatusConflict, statusTransitionMessage(err))
+}
+
+func statusTransitionMessage(err *data.StatusTransitionError) envelope {
+	return envelope{
+		"message":          err.Error(),
+		"from":             err.From,
+		"to":               err.To,
+		"allowed_statuses": err.Allowed,
+	}
+}
+
+// send a 422 naming the subjects and grade levels that are not in the
+// lookup tables
+func (a *app) unknownValuesResponse(w http.ResponseWriter, r *http.Request, err *data.UnknownValuesError) {
+	a.failedValidationResponse(w, r, unknownValuesMessage(err))
+}
+
+func unknownValuesMessage(err *data.UnknownValuesError) map[string]string {
+	v := validator.New()
+	if len(err.Subjects) > 0 {
+		v.AddError("subjects", "contains unknown values: "+strings.Join(err.Subjects, ", "))
+	}
+	if len(err.GradeLevels) > 0 {
+		v.AddError("grade_levels", "contains unknown values: "+strings.Join(err.GradeLevels, ", "))
+	}
+	return v.Errors
+}
+
+// send a 403 when an ownership policy refuses a change, with the reason code
+// so clients can tell which rule was broken
+func (a *app) policyDeniedResponse(w http.ResponseWriter, r *http.Request, reason string) {
+	a.errorResponseJSON(w, r, http.StatusForbidden, policyDeniedMessage(reason))
+}
+
+func policyDeniedMessage(reason string) envelope {
+	return envelope{
+		"message": policyMessages[reason],
+		"reason":  reason,
+	}
+}
+
 // Return a 401 status code
 func (a *app) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
     message := "invalid authentication credentials"
     a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
 }
 
+// send a 429 while sign-ins for an account or IP are backing off or locked out
+func (a *app) loginThrottledResponse(w http.ResponseWriter, r *http.Request, throttle *data.LoginThrottle) {
+	retryAfter := int(math.Ceil(throttle.RetryAfter().Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	message := envelope{
+		"message":             "too many failed sign-in attempts, please try again later",
+		"locked":              throttle.Locked,
+		"retry_after_seconds": retryAfter,
+	}
+	if throttle.Locked {
+		message["message"] = "this account is temporarily locked after too many failed sign-in attempts"
+	}
+	a.errorResponseJSON(w, r, http.StatusTooManyRequests, message)
+}
+
 func (a *app) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("WWW-Authenticate", "Bearer")
 
@@ -89,6 +163,22 @@ func (a *app) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.
 	a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
 }
 
+func (a *app) invalidRefreshTokenResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid, expired or already used refresh token, please sign in again"
+	a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
+}
+
+func (a *app) invalidMFACodeResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid or already used verification code, please sign in again"
+	a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
+}
+
+// send a 403 when the user's role requires two-factor authentication and the
+// user has not set it up yet
+func (a *app) mfaEnrollmentRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	a.policyDeniedResponse(w, r, reasonMFAEnrollment)
+}
+
 func (a *app) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
     message := "you must be authenticated to access this resource"
     a.errorResponseJSON(w, r, http.StatusUnauthorized, message)
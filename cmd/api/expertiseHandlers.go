@@ -0,0 +1,127 @@
+// Filename: cmd/api/expertiseHandlers.go
+
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+)
+
+// getUserSubjectsHandler returns the subjects a user is an expert in
+func (a *app) getUserSubjectsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	canAccess, err := a.canAccessUserData(r.Context(), a.contextGetUser(r), id)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !canAccess {
+		a.notPermittedResponse(w, r)
+		return
+	}
+
+	_, err = a.models.Users.Get(r.Context(), int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	subjects, err := a.models.Users.GetSubjects(r.Context(), id)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"subjects": subjects,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateUserSubjectsHandler replaces the subjects a user is an expert in
+func (a *app) updateUserSubjectsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Subjects []string `json:"subjects"`
+	}
+
+	err = a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Subjects != nil, "subjects", "must be provided")
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = a.models.Users.Get(r.Context(), int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	before, err := a.models.Users.GetSubjects(r.Context(), id)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.models.Users.SetSubjects(r.Context(), id, input.Subjects,
+		a.auditEvent(r, "user.subjects", "user", id, before, input.Subjects))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrUnknownSubject):
+			v.AddError("subjects", "contains an unknown subject")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	subjects, err := a.models.Users.GetSubjects(r.Context(), id)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"subjects": subjects,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,346 @@
+// Filename: cmd/api/exportHandlers.go
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+)
+
+// exportFormats are the formats an export can be written in
+var exportFormats = []string{"csv", "json", "ndjson"}
+
+// exportFlushEvery is how many rows are written between flushes to the client
+const exportFlushEvery = 100
+
+// exportColumn is one CSV column of an export
+type exportColumn[T any] struct {
+	name  string
+	value func(T) string
+}
+
+var resourceExportColumns = []exportColumn[*data.Resource]{
+	{"resource_id", func(r *data.Resource) string { return strconv.FormatInt(r.ID, 10) }},
+	{"title", func(r *data.Resource) string { return r.Title }},
+	{"category", func(r *data.Resource) string { return r.Category }},
+	{"slug", func(r *data.Resource) string { return exportString(r.Slug) }},
+	{"summary", func(r *data.Resource) string { return exportString(r.Summary) }},
+	{"subjects", func(r *data.Resource) string { return strings.Join(r.Subjects, "; ") }},
+	{"grade_levels", func(r *data.Resource) string { return strings.Join(r.GradeLevels, "; ") }},
+	{"drive_link", func(r *data.Resource) string { return exportString(r.DriveLink) }},
+	{"status", func(r *data.Resource) string { return r.Status }},
+	{"published_url", func(r *data.Resource) string { return exportString(r.PublishedURL) }},
+	{"contributor_id", func(r *data.Resource) string { return strconv.FormatInt(r.ContributorID, 10) }},
+	{"contributor_name", func(r *data.Resource) string { return r.ContributorName }},
+	{"view_count", func(r *data.Resource) string { return strconv.FormatInt(r.ViewCount, 10) }},
+	{"created_at", func(r *data.Resource) string { return exportTime(r.CreatedAt) }},
+	{"updated_at", func(r *data.Resource) string { return exportTime(r.UpdatedAt) }},
+	{"version", func(r *data.Resource) string { return strconv.Itoa(int(r.Version)) }},
+}
+
+var userExportColumns = []exportColumn[*data.User]{
+	{"user_id", func(u *data.User) string { return strconv.FormatInt(u.ID, 10) }},
+	{"username", func(u *data.User) string { return u.Username }},
+	{"email", func(u *data.User) string { return u.Email }},
+	{"role_id", func(u *data.User) string { return strconv.Itoa(u.RoleID) }},
+	{"role_name", func(u *data.User) string { return u.RoleName }},
+	{"is_active", func(u *data.User) string { return strconv.FormatBool(u.IsActive) }},
+	{"last_login", func(u *data.User) string {
+		if u.LastLogin == nil {
+			return ""
+		}
+		return exportTime(*u.LastLogin)
+	}},
+	{"created_at", func(u *data.User) string { return exportTime(u.CreatedAt) }},
+	{"updated_at", func(u *data.User) string { return exportTime(u.UpdatedAt) }},
+	{"version", func(u *data.User) string { return strconv.Itoa(int(u.Version)) }},
+}
+
+var reviewExportColumns = []exportColumn[*data.ResourceReview]{
+	{"review_id", func(r *data.ResourceReview) string { return strconv.FormatInt(r.ID, 10) }},
+	{"resource_id", func(r *data.ResourceReview) string { return strconv.FormatInt(r.ResourceID, 10) }},
+	{"reviewer_id", func(r *data.ResourceReview) string { return strconv.FormatInt(r.ReviewerID, 10) }},
+	{"reviewer_role_id", func(r *data.ResourceReview) string { return strconv.FormatInt(r.ReviewerRoleID, 10) }},
+	{"decision", func(r *data.ResourceReview) string { return r.Decision }},
+	{"comment_summary", func(r *data.ResourceReview) string { return r.CommentSummary }},
+	{"reviewed_at", func(r *data.ResourceReview) string { return exportTime(r.ReviewedAt) }},
+}
+
+var accessExportColumns = []exportColumn[*data.ResourceAccess]{
+	{"access_id", func(a *data.ResourceAccess) string { return strconv.FormatInt(a.ID, 10) }},
+	{"resource_id", func(a *data.ResourceAccess) string { return strconv.FormatInt(a.ResourceID, 10) }},
+	{"user_id", func(a *data.ResourceAccess) string { return strconv.FormatInt(a.UserID, 10) }},
+	{"accessed_at", func(a *data.ResourceAccess) string { return exportTime(a.AccessedAt) }},
+}
+
+// exportResourcesHandler handles GET /v1/admin/exports/resources.  It takes
+// the same search, filter and sort parameters as GET /v1/resources and
+// streams every matching resource.
+func (a *app) exportResourcesHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	format := a.readExportFormat(qs, v)
+	filter := a.readResourceFilter(qs, v)
+	filters := data.Filters{
+		Sort:       a.getSingleQueryParameter(qs, "sort", "-created_at"),
+		Conditions: a.readConditions(qs, data.ResourceFields),
+	}
+
+	if data.ValidateExportQuery(v, filters, data.ResourceFields); !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	streamExport(a, w, r, "resources", format, resourceExportColumns, func(fn func(*data.Resource) error) error {
+		return a.models.Resources.Export(r.Context(), filter, filters, fn)
+	})
+}
+
+// exportUsersHandler handles GET /v1/admin/exports/users, with the same
+// filter and sort parameters as GET /v1/users
+func (a *app) exportUsersHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	format := a.readExportFormat(qs, v)
+	filters := data.Filters{
+		Sort:       a.getSingleQueryParameter(qs, "sort", "id"),
+		Conditions: a.readConditions(qs, data.UserFields),
+	}
+
+	if data.ValidateExportQuery(v, filters, data.UserFields); !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	streamExport(a, w, r, "users", format, userExportColumns, func(fn func(*data.User) error) error {
+		return a.models.Users.Export(r.Context(), filters, fn)
+	})
+}
+
+// exportReviewsHandler handles GET /v1/admin/exports/reviews.  Reviews can
+// be filtered by resource_id, reviewer_id, decision and a reviewed_at range.
+func (a *app) exportReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	format := a.readExportFormat(qs, v)
+	filters := data.Filters{
+		Sort:       a.getSingleQueryParameter(qs, "sort", "-reviewed_at"),
+		Conditions: a.readConditions(qs, data.ResourceReviewFields),
+	}
+
+	if data.ValidateExportQuery(v, filters, data.ResourceReviewFields); !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	streamExport(a, w, r, "reviews", format, reviewExportColumns, func(fn func(*data.ResourceReview) error) error {
+		return a.models.ResourceReviews.Export(r.Context(), filters, fn)
+	})
+}
+
+// exportAccessHandler handles GET /v1/admin/exports/access, with the same
+// filter and sort parameters as GET /v1/resource-access
+func (a *app) exportAccessHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	format := a.readExportFormat(qs, v)
+	filters := data.Filters{
+		Sort:       a.getSingleQueryParameter(qs, "sort", "-accessed_at"),
+		Conditions: a.readConditions(qs, data.ResourceAccessFields),
+	}
+
+	if data.ValidateExportQuery(v, filters, data.ResourceAccessFields); !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	streamExport(a, w, r, "access", format, accessExportColumns, func(fn func(*data.ResourceAccess) error) error {
+		return a.models.ResourceAccess.Export(r.Context(), filters, fn)
+	})
+}
+
+// readExportFormat reads ?format=, which defaults to csv
+func (a *app) readExportFormat(qs url.Values, v *validator.Validator) string {
+	format := strings.ToLower(a.getSingleQueryParameter(qs, "format", "csv"))
+	v.Check(validator.PermittedValue(format, exportFormats...), "format",
+		"must be one of "+strings.Join(exportFormats, ", "))
+	return format
+}
+
+// streamExport writes the rows produced by run to the client as they arrive.
+// Until the first row is written a failure gets the usual error response;
+// after that the status has been sent, so the error is logged and the body
+// cut short, which leaves a JSON export unterminated.  A finished export is
+// recorded in the audit log.
+func streamExport[T any](a *app, w http.ResponseWriter, r *http.Request, name, format string,
+	columns []exportColumn[T], run func(func(T) error) error) {
+	e := &exportWriter[T]{w: w, rc: http.NewResponseController(w), name: name, format: format, columns: columns}
+
+	err := run(e.write)
+	if err == nil {
+		err = e.finish()
+	}
+	if err != nil {
+		if !e.started {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		a.logError(r, fmt.Errorf("export %s stopped after %d rows: %w", name, e.rows, err))
+		return
+	}
+
+	audit := a.auditEvent(r, "export."+name, "export", 0, nil,
+		envelope{"format": format, "rows": e.rows, "query": r.URL.RawQuery})
+	if err := a.models.Audit.Insert(r.Context(), audit); err != nil {
+		a.logError(r, err)
+	}
+}
+
+// exportWriter writes export rows in one format, sending the headers with
+// the first row
+type exportWriter[T any] struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	name    string
+	format  string
+	columns []exportColumn[T]
+	csv     *csv.Writer
+	started bool
+	rows    int
+}
+
+func (e *exportWriter[T]) start() error {
+	e.started = true
+
+	contentType := map[string]string{
+		"csv":    "text/csv; charset=utf-8",
+		"json":   "application/json",
+		"ndjson": "application/x-ndjson",
+	}[e.format]
+	filename := fmt.Sprintf("%s-%s.%s", e.name, time.Now().UTC().Format("20060102-150405"), e.format)
+
+	e.w.Header().Set("Content-Type", contentType)
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	e.w.Header().Set("Cache-Control", "no-store")
+	e.w.WriteHeader(http.StatusOK)
+
+	switch e.format {
+	case "csv":
+		e.csv = csv.NewWriter(e.w)
+		header := make([]string, len(e.columns))
+		for i, column := range e.columns {
+			header[i] = column.name
+		}
+		return e.csv.Write(header)
+	case "json":
+		_, err := e.w.Write([]byte("["))
+		return err
+	}
+	return nil
+}
+
+func (e *exportWriter[T]) write(item T) error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	switch e.format {
+	case "csv":
+		record := make([]string, len(e.columns))
+		for i, column := range e.columns {
+			record[i] = csvCell(column.value(item))
+		}
+		err = e.csv.Write(record)
+	case "json":
+		separator := ",\n"
+		if e.rows == 0 {
+			separator = "\n"
+		}
+		err = e.writeJSONLine(separator, item, "")
+	case "ndjson":
+		err = e.writeJSONLine("", item, "\n")
+	}
+	if err != nil {
+		return err
+	}
+
+	e.rows++
+	if e.rows%exportFlushEvery == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+func (e *exportWriter[T]) writeJSONLine(before string, item T, after string) error {
+	js, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write([]byte(before + string(js) + after))
+	return err
+}
+
+// finish closes the export, starting it first if there were no rows
+func (e *exportWriter[T]) finish() error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	if e.format == "json" {
+		if _, err := e.w.Write([]byte("\n]\n")); err != nil {
+			return err
+		}
+	}
+	return e.flush()
+}
+
+func (e *exportWriter[T]) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	// A writer that can't flush still gets everything, just in larger pieces
+	if err := e.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// csvCell stops spreadsheets treating a value as a formula
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func exportString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func exportTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
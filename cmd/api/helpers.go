@@ -1,15 +1,19 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -114,6 +118,18 @@ func (a *app) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// readNamedIDParam reads a positive integer route parameter other than "id",
+// e.g. the :user_id in /resources/:id/coauthors/:user_id
+func (a *app) readNamedIDParam(r *http.Request, name string) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid " + name + " parameter")
+	}
+
+	return id, nil
+}
+
 func (a *app) getSingleQueryParameter(
 	queryParameters url.Values,
 	key string,
@@ -140,6 +156,51 @@ func (a *app) getMultipleQueryParameters(
 	return strings.Split(result, ",")
 }
 
+// call when a parameter may be repeated (?subject=Science&subject=Robotics),
+// comma-separated, or both.  Blank values are dropped.
+func (a *app) getRepeatedQueryParameters(
+	queryParameters url.Values,
+	key string) []string {
+
+	var result []string
+	for _, value := range queryParameters[key] {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+// readConditions collects the filter conditions for a list from the query
+// string.  A condition is written field[op]=value, e.g. created_at[gte]=2024-01-01,
+// or field=value to use the field's default operator.  Other parameters are
+// left alone; ValidateQuery rejects bracketed fields the list doesn't have.
+func (a *app) readConditions(
+	queryParameters url.Values,
+	fields data.Fields) []data.Condition {
+
+	var conditions []data.Condition
+	for _, key := range slices.Sorted(maps.Keys(queryParameters)) {
+		var condition data.Condition
+
+		if name, op, ok := strings.Cut(key, "["); ok && strings.HasSuffix(op, "]") {
+			condition = data.Condition{Field: name, Op: data.Op(strings.TrimSuffix(op, "]"))}
+		} else if field, ok := fields[key]; ok && len(field.Ops) > 0 {
+			condition = data.Condition{Field: key, Op: field.Ops[0]}
+		} else {
+			continue
+		}
+
+		for _, value := range queryParameters[key] {
+			condition.Value = value
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions
+}
+
 // this method can cause a validation error when trying to convert the
 // string to a valid integer value
 func (a *app) getSingleIntegerParameter(
@@ -161,6 +222,26 @@ func (a *app) getSingleIntegerParameter(
 	return intValue
 }
 
+// getSingleBoolParameter reads a true/false query parameter, adding a
+// validation error if it is not a boolean
+func (a *app) getSingleBoolParameter(
+	queryParameters url.Values,
+	key string,
+	defaultValue bool,
+	v *validator.Validator) bool {
+	result := queryParameters.Get(key)
+	if result == "" {
+		return defaultValue
+	}
+	boolValue, err := strconv.ParseBool(result)
+	if err != nil {
+		v.AddError(key, "must be true or false")
+		return defaultValue
+	}
+
+	return boolValue
+}
+
 // Accept a function and run it in the background also recover from any panic
 func (a *app) background(fn func()) {
 	a.wg.Add(1) // Use a wait group to ensure all goroutines finish before we exit
@@ -176,28 +257,79 @@ func (a *app) background(fn func()) {
 	}()
 }
 
+// clientIP returns the IP address of the client that sent the request
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// etag returns the entity tag for a version of a record
+func etag(version int32) string {
+	return `"` + strconv.Itoa(int(version)) + `"`
+}
+
+// etagHeader returns the ETag response header for a version of a record
+func etagHeader(version int32) http.Header {
+	return http.Header{"Etag": []string{etag(version)}}
+}
+
+// ifMatch reports whether the request's If-Match header allows a write to a
+// record at version.  A request without the header always matches.  Tags are
+// compared strongly, so a weak W/ tag never matches.
+func ifMatch(r *http.Request, version int32) bool {
+	values := r.Header.Values("If-Match")
+	if len(values) == 0 {
+		return true
+	}
+
+	current := etag(version)
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || tag == current {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// auditEvent describes a privileged action by the current user for the audit
+// log.  before and after are the target as it was and as it now is; either may
+// be nil.
+func (a *app) auditEvent(r *http.Request, action, targetType string, targetID int64, before, after any) *data.AuditEvent {
+	return &data.AuditEvent{
+		ActorID:    a.contextGetUser(r).ID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     before,
+		After:      after,
+		IPAddress:  clientIP(r),
+		RequestID:  a.contextGetRequestID(r),
+	}
+}
+
 // Check if the current user can access a specific user's data
-// Administrators and Content Contributors can access all users
-// System Users can only access their own data
-func (a *app) canAccessUserData(currentUser *data.User, targetUserID int64) (bool, error) {
-	// Get the role of the current user
-	role, err := a.models.Roles.Get(currentUser.RoleID)
+// Roles granted user:read can access all users
+// Everyone else can only access their own data
+func (a *app) canAccessUserData(ctx context.Context, currentUser *data.User, targetUserID int64) (bool, error) {
+	// Holders of user:read may access anyone's data, once enrolled in
+	// two-factor authentication if their role requires it
+	canRead, _, err := a.userPermission(ctx, currentUser, "user:read")
 	if err != nil {
 		return false, err
 	}
 
-	// Administrators and Content Contributors can access any user
-	if role.RoleName == "admin" || role.RoleName == "CEO" || role.RoleName == "DEC" || role.RoleName == "TSC" {
+	if canRead {
 		return true, nil
 	}
 
-	// System Users can only access their own data
-	if role.RoleName == "Fellow" {
-		return currentUser.ID == targetUserID, nil
-	}
-
-	// Default: deny access
-	return false, nil
+	// Other users can only access their own data
+	return currentUser.ID == targetUserID, nil
 }
 
 // slugify converts a string to a URL-friendly slug
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		version int32
+		want    bool
+	}{
+		{name: "no header", version: 3, want: true},
+		{name: "current version", headers: []string{`"3"`}, version: 3, want: true},
+		{name: "stale version", headers: []string{`"2"`}, version: 3, want: false},
+		{name: "unquoted", headers: []string{`3`}, version: 3, want: false},
+		{name: "weak tag", headers: []string{`W/"3"`}, version: 3, want: false},
+		{name: "any", headers: []string{`*`}, version: 3, want: true},
+		{name: "list containing current", headers: []string{`"1", "3"`}, version: 3, want: true},
+		{name: "list without current", headers: []string{`"1","2"`}, version: 3, want: false},
+		{name: "repeated headers", headers: []string{`"1"`, `"3"`}, version: 3, want: true},
+		{name: "empty header", headers: []string{``}, version: 3, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PATCH", "/v1/resources/1", nil)
+			for _, value := range tt.headers {
+				r.Header.Add("If-Match", value)
+			}
+			if got := ifMatch(r, tt.version); got != tt.want {
+				t.Errorf("ifMatch = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEtag(t *testing.T) {
+	if got := etag(12); got != `"12"` {
+		t.Errorf("etag(12) = %s, want \"12\"", got)
+	}
+	if got := etagHeader(12).Get("ETag"); got != `"12"` {
+		t.Errorf("ETag header = %s, want \"12\"", got)
+	}
+}
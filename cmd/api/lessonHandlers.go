@@ -4,6 +4,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/amilcar-vasquez/501SteamHub/internal/data"
@@ -48,18 +49,50 @@ func (a *app) createLessonHandler(w http.ResponseWriter, r *http.Request) {
 	v.Check(lesson.LessonNumber > 0, "lesson_number", "must be provided")
 	v.Check(lesson.Title != "", "title", "must be provided")
 	v.Check(lesson.Content != "", "content", "must be provided")
+	var doc *data.LessonDocument
+	if lesson.Content != "" {
+		doc = validateLessonContent(v, "content", []byte(lesson.Content))
+	}
 
 	if !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = a.models.Lessons.Insert(lesson)
+	lesson.Content, err = doc.Encode()
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
 	}
 
+	reason, err := a.authorizeResource(r.Context(), a.contextGetUser(r), lesson.ResourceID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("resource_id", "resource does not exist")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if reason != "" {
+		a.policyDeniedResponse(w, r, reason)
+		return
+	}
+
+	err = a.models.Lessons.Insert(r.Context(), lesson, a.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateLessonNumber):
+			v.AddError("lesson_number", "is already used by another lesson of this resource")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	response := envelope{
 		"lesson": lesson,
 	}
@@ -77,7 +110,7 @@ func (a *app) getLessonHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lesson, err := a.models.Lessons.Get(id)
+	lesson, err := a.models.Lessons.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -91,7 +124,7 @@ func (a *app) getLessonHandler(w http.ResponseWriter, r *http.Request) {
 	response := envelope{
 		"lesson": lesson,
 	}
-	err = a.writeJSON(w, http.StatusOK, response, nil)
+	err = a.writeJSON(w, http.StatusOK, response, etagHeader(lesson.Version))
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
@@ -105,7 +138,7 @@ func (a *app) getResourceLessonsHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	lessons, err := a.models.Lessons.GetByResource(id)
+	lessons, err := a.models.Lessons.GetByResource(r.Context(), id)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -120,7 +153,8 @@ func (a *app) getResourceLessonsHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
-// updateLessonHandler updates an existing lesson
+// updateLessonHandler updates an existing lesson.  A change to its content
+// is kept as a new version, with the optional change_description.
 func (a *app) updateLessonHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := a.readIDParam(r)
 	if err != nil {
@@ -128,7 +162,7 @@ func (a *app) updateLessonHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lesson, err := a.models.Lessons.Get(id)
+	lesson, err := a.models.Lessons.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -139,14 +173,33 @@ func (a *app) updateLessonHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	user := a.contextGetUser(r)
+
+	reason, err := a.authorizeResource(r.Context(), user, lesson.ResourceID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if reason != "" {
+		a.policyDeniedResponse(w, r, reason)
+		return
+	}
+
+	if !ifMatch(r, lesson.Version) {
+		a.preconditionFailedResponse(w, r)
+		return
+	}
+
 	var input struct {
-		Title           *string  `json:"title"`
-		DurationMinutes *int     `json:"duration_minutes"`
-		Objectives      []string `json:"objectives"`
-		Materials       []string `json:"materials"`
-		Content         *string  `json:"content"`
-		Assessment      *string  `json:"assessment"`
-		Differentiation *string  `json:"differentiation"`
+		LessonNumber      *int     `json:"lesson_number"`
+		Title             *string  `json:"title"`
+		DurationMinutes   *int     `json:"duration_minutes"`
+		Objectives        []string `json:"objectives"`
+		Materials         []string `json:"materials"`
+		Content           *string  `json:"content"`
+		Assessment        *string  `json:"assessment"`
+		Differentiation   *string  `json:"differentiation"`
+		ChangeDescription string   `json:"change_description"`
 	}
 
 	err = a.readJSON(w, r, &input)
@@ -155,6 +208,9 @@ func (a *app) updateLessonHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if input.LessonNumber != nil {
+		lesson.LessonNumber = *input.LessonNumber
+	}
 	if input.Title != nil {
 		lesson.Title = *input.Title
 	}
@@ -178,24 +234,48 @@ func (a *app) updateLessonHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	v := validator.New()
+	v.Check(lesson.LessonNumber > 0, "lesson_number", "must be a positive integer")
 	v.Check(lesson.Title != "", "title", "must be provided")
 	v.Check(lesson.Content != "", "content", "must be provided")
+	v.Check(len(input.ChangeDescription) <= 500, "change_description", "must not be more than 500 bytes long")
+	// Content that is not being replaced is left alone, even if it predates
+	// the document format and could not be parsed by migration 039
+	var doc *data.LessonDocument
+	if input.Content != nil && lesson.Content != "" {
+		doc = validateLessonContent(v, "content", []byte(lesson.Content))
+	}
 
 	if !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = a.models.Lessons.Update(lesson)
+	if doc != nil {
+		lesson.Content, err = doc.Encode()
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = a.models.Lessons.Update(r.Context(), lesson, user.ID, input.ChangeDescription)
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateLessonNumber):
+			v.AddError("lesson_number", "is already used by another lesson of this resource")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
 	response := envelope{
 		"lesson": lesson,
 	}
-	err = a.writeJSON(w, http.StatusOK, response, nil)
+	err = a.writeJSON(w, http.StatusOK, response, etagHeader(lesson.Version))
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
@@ -209,7 +289,7 @@ func (a *app) deleteLessonHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = a.models.Lessons.Delete(id)
+	err = a.models.Lessons.Delete(r.Context(), id, a.contextGetUser(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -228,3 +308,189 @@ func (a *app) deleteLessonHandler(w http.ResponseWriter, r *http.Request) {
 		a.serverErrorResponse(w, r, err)
 	}
 }
+
+// listLessonVersionsHandler handles GET /v1/lessons/:id/versions, newest first
+func (a *app) listLessonVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	lesson, ok := a.versionedLesson(w, r)
+	if !ok {
+		return
+	}
+
+	versions, err := a.models.Lessons.GetVersions(r.Context(), lesson.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"versions": versions}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// lessonVersionDiffHandler handles GET /v1/lessons/:id/versions/:n/diff,
+// showing what changed in version n since version ?against=, by default the
+// one before it.  To see what a Fellow changed after a NeedsRevision round,
+// diff the latest version against the one the reviewer saw.
+func (a *app) lessonVersionDiffHandler(w http.ResponseWriter, r *http.Request) {
+	lesson, ok := a.versionedLesson(w, r)
+	if !ok {
+		return
+	}
+
+	number, err := a.readNamedIDParam(r, "n")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	against := a.getSingleIntegerParameter(r.URL.Query(), "against", int(number)-1, v)
+	v.Check(against > 0, "against", "must be a version number")
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	version, err := a.models.Lessons.GetVersion(r.Context(), lesson.ID, int(number))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	base, err := a.models.Lessons.GetVersion(r.Context(), lesson.ID, against)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("against", "is not a version of this lesson")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"diff": data.DiffLessonContent(base, version)}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// restoreLessonVersionHandler handles POST /v1/lessons/:id/versions/:n/restore.
+// The lesson's content is set back to version n, which is recorded as a new
+// version; the later versions are kept.  If-Match is honoured as in PATCH
+// /v1/lessons/:id.
+func (a *app) restoreLessonVersionHandler(w http.ResponseWriter, r *http.Request) {
+	lesson, ok := a.versionedLesson(w, r)
+	if !ok {
+		return
+	}
+
+	if !ifMatch(r, lesson.Version) {
+		a.preconditionFailedResponse(w, r)
+		return
+	}
+
+	number, err := a.readNamedIDParam(r, "n")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	version, err := a.models.Lessons.GetVersion(r.Context(), lesson.ID, int(number))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// An old version may predate the document format, or a stricter check,
+	// so it is held to the same rules as content sent to PATCH
+	v := validator.New()
+	doc := validateLessonContent(v, "content", []byte(version.Content))
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	lesson.Content, err = doc.Encode()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.models.Lessons.Update(r.Context(), lesson, a.contextGetUser(r).ID,
+		fmt.Sprintf("Restored version %d", version.VersionNumber))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"lesson": lesson}, etagHeader(lesson.Version))
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// versionedLesson loads the lesson named in the URL and checks that the user
+// may edit its resource, which is also what seeing its versions takes.  ok is
+// false if a response has already been written.
+func (a *app) versionedLesson(w http.ResponseWriter, r *http.Request) (*data.Lesson, bool) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	lesson, err := a.models.Lessons.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	reason, err := a.authorizeResource(r.Context(), a.contextGetUser(r), lesson.ResourceID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+	if reason != "" {
+		a.policyDeniedResponse(w, r, reason)
+		return nil, false
+	}
+
+	return lesson, true
+}
+
+// validateLessonContent decodes lesson content sent in the request field key
+// and checks it, adding any problems to v.  The decoded document is returned
+// so it can be stored normalized, or nil if it could not be decoded.
+func validateLessonContent(v *validator.Validator, key string, content []byte) *data.LessonDocument {
+	doc, err := data.DecodeLessonDocument(content)
+	if err != nil {
+		v.AddError(key, err.Error())
+		return nil
+	}
+
+	data.ValidateLessonDocument(v, key, doc)
+	return doc
+}
@@ -0,0 +1,141 @@
+// Filename: cmd/api/lessonPlanHandlers.go
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+	"github.com/amilcar-vasquez/501SteamHub/internal/lessonplan"
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+)
+
+// exportLessonHandler handles GET /v1/lessons/:id/export, rendering one
+// lesson for printing as ?format=html (the default), md or pdf
+func (a *app) exportLessonHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	format := a.readLessonPlanFormat(r.URL.Query(), v)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	lesson, err := a.models.Lessons.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	resource, err := a.models.Resources.Get(r.Context(), lesson.ResourceID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	a.writeLessonPlan(w, r, resource, []*data.Lesson{lesson}, format, fmt.Sprintf("lesson-%d", lesson.ID))
+}
+
+// exportResourceLessonsHandler handles GET /v1/resources/:id/export,
+// rendering every lesson of a resource, in order, as one document
+func (a *app) exportResourceLessonsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	format := a.readLessonPlanFormat(r.URL.Query(), v)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	resource, err := a.models.Resources.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	lessons, err := a.models.Lessons.GetByResource(r.Context(), resource.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("resource-%d", resource.ID)
+	if resource.Slug != nil && *resource.Slug != "" {
+		filename = *resource.Slug
+	}
+
+	a.writeLessonPlan(w, r, resource, lessons, format, filename)
+}
+
+// readLessonPlanFormat reads ?format=, which defaults to html
+func (a *app) readLessonPlanFormat(qs url.Values, v *validator.Validator) string {
+	format := strings.ToLower(a.getSingleQueryParameter(qs, "format", lessonplan.FormatHTML))
+	v.Check(validator.PermittedValue(format, lessonplan.Formats...), "format",
+		"must be one of "+strings.Join(lessonplan.Formats, ", "))
+	return format
+}
+
+// writeLessonPlan renders the lessons and sends them as a file named
+// filename.  Blocks marked for fellows are only included for users who can
+// edit the resource.  HTML is sent to be shown, ready to print from the
+// browser; Markdown and PDF are sent as downloads.
+func (a *app) writeLessonPlan(w http.ResponseWriter, r *http.Request, resource *data.Resource,
+	lessons []*data.Lesson, format, filename string) {
+	fellowBlocks := false
+	if user := a.contextGetUser(r); !user.IsAnonymous() {
+		reason, err := a.authorizeResource(r.Context(), user, resource.ID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		fellowBlocks = reason == ""
+	}
+
+	body, err := lessonplan.Render(format, lessonplan.NewPlan(resource, lessons, fellowBlocks))
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	disposition := "attachment"
+	if format == lessonplan.FormatHTML {
+		disposition = "inline"
+	}
+
+	w.Header().Set("Content-Type", lessonplan.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, filename+"."+format))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(body); err != nil {
+		a.logError(r, err)
+	}
+}
@@ -0,0 +1,82 @@
+// Filename: cmd/api/loginThrottleHandlers.go
+
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+)
+
+// adminGetUserHandler handles GET /v1/admin/users/:id.  Alongside the user it
+// returns their failed sign-in record so admins can see whether the account is
+// backing off or locked.
+func (a *app) adminGetUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	user, err := a.models.Users.Get(r.Context(), int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	lockout, err := a.models.LoginThrottles.Get(r.Context(), data.ThrottleScopeAccount, user.Email, data.AccountThrottlePolicy)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"user": user, "lockout": lockout}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// adminClearUserLockoutHandler handles DELETE /v1/admin/users/:id/lockout,
+// forgetting the account's failed sign-ins so the user can sign in again
+func (a *app) adminClearUserLockoutHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	user, err := a.models.Users.Get(r.Context(), int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	lockout, err := a.models.LoginThrottles.Get(r.Context(), data.ThrottleScopeAccount, user.Email, data.AccountThrottlePolicy)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.models.LoginThrottles.Clear(r.Context(), data.ThrottleScopeAccount, user.Email,
+		a.auditEvent(r, "user.lockout_clear", "user", user.ID, lockout, nil))
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"message": "lockout successfully cleared"}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -47,6 +47,11 @@ type configuration struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	// tokens controls how long access and refresh tokens stay valid
+	tokens struct {
+		accessTTL  time.Duration
+		refreshTTL time.Duration
+	}
 	limiter struct {
 		rps     float64
 		burst   int
@@ -59,6 +64,12 @@ type configuration struct {
 		password string
 		sender   string
 	}
+	// trash controls how long soft-deleted resources, lessons and comments
+	// are kept before the purge job deletes them for good
+	trash struct {
+		retention     time.Duration
+		purgeInterval time.Duration
+	}
 	// youtube holds OAuth2 credentials used to upload approved Video resources.
 	youtube struct {
 		clientID     string
@@ -76,6 +87,7 @@ type app struct {
 	models          *data.Models
 	mailer          mailer.Mailer
 	wg              sync.WaitGroup
+	stopJobs        context.CancelFunc // stops the periodic jobs on shutdown
 	youtubeUploader *services.YouTubeUploader
 }
 
@@ -103,11 +115,19 @@ func loadConfig() configuration {
 			return nil
 		})
 
+	// Token lifetimes
+	flag.DurationVar(&cfg.tokens.accessTTL, "access-token-ttl", 15*time.Minute, "Access token lifetime")
+	flag.DurationVar(&cfg.tokens.refreshTTL, "refresh-token-ttl", 30*24*time.Hour, "Refresh token lifetime")
+
 	// Rate limiter settings
 	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2, "Rate Limiter Maximum requests per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 5, "Rate Limiter Maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable Rate Limiter")
 
+	// Trash settings; a retention of 0 keeps deleted items forever
+	flag.DurationVar(&cfg.trash.retention, "trash-retention", 30*24*time.Hour, "How long deleted items stay in the trash (0 to keep forever)")
+	flag.DurationVar(&cfg.trash.purgeInterval, "trash-purge-interval", time.Hour, "How often to purge expired items from the trash")
+
 	// SMTP settings
 	flag.StringVar(&cfg.smtp.host, "smtp-host", smtpHost, "SMTP host")
 	flag.IntVar(&cfg.smtp.port, "smtp-port", smtpPort, "SMTP port")
@@ -234,6 +254,13 @@ func main() {
 	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
 	expvar.Publish("database", expvar.Func(func() any { return db.Stats() }))
 
+	// periodic jobs run until the server shuts down
+	jobs, stopJobs := context.WithCancel(context.Background())
+	app.stopJobs = stopJobs
+
+	// permanently delete trashed items once they pass the retention period
+	app.startTrashPurge(jobs)
+
 	err = app.Serve()
 	if err != nil {
 		logger.Error(err.Error())
@@ -0,0 +1,415 @@
+// Filename: cmd/api/mfaHandlers.go
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+	"github.com/amilcar-vasquez/501SteamHub/internal/totp"
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+)
+
+// mfaIssuer is the account name shown in authenticator apps
+const mfaIssuer = "501 STEAM Hub"
+
+// mfaPendingTTL is how long a user has to enter their code after their password
+const mfaPendingTTL = 5 * time.Minute
+
+// verifyMFA checks a TOTP code or, failing that, a recovery code for a user
+// with MFA enabled.  A TOTP step and a recovery code are each accepted once.
+func (a *app) verifyMFA(ctx context.Context, mfa *data.MFA, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := totp.Validate(mfa.Secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return a.models.MFA.UseStep(ctx, mfa.UserID, step)
+	}
+
+	if recoveryCode != "" {
+		return a.models.MFA.UseRecoveryCode(ctx, mfa.UserID, recoveryCode)
+	}
+
+	return false, nil
+}
+
+// createMFAAuthTokenHandler handles POST /v1/tokens/mfa, the second step of
+// signing in.  The pending token from /v1/tokens/authentication is exchanged,
+// with a TOTP or recovery code, for an access token and refresh token.
+func (a *app) createMFAAuthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateTokenPlaintext(v, input.MFAToken)
+	v.Check(input.Code != "" || input.RecoveryCode != "", "code", "a code or recovery_code must be provided")
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := a.models.Users.GetForToken(r.Context(), data.ScopeMFAPending, input.MFAToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.invalidMFACodeResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Codes are guessed against the same throttle as passwords, so refuse
+	// while the account or IP is backing off
+	ip := clientIP(r)
+	blocked, err := a.loginBlocked(r.Context(), user.Email, ip)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if blocked != nil {
+		a.loginThrottledResponse(w, r, blocked)
+		return
+	}
+
+	// The pending token is single-use whether or not the code is right, so a
+	// wrong guess sends the user back to the password step
+	err = a.models.Tokens.DeleteAllForUser(r.Context(), data.ScopeMFAPending, user.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	mfa, err := a.models.MFA.Get(r.Context(), user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.invalidMFACodeResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	ok, err := a.verifyMFA(r.Context(), mfa, input.Code, input.RecoveryCode)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !mfa.Enabled || !ok {
+		a.recordMFAFailure(w, r, user, ip)
+		return
+	}
+
+	// Signing in is finished, so the account starts again with a clean slate
+	err = a.models.LoginThrottles.Clear(r.Context(), data.ThrottleScopeAccount, user.Email, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, refreshToken, err := a.models.Tokens.NewPair(r.Context(), user.ID, a.config.tokens.accessTTL, a.config.tokens.refreshTTL,
+		ip, r.UserAgent())
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusCreated, envelope{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"user":          user,
+	}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// recordMFAFailure counts a wrong TOTP or recovery code against the user's
+// email and the IP, like a wrong password, and sends the invalid code
+// response, or the throttled response once the account is locked
+func (a *app) recordMFAFailure(w http.ResponseWriter, r *http.Request, user *data.User, ip string) {
+	account, err := a.countLoginFailure(r.Context(), user.Email, ip, user)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if account.Locked {
+		a.loginThrottledResponse(w, r, account)
+		return
+	}
+
+	a.invalidMFACodeResponse(w, r)
+}
+
+// getMyMFAHandler handles GET /v1/me/mfa
+func (a *app) getMyMFAHandler(w http.ResponseWriter, r *http.Request) {
+	user := a.contextGetUser(r)
+
+	enabled := false
+	var enabledAt *time.Time
+	mfa, err := a.models.MFA.Get(r.Context(), user.ID)
+	switch {
+	case err == nil:
+		enabled = mfa.Enabled
+		enabledAt = mfa.EnabledAt
+	case !errors.Is(err, data.ErrRecordNotFound):
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	remaining := 0
+	if enabled {
+		remaining, err = a.models.MFA.CountRecoveryCodes(r.Context(), user.ID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"mfa": envelope{
+		"enabled":                  enabled,
+		"enabled_at":               enabledAt,
+		"required":                 user.MFARequired,
+		"recovery_codes_remaining": remaining,
+	}}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// createMFASecretHandler handles POST /v1/me/mfa/totp.  It starts enrollment
+// by returning a new secret and the otpauth:// URI to show as a QR code.
+func (a *app) createMFASecretHandler(w http.ResponseWriter, r *http.Request) {
+	user := a.contextGetUser(r)
+
+	// Re-enrolling would silently replace a working authenticator
+	if user.MFAEnabled {
+		a.errorResponseJSON(w, r, http.StatusConflict, "two-factor authentication is already enabled, disable it first")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.models.MFA.SetSecret(r.Context(), user.ID, secret)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusCreated, envelope{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(mfaIssuer, user.Email, secret),
+	}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// enableMFAHandler handles POST /v1/me/mfa/totp/verify.  The first code from
+// the authenticator app turns MFA on and the recovery codes are returned,
+// once, for the user to store.
+func (a *app) enableMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Code != "", "code", "must be provided")
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := a.contextGetUser(r)
+
+	mfa, err := a.models.MFA.Get(r.Context(), user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.errorResponseJSON(w, r, http.StatusConflict, "start enrollment at /v1/me/mfa/totp first")
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if mfa.Enabled {
+		a.errorResponseJSON(w, r, http.StatusConflict, "two-factor authentication is already enabled")
+		return
+	}
+
+	ok, err := a.verifyMFA(r.Context(), mfa, input.Code, "")
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !ok {
+		v.AddError("code", "is incorrect or has expired")
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	codes, hashes, err := data.GenerateRecoveryCodes()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.models.MFA.Enable(r.Context(), user.ID, hashes)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{
+		"message":        "two-factor authentication enabled",
+		"recovery_codes": codes,
+	}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// regenerateRecoveryCodesHandler handles POST /v1/me/mfa/recovery-codes.  A
+// current code is required and the old recovery codes stop working.
+func (a *app) regenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Code != "", "code", "must be provided")
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := a.contextGetUser(r)
+
+	mfa, ok := a.checkMyMFACode(w, r, user, input.Code)
+	if !ok {
+		return
+	}
+
+	codes, hashes, err := data.GenerateRecoveryCodes()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.models.MFA.ReplaceRecoveryCodes(r.Context(), mfa.UserID, hashes)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"recovery_codes": codes}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// disableMFAHandler handles DELETE /v1/me/mfa.  Users whose role requires
+// MFA cannot turn it off.
+func (a *app) disableMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Code != "", "code", "must be provided")
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := a.contextGetUser(r)
+
+	if user.MFARequired {
+		a.errorResponseJSON(w, r, http.StatusForbidden, "your role requires two-factor authentication")
+		return
+	}
+
+	if _, ok := a.checkMyMFACode(w, r, user, input.Code); !ok {
+		return
+	}
+
+	err = a.models.MFA.Disable(r.Context(), user.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"message": "two-factor authentication disabled"}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// checkMyMFACode loads the signed-in user's enabled MFA and checks a code
+// against it, writing the error response itself when the check fails
+func (a *app) checkMyMFACode(w http.ResponseWriter, r *http.Request, user *data.User, code string) (*data.MFA, bool) {
+	mfa, err := a.models.MFA.Get(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		a.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+	if mfa == nil || !mfa.Enabled {
+		a.errorResponseJSON(w, r, http.StatusConflict, "two-factor authentication is not enabled")
+		return nil, false
+	}
+
+	ok, err := a.verifyMFA(r.Context(), mfa, code, "")
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+	if !ok {
+		v := validator.New()
+		v.AddError("code", "is incorrect or has expired")
+		a.failedValidationResponse(w, r, v.Errors)
+		return nil, false
+	}
+
+	return mfa, true
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+)
+
+// memoryLoginThrottles keeps failed sign-ins in memory, counting them the way
+// LoginThrottleModel does
+type memoryLoginThrottles struct {
+	records map[string]*data.LoginThrottle
+}
+
+func (m *memoryLoginThrottles) key(scope, subject string) string {
+	return scope + " " + data.ThrottleSubject(scope, subject)
+}
+
+func (m *memoryLoginThrottles) Get(_ context.Context, scope, subject string, policy data.ThrottlePolicy) (*data.LoginThrottle, error) {
+	record, ok := m.records[m.key(scope, subject)]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+	throttle := *record
+	throttle.Locked = policy.LockoutAfter > 0 && throttle.FailedCount >= policy.LockoutAfter &&
+		throttle.RetryAfter() > 0
+	return &throttle, nil
+}
+
+func (m *memoryLoginThrottles) RecordFailure(_ context.Context, scope, subject string, policy data.ThrottlePolicy) (*data.LoginThrottle, bool, error) {
+	key := m.key(scope, subject)
+	record, ok := m.records[key]
+	if !ok {
+		record = &data.LoginThrottle{Scope: scope, Subject: data.ThrottleSubject(scope, subject)}
+		m.records[key] = record
+	}
+
+	record.FailedCount++
+	record.LastFailedAt = time.Now()
+	if delay := policy.Delay(record.FailedCount); delay > 0 {
+		blockedUntil := time.Now().Add(delay)
+		record.BlockedUntil = &blockedUntil
+	}
+
+	throttle := *record
+	throttle.Locked = policy.LockoutAfter > 0 && throttle.FailedCount >= policy.LockoutAfter
+	return &throttle, policy.LockoutAfter > 0 && throttle.FailedCount == policy.LockoutAfter, nil
+}
+
+func (m *memoryLoginThrottles) Clear(_ context.Context, scope, subject string, _ *data.AuditEvent) error {
+	delete(m.records, m.key(scope, subject))
+	return nil
+}
+
+// waitOut lifts a backoff, as if the attacker had waited it out, but leaves
+// the failures counted
+func (m *memoryLoginThrottles) waitOut(scope, subject string) {
+	if record, ok := m.records[m.key(scope, subject)]; ok {
+		record.BlockedUntil = nil
+	}
+}
+
+// Wrong second factors count against the account and IP like wrong passwords,
+// so guessing codes locks the account
+func TestRecordMFAFailureLocksAccount(t *testing.T) {
+	throttles := &memoryLoginThrottles{records: map[string]*data.LoginThrottle{}}
+	a := &app{
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		models: &data.Models{LoginThrottles: throttles},
+	}
+	user := &data.User{ID: 7, Username: "ann", Email: "Ann@Example.org"}
+	ip := "203.0.113.7"
+
+	for attempt := 1; attempt <= data.AccountThrottlePolicy.LockoutAfter; attempt++ {
+		throttles.waitOut(data.ThrottleScopeAccount, user.Email)
+
+		blocked, err := a.loginBlocked(context.Background(), user.Email, ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if blocked != nil {
+			t.Fatalf("attempt %d: blocked before the account is locked", attempt)
+		}
+
+		w := httptest.NewRecorder()
+		a.recordMFAFailure(w, httptest.NewRequest(http.MethodPost, "/v1/tokens/mfa", nil), user, ip)
+
+		want := http.StatusUnauthorized
+		if attempt == data.AccountThrottlePolicy.LockoutAfter {
+			want = http.StatusTooManyRequests
+		}
+		if w.Code != want {
+			t.Fatalf("attempt %d: status %d, want %d", attempt, w.Code, want)
+		}
+	}
+	a.wg.Wait()
+
+	blocked, err := a.loginBlocked(context.Background(), "ann@example.org", ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blocked == nil || !blocked.Locked {
+		t.Fatalf("throttle after %d bad codes = %+v, want the account locked", data.AccountThrottlePolicy.LockoutAfter, blocked)
+	}
+	if retry := blocked.RetryAfter(); retry < data.AccountThrottlePolicy.LockoutDuration-time.Minute {
+		t.Errorf("locked for %v, want about %v", retry, data.AccountThrottlePolicy.LockoutDuration)
+	}
+
+	// The locked response says so, and when to come back
+	w := httptest.NewRecorder()
+	a.loginThrottledResponse(w, httptest.NewRequest(http.MethodPost, "/v1/tokens/mfa", nil), blocked)
+	var body struct {
+		Error struct {
+			Locked bool `json:"locked"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if !body.Error.Locked || w.Header().Get("Retry-After") == "" {
+		t.Errorf("locked response = %d %s, want locked with Retry-After", w.Code, w.Body)
+	}
+
+	ipThrottle, err := throttles.Get(context.Background(), data.ThrottleScopeIP, ip, data.IPThrottlePolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipThrottle.FailedCount != data.AccountThrottlePolicy.LockoutAfter {
+		t.Errorf("IP failed count = %d, want %d", ipThrottle.FailedCount, data.AccountThrottlePolicy.LockoutAfter)
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -26,6 +30,31 @@ func (a *app) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+// requestIDRX limits the client supplied request IDs we are willing to keep
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._-]{8,64}$`)
+
+// requestID gives every request an ID, reusing a well-formed X-Request-ID
+// from a proxy, and echoes it back so errors can be matched to logs and
+// audit events
+func (a *app) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRX.MatchString(id) {
+			randomBytes := make([]byte, 16)
+			if _, err := rand.Read(randomBytes); err != nil {
+				a.serverErrorResponse(w, r, err)
+				return
+			}
+			id = hex.EncodeToString(randomBytes)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		r = a.contextSetRequestID(r, id)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (a *app) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Origin")
@@ -55,7 +84,8 @@ func (a *app) enableCORS(next http.Handler) http.Handler {
 				if matched {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
 					w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-					w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+					w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match")
+					w.Header().Set("Access-Control-Expose-Headers", "ETag")
 					w.Header().Set("Access-Control-Max-Age", "86400")
 
 					if r.Method == http.MethodOptions {
@@ -122,7 +152,29 @@ func (a *app) rateLimit(next http.Handler) http.Handler {
 	})
 }
 
+// sessionTouchInterval is how often authenticate records that a token is still
+// in use.  Writing last_used_at on every request would turn every read into a
+// database write.
+const sessionTouchInterval = 5 * time.Minute
+
 func (a *app) authenticate(next http.Handler) http.Handler {
+	var mu sync.Mutex
+	var lastTouched = make(map[string]time.Time)
+
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+			mu.Lock()
+
+			for token, touched := range lastTouched {
+				if time.Since(touched) > sessionTouchInterval {
+					delete(lastTouched, token)
+				}
+			}
+			mu.Unlock()
+		}
+	}()
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authorization")
 
@@ -154,7 +206,7 @@ func (a *app) authenticate(next http.Handler) http.Handler {
 		}
 
 		// Get the user info associated with this authentication token
-		user, err := a.models.Users.GetForToken(data.ScopeAuthentication, token)
+		user, err := a.models.Users.GetForToken(r.Context(), data.ScopeAuthentication, token)
 		if err != nil {
 			switch {
 			case errors.Is(err, data.ErrRecordNotFound):
@@ -166,6 +218,23 @@ func (a *app) authenticate(next http.Handler) http.Handler {
 		}
 		// Add the retrieved user info to the context
 		r = a.contextSetUser(r, user)
+		r = a.contextSetToken(r, token)
+
+		// Record the session as used, at most once per interval
+		mu.Lock()
+		touch := time.Since(lastTouched[token]) > sessionTouchInterval
+		if touch {
+			lastTouched[token] = time.Now()
+		}
+		mu.Unlock()
+
+		if touch {
+			a.background(func() {
+				if err := a.models.Tokens.Touch(context.Background(), token); err != nil {
+					a.logger.Error(err.Error())
+				}
+			})
+		}
 
 		// Call the next handler in the chain.
 		next.ServeHTTP(w, r)
@@ -199,64 +268,71 @@ func (a *app) requireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
 	return a.requireAuthenticatedUser(fn)
 }
 
-// This middleware checks if the user has the required role
-// We send the role name that is expected as an argument
-func (a *app) requireRole(roleName string, next http.HandlerFunc) http.HandlerFunc {
+// requirePermission checks that the user's role has been granted the given
+// permission code (e.g. "resource:delete") in the role_permissions matrix.
+// The matrix is read on every request so admin edits take effect immediately.
+// Users whose role requires MFA are refused until they have enrolled.
+func (a *app) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		user := a.contextGetUser(r)
 
-		// Get the user's role from the database
-		role, err := a.models.Roles.Get(user.RoleID)
+		// Get whether the user's role grants the permission
+		granted, mfaMissing, err := a.userPermission(r.Context(), user, code)
 		if err != nil {
 			a.serverErrorResponse(w, r, err)
 			return
 		}
 
-		// Check if the user has the required role
-		if role.RoleName != roleName {
+		// Privileged roles may be required to use two-factor authentication
+		if mfaMissing {
+			a.mfaEnrollmentRequiredResponse(w, r)
+			return
+		}
+
+		if !granted {
 			a.notPermittedResponse(w, r)
 			return
 		}
 
-		// User has the correct role, continue
+		// User's role has the permission, continue
 		next.ServeHTTP(w, r)
 	}
 
 	return a.requireActivatedUser(fn)
 }
 
-// requireFellow allows Fellows, admin, and DSC to access the wrapped handler.
-func (a *app) requireFellow(next http.HandlerFunc) http.HandlerFunc {
-	return a.requireAnyRole([]string{"Fellow", "admin", "DSC"}, next)
-}
-
-// This middleware checks if the user has one of multiple allowed roles
-func (a *app) requireAnyRole(roleNames []string, next http.HandlerFunc) http.HandlerFunc {
+// requireReviewCommentAccess applies the review comment policy before the
+// wrapped handler runs, so only the reviewer who wrote the comment or a member
+// of its resource can change it.
+func (a *app) requireReviewCommentAccess(next http.HandlerFunc) http.HandlerFunc {
 	fn := func(w http.ResponseWriter, r *http.Request) {
-		user := a.contextGetUser(r)
-
-		// Get the user's role from the database
-		role, err := a.models.Roles.Get(user.RoleID)
+		id, err := a.readIDParam(r)
 		if err != nil {
-			a.serverErrorResponse(w, r, err)
+			a.notFoundResponse(w, r)
 			return
 		}
 
-		// Check if the user has any of the allowed roles
-		hasRole := false
-		for _, allowedRole := range roleNames {
-			if role.RoleName == allowedRole {
-				hasRole = true
-				break
+		resourceID, reviewerID, err := a.models.ResourceMembers.GetReviewCommentAuthor(r.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				a.notFoundResponse(w, r)
+			default:
+				a.serverErrorResponse(w, r, err)
 			}
+			return
 		}
 
-		if !hasRole {
-			a.notPermittedResponse(w, r)
+		reason, err := a.authorizeReviewComment(r.Context(), a.contextGetUser(r), resourceID, reviewerID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		if reason != "" {
+			a.policyDeniedResponse(w, r, reason)
 			return
 		}
 
-		// User has one of the allowed roles, continue
 		next.ServeHTTP(w, r)
 	}
 
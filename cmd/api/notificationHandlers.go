@@ -40,7 +40,7 @@ func (a *app) createNotificationHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = a.models.Notifications.Insert(notification)
+	err = a.models.Notifications.Insert(r.Context(), notification)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -63,7 +63,7 @@ func (a *app) getNotificationHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	notification, err := a.models.Notifications.Get(int(id))
+	notification, err := a.models.Notifications.Get(r.Context(), int(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -83,7 +83,7 @@ func (a *app) getNotificationHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// getNotificationsByUserIDHandler retrieves all notifications for a specific user
+// getNotificationsByUserIDHandler retrieves a page of notifications for a specific user
 func (a *app) getNotificationsByUserIDHandler(w http.ResponseWriter, r *http.Request) {
 	userID := r.URL.Query().Get("user_id")
 	if userID == "" {
@@ -91,14 +91,26 @@ func (a *app) getNotificationsByUserIDHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	qs := r.URL.Query()
 	v := validator.New()
-	id := a.getSingleIntegerParameter(r.URL.Query(), "user_id", 0, v)
-	if !v.IsEmpty() {
+	id := a.getSingleIntegerParameter(qs, "user_id", 0, v)
+
+	var input struct {
+		data.Filters
+	}
+
+	input.Filters.Page = a.getSingleIntegerParameter(qs, "page", 1, v)
+	input.Filters.PageSize = a.getSingleIntegerParameter(qs, "page_size", 20, v)
+	input.Filters.Sort = a.getSingleQueryParameter(qs, "sort", "-sent_at")
+	input.Filters.Cursor = a.getSingleQueryParameter(qs, "cursor", "")
+	input.Filters.Conditions = a.readConditions(qs, data.NotificationFields)
+
+	if data.ValidateQuery(v, input.Filters, data.NotificationFields); !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	notifications, err := a.models.Notifications.GetByUser(id)
+	notifications, metadata, err := a.models.Notifications.GetByUser(r.Context(), id, input.Filters)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -106,6 +118,7 @@ func (a *app) getNotificationsByUserIDHandler(w http.ResponseWriter, r *http.Req
 
 	response := envelope{
 		"notifications": notifications,
+		"metadata":      metadata,
 	}
 	err = a.writeJSON(w, http.StatusOK, response, nil)
 	if err != nil {
@@ -158,7 +171,7 @@ func (a *app) getAllNotificationsHandler(w http.ResponseWriter, r *http.Request)
 	notifications := []*data.Notification{}
 	metadata := data.Metadata{}
 
-	// notifications, metadata, err := a.models.Notifications.GetAll(input.ReadStatus, input.Filters)
+	// notifications, metadata, err := a.models.Notifications.GetAll(r.Context(), input.ReadStatus, input.Filters)
 	// if err != nil {
 	// 	a.serverErrorResponse(w, r, err)
 	// 	return
@@ -182,7 +195,7 @@ func (a *app) updateNotificationHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	notification, err := a.models.Notifications.Get(int(id))
+	notification, err := a.models.Notifications.Get(r.Context(), int(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -219,7 +232,7 @@ func (a *app) updateNotificationHandler(w http.ResponseWriter, r *http.Request)
 
 	// TODO: Implement Update method in NotificationModel
 	// For now, just return the notification as-is
-	// err = a.models.Notifications.Update(notification)
+	// err = a.models.Notifications.Update(r.Context(), notification)
 	// if err != nil {
 	// 	switch {
 	// 	case errors.Is(err, data.ErrEditConflict):
@@ -247,7 +260,7 @@ func (a *app) deleteNotificationHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = a.models.Notifications.Delete(int(id))
+	err = a.models.Notifications.Delete(r.Context(), int(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
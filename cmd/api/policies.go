@@ -0,0 +1,189 @@
+// Filename: cmd/api/policies.go
+
+package main
+
+import (
+	"context"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+)
+
+// Policy reason codes.  A denied policy check is reported as a 403 whose
+// body carries one of these so clients can tell why the change was refused.
+const (
+	reasonNotResourceMember    = "not_resource_member"
+	reasonNotResourceOwner     = "not_resource_owner"
+	reasonNotCommentAuthor     = "not_comment_author"
+	reasonNotReviewParticipant = "not_review_participant"
+	reasonOutsideExpertise     = "outside_subject_expertise"
+	reasonMFAEnrollment        = "mfa_enrollment_required"
+)
+
+// policyMessages holds the human readable message for each reason code
+var policyMessages = map[string]string{
+	reasonNotResourceMember:    "only the contributor, a co-author or an assigned reviewer may modify this resource",
+	reasonNotResourceOwner:     "only the contributor of this resource may perform this action",
+	reasonNotCommentAuthor:     "only the author of this comment may modify it",
+	reasonNotReviewParticipant: "only the reviewer or a member of the resource may modify this review comment",
+	reasonOutsideExpertise:     "this resource is outside of your subject expertise",
+	reasonMFAEnrollment:        "your role requires two-factor authentication, enable it at /v1/me/mfa before continuing",
+}
+
+// mfaSatisfied reports whether user meets their role's two-factor
+// requirement.  A role that requires it grants nothing privileged, by
+// permission or by role name, until the user has enrolled.
+func mfaSatisfied(user *data.User) bool {
+	return !user.MFARequired || user.MFAEnabled
+}
+
+// userPermission reports whether user's role grants the permission code.
+// mfaMissing is set when the role grants it but the user has not enrolled in
+// two-factor authentication as the role requires, in which case granted is
+// false.
+func (a *app) userPermission(ctx context.Context, user *data.User, code string) (granted, mfaMissing bool, err error) {
+	permissions, err := a.models.Permissions.GetAllForRole(ctx, user.RoleID)
+	if err != nil {
+		return false, false, err
+	}
+	if !permissions.Include(code) {
+		return false, false, nil
+	}
+	if !mfaSatisfied(user) {
+		return false, true, nil
+	}
+	return true, false, nil
+}
+
+// authorizeStatusTransition decides whether user may move a resource from
+// one status to another.  A *data.StatusTransitionError is returned when their
+// role may not; when it may but the user has yet to enroll in two-factor
+// authentication as the role requires, the reason says so.
+func authorizeStatusTransition(user *data.User, from, to string) (string, error) {
+	if err := data.CheckStatusTransition(from, to, user.RoleName); err != nil {
+		return "", err
+	}
+	if from != to && !mfaSatisfied(user) {
+		return reasonMFAEnrollment, nil
+	}
+	return "", nil
+}
+
+// authorizeResource decides whether user may modify a resource or its lessons.
+// The contributor, co-authors, assigned reviewers and holders of
+// resource:edit_any are allowed.  An empty reason means the change may go ahead.
+func (a *app) authorizeResource(ctx context.Context, user *data.User, resourceID int64) (string, error) {
+	editAny, mfaMissing, err := a.userPermission(ctx, user, "resource:edit_any")
+	if err != nil {
+		return "", err
+	}
+	if editAny {
+		return "", nil
+	}
+
+	membership, err := a.models.ResourceMembers.GetMembership(ctx, resourceID, user.ID)
+	if err != nil {
+		return "", err
+	}
+	if membership.IsContributor || membership.IsCoAuthor || membership.IsAssignedReviewer {
+		return "", nil
+	}
+	if mfaMissing {
+		return reasonMFAEnrollment, nil
+	}
+
+	return reasonNotResourceMember, nil
+}
+
+// authorizeResourceOwner decides whether user may manage who works on a
+// resource.  Only the contributor and holders of resource:edit_any are allowed.
+func (a *app) authorizeResourceOwner(ctx context.Context, user *data.User, resourceID int64) (string, error) {
+	editAny, mfaMissing, err := a.userPermission(ctx, user, "resource:edit_any")
+	if err != nil {
+		return "", err
+	}
+	if editAny {
+		return "", nil
+	}
+
+	membership, err := a.models.ResourceMembers.GetMembership(ctx, resourceID, user.ID)
+	if err != nil {
+		return "", err
+	}
+	if membership.IsContributor {
+		return "", nil
+	}
+	if mfaMissing {
+		return reasonMFAEnrollment, nil
+	}
+
+	return reasonNotResourceOwner, nil
+}
+
+// authorizeComment decides whether user may edit or delete a resource comment.
+// Only the author and holders of comment:moderate are allowed.
+func (a *app) authorizeComment(ctx context.Context, user *data.User, comment *data.ResourceComment) (string, error) {
+	if comment.UserID == user.ID {
+		return "", nil
+	}
+
+	moderate, mfaMissing, err := a.userPermission(ctx, user, "comment:moderate")
+	if err != nil {
+		return "", err
+	}
+	if moderate {
+		return "", nil
+	}
+	if mfaMissing {
+		return reasonMFAEnrollment, nil
+	}
+
+	return reasonNotCommentAuthor, nil
+}
+
+// authorizeReviewComment decides whether user may change a review comment.
+// The reviewer who wrote it and anyone allowed to modify the resource
+// (so contributors can resolve feedback) are allowed.
+func (a *app) authorizeReviewComment(ctx context.Context, user *data.User, resourceID, reviewerID int64) (string, error) {
+	if reviewerID == user.ID {
+		return "", nil
+	}
+
+	reason, err := a.authorizeResource(ctx, user, resourceID)
+	if err != nil {
+		return "", err
+	}
+	if reason == reasonMFAEnrollment {
+		return reason, nil
+	}
+	if reason != "" {
+		return reasonNotReviewParticipant, nil
+	}
+
+	return "", nil
+}
+
+// authorizeSubjectReview decides whether user may review a resource.  Holders
+// of review:any_subject may review anything; everyone else needs at least one
+// of the resource's subjects in their expertise.
+func (a *app) authorizeSubjectReview(ctx context.Context, user *data.User, resourceID int64) (string, error) {
+	anySubject, mfaMissing, err := a.userPermission(ctx, user, "review:any_subject")
+	if err != nil {
+		return "", err
+	}
+	if anySubject {
+		return "", nil
+	}
+
+	ok, err := a.models.Users.HasExpertiseFor(ctx, user.ID, resourceID)
+	if err != nil {
+		return "", err
+	}
+	if !ok && mfaMissing {
+		return reasonMFAEnrollment, nil
+	}
+	if !ok {
+		return reasonOutsideExpertise, nil
+	}
+
+	return "", nil
+}
@@ -38,7 +38,7 @@ func (a *app) createResourceAccessHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = a.models.ResourceAccess.Insert(access)
+	err = a.models.ResourceAccess.Insert(r.Context(), access)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -61,7 +61,7 @@ func (a *app) getResourceAccessHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	access, err := a.models.ResourceAccess.Get(id)
+	access, err := a.models.ResourceAccess.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -105,14 +105,15 @@ func (a *app) getAccessByResourceIDHandler(w http.ResponseWriter, r *http.Reques
 	input.Filters.Page = a.getSingleIntegerParameter(qs, "page", 1, v)
 	input.Filters.PageSize = a.getSingleIntegerParameter(qs, "page_size", 20, v)
 	input.Filters.Sort = a.getSingleQueryParameter(qs, "sort", "-accessed_at")
-	input.Filters.SortSafelist = []string{"access_id", "accessed_at", "-access_id", "-accessed_at"}
+	input.Filters.Cursor = a.getSingleQueryParameter(qs, "cursor", "")
+	input.Filters.Conditions = a.readConditions(qs, data.ResourceAccessFields)
 
-	if data.ValidateFilters(v, input.Filters); !v.IsEmpty() {
+	if data.ValidateQuery(v, input.Filters, data.ResourceAccessFields); !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	accessRecords, metadata, err := a.models.ResourceAccess.GetByResourceID(int64(id), input.Filters)
+	accessRecords, metadata, err := a.models.ResourceAccess.GetByResourceID(r.Context(), int64(id), input.Filters)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -152,14 +153,15 @@ func (a *app) getAccessByUserIDHandler(w http.ResponseWriter, r *http.Request) {
 	input.Filters.Page = a.getSingleIntegerParameter(qs, "page", 1, v)
 	input.Filters.PageSize = a.getSingleIntegerParameter(qs, "page_size", 20, v)
 	input.Filters.Sort = a.getSingleQueryParameter(qs, "sort", "-accessed_at")
-	input.Filters.SortSafelist = []string{"access_id", "accessed_at", "-access_id", "-accessed_at"}
+	input.Filters.Cursor = a.getSingleQueryParameter(qs, "cursor", "")
+	input.Filters.Conditions = a.readConditions(qs, data.ResourceAccessFields)
 
-	if data.ValidateFilters(v, input.Filters); !v.IsEmpty() {
+	if data.ValidateQuery(v, input.Filters, data.ResourceAccessFields); !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	accessRecords, metadata, err := a.models.ResourceAccess.GetByUserID(int64(id), input.Filters)
+	accessRecords, metadata, err := a.models.ResourceAccess.GetByUserID(r.Context(), int64(id), input.Filters)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -198,29 +200,25 @@ func (a *app) getAllResourceAccessHandler(w http.ResponseWriter, r *http.Request
 	input.Filters.Page = a.getSingleIntegerParameter(qs, "page", 1, v)
 	input.Filters.PageSize = a.getSingleIntegerParameter(qs, "page_size", 20, v)
 	input.Filters.Sort = a.getSingleQueryParameter(qs, "sort", "-accessed_at")
-	input.Filters.SortSafelist = []string{"access_id", "accessed_at", "-access_id", "-accessed_at"}
+	input.Filters.Cursor = a.getSingleQueryParameter(qs, "cursor", "")
+	input.Filters.Conditions = a.readConditions(qs, data.ResourceAccessFields)
 
-	if data.ValidateFilters(v, input.Filters); !v.IsEmpty() {
+	if data.ValidateQuery(v, input.Filters, data.ResourceAccessFields); !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	// TODO: Implement GetAll method in ResourceAccessModel
-	// For now, return empty list
-	accessRecords := []*data.ResourceAccess{}
-	metadata := data.Metadata{}
-
-	// accessRecords, metadata, err := a.models.ResourceAccess.GetAll(input.Filters)
-	// if err != nil {
-	// 	a.serverErrorResponse(w, r, err)
-	// 	return
-	// }
+	accessRecords, metadata, err := a.models.ResourceAccess.GetAll(r.Context(), input.Filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
 
 	response := envelope{
 		"access":   accessRecords,
 		"metadata": metadata,
 	}
-	err := a.writeJSON(w, http.StatusOK, response, nil)
+	err = a.writeJSON(w, http.StatusOK, response, nil)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
@@ -234,7 +232,7 @@ func (a *app) deleteResourceAccessHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = a.models.ResourceAccess.Delete(id)
+	err = a.models.ResourceAccess.Delete(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
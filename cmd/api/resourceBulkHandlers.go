@@ -0,0 +1,308 @@
+// Filename: cmd/api/resourceBulkHandlers.go
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+)
+
+// Operations accepted by POST /v1/resource-bulk
+const (
+	bulkSetStatus        = "set_status"
+	bulkArchive          = "archive"
+	bulkAddSubject       = "add_subject"
+	bulkRemoveSubject    = "remove_subject"
+	bulkAddGradeLevel    = "add_grade_level"
+	bulkRemoveGradeLevel = "remove_grade_level"
+	bulkReassign         = "reassign_contributor"
+	bulkDelete           = "delete"
+)
+
+var bulkOperations = []string{
+	bulkSetStatus, bulkArchive, bulkAddSubject, bulkRemoveSubject,
+	bulkAddGradeLevel, bulkRemoveGradeLevel, bulkReassign, bulkDelete,
+}
+
+// maxBulkResources caps how many resources one bulk request may touch
+const maxBulkResources = 100
+
+// bulkResourceInput is the body of a bulk request.  Only the field that
+// goes with the operation is read.
+type bulkResourceInput struct {
+	IDs           []int64 `json:"ids"`
+	Operation     string  `json:"operation"`
+	Status        string  `json:"status"`
+	Subject       string  `json:"subject"`
+	GradeLevel    string  `json:"grade_level"`
+	ContributorID int64   `json:"contributor_id"`
+	DryRun        bool    `json:"dry_run"`
+}
+
+// bulkResult reports what happened to one resource.  Status and Error are
+// what the single-resource endpoint would have responded with.
+type bulkResult struct {
+	ID      int64 `json:"id"`
+	OK      bool  `json:"ok"`
+	Status  int   `json:"status"`
+	Error   any   `json:"error,omitempty"`
+	Version int32 `json:"version,omitempty"`
+}
+
+// bulkResourcesHandler handles POST /v1/resource-bulk.  The operation is
+// applied to each resource in turn, with the same checks as the
+// single-resource endpoints, and every resource gets a result whether it
+// succeeded or not.  A dry run makes all the checks and changes nothing.
+func (a *app) bulkResourcesHandler(w http.ResponseWriter, r *http.Request) {
+	var input bulkResourceInput
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	validateBulkResourceInput(v, input)
+
+	if input.Operation == bulkReassign && input.ContributorID > 0 {
+		contributor, err := a.models.Users.Get(r.Context(), int(input.ContributorID))
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("contributor_id", "must be an existing user")
+		case err != nil:
+			a.serverErrorResponse(w, r, err)
+			return
+		default:
+			v.Check(contributor.IsActive, "contributor_id", "must be an active user")
+		}
+	}
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := a.contextGetUser(r)
+
+	// Deleting needs the same permission as DELETE /v1/resources/:id
+	if input.Operation == bulkDelete {
+		canDelete, mfaMissing, err := a.userPermission(r.Context(), user, "resource:delete")
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		if mfaMissing {
+			a.mfaEnrollmentRequiredResponse(w, r)
+			return
+		}
+		if !canDelete {
+			a.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	results := make([]bulkResult, 0, len(input.IDs))
+	succeeded := 0
+	for _, id := range input.IDs {
+		result := a.bulkResourceItem(r, user, input, id)
+		if result.OK {
+			succeeded++
+		}
+		results = append(results, result)
+	}
+
+	response := envelope{
+		"operation": input.Operation,
+		"dry_run":   input.DryRun,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"results":   results,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func validateBulkResourceInput(v *validator.Validator, input bulkResourceInput) {
+	v.Check(len(input.IDs) > 0, "ids", "must contain at least one id")
+	v.Check(len(input.IDs) <= maxBulkResources, "ids", "must not contain more than 100 ids")
+	for _, id := range input.IDs {
+		v.Check(id > 0, "ids", "must contain only positive ids")
+	}
+	v.Check(len(uniqueIDs(input.IDs)) == len(input.IDs), "ids", "must not contain duplicate ids")
+
+	v.Check(validator.PermittedValue(input.Operation, bulkOperations...), "operation",
+		"must be one of "+strings.Join(bulkOperations, ", "))
+
+	switch input.Operation {
+	case bulkSetStatus:
+		data.ValidateResourceStatus(v, input.Status)
+	case bulkAddSubject, bulkRemoveSubject:
+		v.Check(input.Subject != "", "subject", "must be provided")
+	case bulkAddGradeLevel, bulkRemoveGradeLevel:
+		v.Check(input.GradeLevel != "", "grade_level", "must be provided")
+	case bulkReassign:
+		v.Check(input.ContributorID > 0, "contributor_id", "must be provided")
+	}
+}
+
+// bulkResourceItem applies the operation to one resource
+func (a *app) bulkResourceItem(r *http.Request, user *data.User, input bulkResourceInput, id int64) bulkResult {
+	ctx := r.Context()
+
+	resource, err := a.models.Resources.Get(ctx, id)
+	if err != nil {
+		return a.bulkFailure(r, id, err)
+	}
+
+	if input.Operation == bulkDelete {
+		if !input.DryRun {
+			err = a.models.Resources.Delete(ctx, id, user.ID, a.auditEvent(r, "resource.delete", "resource", id, resource, nil))
+			if err != nil {
+				return a.bulkFailure(r, id, err)
+			}
+		}
+		return bulkResult{ID: id, OK: true, Status: http.StatusOK}
+	}
+
+	// Reassigning is for the contributor to do, like managing co-authors;
+	// everything else is open to anyone who may edit the resource
+	policy := a.authorizeResource
+	if input.Operation == bulkReassign {
+		policy = a.authorizeResourceOwner
+	}
+	if result, ok := a.bulkAuthorize(r, id, policy, user); !ok {
+		return result
+	}
+
+	oldStatus := resource.Status
+	bundle := data.ResourceBundle{Resource: resource, DryRun: input.DryRun,
+		RevisionBy: user.ID, RevisionNote: "Bulk " + strings.ReplaceAll(input.Operation, "_", " ")}
+	var before, after envelope
+
+	switch input.Operation {
+	case bulkSetStatus, bulkArchive:
+		status := input.Status
+		if input.Operation == bulkArchive {
+			status = data.StatusArchived
+		}
+		reason, err := authorizeStatusTransition(user, oldStatus, status)
+		if err != nil {
+			return a.bulkFailure(r, id, err)
+		}
+		if reason != "" {
+			return bulkResult{ID: id, Status: http.StatusForbidden, Error: policyDeniedMessage(reason)}
+		}
+		if data.IsReviewTransition(oldStatus, status) {
+			if result, ok := a.bulkAuthorize(r, id, a.authorizeSubjectReview, user); !ok {
+				return result
+			}
+		}
+		resource.Status = status
+		before, after = envelope{"status": oldStatus}, envelope{"status": status}
+
+	case bulkAddSubject, bulkRemoveSubject:
+		subjects, err := a.models.Resources.GetSubjects(ctx, id)
+		if err != nil {
+			return a.bulkFailure(r, id, err)
+		}
+		bundle.Subjects = editList(subjects, input.Subject, input.Operation == bulkAddSubject)
+		if len(bundle.Subjects) == 0 {
+			return bulkResult{ID: id, Status: http.StatusUnprocessableEntity,
+				Error: map[string]string{"subjects": "at least one subject must be provided"}}
+		}
+		before, after = envelope{"subjects": subjects}, envelope{"subjects": bundle.Subjects}
+
+	case bulkAddGradeLevel, bulkRemoveGradeLevel:
+		gradeLevels, err := a.models.Resources.GetGradeLevels(ctx, id)
+		if err != nil {
+			return a.bulkFailure(r, id, err)
+		}
+		bundle.GradeLevels = editList(gradeLevels, input.GradeLevel, input.Operation == bulkAddGradeLevel)
+		if len(bundle.GradeLevels) == 0 {
+			return bulkResult{ID: id, Status: http.StatusUnprocessableEntity,
+				Error: map[string]string{"grade_levels": "at least one grade level must be provided"}}
+		}
+		before, after = envelope{"grade_levels": gradeLevels}, envelope{"grade_levels": bundle.GradeLevels}
+
+	case bulkReassign:
+		before, after = envelope{"contributor_id": resource.ContributorID}, envelope{"contributor_id": input.ContributorID}
+		resource.ContributorID = input.ContributorID
+	}
+
+	bundle.Audit = a.auditEvent(r, "resource."+input.Operation, "resource", id, before, after)
+
+	if err = a.models.Resources.UpdateBundle(ctx, bundle); err != nil {
+		return a.bulkFailure(r, id, err)
+	}
+
+	if !input.DryRun && oldStatus != resource.Status {
+		// Reload so the history and YouTube upload see the stored resource
+		if resource, err = a.models.Resources.Get(ctx, id); err != nil {
+			return a.bulkFailure(r, id, err)
+		}
+		a.resourceStatusChanged(resource, oldStatus, user.ID)
+	}
+
+	return bulkResult{ID: id, OK: true, Status: http.StatusOK, Version: resource.Version}
+}
+
+// bulkAuthorize runs a resource policy and turns a refusal into a failed
+// result.  ok is false if the policy refused the change or could not be
+// checked.
+func (a *app) bulkAuthorize(r *http.Request, id int64,
+	policy func(context.Context, *data.User, int64) (string, error), user *data.User) (bulkResult, bool) {
+	reason, err := policy(r.Context(), user, id)
+	if err != nil {
+		return a.bulkFailure(r, id, err), false
+	}
+	if reason != "" {
+		return bulkResult{ID: id, Status: http.StatusForbidden, Error: policyDeniedMessage(reason)}, false
+	}
+	return bulkResult{}, true
+}
+
+// bulkFailure reports an error as the single-resource endpoints would
+func (a *app) bulkFailure(r *http.Request, id int64, err error) bulkResult {
+	var transitionErr *data.StatusTransitionError
+	var unknownErr *data.UnknownValuesError
+
+	switch {
+	case errors.Is(err, data.ErrRecordNotFound):
+		return bulkResult{ID: id, Status: http.StatusNotFound, Error: "the requested resource could not be found"}
+	case errors.As(err, &transitionErr):
+		return bulkResult{ID: id, Status: http.StatusConflict, Error: statusTransitionMessage(transitionErr)}
+	case errors.As(err, &unknownErr):
+		return bulkResult{ID: id, Status: http.StatusUnprocessableEntity, Error: unknownValuesMessage(unknownErr)}
+	case errors.Is(err, data.ErrEditConflict):
+		return bulkResult{ID: id, Status: http.StatusConflict,
+			Error: "unable to update the record due to an edit conflict, please try again"}
+	default:
+		a.logError(r, err)
+		return bulkResult{ID: id, Status: http.StatusInternalServerError,
+			Error: "the server encountered a problem and could not process your request"}
+	}
+}
+
+// editList adds value to a list or removes it
+func editList(values []string, value string, add bool) []string {
+	if add {
+		return append(slices.Clone(values), value)
+	}
+	return slices.DeleteFunc(slices.Clone(values), func(v string) bool { return v == value })
+}
+
+// uniqueIDs returns the ids sorted with duplicates removed
+func uniqueIDs(ids []int64) []int64 {
+	unique := slices.Clone(ids)
+	slices.Sort(unique)
+	return slices.Compact(unique)
+}
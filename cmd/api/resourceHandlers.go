@@ -6,53 +6,87 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/amilcar-vasquez/501SteamHub/internal/data"
 	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
 )
 
-// createResourceHandler creates a new resource
-func (a *app) createResourceHandler(w http.ResponseWriter, r *http.Request) {
-	var input struct {
-		Title         string                 `json:"title"`
-		Category      string                 `json:"category"`
-		Slug          *string                `json:"slug"`
-		Summary       *string                `json:"summary"`
-		Subjects      []string               `json:"subjects"`
-		GradeLevels   []string               `json:"grade_levels"`
-		DriveLink     *string                `json:"drive_link"`
-		Status        string                 `json:"status"`
-		PublishedURL  *string                `json:"published_url"`
-		ContributorID int64                  `json:"contributor_id"`
-		LessonContent map[string]interface{} `json:"lesson_content,omitempty"`
-		VideoMetadata *struct {
-			YouTubeTitle       string   `json:"youtube_title"`
-			YouTubeDescription string   `json:"youtube_description"`
-			Tags               []string `json:"tags"`
-			PrivacyStatus      string   `json:"privacy_status"`
-			MadeForKids        *bool    `json:"made_for_kids"`
-			CategoryID         int      `json:"category_id"`
-		} `json:"video_metadata,omitempty"`
-	}
+// resourceInput is a new resource as a client submits it, to the create
+// endpoint or as one row of an import
+type resourceInput struct {
+	Title         string              `json:"title"`
+	Category      string              `json:"category"`
+	Slug          *string             `json:"slug"`
+	Summary       *string             `json:"summary"`
+	Subjects      []string            `json:"subjects"`
+	GradeLevels   []string            `json:"grade_levels"`
+	DriveLink     *string             `json:"drive_link"`
+	Status        string              `json:"status"`
+	PublishedURL  *string             `json:"published_url"`
+	ContributorID int64               `json:"contributor_id"`
+	LessonContent json.RawMessage     `json:"lesson_content,omitempty"`
+	VideoMetadata *videoMetadataInput `json:"video_metadata,omitempty"`
+}
 
-	// Log the incoming request
-	a.logger.Info("Receiving resource creation request", "method", r.Method, "path", r.URL.Path)
+type videoMetadataInput struct {
+	YouTubeTitle       string   `json:"youtube_title"`
+	YouTubeDescription string   `json:"youtube_description"`
+	Tags               []string `json:"tags"`
+	PrivacyStatus      string   `json:"privacy_status"`
+	MadeForKids        *bool    `json:"made_for_kids"`
+	CategoryID         int      `json:"category_id"`
+}
 
-	err := a.readJSON(w, r, &input)
-	if err != nil {
-		a.logger.Error("Failed to read JSON", "error", err.Error())
-		a.badRequestResponse(w, r, err)
-		return
-	}
+// hasLessonContent reports whether the input carries lesson content; an
+// explicit null is the same as none
+func (input *resourceInput) hasLessonContent() bool {
+	return len(input.LessonContent) > 0 && string(input.LessonContent) != "null"
+}
 
-	// Log the parsed input
-	a.logger.Info("Parsed resource data",
-		"title", input.Title,
-		"category", input.Category,
-		"subjects", input.Subjects,
-		"grade_levels", input.GradeLevels,
-		"contributor_id", input.ContributorID)
+// validateResourceInput checks a new resource against the rules every
+// resource must meet when it is created
+func validateResourceInput(v *validator.Validator, input *resourceInput) {
+	v.Check(input.Title != "", "title", "must be provided")
+	v.Check(len(input.Title) <= 255, "title", "must not be more than 255 characters")
+	v.Check(input.Category != "", "category", "must be provided")
+	v.Check(len(input.Subjects) > 0, "subjects", "at least one subject must be provided")
+	v.Check(len(input.GradeLevels) > 0, "grade_levels", "at least one grade level must be provided")
+	data.ValidateResourceStatus(v, input.Status)
+	// New resources enter the lifecycle at its start; later statuses are only
+	// reachable through the transition rules.
+	v.Check(input.Status == data.StatusDraft || input.Status == data.StatusSubmitted, "status", "must be Draft or Submitted for a new resource")
+	v.Check(input.ContributorID > 0, "contributor_id", "must be provided")
+	// Drive link is required for every resource type except LessonPlan; other
+	// types (Video, Slideshow, Assessment, Other) must link to a Google Drive file.
+	if input.Category != "LessonPlan" {
+		v.Check(input.DriveLink != nil && *input.DriveLink != "", "drive_link", "must be provided for non-lesson-plan resources")
+	}
+	if input.hasLessonContent() {
+		validateLessonContent(v, "lesson_content", input.LessonContent)
+	}
+	// Video resources must include fully-populated video_metadata.
+	if input.Category == "Video" {
+		if input.VideoMetadata == nil {
+			v.AddError("video_metadata", "must be provided for Video resources")
+		} else {
+			v.Check(input.VideoMetadata.YouTubeTitle != "", "video_metadata.youtube_title", "must be provided")
+			v.Check(len(input.VideoMetadata.YouTubeTitle) <= 100, "video_metadata.youtube_title", "must not exceed 100 characters")
+			v.Check(
+				input.VideoMetadata.PrivacyStatus == "public" ||
+					input.VideoMetadata.PrivacyStatus == "private" ||
+					input.VideoMetadata.PrivacyStatus == "unlisted",
+				"video_metadata.privacy_status", "must be public, private, or unlisted")
+			v.Check(input.VideoMetadata.MadeForKids != nil, "video_metadata.made_for_kids", "must be explicitly provided (required by YouTube/COPPA)")
+		}
+	}
+}
 
+// newResourceBundle turns a validated input into the bundle Create writes,
+// generating a slug from the title if none was given
+func newResourceBundle(input *resourceInput) (data.ResourceBundle, error) {
 	resource := &data.Resource{
 		Title:         input.Title,
 		Category:      input.Category,
@@ -64,66 +98,44 @@ func (a *app) createResourceHandler(w http.ResponseWriter, r *http.Request) {
 		ContributorID: input.ContributorID,
 	}
 
-	// Generate slug if not provided
 	if resource.Slug == nil || *resource.Slug == "" {
 		slug, err := generateSlug(resource.Title)
 		if err != nil {
-			a.logger.Error("Failed to generate slug", "error", err.Error())
-			a.serverErrorResponse(w, r, err)
-			return
+			return data.ResourceBundle{}, err
 		}
 		resource.Slug = &slug
-		a.logger.Info("Generated slug for resource", "slug", slug, "title", resource.Title)
-	} else {
-		a.logger.Info("Using provided slug", "slug", *resource.Slug)
 	}
 
-	v := validator.New()
-	// Add comprehensive validation
-	v.Check(resource.Title != "", "title", "must be provided")
-	v.Check(len(resource.Title) <= 255, "title", "must not be more than 255 characters")
-	v.Check(resource.Category != "", "category", "must be provided")
-	v.Check(len(input.Subjects) > 0, "subjects", "at least one subject must be provided")
-	v.Check(len(input.GradeLevels) > 0, "grade_levels", "at least one grade level must be provided")
-	v.Check(resource.Status != "", "status", "must be provided")
-	v.Check(resource.ContributorID > 0, "contributor_id", "must be provided")
-	// Drive link is required for every resource type except LessonPlan; other
-	// types (Video, Slideshow, Assessment, Other) must link to a Google Drive file.
-	if resource.Category != "LessonPlan" {
-		v.Check(resource.DriveLink != nil && *resource.DriveLink != "", "drive_link", "must be provided for non-lesson-plan resources")
+	bundle := data.ResourceBundle{
+		Resource:    resource,
+		Subjects:    input.Subjects,
+		GradeLevels: input.GradeLevels,
 	}
-	// Video resources must include fully-populated video_metadata.
-	if resource.Category == "Video" {
-		if input.VideoMetadata == nil {
-			v.AddError("video_metadata", "must be provided for Video resources")
-		} else {
-			v.Check(input.VideoMetadata.YouTubeTitle != "", "video_metadata.youtube_title", "must be provided")
-			v.Check(len(input.VideoMetadata.YouTubeTitle) <= 100, "video_metadata.youtube_title", "must not exceed 100 characters")
-			v.Check(
-				input.VideoMetadata.PrivacyStatus == "public" ||
-					input.VideoMetadata.PrivacyStatus == "private" ||
-					input.VideoMetadata.PrivacyStatus == "unlisted",
-				"video_metadata.privacy_status", "must be public, private, or unlisted")
-			v.Check(input.VideoMetadata.MadeForKids != nil, "video_metadata.made_for_kids", "must be explicitly provided (required by YouTube/COPPA)")
+
+	// Lesson plans carry their content as a single first lesson
+	if input.hasLessonContent() {
+		doc, err := data.DecodeLessonDocument(input.LessonContent)
+		if err != nil {
+			return data.ResourceBundle{}, err
+		}
+		content, err := doc.Encode()
+		if err != nil {
+			return data.ResourceBundle{}, err
 		}
-	}
 
-	if !v.IsEmpty() {
-		a.logger.Error("Validation failed", "errors", v.Errors)
-		a.failedValidationResponse(w, r, v.Errors)
-		return
+		bundle.Lessons = []*data.Lesson{{
+			LessonNumber: 1,
+			Title:        resource.Title,
+			Content:      content,
+		}}
 	}
 
-	a.logger.Info("Attempting to insert resource into database")
-
-	// For Video resources, insert the resource and video_metadata atomically.
-	// For all other types, a plain insert is sufficient.
 	if resource.Category == "Video" && input.VideoMetadata != nil {
 		categoryID := input.VideoMetadata.CategoryID
 		if categoryID == 0 {
 			categoryID = 27 // Education
 		}
-		vm := &data.VideoMetadata{
+		bundle.VideoMetadata = &data.VideoMetadata{
 			YouTubeTitle:       input.VideoMetadata.YouTubeTitle,
 			YouTubeDescription: input.VideoMetadata.YouTubeDescription,
 			Tags:               input.VideoMetadata.Tags,
@@ -131,76 +143,79 @@ func (a *app) createResourceHandler(w http.ResponseWriter, r *http.Request) {
 			MadeForKids:        *input.VideoMetadata.MadeForKids,
 			CategoryID:         categoryID,
 		}
-		err = a.models.Resources.InsertWithVideoMetadata(resource, vm)
-		if err != nil {
-			a.logger.Error("Failed to insert Video resource with metadata", "error", err.Error())
-			a.serverErrorResponse(w, r, err)
-			return
-		}
-	} else {
-		err = a.models.Resources.Insert(resource)
-		if err != nil {
-			a.logger.Error("Failed to insert resource", "error", err.Error())
-			a.serverErrorResponse(w, r, err)
-			return
-		}
 	}
 
-	a.logger.Info("Resource inserted successfully",
-		"resource_id", resource.ID,
-		"slug", resource.Slug,
-		"hasSlug", resource.Slug != nil && *resource.Slug != "")
+	return bundle, nil
+}
 
-	// Set subjects and grade levels
-	if len(input.Subjects) > 0 {
-		err = a.models.Resources.SetSubjects(resource.ID, input.Subjects)
-		if err != nil {
-			a.logger.Error("Failed to set subjects", "error", err.Error())
-			a.serverErrorResponse(w, r, err)
-			return
-		}
-	}
+// createResourceHandler creates a new resource
+func (a *app) createResourceHandler(w http.ResponseWriter, r *http.Request) {
+	var input resourceInput
 
-	if len(input.GradeLevels) > 0 {
-		err = a.models.Resources.SetGradeLevels(resource.ID, input.GradeLevels)
-		if err != nil {
-			a.logger.Error("Failed to set grade levels", "error", err.Error())
-			a.serverErrorResponse(w, r, err)
-			return
-		}
+	// Log the incoming request
+	a.logger.Info("Receiving resource creation request", "method", r.Method, "path", r.URL.Path)
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.logger.Error("Failed to read JSON", "error", err.Error())
+		a.badRequestResponse(w, r, err)
+		return
 	}
 
-	// Create lesson if lesson_content is provided (for lesson plans)
-	if len(input.LessonContent) > 0 {
-		a.logger.Info("Creating lesson for resource", "resource_id", resource.ID)
+	// Log the parsed input
+	a.logger.Info("Parsed resource data",
+		"title", input.Title,
+		"category", input.Category,
+		"subjects", input.Subjects,
+		"grade_levels", input.GradeLevels,
+		"contributor_id", input.ContributorID)
 
-		// Convert lesson_content map to JSON string
-		lessonContentJSON, err := json.Marshal(input.LessonContent)
-		if err != nil {
-			a.logger.Error("Failed to marshal lesson content", "error", err.Error())
-			a.serverErrorResponse(w, r, err)
-			return
-		}
+	v := validator.New()
+	validateResourceInput(v, &input)
 
-		// Create lesson
-		lesson := &data.Lesson{
-			ResourceID:   resource.ID,
-			LessonNumber: 1,
-			Title:        resource.Title,
-			Content:      string(lessonContentJSON),
-		}
+	if !v.IsEmpty() {
+		a.logger.Error("Validation failed", "errors", v.Errors)
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
 
-		err = a.models.Lessons.Insert(lesson)
-		if err != nil {
-			a.logger.Error("Failed to insert lesson", "error", err.Error())
+	bundle, err := newResourceBundle(&input)
+	if err != nil {
+		a.logger.Error("Failed to prepare resource", "error", err.Error())
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	bundle.RevisionBy = a.contextGetUser(r).ID
+	resource := bundle.Resource
+	a.logger.Info("Using slug for resource", "slug", *resource.Slug, "title", resource.Title)
+
+	// The resource and everything attached to it are written in one
+	// transaction, so a failure leaves no half-created resource behind.
+	a.logger.Info("Attempting to insert resource into database")
+
+	var unknownErr *data.UnknownValuesError
+	err = a.models.Resources.Create(r.Context(), bundle)
+	if err != nil {
+		switch {
+		case errors.As(err, &unknownErr):
+			a.unknownValuesResponse(w, r, unknownErr)
+		case errors.Is(err, data.ErrDuplicateSlug):
+			v.AddError("slug", "a resource with this slug already exists")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.logger.Error("Failed to create resource", "error", err.Error())
 			a.serverErrorResponse(w, r, err)
-			return
 		}
-		a.logger.Info("Lesson created successfully", "lesson_id", lesson.ID)
+		return
 	}
 
+	a.logger.Info("Resource inserted successfully",
+		"resource_id", resource.ID,
+		"slug", resource.Slug,
+		"hasSlug", resource.Slug != nil && *resource.Slug != "")
+
 	// Reload resource to get subjects and grade levels
-	resource, err = a.models.Resources.Get(resource.ID)
+	resource, err = a.models.Resources.Get(r.Context(), resource.ID)
 	if err != nil {
 		a.logger.Error("Failed to reload resource", "error", err.Error())
 		a.serverErrorResponse(w, r, err)
@@ -218,7 +233,7 @@ func (a *app) createResourceHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Include video_metadata in response when it was just created.
 	if resource.Category == "Video" {
-		vm, vmErr := a.models.VideoMetadata.GetByResource(resource.ID)
+		vm, vmErr := a.models.VideoMetadata.GetByResource(r.Context(), resource.ID)
 		if vmErr == nil {
 			response["video_metadata"] = vm
 		}
@@ -236,7 +251,7 @@ func (a *app) createResourceHandler(w http.ResponseWriter, r *http.Request) {
 //
 // GET /v1/resources/metrics  (requires reviewer role)
 func (a *app) resourceMetricsHandler(w http.ResponseWriter, r *http.Request) {
-	counts, err := a.models.Resources.GetStatusCounts()
+	counts, err := a.models.Resources.GetStatusCounts(r.Context())
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -256,7 +271,7 @@ func (a *app) getResourceHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resource, err := a.models.Resources.Get(id)
+	resource, err := a.models.Resources.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -270,40 +285,64 @@ func (a *app) getResourceHandler(w http.ResponseWriter, r *http.Request) {
 	response := envelope{
 		"resource": resource,
 	}
-	err = a.writeJSON(w, http.StatusOK, response, nil)
+	err = a.writeJSON(w, http.StatusOK, response, etagHeader(resource.Version))
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
 }
 
-// getAllResourcesHandler retrieves all resources with pagination and filtering
+// readResourceFilter reads the search and filter parameters shared by the
+// resource listing and its facets.  status, subject, grade_level, category and
+// contributor_id may each be repeated to match any of several values.
+func (a *app) readResourceFilter(qs url.Values, v *validator.Validator) data.ResourceFilter {
+	filter := data.ResourceFilter{
+		Search:      strings.TrimSpace(a.getSingleQueryParameter(qs, "q", "")),
+		Statuses:    a.getRepeatedQueryParameters(qs, "status"),
+		Subjects:    a.getRepeatedQueryParameters(qs, "subject"),
+		GradeLevels: a.getRepeatedQueryParameters(qs, "grade_level"),
+		Categories:  a.getRepeatedQueryParameters(qs, "category"),
+	}
+	v.Check(len(filter.Search) <= 200, "q", "must not be more than 200 bytes long")
+
+	for _, value := range a.getRepeatedQueryParameters(qs, "contributor_id") {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id < 1 {
+			v.AddError("contributor_id", "must be a positive integer")
+			break
+		}
+		filter.ContributorIDs = append(filter.ContributorIDs, id)
+	}
+
+	return filter
+}
+
+// getAllResourcesHandler retrieves all resources with pagination and filtering.
+// The q parameter runs a ranked full-text search.  Pages are numbered, or
+// follow the cursor from a previous page's next_cursor/prev_cursor.
 func (a *app) getAllResourcesHandler(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 
 	var input struct {
-		Status     string
-		Subject    string
-		GradeLevel string
+		data.ResourceFilter
 		data.Filters
 	}
 
 	v := validator.New()
 
-	input.Status = a.getSingleQueryParameter(qs, "status", "")
-	input.Subject = a.getSingleQueryParameter(qs, "subject", "")
-	input.GradeLevel = a.getSingleQueryParameter(qs, "grade_level", "")
+	input.ResourceFilter = a.readResourceFilter(qs, v)
 
 	input.Filters.Page = a.getSingleIntegerParameter(qs, "page", 1, v)
 	input.Filters.PageSize = a.getSingleIntegerParameter(qs, "page_size", 20, v)
-	input.Filters.Sort = a.getSingleQueryParameter(qs, "sort", "resource_id")
-	input.Filters.SortSafelist = []string{"resource_id", "title", "created_at", "-resource_id", "-title", "-created_at"}
+	input.Filters.Sort = a.getSingleQueryParameter(qs, "sort", "-created_at")
+	input.Filters.Cursor = a.getSingleQueryParameter(qs, "cursor", "")
+	input.Filters.Conditions = a.readConditions(qs, data.ResourceFields)
 
-	if data.ValidateFilters(v, input.Filters); !v.IsEmpty() {
+	if data.ValidateQuery(v, input.Filters, data.ResourceFields); !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	resources, metadata, err := a.models.Resources.GetAll(input.Status, input.Subject, input.GradeLevel, input.Filters)
+	resources, metadata, err := a.models.Resources.GetAll(r.Context(), input.ResourceFilter, input.Filters)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -319,6 +358,41 @@ func (a *app) getAllResourcesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// resourceFacetsHandler handles GET /v1/resource-facets.  It takes the same
+// filter parameters as GET /v1/resources and returns, for each facet, how many
+// resources each option would give.
+func (a *app) resourceFacetsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	var input struct {
+		data.ResourceFilter
+		data.Filters
+	}
+
+	input.ResourceFilter = a.readResourceFilter(qs, v)
+	input.Filters.Conditions = a.readConditions(qs, data.ResourceFields)
+
+	// Only the conditions matter here, but the page has to be valid too
+	input.Filters.Page, input.Filters.PageSize = 1, 1
+
+	if data.ValidateQuery(v, input.Filters, data.ResourceFields); !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	facets, err := a.models.Resources.GetFacets(r.Context(), input.ResourceFilter, input.Filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"facets": facets}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
 // updateResourceHandler updates an existing resource
 func (a *app) updateResourceHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := a.readIDParam(r)
@@ -327,7 +401,7 @@ func (a *app) updateResourceHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resource, err := a.models.Resources.Get(id)
+	resource, err := a.models.Resources.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -338,6 +412,24 @@ func (a *app) updateResourceHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	user := a.contextGetUser(r)
+
+	reason, err := a.authorizeResource(r.Context(), user, resource.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if reason != "" {
+		a.policyDeniedResponse(w, r, reason)
+		return
+	}
+
+	// A client that sends If-Match is editing the version it last fetched
+	if !ifMatch(r, resource.Version) {
+		a.preconditionFailedResponse(w, r)
+		return
+	}
+
 	// Capture old status before applying any input changes (used for transition rules and history)
 	oldStatus := resource.Status
 
@@ -386,82 +478,108 @@ func (a *app) updateResourceHandler(w http.ResponseWriter, r *http.Request) {
 	// setting a new status), auto-advance it back to UnderReview so reviewers know
 	// the content has been updated.
 	// All other status changes are explicit (set via input.Status).
-	if input.Status == nil && oldStatus == "NeedsRevision" {
-		resource.Status = "UnderReview"
+	if input.Status == nil && oldStatus == data.StatusNeedsRevision {
+		reason, err := authorizeStatusTransition(user, oldStatus, data.StatusUnderReview)
+		if reason == "" && err == nil {
+			resource.Status = data.StatusUnderReview
+		}
 	}
 
 	v := validator.New()
 	// TODO: Add resource validation
 	v.Check(resource.Title != "", "title", "must be provided")
-	v.Check(resource.Status != "", "status", "must be provided")
+	data.ValidateResourceStatus(v, resource.Status)
 
 	if !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = a.models.Resources.Update(resource)
-	if err != nil {
-		switch {
-		case errors.Is(err, data.ErrEditConflict):
-			a.editConflictResponse(w, r)
-		default:
-			a.serverErrorResponse(w, r, err)
-		}
+	// The caller's role must be allowed to make this status change.
+	var transitionErr *data.StatusTransitionError
+	reason, err = authorizeStatusTransition(user, oldStatus, resource.Status)
+	if errors.As(err, &transitionErr) {
+		a.invalidStatusTransitionResponse(w, r, transitionErr)
 		return
 	}
-
-	// Record status transition in history when status actually changed
-	if oldStatus != resource.Status {
-		user := a.contextGetUser(r)
-		a.logResourceStatusChange(resource.ID, oldStatus, resource.Status, user.ID)
+	if reason != "" {
+		a.policyDeniedResponse(w, r, reason)
+		return
 	}
 
-	// Update subjects if provided
-	if input.Subjects != nil {
-		err = a.models.Resources.SetSubjects(resource.ID, input.Subjects)
+	// Review decisions also need subject expertise for the resource.
+	if data.IsReviewTransition(oldStatus, resource.Status) {
+		reason, err := a.authorizeSubjectReview(r.Context(), user, resource.ID)
 		if err != nil {
 			a.serverErrorResponse(w, r, err)
 			return
 		}
+		if reason != "" {
+			a.policyDeniedResponse(w, r, reason)
+			return
+		}
 	}
 
-	// Update grade levels if provided
-	if input.GradeLevels != nil {
-		err = a.models.Resources.SetGradeLevels(resource.ID, input.GradeLevels)
-		if err != nil {
+	var unknownErr *data.UnknownValuesError
+	err = a.models.Resources.UpdateBundle(r.Context(), data.ResourceBundle{
+		Resource:    resource,
+		Subjects:    input.Subjects,
+		GradeLevels: input.GradeLevels,
+		RevisionBy:  user.ID,
+	})
+	if err != nil {
+		switch {
+		case errors.As(err, &transitionErr):
+			a.invalidStatusTransitionResponse(w, r, transitionErr)
+		case errors.As(err, &unknownErr):
+			a.unknownValuesResponse(w, r, unknownErr)
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
 			a.serverErrorResponse(w, r, err)
-			return
 		}
+		return
 	}
 
 	// Reload resource to get updated subjects and grade levels
-	resource, err = a.models.Resources.Get(resource.ID)
+	resource, err = a.models.Resources.Get(r.Context(), resource.ID)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
 	}
 
-	// Trigger YouTube upload when a Video resource transitions to Approved.
-	// This runs in a background goroutine so the HTTP response is not blocked.
-	if oldStatus != resource.Status && resource.Status == "Approved" && resource.Category == "Video" {
-		if a.youtubeUploader != nil {
-			a.youtubeUploader.UploadResourceToYouTube(resource)
-		} else {
-			a.logger.Warn("video approved but YouTube uploader is not configured — skipping upload",
-				"resource_id", resource.ID)
-		}
-	}
+	a.resourceStatusChanged(resource, oldStatus, user.ID)
 
 	response := envelope{
 		"resource": resource,
 	}
-	err = a.writeJSON(w, http.StatusOK, response, nil)
+	err = a.writeJSON(w, http.StatusOK, response, etagHeader(resource.Version))
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
 }
 
+// resourceStatusChanged records a status change in the resource's history and
+// triggers the YouTube upload when a Video resource transitions to Approved.
+// The upload runs in a background goroutine so the HTTP response is not
+// blocked.  Nothing happens if the status did not change.
+func (a *app) resourceStatusChanged(resource *data.Resource, oldStatus string, changedBy int64) {
+	if oldStatus == resource.Status {
+		return
+	}
+
+	a.logResourceStatusChange(resource.ID, oldStatus, resource.Status, changedBy)
+
+	if resource.Status == data.StatusApproved && resource.Category == "Video" {
+		if a.youtubeUploader != nil {
+			a.youtubeUploader.UploadResourceToYouTube(resource)
+		} else {
+			a.logger.Warn("video approved but YouTube uploader is not configured — skipping upload",
+				"resource_id", resource.ID)
+		}
+	}
+}
+
 // deleteResourceHandler deletes a resource
 func (a *app) deleteResourceHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := a.readIDParam(r)
@@ -470,7 +588,18 @@ func (a *app) deleteResourceHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = a.models.Resources.Delete(id)
+	resource, err := a.models.Resources.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.models.Resources.Delete(r.Context(), id, a.contextGetUser(r).ID, a.auditEvent(r, "resource.delete", "resource", id, resource, nil))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -498,7 +627,7 @@ func (a *app) getResourceBySlugHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resource, err := a.models.Resources.GetBySlug(slug)
+	resource, err := a.models.Resources.GetBySlug(r.Context(), slug)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -512,7 +641,7 @@ func (a *app) getResourceBySlugHandler(w http.ResponseWriter, r *http.Request) {
 	// Record a view for authenticated (non-anonymous) users
 	user := a.contextGetUser(r)
 	if !user.IsAnonymous() {
-		_ = a.models.ResourceAccess.Insert(&data.ResourceAccess{
+		_ = a.models.ResourceAccess.Insert(r.Context(), &data.ResourceAccess{
 			ResourceID: resource.ID,
 			UserID:     user.ID,
 		})
@@ -522,7 +651,7 @@ func (a *app) getResourceBySlugHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch lessons for this resource
-	lessons, err := a.models.Lessons.GetByResource(resource.ID)
+	lessons, err := a.models.Lessons.GetByResource(r.Context(), resource.ID)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -535,13 +664,13 @@ func (a *app) getResourceBySlugHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Include video_metadata for Video resources.
 	if resource.Category == "Video" {
-		vm, vmErr := a.models.VideoMetadata.GetByResource(resource.ID)
+		vm, vmErr := a.models.VideoMetadata.GetByResource(r.Context(), resource.ID)
 		if vmErr == nil {
 			response["video_metadata"] = vm
 		}
 	}
 
-	err = a.writeJSON(w, http.StatusOK, response, nil)
+	err = a.writeJSON(w, http.StatusOK, response, etagHeader(resource.Version))
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
@@ -0,0 +1,394 @@
+// Filename: cmd/api/resourceImportHandlers.go
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+)
+
+const (
+	// maxImportBytes and maxImportRows bound the size of one import
+	maxImportBytes = 10 << 20
+	maxImportRows  = 1000
+	// importBatchSize is how many rows are written per transaction
+	importBatchSize = 25
+)
+
+// importColumns are the CSV columns an import understands.  Subjects, grade
+// levels and YouTube tags hold several values separated by semicolons.
+var importColumns = []string{
+	"title", "category", "slug", "summary", "subjects", "grade_levels",
+	"drive_link", "status", "published_url", "contributor_id",
+	"youtube_title", "youtube_description", "youtube_tags", "privacy_status",
+	"made_for_kids", "youtube_category_id",
+}
+
+// importRow is one row of an import file.  Line is where the row starts in
+// the file, so it matches what a spreadsheet shows.  Errors holds problems
+// found while parsing the row.
+type importRow struct {
+	Line   int
+	Input  resourceInput
+	Errors map[string]string
+}
+
+// importRowResult reports what happened to one row
+type importRowResult struct {
+	Row        int               `json:"row"`
+	OK         bool              `json:"ok"`
+	Title      string            `json:"title,omitempty"`
+	Slug       string            `json:"slug,omitempty"`
+	ResourceID int64             `json:"resource_id,omitempty"`
+	Errors     map[string]string `json:"errors,omitempty"`
+}
+
+// importResourcesHandler handles POST /v1/admin/resources/import.  The body
+// is a CSV file with a header row or NDJSON with one resource per line,
+// chosen by ?format= or the Content-Type.  Each row is checked with the same
+// rules as createResourceHandler and the valid ones are written in batches.
+// Rows without a contributor are credited to the importing user and rows
+// without a status start as Draft.  ?dry_run=true checks every row and
+// writes nothing.
+func (a *app) importResourcesHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	dryRun := a.getSingleBoolParameter(qs, "dry_run", false, v)
+	format := importFormat(r)
+	v.Check(format == "csv" || format == "ndjson", "format", "must be csv or ndjson, set with ?format= or the Content-Type")
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	var rows []importRow
+	var err error
+	if format == "csv" {
+		rows, err = readCSVImport(r.Body)
+	} else {
+		rows, err = readNDJSONImport(r.Body)
+	}
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			err = fmt.Errorf("the file must not be larger than %d bytes", maxBytesError.Limit)
+		}
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := a.contextGetUser(r)
+
+	results := make([]importRowResult, len(rows))
+	var bundles []data.ResourceBundle
+	var pending []int // index into results for each bundle
+
+	for i := range rows {
+		row := &rows[i]
+		input := &row.Input
+		results[i] = importRowResult{Row: row.Line, Title: input.Title}
+
+		if input.ContributorID == 0 {
+			input.ContributorID = user.ID
+		}
+		if input.Status == "" {
+			input.Status = data.StatusDraft
+		}
+
+		// A row that could not be read is reported as it is; the rules
+		// would only add noise about the fields it appeared to be missing
+		v := validator.New()
+		for key, message := range row.Errors {
+			v.AddError(key, message)
+		}
+		if _, unreadable := row.Errors["row"]; !unreadable {
+			validateResourceInput(v, input)
+		}
+		if !v.IsEmpty() {
+			results[i].Errors = v.Errors
+			continue
+		}
+
+		bundle, err := newResourceBundle(input)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		bundle.Audit = a.auditEvent(r, "resource.import", "resource", 0, nil, bundle.Resource)
+		bundle.RevisionBy, bundle.RevisionNote = user.ID, "Imported"
+		results[i].Slug = *bundle.Resource.Slug
+
+		bundles = append(bundles, bundle)
+		pending = append(pending, i)
+	}
+
+	for start := 0; start < len(bundles); start += importBatchSize {
+		end := min(start+importBatchSize, len(bundles))
+
+		errs, err := a.models.Resources.CreateBatch(r.Context(), bundles[start:end], dryRun)
+		if err != nil {
+			// Earlier batches are already in; say which rows did not make it
+			a.logError(r, err)
+			for _, i := range pending[start:] {
+				results[i].Errors = map[string]string{"row": "not imported, the server encountered a problem"}
+			}
+			break
+		}
+
+		for j, err := range errs {
+			i := pending[start+j]
+			results[i].Errors = a.importRowError(r, err)
+			if results[i].Errors == nil {
+				results[i].OK = true
+				if !dryRun {
+					results[i].ResourceID = bundles[start+j].Resource.ID
+				}
+			}
+		}
+	}
+
+	succeeded := 0
+	for _, result := range results {
+		if result.OK {
+			succeeded++
+		}
+	}
+
+	response := envelope{
+		"format":    format,
+		"dry_run":   dryRun,
+		"total":     len(results),
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"rows":      results,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// importRowError turns the error from writing one row into its report entry
+func (a *app) importRowError(r *http.Request, err error) map[string]string {
+	var unknownErr *data.UnknownValuesError
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &unknownErr):
+		return unknownValuesMessage(unknownErr)
+	case errors.Is(err, data.ErrDuplicateSlug):
+		return map[string]string{"slug": "a resource with this slug already exists"}
+	default:
+		a.logError(r, err)
+		return map[string]string{"row": "not imported, the server encountered a problem"}
+	}
+}
+
+// importFormat picks the import format from ?format= or else the Content-Type
+func importFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.ToLower(format)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return "ndjson"
+	}
+	return ""
+}
+
+// readCSVImport reads a CSV file whose first row names the columns
+func readCSVImport(body io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("the file is empty")
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Spreadsheet exports often start with a byte order mark
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !validator.PermittedValue(name, importColumns...) {
+			return nil, fmt.Errorf("unknown column %q, columns are %s", name, strings.Join(importColumns, ", "))
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("column %q appears more than once", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("the header must include a title column")
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if isBlankRecord(record) {
+			continue
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("the file must not contain more than %d rows", maxImportRows)
+		}
+
+		row := importRow{Line: line, Errors: map[string]string{}}
+		if len(record) > len(header) {
+			row.Errors["row"] = fmt.Sprintf("has %d fields but the header has %d", len(record), len(header))
+		}
+
+		cell := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		row.Input = csvResourceInput(cell, row.Errors)
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// csvResourceInput maps the cells of one CSV row to a resource, adding an
+// error for any cell that cannot be converted
+func csvResourceInput(cell func(string) string, errs map[string]string) resourceInput {
+	input := resourceInput{
+		Title:        cell("title"),
+		Category:     cell("category"),
+		Slug:         optionalString(cell("slug")),
+		Summary:      optionalString(cell("summary")),
+		Subjects:     splitList(cell("subjects")),
+		GradeLevels:  splitList(cell("grade_levels")),
+		DriveLink:    optionalString(cell("drive_link")),
+		Status:       cell("status"),
+		PublishedURL: optionalString(cell("published_url")),
+	}
+
+	if value := cell("contributor_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			errs["contributor_id"] = "must be an integer value"
+		}
+		input.ContributorID = id
+	}
+
+	if input.Category == "Video" {
+		video := &videoMetadataInput{
+			YouTubeTitle:       cell("youtube_title"),
+			YouTubeDescription: cell("youtube_description"),
+			Tags:               splitList(cell("youtube_tags")),
+			PrivacyStatus:      cell("privacy_status"),
+		}
+		if value := cell("made_for_kids"); value != "" {
+			madeForKids, err := strconv.ParseBool(value)
+			if err != nil {
+				errs["video_metadata.made_for_kids"] = "must be true or false"
+			}
+			video.MadeForKids = &madeForKids
+		}
+		if value := cell("youtube_category_id"); value != "" {
+			categoryID, err := strconv.Atoi(value)
+			if err != nil {
+				errs["video_metadata.category_id"] = "must be an integer value"
+			}
+			video.CategoryID = categoryID
+		}
+		input.VideoMetadata = video
+	}
+
+	return input
+}
+
+// readNDJSONImport reads one JSON resource per line, in the same shape as
+// the body of POST /v1/resources
+func readNDJSONImport(body io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportBytes)
+
+	var rows []importRow
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("the file must not contain more than %d rows", maxImportRows)
+		}
+
+		row := importRow{Line: line, Errors: map[string]string{}}
+
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&row.Input); err != nil {
+			row.Errors["row"] = "contains badly-formed JSON: " + err.Error()
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, scanner.Err()
+}
+
+// splitList splits a semicolon-separated cell, dropping empty values
+func splitList(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func isBlankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,283 @@
+// Filename: cmd/api/resourceMemberHandlers.go
+
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+)
+
+// getResourceMembersHandler lists the contributor, co-authors and assigned
+// reviewers of a resource
+func (a *app) getResourceMembersHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	members, err := a.models.ResourceMembers.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	response := envelope{
+		"members": members,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// addCoAuthorHandler lets the contributor of a resource add a co-author
+func (a *app) addCoAuthorHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		UserID int64 `json:"user_id"`
+	}
+
+	err = a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.UserID > 0, "user_id", "must be provided")
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	reason, err := a.authorizeResourceOwner(r.Context(), a.contextGetUser(r), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if reason != "" {
+		a.policyDeniedResponse(w, r, reason)
+		return
+	}
+
+	_, err = a.models.Users.Get(r.Context(), int(input.UserID))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("user_id", "user does not exist")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.models.ResourceMembers.AddCoAuthor(r.Context(), id, input.UserID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	members, err := a.models.ResourceMembers.Get(r.Context(), id)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"members": members,
+	}
+	err = a.writeJSON(w, http.StatusCreated, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// removeCoAuthorHandler removes a co-author from a resource.  The contributor
+// may remove anyone; a co-author may also remove themselves.
+func (a *app) removeCoAuthorHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	userID, err := a.readNamedIDParam(r, "user_id")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	user := a.contextGetUser(r)
+	if user.ID != userID {
+		reason, err := a.authorizeResourceOwner(r.Context(), user, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				a.notFoundResponse(w, r)
+			default:
+				a.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		if reason != "" {
+			a.policyDeniedResponse(w, r, reason)
+			return
+		}
+	}
+
+	err = a.models.ResourceMembers.RemoveCoAuthor(r.Context(), id, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	response := envelope{
+		"message": "co-author successfully removed",
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// assignReviewerHandler assigns a reviewer to a resource.  The reviewer's
+// role must be able to create reviews.
+func (a *app) assignReviewerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		ReviewerID int64 `json:"reviewer_id"`
+	}
+
+	err = a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.ReviewerID > 0, "reviewer_id", "must be provided")
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = a.models.Resources.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	reviewer, err := a.models.Users.Get(r.Context(), int(input.ReviewerID))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("reviewer_id", "user does not exist")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	permissions, err := a.models.Permissions.GetAllForRole(r.Context(), reviewer.RoleID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !permissions.Include("review:create") {
+		v.AddError("reviewer_id", "user is not allowed to review resources")
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.models.ResourceMembers.AssignReviewer(r.Context(), id, reviewer.ID, a.contextGetUser(r).ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	members, err := a.models.ResourceMembers.Get(r.Context(), id)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"members": members,
+	}
+	err = a.writeJSON(w, http.StatusCreated, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// unassignReviewerHandler removes a reviewer assignment from a resource
+func (a *app) unassignReviewerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	reviewerID, err := a.readNamedIDParam(r, "user_id")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	err = a.models.ResourceMembers.UnassignReviewer(r.Context(), id, reviewerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	response := envelope{
+		"message": "reviewer successfully unassigned",
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -14,8 +14,6 @@ import (
 func (a *app) createResourceReviewHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		ResourceID     int64  `json:"resource_id"`
-		ReviewerID     int64  `json:"reviewer_id"`
-		ReviewerRoleID int64  `json:"reviewer_role_id"`
 		Decision       string `json:"decision"`
 		CommentSummary string `json:"comment_summary"`
 	}
@@ -26,10 +24,13 @@ func (a *app) createResourceReviewHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// The review is always recorded as the signed-in user's, under their role
+	user := a.contextGetUser(r)
+
 	review := &data.ResourceReview{
 		ResourceID:     input.ResourceID,
-		ReviewerID:     input.ReviewerID,
-		ReviewerRoleID: input.ReviewerRoleID,
+		ReviewerID:     user.ID,
+		ReviewerRoleID: int64(user.RoleID),
 		Decision:       input.Decision,
 		CommentSummary: input.CommentSummary,
 	}
@@ -37,56 +38,87 @@ func (a *app) createResourceReviewHandler(w http.ResponseWriter, r *http.Request
 	v := validator.New()
 	// TODO: Add resource review validation
 	v.Check(review.ResourceID > 0, "resource_id", "must be provided")
-	v.Check(review.ReviewerID > 0, "reviewer_id", "must be provided")
 	v.Check(review.Decision != "", "decision", "must be provided")
+	v.Check(validator.PermittedValue(review.Decision, "Approved", "Rejected"), "decision", "must be Approved or Rejected")
 
 	if !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = a.models.ResourceReviews.Insert(review)
+	resource, err := a.models.Resources.Get(r.Context(), review.ResourceID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("resource_id", "resource does not exist")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	reason, err := a.authorizeSubjectReview(r.Context(), user, resource.ID)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
 	}
+	if reason != "" {
+		a.policyDeniedResponse(w, r, reason)
+		return
+	}
 
-	// Apply resource status transition based on the reviewer's decision.
-	// "Rejected" moves the resource to NeedsRevision so the contributor can revise.
-	// "Approved" moves the resource to the Approved stage.
-	// We do this as a best-effort update; a failure is logged but does not abort the response.
-	if review.ResourceID > 0 {
-		resource, getErr := a.models.Resources.Get(review.ResourceID)
-		if getErr == nil {
-			oldStatus := resource.Status
-			switch review.Decision {
-			case "Rejected":
-				resource.Status = "NeedsRevision"
-			case "Approved":
-				resource.Status = "Approved"
-			}
-			if oldStatus != resource.Status {
-				if updateErr := a.models.Resources.Update(resource); updateErr == nil {
-					user := a.contextGetUser(r)
-					a.logResourceStatusChange(resource.ID, oldStatus, resource.Status, user.ID)
-					// Trigger YouTube upload for approved Video resources.
-					// Runs in a background goroutine; the HTTP response is not blocked.
-					if review.Decision == "Approved" && resource.Category == "Video" {
-						if a.youtubeUploader != nil {
-							a.youtubeUploader.UploadResourceToYouTube(resource)
-						} else {
-							a.logger.Warn("video approved but YouTube uploader is not configured — skipping upload",
-								"resource_id", resource.ID)
-						}
-					}
-				} else {
-					a.logger.Error("failed to update resource status after review decision",
-						"resource_id", review.ResourceID,
-						"decision", review.Decision,
-						"error", updateErr,
-					)
-				}
-			}
+	// Work out the status changes the decision implies and make sure the
+	// reviewer's role may make them before anything is recorded.
+	statusPath := reviewDecisionStatusPath(resource.Status, review.Decision)
+	from := resource.Status
+	for _, next := range statusPath {
+		var transitionErr *data.StatusTransitionError
+		reason, err := authorizeStatusTransition(user, from, next)
+		if errors.As(err, &transitionErr) {
+			a.invalidStatusTransitionResponse(w, r, transitionErr)
+			return
+		}
+		if reason != "" {
+			a.policyDeniedResponse(w, r, reason)
+			return
+		}
+		from = next
+	}
+
+	// Record the review and apply the status changes together, so a decision
+	// is never stored without the status it implies
+	oldStatus := resource.Status
+	err = a.models.ResourceReviews.InsertDecision(r.Context(), review, resource, statusPath)
+	if err != nil {
+		var transitionErr *data.StatusTransitionError
+		switch {
+		case errors.As(err, &transitionErr):
+			a.invalidStatusTransitionResponse(w, r, transitionErr)
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	previous := oldStatus
+	for _, next := range statusPath {
+		a.logResourceStatusChange(resource.ID, previous, next, user.ID)
+		previous = next
+	}
+
+	// Trigger YouTube upload for approved Video resources.
+	// Runs in a background goroutine; the HTTP response is not blocked.
+	if oldStatus != resource.Status && resource.Status == data.StatusApproved && resource.Category == "Video" {
+		if a.youtubeUploader != nil {
+			a.youtubeUploader.UploadResourceToYouTube(resource)
+		} else {
+			a.logger.Warn("video approved but YouTube uploader is not configured — skipping upload",
+				"resource_id", resource.ID)
 		}
 	}
 
@@ -99,6 +131,89 @@ func (a *app) createResourceReviewHandler(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// reviewDecisionStatusPath returns the statuses a resource moves through when
+// a review decision is recorded.  "Rejected" sends the resource to
+// NeedsRevision so the contributor can revise; "Approved" moves it to the
+// Approved stage.  A resource still in Submitted is taken UnderReview first.
+func reviewDecisionStatusPath(current, decision string) []string {
+	target := data.StatusApproved
+	if decision == "Rejected" {
+		target = data.StatusNeedsRevision
+	}
+
+	switch current {
+	case target:
+		return nil
+	case data.StatusSubmitted:
+		return []string{data.StatusUnderReview, target}
+	default:
+		return []string{target}
+	}
+}
+
+// getReviewQueueHandler lists resources waiting on reviewers.  Reviewers
+// without review:any_subject only see resources in their subject expertise.
+func (a *app) getReviewQueueHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	var input struct {
+		Statuses   []string
+		Subject    string
+		GradeLevel string
+		data.Filters
+	}
+
+	v := validator.New()
+
+	input.Statuses = a.getMultipleQueryParameters(qs, "status",
+		[]string{data.StatusSubmitted, data.StatusUnderReview, data.StatusNeedsRevision})
+	input.Subject = a.getSingleQueryParameter(qs, "subject", "")
+	input.GradeLevel = a.getSingleQueryParameter(qs, "grade_level", "")
+
+	input.Filters.Page = a.getSingleIntegerParameter(qs, "page", 1, v)
+	input.Filters.PageSize = a.getSingleIntegerParameter(qs, "page_size", 20, v)
+	input.Filters.Sort = "created_at"
+	input.Filters.SortSafelist = []string{"created_at"}
+
+	for _, status := range input.Statuses {
+		v.Check(validator.PermittedValue(status, data.ResourceStatuses...), "status", "must be a valid resource status")
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := a.contextGetUser(r)
+
+	permissions, err := a.models.Permissions.GetAllForRole(r.Context(), user.RoleID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Zero means no expertise filter
+	var expertID int64
+	if !permissions.Include("review:any_subject") {
+		expertID = user.ID
+	}
+
+	resources, metadata, err := a.models.Resources.GetReviewQueue(r.Context(), expertID, input.Statuses, input.Subject, input.GradeLevel, input.Filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"resources": resources,
+		"metadata":  metadata,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
 // getResourceReviewHandler retrieves a specific resource review by ID
 func (a *app) getResourceReviewHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := a.readIDParam(r)
@@ -107,7 +222,7 @@ func (a *app) getResourceReviewHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	review, err := a.models.ResourceReviews.Get(id)
+	review, err := a.models.ResourceReviews.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -142,7 +257,7 @@ func (a *app) getReviewsByResourceIDHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	reviews, err := a.models.ResourceReviews.GetByResourceID(int64(id))
+	reviews, err := a.models.ResourceReviews.GetByResourceID(r.Context(), int64(id))
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -191,7 +306,7 @@ func (a *app) getAllResourceReviewsHandler(w http.ResponseWriter, r *http.Reques
 	reviews := []*data.ResourceReview{}
 	metadata := data.Metadata{}
 
-	// reviews, metadata, err := a.models.ResourceReviews.GetAll(input.Decision, input.Filters)
+	// reviews, metadata, err := a.models.ResourceReviews.GetAll(r.Context(), input.Decision, input.Filters)
 	// if err != nil {
 	// 	a.serverErrorResponse(w, r, err)
 	// 	return
@@ -215,7 +330,7 @@ func (a *app) updateResourceReviewHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	review, err := a.models.ResourceReviews.Get(id)
+	review, err := a.models.ResourceReviews.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -226,6 +341,16 @@ func (a *app) updateResourceReviewHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	reason, err := a.authorizeSubjectReview(r.Context(), a.contextGetUser(r), review.ResourceID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if reason != "" {
+		a.policyDeniedResponse(w, r, reason)
+		return
+	}
+
 	var input struct {
 		Decision       *string `json:"decision"`
 		CommentSummary *string `json:"comment_summary"`
@@ -255,7 +380,7 @@ func (a *app) updateResourceReviewHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = a.models.ResourceReviews.Update(review)
+	err = a.models.ResourceReviews.Update(r.Context(), review)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -283,7 +408,18 @@ func (a *app) deleteResourceReviewHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = a.models.ResourceReviews.Delete(id)
+	review, err := a.models.ResourceReviews.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.models.ResourceReviews.Delete(r.Context(), id, a.auditEvent(r, "review.delete", "resource_review", id, review, nil))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
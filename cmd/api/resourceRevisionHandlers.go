@@ -0,0 +1,244 @@
+// Filename: cmd/api/resourceRevisionHandlers.go
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+)
+
+// listResourceRevisionsHandler handles GET /v1/resources/:id/revisions,
+// newest first
+func (a *app) listResourceRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	resource, ok := a.revisionResource(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := a.models.Resources.GetRevisions(r.Context(), resource.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"revisions": revisions}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// getResourceRevisionHandler handles GET /v1/resources/:id/revisions/:revision
+func (a *app) getResourceRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	resource, ok := a.revisionResource(w, r)
+	if !ok {
+		return
+	}
+
+	number, err := a.readNamedIDParam(r, "revision")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	revision, err := a.models.Resources.GetRevision(r.Context(), resource.ID, int(number))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"revision": revision}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// resourceRevisionDiffHandler handles GET /v1/resources/:id/revision-diff,
+// listing the fields that changed between revisions ?from= and ?to=.  to
+// defaults to the latest revision and from to the one before to.
+func (a *app) resourceRevisionDiffHandler(w http.ResponseWriter, r *http.Request) {
+	resource, ok := a.revisionResource(w, r)
+	if !ok {
+		return
+	}
+
+	qs := r.URL.Query()
+	v := validator.New()
+
+	from := a.getSingleIntegerParameter(qs, "from", 0, v)
+	to := a.getSingleIntegerParameter(qs, "to", 0, v)
+	v.Check(from >= 0, "from", "must be a revision number")
+	v.Check(to >= 0, "to", "must be a revision number")
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	toRevision, err := a.models.Resources.GetRevision(r.Context(), resource.ID, to)
+	if err != nil {
+		a.revisionErrorResponse(w, r, "to", err)
+		return
+	}
+	if from == 0 {
+		from = toRevision.RevisionNumber - 1
+		if from == 0 {
+			v.AddError("from", "must be given, there is no revision before the first")
+			a.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+	fromRevision, err := a.models.Resources.GetRevision(r.Context(), resource.ID, from)
+	if err != nil {
+		a.revisionErrorResponse(w, r, "from", err)
+		return
+	}
+
+	response := envelope{
+		"from":    fromRevision.RevisionNumber,
+		"to":      toRevision.RevisionNumber,
+		"changes": data.DiffSnapshots(fromRevision.Snapshot, toRevision.Snapshot),
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// revertResourceHandler handles POST /v1/resources/:id/revisions/:revision/revert.
+// The resource's fields, subjects and grade levels are set back to the
+// revision's, which adds a new revision rather than removing the later ones.
+// Status and contributor are left as they are: status only moves through the
+// review lifecycle and the contributor is changed by reassigning.  If-Match
+// is honoured as in PATCH /v1/resources/:id.
+func (a *app) revertResourceHandler(w http.ResponseWriter, r *http.Request) {
+	resource, ok := a.revisionResource(w, r)
+	if !ok {
+		return
+	}
+
+	if !ifMatch(r, resource.Version) {
+		a.preconditionFailedResponse(w, r)
+		return
+	}
+
+	number, err := a.readNamedIDParam(r, "revision")
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	revision, err := a.models.Resources.GetRevision(r.Context(), resource.ID, int(number))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	snapshot := revision.Snapshot
+	before := *resource
+
+	resource.Title = snapshot.Title
+	resource.Category = snapshot.Category
+	resource.Slug = snapshot.Slug
+	resource.Summary = snapshot.Summary
+	resource.DriveLink = snapshot.DriveLink
+	resource.PublishedURL = snapshot.PublishedURL
+
+	user := a.contextGetUser(r)
+
+	// Non-nil lists so an empty one in the snapshot still replaces the current
+	bundle := data.ResourceBundle{
+		Resource:     resource,
+		Subjects:     append([]string{}, snapshot.Subjects...),
+		GradeLevels:  append([]string{}, snapshot.GradeLevels...),
+		RevisionBy:   user.ID,
+		RevisionNote: fmt.Sprintf("Reverted to revision %d", revision.RevisionNumber),
+	}
+	bundle.Audit = a.auditEvent(r, "resource.revert", "resource", resource.ID,
+		before, envelope{"revision": revision.RevisionNumber, "snapshot": snapshot})
+
+	var unknownErr *data.UnknownValuesError
+	err = a.models.Resources.UpdateBundle(r.Context(), bundle)
+	if err != nil {
+		switch {
+		case errors.As(err, &unknownErr):
+			a.unknownValuesResponse(w, r, unknownErr)
+		case errors.Is(err, data.ErrDuplicateSlug):
+			a.failedValidationResponse(w, r, map[string]string{
+				"slug": "is now used by another resource, so this revision cannot be restored"})
+		case errors.Is(err, data.ErrEditConflict):
+			a.editConflictResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	resource, err = a.models.Resources.Get(r.Context(), resource.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"resource": resource}, etagHeader(resource.Version))
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// revisionResource loads the resource named in the URL and checks that the
+// user may edit it, which is also what seeing its history takes.  ok is false
+// if a response has already been written.
+func (a *app) revisionResource(w http.ResponseWriter, r *http.Request) (*data.Resource, bool) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	resource, err := a.models.Resources.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	reason, err := a.authorizeResource(r.Context(), a.contextGetUser(r), resource.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+	if reason != "" {
+		a.policyDeniedResponse(w, r, reason)
+		return nil, false
+	}
+
+	return resource, true
+}
+
+// revisionErrorResponse reports a revision named in the query string that
+// could not be loaded
+func (a *app) revisionErrorResponse(w http.ResponseWriter, r *http.Request, key string, err error) {
+	if errors.Is(err, data.ErrRecordNotFound) {
+		a.failedValidationResponse(w, r, map[string]string{key: "is not a revision of this resource"})
+		return
+	}
+	a.serverErrorResponse(w, r, err)
+}
@@ -13,7 +13,8 @@ import (
 // createRoleHandler creates a new role
 func (a *app) createRoleHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		RoleName string `json:"role_name"`
+		RoleName    string `json:"role_name"`
+		MFARequired bool   `json:"mfa_required"`
 	}
 
 	err := a.readJSON(w, r, &input)
@@ -23,7 +24,8 @@ func (a *app) createRoleHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	role := &data.Role{
-		RoleName: input.RoleName,
+		RoleName:    input.RoleName,
+		MFARequired: input.MFARequired,
 	}
 
 	v := validator.New()
@@ -32,7 +34,7 @@ func (a *app) createRoleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = a.models.Roles.Insert(role)
+	err = a.models.Roles.Insert(r.Context(), role)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -55,7 +57,7 @@ func (a *app) getRoleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	role, err := a.models.Roles.Get(int(id))
+	role, err := a.models.Roles.Get(r.Context(), int(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -83,7 +85,7 @@ func (a *app) getRoleByNameHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	role, err := a.models.Roles.GetByName(name)
+	role, err := a.models.Roles.GetByName(r.Context(), name)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -113,7 +115,7 @@ func (a *app) getAllRolesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	roles, err := a.models.Roles.GetAll()
+	roles, err := a.models.Roles.GetAll(r.Context())
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -136,7 +138,7 @@ func (a *app) updateRoleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	role, err := a.models.Roles.Get(int(id))
+	role, err := a.models.Roles.Get(r.Context(), int(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -148,7 +150,8 @@ func (a *app) updateRoleHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var input struct {
-		RoleName *string `json:"role_name"`
+		RoleName    *string `json:"role_name"`
+		MFARequired *bool   `json:"mfa_required"`
 	}
 
 	err = a.readJSON(w, r, &input)
@@ -157,9 +160,13 @@ func (a *app) updateRoleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := *role
 	if input.RoleName != nil {
 		role.RoleName = *input.RoleName
 	}
+	if input.MFARequired != nil {
+		role.MFARequired = *input.MFARequired
+	}
 
 	v := validator.New()
 	if data.ValidateRole(v, role); !v.IsEmpty() {
@@ -167,7 +174,7 @@ func (a *app) updateRoleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = a.models.Roles.Update(role)
+	err = a.models.Roles.Update(r.Context(), role, a.auditEvent(r, "role.update", "role", int64(role.ID), before, role))
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -190,7 +197,18 @@ func (a *app) deleteRoleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = a.models.Roles.Delete(int(id))
+	role, err := a.models.Roles.Get(r.Context(), int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.models.Roles.Delete(r.Context(), role.ID, a.auditEvent(r, "role.delete", "role", id, role, nil))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -209,3 +227,134 @@ func (a *app) deleteRoleHandler(w http.ResponseWriter, r *http.Request) {
 		a.serverErrorResponse(w, r, err)
 	}
 }
+
+// getAllPermissionsHandler lists every permission that can be granted to a role
+func (a *app) getAllPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	permissions, err := a.models.Permissions.GetAll(r.Context())
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"permissions": permissions,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// getRolePermissionsHandler retrieves the permission codes granted to a role
+func (a *app) getRolePermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	role, err := a.models.Roles.Get(r.Context(), int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	permissions, err := a.models.Permissions.GetAllForRole(r.Context(), role.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"role":        role,
+		"permissions": permissions,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateRolePermissionsHandler replaces the permission codes granted to a role
+func (a *app) updateRolePermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	role, err := a.models.Roles.Get(r.Context(), int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Permissions []string `json:"permissions"`
+	}
+
+	err = a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	known, err := a.models.Permissions.GetAll(r.Context())
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.Permissions != nil, "permissions", "must be provided")
+	data.ValidatePermissionCodes(v, input.Permissions, known)
+
+	// Don't let an administrator lock themselves out of the permission matrix
+	user := a.contextGetUser(r)
+	if user.RoleID == role.ID {
+		v.Check(data.Permissions(input.Permissions).Include("role:manage"), "permissions", "cannot remove role:manage from your own role")
+	}
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	before, err := a.models.Permissions.GetAllForRole(r.Context(), role.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.models.Permissions.SetForRole(r.Context(), role.ID, input.Permissions,
+		a.auditEvent(r, "role.permissions", "role", int64(role.ID), before, input.Permissions))
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	permissions, err := a.models.Permissions.GetAllForRole(r.Context(), role.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := envelope{
+		"role":        role,
+		"permissions": permissions,
+	}
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -24,27 +24,40 @@ func (a *app) routes() http.Handler {
 	router.HandlerFunc(http.MethodPost, apiV1Route+"/users", a.registerUserHandler)
 	// *-- Activate a User (public) -- *
 	router.HandlerFunc(http.MethodPut, apiV1Route+"/users/activated", a.activateUserHandler)
+	// *-- Reset a forgotten password with a password-reset token (public) -- *
+	router.HandlerFunc(http.MethodPut, apiV1Route+"/users/password", a.updateUserPasswordHandler)
 
-	// Protected user routes - admin, CEO, DEC, TSC can view all users (must be activated)
-	router.Handler(http.MethodGet, apiV1Route+"/users", a.requireAnyRole([]string{"admin", "CEO", "DEC", "TSC"}, http.HandlerFunc(a.getAllUsersHandler)))
+	// Protected user routes - roles with user:read can view all users (must be activated)
+	router.Handler(http.MethodGet, apiV1Route+"/users", a.requirePermission("user:read", http.HandlerFunc(a.getAllUsersHandler)))
 	router.Handler(http.MethodGet, apiV1Route+"/users/:id", a.requireActivatedUser(a.getUserHandler))
-	// admin, CEO, and DEC can update users (must be activated)
+	// users can update themselves; user:read holders can update others (must be activated)
 	router.Handler(http.MethodPatch, apiV1Route+"/users/:id", a.requireActivatedUser(a.updateUserHandler))
-	// Only admin can delete users (must be activated)
 	router.Handler(http.MethodDelete, apiV1Route+"/users/:id",
-		a.requireRole("admin", http.HandlerFunc(a.deleteUserHandler)))
+		a.requirePermission("user:delete", http.HandlerFunc(a.deleteUserHandler)))
+	// Subject expertise - decides which resources a reviewer may review.
+	// Outside /users/:id to avoid the httprouter wildcard conflict described below.
+	router.Handler(http.MethodGet, apiV1Route+"/user-subjects/:id",
+		a.requireActivatedUser(http.HandlerFunc(a.getUserSubjectsHandler)))
+	router.Handler(http.MethodPut, apiV1Route+"/user-subjects/:id",
+		a.requirePermission("expertise:manage", http.HandlerFunc(a.updateUserSubjectsHandler)))
 
-	// Role routes - Only admin can manage roles (must be activated)
+	// Role routes - role:manage holders manage roles and the permission matrix (must be activated)
 	router.Handler(http.MethodPost, apiV1Route+"/roles",
-		a.requireRole("admin", http.HandlerFunc(a.createRoleHandler)))
+		a.requirePermission("role:manage", http.HandlerFunc(a.createRoleHandler)))
 	router.Handler(http.MethodGet, apiV1Route+"/roles",
 		http.HandlerFunc(a.getAllRolesHandler))
 	router.Handler(http.MethodGet, apiV1Route+"/roles/:id",
 		a.requireActivatedUser(http.HandlerFunc(a.getRoleHandler)))
 	router.Handler(http.MethodPatch, apiV1Route+"/roles/:id",
-		a.requireRole("admin", http.HandlerFunc(a.updateRoleHandler)))
+		a.requirePermission("role:manage", http.HandlerFunc(a.updateRoleHandler)))
 	router.Handler(http.MethodDelete, apiV1Route+"/roles/:id",
-		a.requireRole("admin", http.HandlerFunc(a.deleteRoleHandler)))
+		a.requirePermission("role:manage", http.HandlerFunc(a.deleteRoleHandler)))
+	router.Handler(http.MethodGet, apiV1Route+"/roles/:id/permissions",
+		a.requireActivatedUser(http.HandlerFunc(a.getRolePermissionsHandler)))
+	router.Handler(http.MethodPut, apiV1Route+"/roles/:id/permissions",
+		a.requirePermission("role:manage", http.HandlerFunc(a.updateRolePermissionsHandler)))
+	router.Handler(http.MethodGet, apiV1Route+"/permissions",
+		a.requirePermission("role:manage", http.HandlerFunc(a.getAllPermissionsHandler)))
 
 	// ── Fellow application routes ────────────────────────────────────────────
 	// IMPORTANT – httprouter wildcard rule:
@@ -80,7 +93,7 @@ func (a *app) routes() http.Handler {
 	router.Handler(http.MethodPatch, apiV1Route+"/fellows/:id",
 		a.requireActivatedUser(http.HandlerFunc(a.updateFellowHandler)))
 	router.Handler(http.MethodDelete, apiV1Route+"/fellows/:id",
-		a.requireAnyRole([]string{"admin", "CEO", "TSC"}, http.HandlerFunc(a.deleteFellowHandler)))
+		a.requirePermission("fellow:delete", http.HandlerFunc(a.deleteFellowHandler)))
 
 	// Resource slug route — outside /resources/:id to avoid httprouter wildcard
 	// conflict (see the fellow-applications comment above for the full rule).
@@ -88,33 +101,72 @@ func (a *app) routes() http.Handler {
 
 	// Resource metrics — same reason: avoids wildcard-vs-children conflict.
 	router.Handler(http.MethodGet, apiV1Route+"/resource-metrics",
-		a.requireAnyRole([]string{"SubjectExpert", "TeamLead", "DSC", "admin"}, http.HandlerFunc(a.resourceMetricsHandler)))
+		a.requirePermission("resource:metrics", http.HandlerFunc(a.resourceMetricsHandler)))
 
-	// Resource routes - Fellows, admin, DSC can create; public can view
+	// Resource facets — public like the listing they describe, same prefix rule.
+	router.HandlerFunc(http.MethodGet, apiV1Route+"/resource-facets", a.resourceFacetsHandler)
+
+	// Bulk resource operations — same prefix rule, since POST /resources/:id/…
+	// routes exist.  Each resource is checked as in the single-resource routes.
+	router.Handler(http.MethodPost, apiV1Route+"/resource-bulk",
+		a.requireActivatedUser(http.HandlerFunc(a.bulkResourcesHandler)))
+
+	// Resource routes - resource:create holders can create; public can view
 	router.HandlerFunc(http.MethodGet, apiV1Route+"/resources", a.getAllResourcesHandler)
 	router.Handler(http.MethodPost, apiV1Route+"/resources",
-		a.requireFellow(http.HandlerFunc(a.createResourceHandler)))
+		a.requirePermission("resource:create", http.HandlerFunc(a.createResourceHandler)))
 	router.HandlerFunc(http.MethodGet, apiV1Route+"/resources/:id/lessons", a.getResourceLessonsHandler)
 	router.HandlerFunc(http.MethodGet, apiV1Route+"/resources/:id/comments", a.getResourceCommentsHandler)
+	// Printable lesson plan of every lesson; fellow-only blocks are shown to those who can edit it
+	router.HandlerFunc(http.MethodGet, apiV1Route+"/resources/:id/export", a.exportResourceLessonsHandler)
 	// Review comments per resource (anyone authenticated can view; reviewers can create/resolve)
 	router.Handler(http.MethodGet, apiV1Route+"/resources/:id/review-comments",
 		a.requireActivatedUser(http.HandlerFunc(a.getReviewCommentsByResourceHandler)))
+	// Resource members - the contributor manages co-authors; review:assign holders assign reviewers
+	router.Handler(http.MethodGet, apiV1Route+"/resources/:id/members",
+		a.requireActivatedUser(http.HandlerFunc(a.getResourceMembersHandler)))
+	router.Handler(http.MethodPost, apiV1Route+"/resources/:id/coauthors",
+		a.requireActivatedUser(http.HandlerFunc(a.addCoAuthorHandler)))
+	router.Handler(http.MethodDelete, apiV1Route+"/resources/:id/coauthors/:user_id",
+		a.requireActivatedUser(http.HandlerFunc(a.removeCoAuthorHandler)))
+	router.Handler(http.MethodPost, apiV1Route+"/resources/:id/reviewers",
+		a.requirePermission("review:assign", http.HandlerFunc(a.assignReviewerHandler)))
+	router.Handler(http.MethodDelete, apiV1Route+"/resources/:id/reviewers/:user_id",
+		a.requirePermission("review:assign", http.HandlerFunc(a.unassignReviewerHandler)))
+	// Revision history - visible to, and revertible by, those who can edit the resource
+	router.Handler(http.MethodGet, apiV1Route+"/resources/:id/revisions",
+		a.requireActivatedUser(http.HandlerFunc(a.listResourceRevisionsHandler)))
+	router.Handler(http.MethodGet, apiV1Route+"/resources/:id/revisions/:revision",
+		a.requireActivatedUser(http.HandlerFunc(a.getResourceRevisionHandler)))
+	router.Handler(http.MethodPost, apiV1Route+"/resources/:id/revisions/:revision/revert",
+		a.requireActivatedUser(http.HandlerFunc(a.revertResourceHandler)))
+	router.Handler(http.MethodGet, apiV1Route+"/resources/:id/revision-diff",
+		a.requireActivatedUser(http.HandlerFunc(a.resourceRevisionDiffHandler)))
 	router.HandlerFunc(http.MethodGet, apiV1Route+"/resources/:id", a.getResourceHandler)
+	// contributors, co-authors, assigned reviewers and resource:edit_any holders can update
 	router.Handler(http.MethodPatch, apiV1Route+"/resources/:id",
 		a.requireActivatedUser(http.HandlerFunc(a.updateResourceHandler)))
 	router.Handler(http.MethodDelete, apiV1Route+"/resources/:id",
-		a.requireAnyRole([]string{"admin", "CEO", "DEC"}, http.HandlerFunc(a.deleteResourceHandler)))
+		a.requirePermission("resource:delete", http.HandlerFunc(a.deleteResourceHandler)))
 
-	// Lesson routes - Public can view, authenticated users can create/modify
+	// Lesson routes - Public can view, members of the parent resource can create/modify
 	router.Handler(http.MethodPost, apiV1Route+"/lessons",
 		a.requireActivatedUser(http.HandlerFunc(a.createLessonHandler)))
 	router.HandlerFunc(http.MethodGet, apiV1Route+"/lessons/:id", a.getLessonHandler)
+	router.HandlerFunc(http.MethodGet, apiV1Route+"/lessons/:id/export", a.exportLessonHandler)
 	router.Handler(http.MethodPatch, apiV1Route+"/lessons/:id",
 		a.requireActivatedUser(http.HandlerFunc(a.updateLessonHandler)))
 	router.Handler(http.MethodDelete, apiV1Route+"/lessons/:id",
-		a.requireAnyRole([]string{"admin", "CEO", "DEC"}, http.HandlerFunc(a.deleteLessonHandler)))
+		a.requirePermission("lesson:delete", http.HandlerFunc(a.deleteLessonHandler)))
+	// Lesson versions - visible to, and restorable by, those who can edit the lesson
+	router.Handler(http.MethodGet, apiV1Route+"/lessons/:id/versions",
+		a.requireActivatedUser(http.HandlerFunc(a.listLessonVersionsHandler)))
+	router.Handler(http.MethodGet, apiV1Route+"/lessons/:id/versions/:n/diff",
+		a.requireActivatedUser(http.HandlerFunc(a.lessonVersionDiffHandler)))
+	router.Handler(http.MethodPost, apiV1Route+"/lessons/:id/versions/:n/restore",
+		a.requireActivatedUser(http.HandlerFunc(a.restoreLessonVersionHandler)))
 
-	// Comment routes - Public can view, authenticated users can create/modify
+	// Comment routes - Public can view, authors (or comment:moderate holders) can modify
 	router.Handler(http.MethodPost, apiV1Route+"/comments",
 		a.requireActivatedUser(http.HandlerFunc(a.createCommentHandler)))
 	router.HandlerFunc(http.MethodGet, apiV1Route+"/comments/:id", a.getCommentHandler)
@@ -123,49 +175,53 @@ func (a *app) routes() http.Handler {
 	router.Handler(http.MethodDelete, apiV1Route+"/comments/:id",
 		a.requireActivatedUser(http.HandlerFunc(a.deleteCommentHandler)))
 
-	// Resource Review routes - admin/CEO/TSC/DEC can create reviews (must be activated)
+	// Review queue - filtered to the reviewer's subject expertise unless they hold review:any_subject
+	router.Handler(http.MethodGet, apiV1Route+"/review-queue",
+		a.requirePermission("review:create", http.HandlerFunc(a.getReviewQueueHandler)))
+
+	// Resource Review routes - review:create holders can create reviews in their subjects (must be activated)
 	router.Handler(http.MethodGet, apiV1Route+"/resource-reviews",
 		a.requireActivatedUser(http.HandlerFunc(a.getAllResourceReviewsHandler)))
 	router.Handler(http.MethodPost, apiV1Route+"/resource-reviews",
-		a.requireAnyRole([]string{"admin", "CEO", "TSC", "DEC"}, http.HandlerFunc(a.createResourceReviewHandler)))
+		a.requirePermission("review:create", http.HandlerFunc(a.createResourceReviewHandler)))
 	router.Handler(http.MethodGet, apiV1Route+"/resource-reviews/:id",
 		a.requireActivatedUser(http.HandlerFunc(a.getResourceReviewHandler)))
 	router.Handler(http.MethodPatch, apiV1Route+"/resource-reviews/:id",
-		a.requireAnyRole([]string{"admin", "CEO", "TSC", "DEC"}, http.HandlerFunc(a.updateResourceReviewHandler)))
+		a.requirePermission("review:create", http.HandlerFunc(a.updateResourceReviewHandler)))
 	router.Handler(http.MethodDelete, apiV1Route+"/resource-reviews/:id",
-		a.requireRole("admin", http.HandlerFunc(a.deleteResourceReviewHandler)))
+		a.requirePermission("review:delete", http.HandlerFunc(a.deleteResourceReviewHandler)))
 
 	// Review comment routes
 	// Reviewers add iterative comments; contributors/reviewers resolve them
 	router.Handler(http.MethodPost, apiV1Route+"/review-comments",
-		a.requireAnyRole([]string{"admin", "CEO", "TSC", "DEC"}, http.HandlerFunc(a.createReviewCommentHandler)))
+		a.requirePermission("review:create", http.HandlerFunc(a.createReviewCommentHandler)))
 	router.Handler(http.MethodPatch, apiV1Route+"/review-comments/:id/resolve",
-		a.requireActivatedUser(http.HandlerFunc(a.resolveReviewCommentHandler)))
+		a.requireReviewCommentAccess(http.HandlerFunc(a.resolveReviewCommentHandler)))
 
 	// Resource Access routes - track resource access (must be activated)
 	router.Handler(http.MethodGet, apiV1Route+"/resource-access",
-		a.requireAnyRole([]string{"admin", "CEO", "DEC"}, http.HandlerFunc(a.getAllResourceAccessHandler)))
+		a.requirePermission("resource_access:read", http.HandlerFunc(a.getAllResourceAccessHandler)))
 	router.Handler(http.MethodPost, apiV1Route+"/resource-access",
 		a.requireActivatedUser(http.HandlerFunc(a.createResourceAccessHandler)))
 	router.Handler(http.MethodGet, apiV1Route+"/resource-access/:id",
-		a.requireAnyRole([]string{"admin", "CEO", "DEC"}, http.HandlerFunc(a.getResourceAccessHandler)))
+		a.requirePermission("resource_access:read", http.HandlerFunc(a.getResourceAccessHandler)))
 	router.Handler(http.MethodDelete, apiV1Route+"/resource-access/:id",
-		a.requireRole("admin", http.HandlerFunc(a.deleteResourceAccessHandler)))
+		a.requirePermission("resource_access:delete", http.HandlerFunc(a.deleteResourceAccessHandler)))
 
-	// Contribution routes - admin can manage contributions (must be activated)
+	// Contribution routes - contribution:manage holders can manage contributions (must be activated)
 	router.Handler(http.MethodGet, apiV1Route+"/contributions",
 		a.requireActivatedUser(http.HandlerFunc(a.getAllContributionsHandler)))
 	router.Handler(http.MethodPost, apiV1Route+"/contributions",
-		a.requireAnyRole([]string{"admin", "CEO"}, http.HandlerFunc(a.createContributionHandler)))
+		a.requirePermission("contribution:manage", http.HandlerFunc(a.createContributionHandler)))
 	router.Handler(http.MethodGet, apiV1Route+"/contributions/:id",
 		a.requireActivatedUser(http.HandlerFunc(a.getContributionHandler)))
 	router.Handler(http.MethodPatch, apiV1Route+"/contributions/:id",
-		a.requireAnyRole([]string{"admin", "CEO"}, http.HandlerFunc(a.updateContributionHandler)))
+		a.requirePermission("contribution:manage", http.HandlerFunc(a.updateContributionHandler)))
 	router.Handler(http.MethodDelete, apiV1Route+"/contributions/:id",
-		a.requireRole("admin", http.HandlerFunc(a.deleteContributionHandler)))
+		a.requirePermission("contribution:delete", http.HandlerFunc(a.deleteContributionHandler)))
 
-	// Notification routes - admin/CEO/Secretary can create, users can manage their own (must be activated)
-	router.Handler(http.MethodPost, apiV1Route+"/notifications", a.requireAnyRole([]string{"admin", "CEO", "Secretary"}, http.HandlerFunc(a.createNotificationHandler)))
+	// Notification routes - notification:create holders can create, users can manage their own (must be activated)
+	router.Handler(http.MethodPost, apiV1Route+"/notifications", a.requirePermission("notification:create", http.HandlerFunc(a.createNotificationHandler)))
 	router.Handler(http.MethodGet, apiV1Route+"/notifications",
 		a.requireActivatedUser(http.HandlerFunc(a.getAllNotificationsHandler)))
 	router.Handler(http.MethodGet, apiV1Route+"/notifications/:id",
@@ -174,41 +230,93 @@ func (a *app) routes() http.Handler {
 		a.requireActivatedUser(http.HandlerFunc(a.updateNotificationHandler)))
 	router.Handler(http.MethodDelete, apiV1Route+"/notifications/:id",
 		a.requireActivatedUser(http.HandlerFunc(a.deleteNotificationHandler)))
-	// ── Admin routes (admin + DSC only) ────────────────────────────────────
+	// ── Admin routes (admin + DSC by default) ──────────────────────────────
 
 	// Resource status override — lets admins/DSC force-set any status value.
 	router.Handler(http.MethodPost, apiV1Route+"/resources/:id/status",
-		a.requireAnyRole([]string{"admin", "DSC"}, http.HandlerFunc(a.overrideResourceStatusHandler)))
+		a.requirePermission("resource:status_override", http.HandlerFunc(a.overrideResourceStatusHandler)))
 
 	// Admin fellow application review endpoints
 	router.Handler(http.MethodGet, apiV1Route+"/admin/fellow-applications",
-		a.requireAnyRole([]string{"admin", "DSC"}, http.HandlerFunc(a.adminListFellowApplicationsHandler)))
+		a.requirePermission("fellow_application:review", http.HandlerFunc(a.adminListFellowApplicationsHandler)))
 	router.Handler(http.MethodPatch, apiV1Route+"/admin/fellow-applications/:id/approve",
-		a.requireAnyRole([]string{"admin", "DSC"}, http.HandlerFunc(a.adminApproveFellowHandler)))
+		a.requirePermission("fellow_application:review", http.HandlerFunc(a.adminApproveFellowHandler)))
 	router.Handler(http.MethodPatch, apiV1Route+"/admin/fellow-applications/:id/reject",
-		a.requireAnyRole([]string{"admin", "DSC"}, http.HandlerFunc(a.adminRejectFellowHandler)))
+		a.requirePermission("fellow_application:review", http.HandlerFunc(a.adminRejectFellowHandler)))
 
 	// Admin-level metrics: user counts + full resource-status breakdown.
 	router.Handler(http.MethodGet, apiV1Route+"/admin/metrics",
-		a.requireAnyRole([]string{"admin", "DSC"}, http.HandlerFunc(a.adminMetricsHandler)))
+		a.requirePermission("admin:metrics", http.HandlerFunc(a.adminMetricsHandler)))
+
+	// Audit log of privileged actions
+	router.Handler(http.MethodGet, apiV1Route+"/admin/audit",
+		a.requirePermission("audit:read", http.HandlerFunc(a.listAuditEventsHandler)))
+
+	// Bulk import of resources from CSV or NDJSON, with a dry-run preview
+	router.Handler(http.MethodPost, apiV1Route+"/admin/resources/import",
+		a.requirePermission("resource:import", http.HandlerFunc(a.importResourcesHandler)))
+
+	// Streaming exports, filtered like the matching list endpoints
+	router.Handler(http.MethodGet, apiV1Route+"/admin/exports/resources",
+		a.requirePermission("data:export", http.HandlerFunc(a.exportResourcesHandler)))
+	router.Handler(http.MethodGet, apiV1Route+"/admin/exports/users",
+		a.requirePermission("data:export", http.HandlerFunc(a.exportUsersHandler)))
+	router.Handler(http.MethodGet, apiV1Route+"/admin/exports/reviews",
+		a.requirePermission("data:export", http.HandlerFunc(a.exportReviewsHandler)))
+	router.Handler(http.MethodGet, apiV1Route+"/admin/exports/access",
+		a.requirePermission("data:export", http.HandlerFunc(a.exportAccessHandler)))
+
+	// Trash of soft-deleted resources, lessons and comments
+	router.Handler(http.MethodGet, apiV1Route+"/admin/trash",
+		a.requirePermission("trash:manage", http.HandlerFunc(a.listTrashHandler)))
+	router.Handler(http.MethodPost, apiV1Route+"/admin/trash/:type/:id/restore",
+		a.requirePermission("trash:manage", http.HandlerFunc(a.restoreTrashHandler)))
 
 	// Admin user management — distinct from the general /users endpoints so
-	// that role and activation changes always require admin/DSC privilege.
+	// that role and activation changes always require the user:manage permission.
 	router.Handler(http.MethodPost, apiV1Route+"/admin/users",
-		a.requireAnyRole([]string{"admin", "DSC"}, http.HandlerFunc(a.adminCreateUserHandler)))
+		a.requirePermission("user:manage", http.HandlerFunc(a.adminCreateUserHandler)))
+	router.Handler(http.MethodGet, apiV1Route+"/admin/users/:id",
+		a.requirePermission("user:manage", http.HandlerFunc(a.adminGetUserHandler)))
 	router.Handler(http.MethodPut, apiV1Route+"/admin/users/:id",
-		a.requireAnyRole([]string{"admin", "DSC"}, http.HandlerFunc(a.adminUpdateUserHandler)))
+		a.requirePermission("user:manage", http.HandlerFunc(a.adminUpdateUserHandler)))
 	router.Handler(http.MethodPatch, apiV1Route+"/admin/users/:id/role",
-		a.requireAnyRole([]string{"admin", "DSC"}, http.HandlerFunc(a.adminUpdateUserRoleHandler)))
+		a.requirePermission("user:manage", http.HandlerFunc(a.adminUpdateUserRoleHandler)))
 	router.Handler(http.MethodPatch, apiV1Route+"/admin/users/:id/active",
-		a.requireAnyRole([]string{"admin", "DSC"}, http.HandlerFunc(a.adminToggleUserActiveHandler)))
+		a.requirePermission("user:manage", http.HandlerFunc(a.adminToggleUserActiveHandler)))
+	router.Handler(http.MethodDelete, apiV1Route+"/admin/users/:id/lockout",
+		a.requirePermission("user:manage", http.HandlerFunc(a.adminClearUserLockoutHandler)))
 
 	// Token routes
 	// TODO: Implement token handlers
 	router.HandlerFunc(http.MethodPost, apiV1Route+"/tokens/authentication", a.createAuthTokenHandler)
+	router.HandlerFunc(http.MethodPost, apiV1Route+"/tokens/mfa", a.createMFAAuthTokenHandler)
+	router.HandlerFunc(http.MethodPost, apiV1Route+"/tokens/refresh", a.refreshAuthTokenHandler)
+	router.Handler(http.MethodDelete, apiV1Route+"/tokens/authentication",
+		a.requireAuthenticatedUser(http.HandlerFunc(a.deleteAuthTokenHandler)))
+
+	// Session routes - users can see and revoke their own sign-ins
+	router.Handler(http.MethodGet, apiV1Route+"/me/sessions",
+		a.requireAuthenticatedUser(http.HandlerFunc(a.getMySessionsHandler)))
+	router.Handler(http.MethodDelete, apiV1Route+"/me/sessions/:id",
+		a.requireAuthenticatedUser(http.HandlerFunc(a.deleteMySessionHandler)))
+
+	// Two-factor authentication routes - enrollment is open to every activated
+	// user so that roles which require MFA can still set it up
+	router.Handler(http.MethodGet, apiV1Route+"/me/mfa",
+		a.requireActivatedUser(http.HandlerFunc(a.getMyMFAHandler)))
+	router.Handler(http.MethodPost, apiV1Route+"/me/mfa/totp",
+		a.requireActivatedUser(http.HandlerFunc(a.createMFASecretHandler)))
+	router.Handler(http.MethodPost, apiV1Route+"/me/mfa/totp/verify",
+		a.requireActivatedUser(http.HandlerFunc(a.enableMFAHandler)))
+	router.Handler(http.MethodPost, apiV1Route+"/me/mfa/recovery-codes",
+		a.requireActivatedUser(http.HandlerFunc(a.regenerateRecoveryCodesHandler)))
+	router.Handler(http.MethodDelete, apiV1Route+"/me/mfa",
+		a.requireActivatedUser(http.HandlerFunc(a.disableMFAHandler)))
 	router.HandlerFunc(http.MethodPost, apiV1Route+"/tokens/activation", a.createActivationTokenHandler)
+	router.HandlerFunc(http.MethodPost, apiV1Route+"/tokens/password-reset", a.createPasswordResetTokenHandler)
 	router.Handler(http.MethodDelete, apiV1Route+"/tokens/user/:user_id",
-		a.authenticate(a.requireActivatedUser(a.requireRole("admin", http.HandlerFunc(a.deleteAllTokensForUserHandler)))))
+		a.requirePermission("token:manage", http.HandlerFunc(a.deleteAllTokensForUserHandler)))
 
 	// Google OAuth2 routes — used once to obtain a refresh token for
 	// YOUTUBE_REFRESH_TOKEN.  Keep these behind your firewall or restrict them
@@ -225,6 +333,7 @@ func (a *app) routes() http.Handler {
 	handler = a.authenticate(handler)
 	handler = a.rateLimit(handler)
 	handler = a.enableCORS(handler)
+	handler = a.requestID(handler)
 
 	return handler
 }
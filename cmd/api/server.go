@@ -39,7 +39,10 @@ func (app *app) Serve() error {
 			shutdownError <- err
 		}
 
-		// wait for background tasks to complete
+		// stop the periodic jobs, then wait for background tasks to complete
+		if app.stopJobs != nil {
+			app.stopJobs()
+		}
 		app.logger.Info("Completing background tasks", "address", srv.Addr)
 		app.wg.Wait()
 		shutdownError <- nil
@@ -0,0 +1,103 @@
+// Filename: cmd/api/sessionHandlers.go
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+	"github.com/julienschmidt/httprouter"
+)
+
+// sessionIDRX matches the hex family IDs used as session IDs
+var sessionIDRX = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// getMySessionsHandler handles GET /v1/me/sessions
+func (a *app) getMySessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := a.contextGetUser(r)
+
+	sessions, err := a.models.Tokens.GetSessionsForUser(r.Context(), user.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Flag the session this request was made with
+	currentID, err := a.models.Tokens.GetFamilyID(r.Context(), a.contextGetToken(r))
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == currentID
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"sessions": sessions}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteMySessionHandler handles DELETE /v1/me/sessions/:id, signing the
+// user out of one of their sessions
+func (a *app) deleteMySessionHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	sessionID := params.ByName("id")
+	if !sessionIDRX.MatchString(sessionID) {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	user := a.contextGetUser(r)
+
+	err := a.models.Tokens.DeleteSessionForUser(r.Context(), user.ID, sessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"message": "session successfully revoked"}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteAuthTokenHandler handles DELETE /v1/tokens/authentication.  It logs
+// the user out by deleting the current token together with the refresh
+// token issued alongside it.
+func (a *app) deleteAuthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	token := a.contextGetToken(r)
+
+	familyID, err := a.models.Tokens.GetFamilyID(r.Context(), token)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.invalidAuthenticationTokenResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if familyID != "" {
+		err = a.models.Tokens.DeleteFamily(r.Context(), familyID)
+	} else {
+		err = a.models.Tokens.DeleteByPlaintext(r.Context(), token)
+	}
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"message": "you have been logged out"}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"time"
@@ -32,15 +33,25 @@ func (a *app) createAuthTokenHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Default TTL to 24 hours if not provided
-	ttl := 24 * time.Hour
+	// Refuse while the account or IP is backing off, before spending any
+	// time on the password hash
+	ip := clientIP(r)
+	blocked, err := a.loginBlocked(r.Context(), input.Email, ip)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if blocked != nil {
+		a.loginThrottledResponse(w, r, blocked)
+		return
+	}
 
 	// Is there an associated user for the provided email?
-	user, err := a.models.Users.GetByEmail(input.Email)
+	user, err := a.models.Users.GetByEmail(r.Context(), input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			a.invalidCredentialsResponse(w, r)
+			a.recordLoginFailure(w, r, input.Email, ip, nil)
 		default:
 			a.serverErrorResponse(w, r, err)
 		}
@@ -56,27 +67,211 @@ func (a *app) createAuthTokenHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Wrong password
 	if !match {
-		a.invalidCredentialsResponse(w, r)
+		a.recordLoginFailure(w, r, input.Email, ip, user)
 		return
 	}
 
+	mfa, err := a.models.MFA.Get(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	mfaEnabled := mfa != nil && mfa.Enabled
+
+	// The password was right, so the account starts again with a clean slate.
+	// With two-factor authentication the sign-in is not finished yet: the
+	// failures keep counting through /v1/tokens/mfa, which clears them once a
+	// code is accepted, so the second factor cannot be guessed without limit.
+	if !mfaEnabled {
+		err = a.models.LoginThrottles.Clear(r.Context(), data.ThrottleScopeAccount, input.Email, nil)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
 	// Is the user active/activated?
 	if !user.IsActive {
 		a.inactiveAccountResponse(w, r)
 		return
 	}
 
-	// Create the token
-	token, err := a.models.Tokens.New(user.ID, ttl, data.ScopeAuthentication)
+	// Users with two-factor authentication get a short-lived pending token
+	// which must be exchanged, together with a code, at /v1/tokens/mfa
+	if mfaEnabled {
+		pending, err := a.models.Tokens.New(r.Context(), user.ID, mfaPendingTTL, data.ScopeMFAPending)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = a.writeJSON(w, http.StatusAccepted, envelope{
+			"mfa_required": true,
+			"mfa_token":    pending,
+		}, nil)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Create a short-lived access token and the refresh token used to renew it
+	token, refreshToken, err := a.models.Tokens.NewPair(r.Context(), user.ID, a.config.tokens.accessTTL, a.config.tokens.refreshTTL,
+		clientIP(r), r.UserAgent())
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Return the tokens and user data for the client
+	err = a.writeJSON(w, http.StatusCreated, envelope{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"user":          user,
+	}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// loginBlocked returns the throttle record that is currently blocking a
+// sign-in for this email or IP, or nil if the attempt may go ahead
+func (a *app) loginBlocked(ctx context.Context, email, ip string) (*data.LoginThrottle, error) {
+	checks := []struct {
+		scope   string
+		subject string
+		policy  data.ThrottlePolicy
+	}{
+		{data.ThrottleScopeAccount, email, data.AccountThrottlePolicy},
+		{data.ThrottleScopeIP, ip, data.IPThrottlePolicy},
+	}
+
+	for _, check := range checks {
+		throttle, err := a.models.LoginThrottles.Get(ctx, check.scope, check.subject, check.policy)
+		if err != nil {
+			if errors.Is(err, data.ErrRecordNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if throttle.RetryAfter() > 0 {
+			return throttle, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// recordLoginFailure counts a failed sign-in against the email and IP and
+// sends the invalid credentials response.  user is nil when the email does not
+// belong to an account; the response is the same either way.
+func (a *app) recordLoginFailure(w http.ResponseWriter, r *http.Request, email, ip string, user *data.User) {
+	account, err := a.countLoginFailure(r.Context(), email, ip, user)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if account.Locked {
+		a.loginThrottledResponse(w, r, account)
+		return
+	}
+
+	a.invalidCredentialsResponse(w, r)
+}
+
+// countLoginFailure counts a failed sign-in, a wrong password or a wrong
+// second factor, against the email and IP, and emails the user when it locks
+// their account.  It returns the account's throttle record.
+func (a *app) countLoginFailure(ctx context.Context, email, ip string, user *data.User) (*data.LoginThrottle, error) {
+	account, lockedNow, err := a.models.LoginThrottles.RecordFailure(ctx, data.ThrottleScopeAccount, email, data.AccountThrottlePolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, err = a.models.LoginThrottles.RecordFailure(ctx, data.ThrottleScopeIP, ip, data.IPThrottlePolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	if lockedNow {
+		a.logger.Warn("account locked after repeated failed sign-ins", "email", email, "ip", ip)
+
+		if user != nil {
+			lockoutMinutes := int(data.AccountThrottlePolicy.LockoutDuration.Minutes())
+			a.background(func() {
+				data := map[string]any{
+					"username":       user.Username,
+					"ipAddress":      ip,
+					"lockoutMinutes": lockoutMinutes,
+				}
+
+				err := a.mailer.Send(user.Email, "account_locked.tmpl", data)
+				if err != nil {
+					a.logger.Error(err.Error())
+				}
+			})
+		}
+	}
+
+	return account, nil
+}
+
+// refreshAuthTokenHandler handles POST /v1/tokens/refresh.  The refresh token
+// is single-use: each call returns a new access token and a new refresh token.
+func (a *app) refreshAuthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, input.RefreshToken); !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	token, refreshToken, err := a.models.Tokens.Rotate(r.Context(), input.RefreshToken, a.config.tokens.accessTTL, a.config.tokens.refreshTTL,
+		clientIP(r), r.UserAgent())
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTokenReused):
+			a.logger.Warn("refresh token reused, token family revoked", "ip", r.RemoteAddr)
+			a.invalidRefreshTokenResponse(w, r)
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.invalidRefreshTokenResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := a.models.Users.Get(r.Context(), int(token.UserID))
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
 	}
 
-	// Return both token and user data for the client
+	// A deactivated account may not keep renewing its session
+	if !user.IsActive {
+		err = a.models.Tokens.DeleteFamily(r.Context(), token.FamilyID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		a.inactiveAccountResponse(w, r)
+		return
+	}
+
 	err = a.writeJSON(w, http.StatusCreated, envelope{
-		"token": token,
-		"user":  user,
+		"token":         token,
+		"refresh_token": refreshToken,
+		"user":          user,
 	}, nil)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
@@ -104,7 +299,7 @@ func (a *app) createActivationTokenHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Activation tokens typically have shorter TTL (e.g., 3 days)
-	token, err := a.models.Tokens.New(input.UserID, 3*24*time.Hour, data.ScopeActivation)
+	token, err := a.models.Tokens.New(r.Context(), input.UserID, 3*24*time.Hour, data.ScopeActivation)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -116,6 +311,77 @@ func (a *app) createActivationTokenHandler(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// createPasswordResetTokenHandler handles POST /v1/tokens/password-reset.
+// The response is the same whether or not the email belongs to an account so
+// the endpoint cannot be used to discover registered addresses.
+func (a *app) createPasswordResetTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateEmail(v, input.Email); !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	response := envelope{
+		"message": "if an activated account uses this email address, a password reset token has been sent to it",
+	}
+
+	user, err := a.models.Users.GetByEmail(r.Context(), input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			err = a.writeJSON(w, http.StatusAccepted, response, nil)
+			if err != nil {
+				a.serverErrorResponse(w, r, err)
+			}
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if user.IsActive {
+		// Only the most recent reset token is valid
+		err = a.models.Tokens.DeleteAllForUser(r.Context(), data.ScopePasswordReset, user.ID)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+
+		token, err := a.models.Tokens.New(r.Context(), user.ID, 45*time.Minute, data.ScopePasswordReset)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+
+		a.background(func() {
+			data := map[string]any{
+				"passwordResetToken": token.Plaintext,
+				"username":           user.Username,
+			}
+
+			err := a.mailer.Send(user.Email, "password_reset.tmpl", data)
+			if err != nil {
+				a.logger.Error(err.Error())
+			}
+		})
+	}
+
+	err = a.writeJSON(w, http.StatusAccepted, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
 // deleteAllTokensForUserHandler handles DELETE /v1/tokens/user/:user_id
 func (a *app) deleteAllTokensForUserHandler(w http.ResponseWriter, r *http.Request) {
 	userID, err := a.readIDParam(r)
@@ -131,14 +397,16 @@ func (a *app) deleteAllTokensForUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	v := validator.New()
-	v.Check(scope == data.ScopeActivation || scope == data.ScopeAuthentication, "scope", "must be 'activation' or 'authentication'")
+	v.Check(validator.PermittedValue(scope, data.ScopeActivation, data.ScopeAuthentication, data.ScopeRefresh, data.ScopePasswordReset),
+		"scope", "must be 'activation', 'authentication', 'refresh' or 'password-reset'")
 
 	if !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = a.models.Tokens.DeleteAllForUser(scope, userID)
+	err = a.models.Tokens.PurgeForUser(r.Context(), scope, userID,
+		a.auditEvent(r, "tokens.purge", "user", userID, nil, envelope{"scope": scope}))
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
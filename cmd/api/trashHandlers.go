@@ -0,0 +1,136 @@
+// Filename: cmd/api/trashHandlers.go
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// listTrashHandler handles GET /v1/admin/trash.  Items can be filtered by
+// type, resource_id, deleted_by and a deleted_at range.
+func (a *app) listTrashHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	var input struct {
+		data.Filters
+	}
+
+	v := validator.New()
+
+	input.Filters.Page = a.getSingleIntegerParameter(qs, "page", 1, v)
+	input.Filters.PageSize = a.getSingleIntegerParameter(qs, "page_size", 50, v)
+	input.Filters.Sort = a.getSingleQueryParameter(qs, "sort", "-deleted_at")
+	input.Filters.Cursor = a.getSingleQueryParameter(qs, "cursor", "")
+	input.Filters.Conditions = a.readConditions(qs, data.TrashFields)
+
+	if data.ValidateQuery(v, input.Filters, data.TrashFields); !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	items, metadata, err := a.models.Trash.GetAll(r.Context(), input.Filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"trash": items, "metadata": metadata}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// restoreTrashHandler handles POST /v1/admin/trash/:type/:id/restore, where
+// type is resource, lesson or comment
+func (a *app) restoreTrashHandler(w http.ResponseWriter, r *http.Request) {
+	itemType := httprouter.ParamsFromContext(r.Context()).ByName("type")
+
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r)
+		return
+	}
+
+	err = a.models.Trash.Restore(r.Context(), itemType, id,
+		a.auditEvent(r, itemType+".restore", itemType, id, nil, nil))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrDuplicateLessonNumber):
+			a.errorResponseJSON(w, r, http.StatusConflict, "another lesson of this resource now has this lesson's number, renumber it before restoring this one")
+		case errors.Is(err, data.ErrParentCommentTrashed):
+			a.errorResponseJSON(w, r, http.StatusConflict, "this reply's comment is in the trash, restore the comment to restore its replies")
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	response := envelope{
+		"message": fmt.Sprintf("%s successfully restored", itemType),
+	}
+
+	err = a.writeJSON(w, http.StatusOK, response, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// startTrashPurge permanently deletes trashed items once they are older than
+// the retention period, checking at startup and then every purge interval.
+// A zero retention keeps the trash forever.  The job stops when ctx is
+// cancelled, abandoning any purge in progress, and is counted in the wait
+// group so shutdown waits for it.
+func (a *app) startTrashPurge(ctx context.Context) {
+	retention := a.config.trash.retention
+	if retention <= 0 || a.config.trash.purgeInterval <= 0 {
+		a.logger.Info("Trash purge disabled")
+		return
+	}
+
+	purge := func() {
+		defer func() {
+			if err := recover(); err != nil {
+				a.logger.Error(fmt.Sprintf("%v", err))
+			}
+		}()
+
+		counts, err := a.models.Trash.Purge(ctx, time.Now().Add(-retention))
+		if err != nil {
+			if ctx.Err() == nil {
+				a.logger.Error("Trash purge failed", "error", err.Error())
+			}
+			return
+		}
+		if counts.Resources+counts.Lessons+counts.Comments > 0 {
+			a.logger.Info("Trash purged", "resources", counts.Resources,
+				"lessons", counts.Lessons, "comments", counts.Comments)
+		}
+	}
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ticker := time.NewTicker(a.config.trash.purgeInterval)
+		defer ticker.Stop()
+
+		for {
+			purge()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
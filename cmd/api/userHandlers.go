@@ -26,7 +26,7 @@ func (a *app) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if this is the first user registering
-	userCount, err := a.models.Users.CountUsers()
+	userCount, err := a.models.Users.CountUsers(r.Context())
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -61,7 +61,7 @@ func (a *app) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Try to insert the user data into the database
-	err = a.models.Users.Insert(user)
+	err = a.models.Users.Insert(r.Context(), user)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
@@ -74,7 +74,7 @@ func (a *app) registerUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Generate a new activation token which expires in 3 days
-	token, err := a.models.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
+	token, err := a.models.Tokens.New(r.Context(), user.ID, 3*24*time.Hour, data.ScopeActivation)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -123,7 +123,7 @@ func (a *app) activateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Let's check if the token provided belongs to the user
-	user, err := a.models.Users.GetForToken(data.ScopeActivation,
+	user, err := a.models.Users.GetForToken(r.Context(), data.ScopeActivation,
 		incomingData.TokenPlaintext)
 
 	if err != nil {
@@ -139,7 +139,7 @@ func (a *app) activateUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	// User provided the right token so activate them
 	a.logger.Info("Activating user", "user_id", user.ID, "username", user.Username, "email", user.Email)
-	err = a.models.Users.UpdateActivation(user.ID, true)
+	err = a.models.Users.UpdateActivation(r.Context(), user.ID, true, nil)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -156,7 +156,7 @@ func (a *app) activateUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	// User has been activated so delete the activation token to
 	// prevent reuse.
-	err = a.models.Tokens.DeleteAllForUser(data.ScopeActivation, user.ID)
+	err = a.models.Tokens.DeleteAllForUser(r.Context(), data.ScopeActivation, user.ID)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -173,6 +173,57 @@ func (a *app) activateUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// updateUserPasswordHandler handles PUT /v1/users/password.  It consumes a
+// password-reset token, stores the new password and signs the user out
+// everywhere, in one transaction.
+func (a *app) updateUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password       string `json:"password"`
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidatePasswordPlaintext(v, input.Password)
+	data.ValidateTokenPlaintext(v, input.TokenPlaintext)
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := &data.User{}
+	err = user.Password.Set(input.Password)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The token is consumed, the password stored and every session revoked
+	// together, so a failure part way cannot leave the token usable
+	err = a.models.Users.ResetPassword(r.Context(), input.TokenPlaintext, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired password reset token")
+			a.failedValidationResponse(w, r, v.Errors)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"message": "your password was successfully reset"}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
 // getUserHandler retrieves a specific user by ID
 func (a *app) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	// Get the ID from the URL
@@ -186,7 +237,7 @@ func (a *app) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	currentUser := a.contextGetUser(r)
 
 	// Check if the current user can access this user's data
-	canAccess, err := a.canAccessUserData(currentUser, id)
+	canAccess, err := a.canAccessUserData(r.Context(), currentUser, id)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -198,7 +249,7 @@ func (a *app) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Try to get the user from the database
-	user, err := a.models.Users.Get(int(id))
+	user, err := a.models.Users.Get(r.Context(), int(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -212,7 +263,7 @@ func (a *app) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	response := envelope{
 		"user": user,
 	}
-	err = a.writeJSON(w, http.StatusOK, response, nil)
+	err = a.writeJSON(w, http.StatusOK, response, etagHeader(user.Version))
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
@@ -228,7 +279,7 @@ func (a *app) getUserByEmailHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Try to get the user from the database
-	user, err := a.models.Users.GetByEmail(email)
+	user, err := a.models.Users.GetByEmail(r.Context(), email)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -258,55 +309,32 @@ func (a *app) getAllUsersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse query parameters for pagination and filtering
+	// Parse query parameters for pagination and filtering, e.g.
+	// ?username=ann&is_active=true&created_at[gte]=2024-01-01&role[in]=admin,DSC
 	var input struct {
-		RegionID    int
-		FormationID int
-		RankID      int
-		IsActive    *bool
-		LastName    string
-		Username    string
 		data.Filters
 	}
 
 	v := validator.New()
 
-	// Parse filter parameters
-	input.RegionID = a.getSingleIntegerParameter(qs, "region_id", 0, v)
-	input.FormationID = a.getSingleIntegerParameter(qs, "formation_id", 0, v)
-	input.RankID = a.getSingleIntegerParameter(qs, "rank_id", 0, v)
-	input.LastName = a.getSingleQueryParameter(qs, "last_name", "")
-	input.Username = a.getSingleQueryParameter(qs, "username", "")
-
-	// Parse is_active parameter
-	if activeStr := qs.Get("is_active"); activeStr != "" {
-		if activeStr == "true" {
-			active := true
-			input.IsActive = &active
-		} else if activeStr == "false" {
-			active := false
-			input.IsActive = &active
-		} else {
-			v.AddError("is_active", "must be true or false")
-		}
-	}
+	input.Filters.Conditions = a.readConditions(qs, data.UserFields)
 
 	// Parse pagination parameters
 	input.Filters.Page = a.getSingleIntegerParameter(qs, "page", 1, v)
 	input.Filters.PageSize = a.getSingleIntegerParameter(qs, "page_size", 20, v)
 
-	// Parse sort parameter
+	// Parse sort parameter; several fields may be given, e.g. "-created_at,username"
 	input.Filters.Sort = a.getSingleQueryParameter(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "user_id", "last_name", "first_name", "username", "email", "created_at", "-id", "-user_id", "-last_name", "-first_name", "-username", "-email", "-created_at"}
+	input.Filters.Cursor = a.getSingleQueryParameter(qs, "cursor", "")
 
 	// Validate filters
-	if data.ValidateFilters(v, input.Filters); !v.IsEmpty() {
+	if data.ValidateQuery(v, input.Filters, data.UserFields); !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
 	// Get users from database
-	users, metadata, err := a.models.Users.GetAll(input.RegionID, input.FormationID, input.RankID, input.IsActive, input.LastName, input.Username, input.Filters)
+	users, metadata, err := a.models.Users.GetAll(r.Context(), input.Filters)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -335,7 +363,7 @@ func (a *app) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 	currentUser := a.contextGetUser(r)
 
 	// Check if the current user can access this user's data
-	canAccess, err := a.canAccessUserData(currentUser, id)
+	canAccess, err := a.canAccessUserData(r.Context(), currentUser, id)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -347,7 +375,7 @@ func (a *app) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get the existing user from the database
-	user, err := a.models.Users.Get(int(id))
+	user, err := a.models.Users.Get(r.Context(), int(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -358,6 +386,11 @@ func (a *app) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !ifMatch(r, user.Version) {
+		a.preconditionFailedResponse(w, r)
+		return
+	}
+
 	// Parse the request body for updates
 	var input struct {
 		Username *string `json:"username"`
@@ -373,17 +406,22 @@ func (a *app) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if user is trying to update role_id/is_active and is not an Administrator
+	// Check if user is trying to update role_id/is_active without user:manage
 	if input.RoleID != nil || input.IsActive != nil {
-		// Get the current user's role
-		currentUserRole, err := a.models.Roles.Get(currentUser.RoleID)
+		// Check whether the current user's role grants user:manage
+		canManage, mfaMissing, err := a.userPermission(r.Context(), currentUser, "user:manage")
 		if err != nil {
 			a.serverErrorResponse(w, r, err)
 			return
 		}
 
-		// Only Administrators can change roles or activation status
-		if currentUserRole.RoleName != "Administrator" && currentUserRole.RoleName != "admin" {
+		if mfaMissing {
+			a.mfaEnrollmentRequiredResponse(w, r)
+			return
+		}
+
+		// Only user managers can change roles or activation status
+		if !canManage {
 			v := validator.New()
 			if input.RoleID != nil {
 				v.AddError("role_id", "only administrators can change user roles")
@@ -397,6 +435,13 @@ func (a *app) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Role and activation changes are recorded in the audit log
+	var audit *data.AuditEvent
+	if input.RoleID != nil || input.IsActive != nil {
+		audit = a.auditEvent(r, "user.update", "user", user.ID,
+			envelope{"role_id": user.RoleID, "is_active": user.IsActive}, nil)
+	}
+
 	// Update only the fields that were provided
 	if input.Username != nil {
 		user.Username = *input.Username
@@ -427,8 +472,12 @@ func (a *app) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if audit != nil {
+		audit.After = envelope{"role_id": user.RoleID, "is_active": user.IsActive}
+	}
+
 	// Try to update the user in the database
-	err = a.models.Users.Update(user)
+	err = a.models.Users.Update(r.Context(), user, audit)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
@@ -445,7 +494,7 @@ func (a *app) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 	response := envelope{
 		"user": user,
 	}
-	err = a.writeJSON(w, http.StatusOK, response, nil)
+	err = a.writeJSON(w, http.StatusOK, response, etagHeader(user.Version))
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
@@ -460,8 +509,19 @@ func (a *app) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	user, err := a.models.Users.Get(r.Context(), int(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			a.notFoundResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	// Try to delete the user from the database
-	err = a.models.Users.Delete(int(id))
+	err = a.models.Users.Delete(r.Context(), int(id), a.auditEvent(r, "user.delete", "user", id, user, nil))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
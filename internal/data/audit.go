@@ -0,0 +1,191 @@
+//filename: internal/data/audit.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AuditEvent records one privileged action: who did what to which record,
+// with the record as it was before and after.  Events are written in the same
+// transaction as the change they describe.
+type AuditEvent struct {
+	ID            int64     `json:"audit_id"`
+	ActorID       int64     `json:"actor_id,omitempty"`
+	ActorUsername string    `json:"actor_username,omitempty"`
+	Action        string    `json:"action"`
+	TargetType    string    `json:"target_type"`
+	TargetID      int64     `json:"target_id"`
+	Before        any       `json:"before,omitempty"`
+	After         any       `json:"after,omitempty"`
+	IPAddress     string    `json:"ip_address,omitempty"`
+	RequestID     string    `json:"request_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// AuditFilter narrows the audit log.  Zero values are ignored.
+type AuditFilter struct {
+	ActorID    int64
+	Action     string
+	TargetType string
+	TargetID   int64
+	From       time.Time
+	To         time.Time
+}
+
+// insertAuditEvent stores an event using either the pool or a transaction.
+// A nil event is ignored so unprivileged callers can share the same method.
+func insertAuditEvent(ctx context.Context, db interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+}, event *AuditEvent) error {
+	if event == nil {
+		return nil
+	}
+
+	before, err := marshalAuditState(event.Before)
+	if err != nil {
+		return err
+	}
+	after, err := marshalAuditState(event.After)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_events (actor_id, action, target_type, target_id, before_state, after_state, ip_address, request_id)
+		VALUES (NULLIF($1, 0), $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''))`
+
+	args := []any{event.ActorID, event.Action, event.TargetType, event.TargetID,
+		before, after, event.IPAddress, event.RequestID}
+
+	_, err = db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// marshalAuditState turns a before/after value into JSONB, or NULL if unset
+func marshalAuditState(state any) (any, error) {
+	if state == nil {
+		return nil, nil
+	}
+	js, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	// A typed nil pointer marshals to null; store that as SQL NULL too
+	if string(js) == "null" {
+		return nil, nil
+	}
+	return string(js), nil
+}
+
+type AuditModel struct {
+	DB *sql.DB
+}
+
+// Insert records an event on its own, for actions with no database change
+// of their own to share a transaction with
+func (m AuditModel) Insert(ctx context.Context, event *AuditEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return insertAuditEvent(ctx, m.DB, event)
+}
+
+// AuditFields are the fields the audit log can be sorted on
+var AuditFields = Fields{
+	"created_at": {Column: "e.created_at", Type: TimeField, Sortable: true},
+}
+
+// GetAll returns audit events, newest first by default
+func (m AuditModel) GetAll(ctx context.Context, filter AuditFilter, filters Filters) ([]*AuditEvent, Metadata, error) {
+	b := &queryBuilder{}
+
+	if filter.ActorID > 0 {
+		b.add("e.actor_id = %s", filter.ActorID)
+	}
+	if filter.Action != "" {
+		b.add("e.action = %s", filter.Action)
+	}
+	if filter.TargetType != "" {
+		b.add("e.target_type = %s", filter.TargetType)
+	}
+	if filter.TargetID > 0 {
+		b.add("e.target_id = %s", filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		b.add("e.created_at >= %s", filter.From)
+	}
+	if !filter.To.IsZero() {
+		b.add("e.created_at < %s", filter.To)
+	}
+
+	page := filters.paginate(b, filters.sortKeys(AuditFields), "e.audit_id")
+
+	query := fmt.Sprintf(`
+		SELECT %s, e.audit_id, COALESCE(e.actor_id, 0), COALESCE(u.username, ''),
+		       e.action, e.target_type, e.target_id, e.before_state, e.after_state,
+		       COALESCE(e.ip_address, ''), COALESCE(e.request_id, ''), e.created_at
+		FROM audit_events e
+		LEFT JOIN users u ON u.user_id = e.actor_id
+		WHERE %s
+		ORDER BY %s
+		%s`, page.count, b.where(), page.orderBy, page.limit)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	events := []*AuditEvent{}
+
+	for rows.Next() {
+		var event AuditEvent
+		var before, after []byte
+
+		err := rows.Scan(
+			&totalRecords,
+			&event.ID,
+			&event.ActorID,
+			&event.ActorUsername,
+			&event.Action,
+			&event.TargetType,
+			&event.TargetID,
+			&before,
+			&after,
+			&event.IPAddress,
+			&event.RequestID,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if before != nil {
+			event.Before = json.RawMessage(before)
+		}
+		if after != nil {
+			event.After = json.RawMessage(after)
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	events, metadata := pageResults(filters, page, events, totalRecords, func(e *AuditEvent) (map[string]any, int64) {
+		return map[string]any{"created_at": e.CreatedAt}, e.ID
+	})
+
+	return events, metadata, nil
+}
@@ -5,6 +5,7 @@ package data
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
 )
 
@@ -22,11 +23,17 @@ type ResourceCommentModel struct {
 	DB *sql.DB
 }
 
-// Insert a new comment
-func (m ResourceCommentModel) Insert(comment *ResourceComment) error {
+// Insert a new comment.  A reply's parent must be a live comment on the same
+// resource, or ErrRecordNotFound is returned, so no reply is left live under
+// a comment in the trash.
+func (m ResourceCommentModel) Insert(ctx context.Context, comment *ResourceComment) error {
 	query := `
 		INSERT INTO resource_comments (resource_id, user_id, parent_comment_id, content)
-		VALUES ($1, $2, $3, $4)
+		SELECT $1::int, $2::int, $3::int, $4::text
+		WHERE $3 IS NULL OR EXISTS (
+			SELECT 1 FROM resource_comments
+			WHERE comment_id = $3 AND resource_id = $1 AND deleted_at IS NULL
+		)
 		RETURNING comment_id, created_at, updated_at`
 
 	args := []any{
@@ -36,26 +43,38 @@ func (m ResourceCommentModel) Insert(comment *ResourceComment) error {
 		comment.Content,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&comment.ID, &comment.CreatedAt, &comment.UpdatedAt)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&comment.ID, &comment.CreatedAt, &comment.UpdatedAt)
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Get a comment by ID
-func (m ResourceCommentModel) Get(id int64) (*ResourceComment, error) {
+func (m ResourceCommentModel) Get(ctx context.Context, id int64) (*ResourceComment, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
+	// A comment whose resource is in the trash is treated as trashed with it
 	query := `
-		SELECT comment_id, resource_id, user_id, parent_comment_id, content, created_at, updated_at
-		FROM resource_comments
-		WHERE comment_id = $1`
+		SELECT c.comment_id, c.resource_id, c.user_id, c.parent_comment_id, c.content, c.created_at, c.updated_at
+		FROM resource_comments c
+		JOIN resources r ON r.resource_id = c.resource_id AND r.deleted_at IS NULL
+		WHERE c.comment_id = $1 AND c.deleted_at IS NULL`
 
 	var comment ResourceComment
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, id).Scan(
@@ -80,27 +99,41 @@ func (m ResourceCommentModel) Get(id int64) (*ResourceComment, error) {
 	return &comment, nil
 }
 
-// GetByResource returns all comments for a resource
-func (m ResourceCommentModel) GetByResource(resourceID int64) ([]*ResourceComment, error) {
-	query := `
-		SELECT comment_id, resource_id, user_id, parent_comment_id, content, created_at, updated_at
+// CommentFields are the fields a resource's comments can be sorted on
+var CommentFields = Fields{
+	"comment_id": {Column: "comment_id", Type: IntField, Sortable: true},
+	"created_at": {Column: "created_at", Type: TimeField, Sortable: true},
+}
+
+// GetByResource returns a page of comments for a resource
+func (m ResourceCommentModel) GetByResource(ctx context.Context, resourceID int64, filters Filters) ([]*ResourceComment, Metadata, error) {
+	b := &queryBuilder{}
+	b.add("resource_id = %s", resourceID)
+	b.add("deleted_at IS NULL")
+	page := filters.paginate(b, filters.sortKeys(CommentFields), "comment_id")
+
+	query := fmt.Sprintf(`
+		SELECT %s, comment_id, resource_id, user_id, parent_comment_id, content, created_at, updated_at
 		FROM resource_comments
-		WHERE resource_id = $1
-		ORDER BY created_at ASC`
+		WHERE %s
+		ORDER BY %s
+		%s`, page.count, b.where(), page.orderBy, page.limit)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query, resourceID)
+	rows, err := m.DB.QueryContext(ctx, query, b.args...)
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 	defer rows.Close()
 
+	totalRecords := 0
 	comments := []*ResourceComment{}
 	for rows.Next() {
 		var comment ResourceComment
 		err := rows.Scan(
+			&totalRecords,
 			&comment.ID,
 			&comment.ResourceID,
 			&comment.UserID,
@@ -110,23 +143,33 @@ func (m ResourceCommentModel) GetByResource(resourceID int64) ([]*ResourceCommen
 			&comment.UpdatedAt,
 		)
 		if err != nil {
-			return nil, err
+			return nil, Metadata{}, err
 		}
 		comments = append(comments, &comment)
 	}
 
-	return comments, rows.Err()
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	comments, metadata := pageResults(filters, page, comments, totalRecords, func(c *ResourceComment) (map[string]any, int64) {
+		return map[string]any{"comment_id": c.ID, "created_at": c.CreatedAt}, c.ID
+	})
+
+	return comments, metadata, nil
 }
 
-// GetReplies returns all replies to a comment
-func (m ResourceCommentModel) GetReplies(commentID int64) ([]*ResourceComment, error) {
+// GetReplies returns all replies to a comment, none if its resource is in
+// the trash
+func (m ResourceCommentModel) GetReplies(ctx context.Context, commentID int64) ([]*ResourceComment, error) {
 	query := `
-		SELECT comment_id, resource_id, user_id, parent_comment_id, content, created_at, updated_at
-		FROM resource_comments
-		WHERE parent_comment_id = $1
-		ORDER BY created_at ASC`
+		SELECT c.comment_id, c.resource_id, c.user_id, c.parent_comment_id, c.content, c.created_at, c.updated_at
+		FROM resource_comments c
+		JOIN resources r ON r.resource_id = c.resource_id AND r.deleted_at IS NULL
+		WHERE c.parent_comment_id = $1 AND c.deleted_at IS NULL
+		ORDER BY c.created_at ASC`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	rows, err := m.DB.QueryContext(ctx, query, commentID)
@@ -157,11 +200,11 @@ func (m ResourceCommentModel) GetReplies(commentID int64) ([]*ResourceComment, e
 }
 
 // Update a comment
-func (m ResourceCommentModel) Update(comment *ResourceComment) error {
+func (m ResourceCommentModel) Update(ctx context.Context, comment *ResourceComment) error {
 	query := `
 		UPDATE resource_comments
 		SET content = $1
-		WHERE comment_id = $2
+		WHERE comment_id = $2 AND deleted_at IS NULL
 		RETURNING comment_id, updated_at`
 
 	args := []any{
@@ -169,7 +212,7 @@ func (m ResourceCommentModel) Update(comment *ResourceComment) error {
 		comment.ID,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&comment.ID, &comment.UpdatedAt)
@@ -185,18 +228,32 @@ func (m ResourceCommentModel) Update(comment *ResourceComment) error {
 	return nil
 }
 
-// Delete a comment
-func (m ResourceCommentModel) Delete(id int64) error {
+// Delete moves a comment and its replies to the trash.  They stay in the
+// database, hidden, until they are restored or purged together.
+func (m ResourceCommentModel) Delete(ctx context.Context, id, deletedBy int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
-	query := `DELETE FROM resource_comments WHERE comment_id = $1`
+	// The replies are trashed at the same time as the comment, which is how
+	// TrashModel.Restore finds them again
+	query := `
+		WITH RECURSIVE thread AS (
+			SELECT comment_id FROM resource_comments
+			WHERE comment_id = $1 AND deleted_at IS NULL
+			UNION ALL
+			SELECT c.comment_id FROM resource_comments c
+			JOIN thread t ON c.parent_comment_id = t.comment_id
+			WHERE c.deleted_at IS NULL
+		)
+		UPDATE resource_comments
+		SET deleted_at = NOW(), deleted_by = NULLIF($2, 0)
+		WHERE comment_id IN (SELECT comment_id FROM thread)`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	result, err := m.DB.ExecContext(ctx, query, id, deletedBy)
 	if err != nil {
 		return err
 	}
@@ -20,7 +20,7 @@ type ContributionModel struct {
 }
 
 // Insert a new contribution into the database
-func (m ContributionModel) Insert(contribution *Contribution) error {
+func (m ContributionModel) Insert(ctx context.Context, contribution *Contribution) error {
 	query := `
 		INSERT INTO contributions (resource_id, score)
 		VALUES ($1, $2)
@@ -31,14 +31,14 @@ func (m ContributionModel) Insert(contribution *Contribution) error {
 		contribution.Score,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	return m.DB.QueryRowContext(ctx, query, args...).Scan(&contribution.ID, &contribution.CalculatedAt)
 }
 
 // Get a contribution by ID
-func (m ContributionModel) Get(id int64) (*Contribution, error) {
+func (m ContributionModel) Get(ctx context.Context, id int64) (*Contribution, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -50,7 +50,7 @@ func (m ContributionModel) Get(id int64) (*Contribution, error) {
 
 	var contribution Contribution
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, id).Scan(
@@ -73,7 +73,7 @@ func (m ContributionModel) Get(id int64) (*Contribution, error) {
 }
 
 // Get a contribution by resource ID
-func (m ContributionModel) GetByResourceID(resourceID int64) (*Contribution, error) {
+func (m ContributionModel) GetByResourceID(ctx context.Context, resourceID int64) (*Contribution, error) {
 	if resourceID < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -85,7 +85,7 @@ func (m ContributionModel) GetByResourceID(resourceID int64) (*Contribution, err
 
 	var contribution Contribution
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, resourceID).Scan(
@@ -108,7 +108,7 @@ func (m ContributionModel) GetByResourceID(resourceID int64) (*Contribution, err
 }
 
 // Update a contribution
-func (m ContributionModel) Update(contribution *Contribution) error {
+func (m ContributionModel) Update(ctx context.Context, contribution *Contribution) error {
 	query := `
 		UPDATE contributions
 		SET score = $1, calculated_at = CURRENT_TIMESTAMP
@@ -120,7 +120,7 @@ func (m ContributionModel) Update(contribution *Contribution) error {
 		contribution.ResourceID,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&contribution.ID, &contribution.CalculatedAt)
@@ -137,7 +137,7 @@ func (m ContributionModel) Update(contribution *Contribution) error {
 }
 
 // Delete a contribution
-func (m ContributionModel) Delete(id int64) error {
+func (m ContributionModel) Delete(ctx context.Context, id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
@@ -146,7 +146,7 @@ func (m ContributionModel) Delete(id int64) error {
 		DELETE FROM contributions
 		WHERE contribution_id = $1`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	result, err := m.DB.ExecContext(ctx, query, id)
@@ -167,14 +167,14 @@ func (m ContributionModel) Delete(id int64) error {
 }
 
 // Get all contributions ordered by score
-func (m ContributionModel) GetAll(filters Filters) ([]*Contribution, Metadata, error) {
+func (m ContributionModel) GetAll(ctx context.Context, filters Filters) ([]*Contribution, Metadata, error) {
 	query := `
 		SELECT COUNT(*) OVER(), contribution_id, resource_id, score, calculated_at
 		FROM contributions
 		ORDER BY score DESC
 		LIMIT $1 OFFSET $2`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	args := []any{filters.limit(), filters.offset()}
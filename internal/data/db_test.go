@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestDB connects to TEST_DB_DSN, a scratch database with every migration
+// applied.  Tests that need a database are skipped without one.  Each test
+// removes the rows it creates.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), QueryTimeout)
+	defer cancel()
+	if err = db.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// insertTestResource creates a user and a draft resource they contribute,
+// both deleted again when the test ends
+func insertTestResource(t *testing.T, db *sql.DB) (userID, resourceID int64) {
+	t.Helper()
+
+	ctx := context.Background()
+	name := fmt.Sprintf("test%d", time.Now().UnixNano())
+
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO users (username, email, password_hash)
+		VALUES ($1, $1 || '@example.org', 'x')
+		RETURNING user_id`, name).Scan(&userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM users WHERE user_id = $1", userID) })
+
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO resources (title, category, contributor_id)
+		VALUES ($1, 'LessonPlan', $2)
+		RETURNING resource_id`, name, userID).Scan(&resourceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM resources WHERE resource_id = $1", resourceID) })
+
+	return userID, resourceID
+}
@@ -7,3 +7,8 @@ import (
 
 var ErrRecordNotFound = errors.New("record not found")
 var ErrEditConflict = errors.New("edit conflict")
+var ErrUnknownSubject = errors.New("unknown subject")
+var ErrTokenReused = errors.New("refresh token reused")
+var ErrDuplicateSlug = errors.New("duplicate slug")
+var ErrParentCommentTrashed = errors.New("parent comment is in the trash")
+var ErrDuplicateLessonNumber = errors.New("duplicate lesson number")
@@ -0,0 +1,64 @@
+//filename: internal/data/export.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// exportFetchSize is how many rows an export reads from its cursor at a time
+const exportFetchSize = 500
+
+// exportRows runs query through a server-side cursor in a read-only
+// transaction and calls scan for each row.  Only one batch of rows is held at
+// a time, so an export of any size streams in constant memory.  Returning an
+// error from scan stops the export.
+func exportRows(ctx context.Context, db *sql.DB, query string, args []any, scan func(*sql.Rows) error) error {
+	ctx, cancel := context.WithTimeout(ctx, ExportTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DECLARE export_rows NO SCROLL CURSOR FOR "+query, args...)
+	if err != nil {
+		return err
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM export_rows", exportFetchSize)
+	for {
+		n, err := exportBatch(ctx, tx, fetch, scan)
+		if err != nil {
+			return err
+		}
+		if n < exportFetchSize {
+			break
+		}
+	}
+
+	return tx.Commit()
+}
+
+// exportBatch fetches the next batch from the cursor and returns its size
+func exportBatch(ctx context.Context, tx *sql.Tx, fetch string, scan func(*sql.Rows) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		n++
+		if err := scan(rows); err != nil {
+			return n, err
+		}
+	}
+
+	return n, rows.Err()
+}
@@ -1,6 +1,11 @@
 package data
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
@@ -9,32 +14,45 @@ import (
 // The Filters type will contain the fields related to pagination
 // and eventually the fields related to sorting.
 type Filters struct {
-	Page         int      // which page number does the client want
-	PageSize     int      // how many records per page
-	Sort         string   // which column do we want to sort by
-	SortSafelist []string // list of columns that are allowed to be sorted by
+	Page         int         // which page number does the client want
+	PageSize     int         // how many records per page
+	Sort         string      // which fields to sort by, e.g. "-created_at,title"
+	SortSafelist []string    // sorts allowed by ValidateFilters
+	Cursor       string      // opaque position from next_cursor/prev_cursor; replaces Page
+	Conditions   []Condition // filters checked by ValidateQuery
 }
 
 // type to hold page metadata
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
 }
 
 // Next we validate page and PageSize
 // We follow the same approach that we used to validate a Comment
 func ValidateFilters(v *validator.Validator, f Filters) {
+	validatePage(v, f)
+	// Check if sort fields provided are valid
+	v.Check(validator.PermittedValue(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+}
+
+// validatePage checks the page, page size and cursor
+func validatePage(v *validator.Validator, f Filters) {
 	v.Check(f.Page > 0, "page", "must be greater than zero")
 	v.Check(f.Page <= 500, "page", "must be a maximum of 500")
 	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
-	// Check if sort fields provided are valid
-	// We will implement PermittedValue() later
-	v.Check(validator.PermittedValue(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
-
+	// A cursor only makes sense with the sort order it was issued for
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		v.Check(err == nil, "cursor", "is not valid")
+		v.Check(err != nil || c.Sort == f.Sort, "cursor", "was issued for a different sort")
+	}
 }
 
 // calculate how many records to send back
@@ -62,30 +80,193 @@ func calculateMetadata(totalRecords, page, pageSize int) Metadata {
 	}
 }
 
-// Implement the sorting feature
-func (f Filters) sortColumn() string {
-	for _, safeValue := range f.SortSafelist {
-		if f.Sort == safeValue {
-			column := strings.TrimPrefix(f.Sort, "-")
-			// Map API field names to database column names
-			switch column {
-			// User table mappings
-			case "id":
-				return "user_id"
-			default:
-				return column
+// cursor is a position in a sorted list: the sort fields' values and the ID
+// of the row a page ended (or started) at.  Clients treat it as opaque.
+type cursor struct {
+	Sort   string         `json:"s"`
+	Values map[string]any `json:"v"`
+	ID     int64          `json:"i"`
+	Prev   bool           `json:"p,omitempty"` // page backwards from this position
+}
+
+func (c cursor) encode() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+
+	// Keep numbers as written so large IDs and ranks survive the round trip
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil {
+		return c, err
+	}
+	if c.ID < 1 {
+		return c, fmt.Errorf("cursor has no row ID")
+	}
+
+	return c, nil
+}
+
+// pagination is the SQL a list query needs for the requested page
+type pagination struct {
+	count   string // select expression for the total; 0 when paging by cursor
+	orderBy string
+	limit   string
+	keys    []sortKey
+}
+
+// paginate works out the ORDER BY and LIMIT for a list sorted by keys, with
+// idColumn breaking ties.  With a cursor the page is found by seeking past
+// the cursor's row, added to b as a condition, instead of counting through an
+// offset, and the total is not computed, so deep pages cost the same as the
+// first.  One extra row is fetched so pageResults can tell whether there is
+// more.
+func (f Filters) paginate(b *queryBuilder, keys []sortKey, idColumn string) pagination {
+	p := pagination{count: "COUNT(*) OVER()", keys: keys}
+
+	all := withIDKey(keys, idColumn)
+
+	var c cursor
+	if f.Cursor != "" {
+		c, _ = decodeCursor(f.Cursor) // checked by ValidateFilters
+
+		// A backwards page is read in reverse and flipped by pageResults
+		if c.Prev {
+			for i := range all {
+				all[i].desc = !all[i].desc
 			}
 		}
+		b.conditions = append(b.conditions, keysetCondition(b, all, c))
+		p.count = "0"
+	}
+
+	p.orderBy = orderClause(all)
+
+	if f.Cursor == "" {
+		p.limit = fmt.Sprintf("LIMIT %s OFFSET %s", b.param(f.limit()+1), b.param(f.offset()))
+	} else {
+		p.limit = fmt.Sprintf("LIMIT %s", b.param(f.limit()+1))
+	}
+
+	return p
+}
+
+// withIDKey adds idColumn to the sort keys to break ties.  The ID sorts the
+// same way as the last key.
+func withIDKey(keys []sortKey, idColumn string) []sortKey {
+	idDesc := len(keys) > 0 && keys[len(keys)-1].desc
+	return append(slices.Clone(keys), sortKey{field: "id", column: idColumn, desc: idDesc})
+}
+
+// orderClause is the body of an ORDER BY for the sort keys
+func orderClause(keys []sortKey) string {
+	order := make([]string, len(keys))
+	for i, key := range keys {
+		order[i] = key.column + " ASC"
+		if key.desc {
+			order[i] = key.column + " DESC"
+		}
 	}
-	// don't allow the operation to continue
-	// if case of SQL injection attack
-	panic("unsafe sort parameter: " + f.Sort)
+	return strings.Join(order, ", ")
 }
 
-// Get the sort order
-func (f Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
-		return "DESC"
+// keysetCondition matches the rows after the cursor in the order given by
+// keys.  When every key sorts the same way it is a single row comparison,
+// which an index on those columns can answer directly.
+func keysetCondition(b *queryBuilder, keys []sortKey, c cursor) string {
+	columns := make([]string, len(keys))
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		columns[i] = key.column
+		if key.field == "id" && i == len(keys)-1 {
+			values[i] = b.param(c.ID)
+		} else {
+			values[i] = b.param(c.Values[key.field])
+		}
+	}
+
+	op := func(key sortKey) string {
+		if key.desc {
+			return "<"
+		}
+		return ">"
+	}
+
+	uniform := true
+	for _, key := range keys {
+		uniform = uniform && key.desc == keys[0].desc
+	}
+	if uniform {
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(columns, ", "), op(keys[0]), strings.Join(values, ", "))
+	}
+
+	// (a > x) OR (a = x AND b < y) OR (a = x AND b = y AND id > z) ...
+	var terms []string
+	for i, key := range keys {
+		var term []string
+		for j := range i {
+			term = append(term, columns[j]+" = "+values[j])
+		}
+		term = append(term, columns[i]+" "+op(key)+" "+values[i])
+		terms = append(terms, "("+strings.Join(term, " AND ")+")")
 	}
-	return "ASC"
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
+
+// pageResults trims the extra row fetched by paginate, puts a backwards page
+// back in order and works out the metadata, including the cursors for the
+// pages either side.  values returns an item's sort field values by field
+// name, and its ID.
+func pageResults[T any](f Filters, p pagination, items []T, totalRecords int, values func(T) (map[string]any, int64)) ([]T, Metadata) {
+	var c cursor
+	if f.Cursor != "" {
+		c, _ = decodeCursor(f.Cursor)
+	}
+
+	more := len(items) > f.limit()
+	if more {
+		items = items[:f.limit()]
+	}
+	if c.Prev {
+		slices.Reverse(items)
+	}
+
+	metadata := Metadata{PageSize: f.PageSize}
+	if f.Cursor == "" {
+		metadata = calculateMetadata(totalRecords, f.Page, f.PageSize)
+	}
+	if len(items) == 0 {
+		return items, metadata
+	}
+
+	// Coming from a cursor means there is a page on the side we came from
+	hasNext, hasPrev := more, f.Cursor != "" || f.Page > 1
+	if c.Prev {
+		hasNext, hasPrev = true, more
+	}
+
+	position := func(item T, prev bool) string {
+		all, id := values(item)
+		sortValues := make(map[string]any, len(p.keys))
+		for _, key := range p.keys {
+			sortValues[key.field] = all[key.field]
+		}
+		return cursor{Sort: f.Sort, Values: sortValues, ID: id, Prev: prev}.encode()
+	}
+	if hasNext {
+		metadata.NextCursor = position(items[len(items)-1], false)
+	}
+	if hasPrev {
+		metadata.PrevCursor = position(items[0], true)
+	}
+
+	return items, metadata
 }
@@ -0,0 +1,300 @@
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+)
+
+func TestKeysetCondition(t *testing.T) {
+	c := cursor{Values: map[string]any{"title": "Fractions", "created_at": "2024-05-01T00:00:00Z"}, ID: 7}
+
+	tests := []struct {
+		name string
+		keys []sortKey
+		want string
+		args []any
+	}{
+		{
+			name: "ascending",
+			keys: []sortKey{{field: "title", column: "title"}, {field: "id", column: "id"}},
+			want: "(title, id) > ($1, $2)",
+			args: []any{"Fractions", int64(7)},
+		},
+		{
+			name: "descending",
+			keys: []sortKey{{field: "created_at", column: "created_at", desc: true}, {field: "id", column: "id", desc: true}},
+			want: "(created_at, id) < ($1, $2)",
+			args: []any{"2024-05-01T00:00:00Z", int64(7)},
+		},
+		{
+			name: "mixed directions",
+			keys: []sortKey{
+				{field: "title", column: "title"},
+				{field: "created_at", column: "created_at", desc: true},
+				{field: "id", column: "id", desc: true},
+			},
+			want: "((title > $1) OR (title = $1 AND created_at < $2) OR (title = $1 AND created_at = $2 AND id < $3))",
+			args: []any{"Fractions", "2024-05-01T00:00:00Z", int64(7)},
+		},
+		{
+			name: "id only",
+			keys: []sortKey{{field: "id", column: "r.resource_id"}},
+			want: "(r.resource_id) > ($1)",
+			args: []any{int64(7)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &queryBuilder{}
+			got := keysetCondition(b, tt.keys, c)
+			if got != tt.want {
+				t.Errorf("condition = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(b.args, tt.args) {
+				t.Errorf("args = %#v, want %#v", b.args, tt.args)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	keys := []sortKey{{field: "created_at", column: "created_at", desc: true}}
+	position := map[string]any{"created_at": "2024-05-01T00:00:00Z"}
+
+	tests := []struct {
+		name      string
+		filters   Filters
+		count     string
+		orderBy   string
+		limit     string
+		condition string
+		args      []any
+	}{
+		{
+			name:    "first page",
+			filters: Filters{Page: 1, PageSize: 20, Sort: "-created_at"},
+			count:   "COUNT(*) OVER()",
+			orderBy: "created_at DESC, id DESC",
+			limit:   "LIMIT $1 OFFSET $2",
+			args:    []any{21, 0},
+		},
+		{
+			name:    "offset page",
+			filters: Filters{Page: 3, PageSize: 20, Sort: "-created_at"},
+			count:   "COUNT(*) OVER()",
+			orderBy: "created_at DESC, id DESC",
+			limit:   "LIMIT $1 OFFSET $2",
+			args:    []any{21, 40},
+		},
+		{
+			name: "forward cursor",
+			filters: Filters{Page: 1, PageSize: 20, Sort: "-created_at",
+				Cursor: cursor{Sort: "-created_at", Values: position, ID: 9}.encode()},
+			count:     "0",
+			orderBy:   "created_at DESC, id DESC",
+			limit:     "LIMIT $3",
+			condition: "(created_at, id) < ($1, $2)",
+			args:      []any{"2024-05-01T00:00:00Z", int64(9), 21},
+		},
+		{
+			name: "backward cursor",
+			filters: Filters{Page: 1, PageSize: 20, Sort: "-created_at",
+				Cursor: cursor{Sort: "-created_at", Values: position, ID: 9, Prev: true}.encode()},
+			count:     "0",
+			orderBy:   "created_at ASC, id ASC",
+			limit:     "LIMIT $3",
+			condition: "(created_at, id) > ($1, $2)",
+			args:      []any{"2024-05-01T00:00:00Z", int64(9), 21},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &queryBuilder{}
+			p := tt.filters.paginate(b, keys, "id")
+
+			if p.count != tt.count {
+				t.Errorf("count = %q, want %q", p.count, tt.count)
+			}
+			if p.orderBy != tt.orderBy {
+				t.Errorf("orderBy = %q, want %q", p.orderBy, tt.orderBy)
+			}
+			if p.limit != tt.limit {
+				t.Errorf("limit = %q, want %q", p.limit, tt.limit)
+			}
+
+			var condition string
+			if len(b.conditions) > 0 {
+				condition = b.conditions[len(b.conditions)-1]
+			}
+			if condition != tt.condition {
+				t.Errorf("condition = %q, want %q", condition, tt.condition)
+			}
+			if !reflect.DeepEqual(b.args, tt.args) {
+				t.Errorf("args = %#v, want %#v", b.args, tt.args)
+			}
+		})
+	}
+}
+
+func TestPageResults(t *testing.T) {
+	type row struct{ id int64 }
+	rows := func(ids ...int64) []row {
+		r := make([]row, len(ids))
+		for i, id := range ids {
+			r[i] = row{id}
+		}
+		return r
+	}
+	ids := func(items []row) []int64 {
+		out := make([]int64, len(items))
+		for i, item := range items {
+			out[i] = item.id
+		}
+		return out
+	}
+	values := func(r row) (map[string]any, int64) {
+		return map[string]any{"rank": r.id * 10}, r.id
+	}
+	keys := []sortKey{{field: "rank", column: "rank"}}
+	at := func(id int64, prev bool) string {
+		return cursor{Sort: "rank", Values: map[string]any{"rank": id * 10}, ID: id, Prev: prev}.encode()
+	}
+
+	tests := []struct {
+		name     string
+		filters  Filters
+		items    []row
+		total    int
+		want     []int64
+		next     string
+		prev     string
+		lastPage int
+	}{
+		{
+			name:     "first page with more",
+			filters:  Filters{Page: 1, PageSize: 2, Sort: "rank"},
+			items:    rows(1, 2, 3),
+			total:    5,
+			want:     []int64{1, 2},
+			next:     at(2, false),
+			lastPage: 3,
+		},
+		{
+			name:     "last offset page",
+			filters:  Filters{Page: 3, PageSize: 2, Sort: "rank"},
+			items:    rows(5),
+			total:    5,
+			want:     []int64{5},
+			prev:     at(5, true),
+			lastPage: 3,
+		},
+		{
+			name:    "forward cursor at the end",
+			filters: Filters{Page: 1, PageSize: 2, Sort: "rank", Cursor: at(2, false)},
+			items:   rows(3, 4),
+			want:    []int64{3, 4},
+			prev:    at(3, true),
+		},
+		{
+			name:    "backward page with more before it",
+			filters: Filters{Page: 1, PageSize: 2, Sort: "rank", Cursor: at(5, true)},
+			items:   rows(4, 3, 2),
+			want:    []int64{3, 4},
+			next:    at(4, false),
+			prev:    at(3, true),
+		},
+		{
+			name:    "backward page reaching the start",
+			filters: Filters{Page: 1, PageSize: 2, Sort: "rank", Cursor: at(3, true)},
+			items:   rows(2, 1),
+			want:    []int64{1, 2},
+			next:    at(2, false),
+		},
+		{
+			name:    "empty",
+			filters: Filters{Page: 1, PageSize: 2, Sort: "rank"},
+			want:    []int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, metadata := pageResults(tt.filters, pagination{keys: keys}, tt.items, tt.total, values)
+
+			if !reflect.DeepEqual(ids(got), tt.want) {
+				t.Errorf("items = %v, want %v", ids(got), tt.want)
+			}
+			if metadata.NextCursor != tt.next {
+				t.Errorf("next cursor = %q, want %q", metadata.NextCursor, tt.next)
+			}
+			if metadata.PrevCursor != tt.prev {
+				t.Errorf("prev cursor = %q, want %q", metadata.PrevCursor, tt.prev)
+			}
+			if metadata.LastPage != tt.lastPage {
+				t.Errorf("last page = %d, want %d", metadata.LastPage, tt.lastPage)
+			}
+		})
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		cursor  string
+		want    cursor
+		wantErr bool
+	}{
+		{
+			name:   "round trip",
+			cursor: cursor{Sort: "-rank", Values: map[string]any{"rank": 9007199254740993}, ID: 12, Prev: true}.encode(),
+			want:   cursor{Sort: "-rank", Values: map[string]any{"rank": json.Number("9007199254740993")}, ID: 12, Prev: true},
+		},
+		{name: "not base64", cursor: "!!!", wantErr: true},
+		{name: "not JSON", cursor: "bm90IGpzb24", wantErr: true},
+		{name: "no row ID", cursor: cursor{Sort: "title"}.encode(), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.cursor)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cursor = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePageCursor(t *testing.T) {
+	issued := cursor{Sort: "-created_at", Values: map[string]any{"created_at": "2024-05-01T00:00:00Z"}, ID: 3}.encode()
+
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+		want   string
+	}{
+		{name: "no cursor", sort: "title"},
+		{name: "same sort", sort: "-created_at", cursor: issued},
+		{name: "different sort", sort: "created_at", cursor: issued, want: "was issued for a different sort"},
+		{name: "default sort", sort: "", cursor: issued, want: "was issued for a different sort"},
+		{name: "garbage", sort: "-created_at", cursor: "garbage!", want: "is not valid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			validatePage(v, Filters{Page: 1, PageSize: 20, Sort: tt.sort, Cursor: tt.cursor})
+			if got := v.Errors["cursor"]; got != tt.want {
+				t.Errorf("cursor error = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,66 +2,89 @@
 
 package data
 
+import "context"
+
 // RoleModelInterface defines the interface for role operations
 type RoleModelInterface interface {
-	Insert(*Role) error
-	Get(int) (*Role, error)
-	GetByName(string) (*Role, error)
-	GetAll() ([]*Role, error)
-	Update(*Role) error
-	Delete(int) error
+	Insert(context.Context, *Role) error
+	Get(context.Context, int) (*Role, error)
+	GetByName(context.Context, string) (*Role, error)
+	GetAll(context.Context) ([]*Role, error)
+	Update(context.Context, *Role, *AuditEvent) error
+	Delete(context.Context, int, *AuditEvent) error
+}
+
+// PermissionModelInterface defines the interface for permission operations
+type PermissionModelInterface interface {
+	GetAll(context.Context) ([]*Permission, error)
+	GetAllForRole(context.Context, int) (Permissions, error)
+	SetForRole(context.Context, int, []string, *AuditEvent) error
 }
 
 // ResourceModelInterface defines the interface for resource operations
 type ResourceModelInterface interface {
-	Insert(*Resource) error
-	InsertWithVideoMetadata(*Resource, *VideoMetadata) error
-	Get(int64) (*Resource, error)
-	GetBySlug(string) (*Resource, error)
-	GetAll(status, subject, gradeLevel string, filters Filters) ([]*Resource, Metadata, error)
-	GetStatusCounts() (*ResourceStatusCounts, error)
-	Update(*Resource) error
-	Delete(int64) error
-	GetSubjects(int64) ([]string, error)
-	GetGradeLevels(int64) ([]string, error)
-	SetSubjects(int64, []string) error
-	SetGradeLevels(int64, []string) error
+	Insert(context.Context, *Resource) error
+	Create(context.Context, ResourceBundle) error
+	CreateBatch(ctx context.Context, bundles []ResourceBundle, dryRun bool) ([]error, error)
+	Get(context.Context, int64) (*Resource, error)
+	GetBySlug(context.Context, string) (*Resource, error)
+	GetAll(context.Context, ResourceFilter, Filters) ([]*Resource, Metadata, error)
+	Export(ctx context.Context, filter ResourceFilter, filters Filters, fn func(*Resource) error) error
+	GetFacets(context.Context, ResourceFilter, Filters) (*ResourceFacets, error)
+	GetReviewQueue(ctx context.Context, expertID int64, statuses []string, subject, gradeLevel string, filters Filters) ([]*Resource, Metadata, error)
+	GetStatusCounts(context.Context) (*ResourceStatusCounts, error)
+	Update(ctx context.Context, resource *Resource, changedBy int64, description string) error
+	UpdateBundle(context.Context, ResourceBundle) error
+	Delete(ctx context.Context, id, deletedBy int64, audit *AuditEvent) error
+	GetRevisions(context.Context, int64) ([]*ResourceRevision, error)
+	GetRevision(ctx context.Context, resourceID int64, number int) (*ResourceRevision, error)
+	GetSubjects(context.Context, int64) ([]string, error)
+	GetGradeLevels(context.Context, int64) ([]string, error)
+	SetSubjects(context.Context, int64, []string) error
+	SetGradeLevels(context.Context, int64, []string) error
 }
 
 // FellowApplicationModelInterface defines the interface for fellow application operations
 type FellowApplicationModelInterface interface {
-	Insert(*FellowApplication) error
-	Get(int64) (*FellowApplication, error)
-	GetByUserID(int64) (*FellowApplication, error)
-	HasPendingApplication(int64) (bool, error)
-	Approve(id, reviewerID int64) error
-	Reject(id, reviewerID int64) error
-	GetAll(statusFilter string) ([]*FellowApplication, error)
+	Insert(context.Context, *FellowApplication) error
+	Get(context.Context, int64) (*FellowApplication, error)
+	GetByUserID(context.Context, int64) (*FellowApplication, error)
+	HasPendingApplication(context.Context, int64) (bool, error)
+	Approve(ctx context.Context, id, reviewerID int64) error
+	Reject(ctx context.Context, id, reviewerID int64) error
+	GetAll(ctx context.Context, statusFilter string) ([]*FellowApplication, error)
 }
 
 // FellowModelInterface defines the interface for fellow operations
 type FellowModelInterface interface {
-	Insert(*Fellow) error
-	Get(int64) (*Fellow, error)
-	GetByUserID(int64) (*Fellow, error)
-	Update(*Fellow) error
-	Delete(int64) error
+	Insert(context.Context, *Fellow) error
+	Get(context.Context, int64) (*Fellow, error)
+	GetByUserID(context.Context, int64) (*Fellow, error)
+	Update(context.Context, *Fellow) error
+	Delete(context.Context, int64) error
 }
 
 // NotificationModelInterface defines the interface for notification operations
 type NotificationModelInterface interface {
-	Insert(*Notification) error
-	Get(int) (*Notification, error)
-	GetByUser(int) ([]*Notification, error)
-	Delete(int) error
+	Insert(context.Context, *Notification) error
+	Get(context.Context, int) (*Notification, error)
+	GetByUser(context.Context, int, Filters) ([]*Notification, Metadata, error)
+	Delete(context.Context, int) error
 }
 
 // ContributionModelInterface defines the interface for contribution operations
 type ContributionModelInterface interface {
-	Insert(*Contribution) error
-	Get(int64) (*Contribution, error)
-	GetByResourceID(int64) (*Contribution, error)
-	GetAll(Filters) ([]*Contribution, Metadata, error)
-	Update(*Contribution) error
-	Delete(int64) error
+	Insert(context.Context, *Contribution) error
+	Get(context.Context, int64) (*Contribution, error)
+	GetByResourceID(context.Context, int64) (*Contribution, error)
+	GetAll(context.Context, Filters) ([]*Contribution, Metadata, error)
+	Update(context.Context, *Contribution) error
+	Delete(context.Context, int64) error
+}
+
+// LoginThrottleModelInterface defines the interface for failed sign-in records
+type LoginThrottleModelInterface interface {
+	Get(ctx context.Context, scope, subject string, policy ThrottlePolicy) (*LoginThrottle, error)
+	RecordFailure(ctx context.Context, scope, subject string, policy ThrottlePolicy) (*LoginThrottle, bool, error)
+	Clear(ctx context.Context, scope, subject string, audit *AuditEvent) error
 }
@@ -0,0 +1,345 @@
+//filename: internal/data/lesson_content.go
+
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+)
+
+// LessonDocumentVersion is the version of the lesson document format written
+// today.  Documents without a version are read as this one.
+const LessonDocumentVersion = 1
+
+// Lesson block types.  The section of a review comment is one of these.
+const (
+	BlockObjectives      = "objectives"
+	BlockMaterials       = "materials"
+	BlockWarmup          = "warmup"
+	BlockActivity        = "activity"
+	BlockAssessment      = "assessment"
+	BlockDifferentiation = "differentiation"
+	BlockExtension       = "extension"
+	BlockMedia           = "media"
+	BlockFellowNotes     = "fellow_notes"
+)
+
+// LessonBlockTypes lists every block type a lesson document may hold
+var LessonBlockTypes = []string{
+	BlockObjectives, BlockMaterials, BlockWarmup, BlockActivity, BlockAssessment,
+	BlockDifferentiation, BlockExtension, BlockMedia, BlockFellowNotes,
+}
+
+// Who can see a block: everyone, or only fellows
+const (
+	VisibilityPublic = "public"
+	VisibilityFellow = "fellow"
+)
+
+// MediaKinds lists the kinds of file a media block may link to
+var MediaKinds = []string{"image", "video", "audio", "document", "link"}
+
+// LessonDocument is the structured content of a lesson, as written by the
+// lesson builder and kept as JSON in lessons.content
+type LessonDocument struct {
+	Version int           `json:"version"`
+	Blocks  []LessonBlock `json:"blocks"`
+}
+
+// LessonBlock is one section of a lesson.  Content holds a value whose type
+// depends on Type:
+//
+//	objectives, materials, extension  []string
+//	warmup                            WarmupContent
+//	activity                          []ActivityStep
+//	assessment                        AssessmentContent
+//	differentiation                   DifferentiationContent
+//	media                             MediaContent
+//	fellow_notes                      string
+//
+// The block_index of a review comment is the block's position in Blocks.
+type LessonBlock struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Visibility string `json:"visibility"`
+	Content    any    `json:"content"`
+}
+
+type WarmupContent struct {
+	Description     string `json:"description"`
+	DurationMinutes *int   `json:"duration_minutes,omitempty"`
+}
+
+type ActivityStep struct {
+	Step int    `json:"step"`
+	Text string `json:"text"`
+}
+
+type AssessmentContent struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// DifferentiationContent lists how the lesson is adapted for students who
+// need more support and for those who need more challenge
+type DifferentiationContent struct {
+	Support   []string `json:"support"`
+	Challenge []string `json:"challenge"`
+}
+
+type MediaContent struct {
+	URL     string `json:"url"`
+	Kind    string `json:"kind"`
+	Caption string `json:"caption,omitempty"`
+}
+
+// DecodeLessonDocument reads a lesson document from JSON.  Every block's
+// content is decoded into the type for its block type, and unknown fields
+// are rejected.  The document is normalized as migration 039 normalized the
+// existing rows: a missing version is taken as the current one, blocks
+// without an id are given one from their position, blocks without a
+// visibility are public (fellow notes are for fellows), and activity steps
+// are numbered in order.  Decoding does not validate the values themselves;
+// that is ValidateLessonDocument's job.
+func DecodeLessonDocument(content []byte) (*LessonDocument, error) {
+	var raw struct {
+		Version int `json:"version"`
+		Blocks  []struct {
+			ID         string          `json:"id"`
+			Type       string          `json:"type"`
+			Title      string          `json:"title"`
+			Visibility string          `json:"visibility"`
+			Content    json.RawMessage `json:"content"`
+		} `json:"blocks"`
+	}
+	if err := decodeStrict(content, &raw); err != nil {
+		return nil, err
+	}
+	if raw.Blocks == nil {
+		return nil, errors.New("must be a document with a blocks array")
+	}
+
+	doc := &LessonDocument{Version: raw.Version, Blocks: make([]LessonBlock, len(raw.Blocks))}
+	if doc.Version == 0 {
+		doc.Version = LessonDocumentVersion
+	}
+
+	for i, rb := range raw.Blocks {
+		block := LessonBlock{ID: rb.ID, Type: rb.Type, Title: rb.Title, Visibility: rb.Visibility}
+		if block.ID == "" {
+			block.ID = fmt.Sprintf("block-%d", i+1)
+		}
+		if block.Visibility == "" {
+			block.Visibility = VisibilityPublic
+			if block.Type == BlockFellowNotes {
+				block.Visibility = VisibilityFellow
+			}
+		}
+
+		content, err := decodeBlockContent(block.Type, rb.Content)
+		if err != nil {
+			return nil, fmt.Errorf("blocks.%d: %w", i, err)
+		}
+		block.Content = content
+		doc.Blocks[i] = block
+	}
+
+	return doc, nil
+}
+
+// decodeBlockContent decodes a block's content into the type for its block
+// type.  Missing content is the empty value of that type.
+func decodeBlockContent(blockType string, content json.RawMessage) (any, error) {
+	if len(content) == 0 || string(content) == "null" {
+		content = nil
+	}
+
+	decode := func(dst any) error {
+		if content == nil {
+			return nil
+		}
+		if err := decodeStrict(content, dst); err != nil {
+			return fmt.Errorf("content %w", err)
+		}
+		return nil
+	}
+
+	var value any
+	var err error
+	switch blockType {
+	case BlockObjectives, BlockMaterials, BlockExtension:
+		items := []string{}
+		err = decode(&items)
+		value = items
+	case BlockWarmup:
+		var warmup WarmupContent
+		err = decode(&warmup)
+		value = warmup
+	case BlockActivity:
+		steps := []ActivityStep{}
+		err = decode(&steps)
+		for i := range steps {
+			steps[i].Step = i + 1
+		}
+		value = steps
+	case BlockAssessment:
+		var assessment AssessmentContent
+		err = decode(&assessment)
+		value = assessment
+	case BlockDifferentiation:
+		differentiation := DifferentiationContent{Support: []string{}, Challenge: []string{}}
+		err = decode(&differentiation)
+		value = differentiation
+	case BlockMedia:
+		var media MediaContent
+		err = decode(&media)
+		value = media
+	case BlockFellowNotes:
+		var notes string
+		err = decode(&notes)
+		value = notes
+	case "":
+		return nil, errors.New("type must be provided")
+	default:
+		return nil, fmt.Errorf("type %q is not a known block type", blockType)
+	}
+
+	return value, err
+}
+
+// decodeStrict decodes a single JSON value, rejecting unknown fields, and
+// describes any error in terms a client can act on
+func decodeStrict(content []byte, dst any) error {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err == nil && dec.More() {
+		return errors.New("must be a single JSON value")
+	}
+
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, io.EOF):
+		return errors.New("must not be empty")
+	case errors.As(err, &syntaxError):
+		return fmt.Errorf("is not valid JSON (at character %d)", syntaxError.Offset)
+	case errors.As(err, &typeError):
+		if typeError.Field != "" {
+			return fmt.Errorf("has the wrong type for %s: must be %s", typeError.Field, jsonTypeName(typeError.Type.Kind().String()))
+		}
+		return fmt.Errorf("has the wrong type: must be %s", jsonTypeName(typeError.Type.Kind().String()))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Errorf("has unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		return fmt.Errorf("is not valid JSON: %w", err)
+	}
+}
+
+func jsonTypeName(kind string) string {
+	switch kind {
+	case "slice", "array":
+		return "an array"
+	case "struct", "map":
+		return "an object"
+	case "string":
+		return "a string"
+	case "bool":
+		return "true or false"
+	default:
+		return "a number"
+	}
+}
+
+// Encode returns the document as the JSON kept in lessons.content
+func (d *LessonDocument) Encode() (string, error) {
+	content, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// ValidateLessonDocument checks a decoded lesson document.  Errors are keyed
+// by key, the name of the field the document came in, and the path of the
+// value within it, such as "content.blocks.2.content.url".
+func ValidateLessonDocument(v *validator.Validator, key string, doc *LessonDocument) {
+	v.Check(doc.Version == LessonDocumentVersion, key+".version", fmt.Sprintf("must be %d", LessonDocumentVersion))
+	v.Check(len(doc.Blocks) > 0, key+".blocks", "must contain at least one block")
+	v.Check(len(doc.Blocks) <= 200, key+".blocks", "must not contain more than 200 blocks")
+
+	ids := make(map[string]int, len(doc.Blocks))
+	for i, block := range doc.Blocks {
+		path := fmt.Sprintf("%s.blocks.%d", key, i)
+
+		v.Check(len(block.ID) <= 100, path+".id", "must not be more than 100 characters")
+		if first, ok := ids[block.ID]; ok {
+			v.AddError(path+".id", fmt.Sprintf("must be unique, block %d has the same id", first))
+		}
+		ids[block.ID] = i
+
+		v.Check(len(block.Title) <= 255, path+".title", "must not be more than 255 characters")
+		v.Check(validator.PermittedValue(block.Visibility, VisibilityPublic, VisibilityFellow),
+			path+".visibility", "must be public or fellow")
+
+		validateBlockContent(v, path+".content", block)
+	}
+}
+
+func validateBlockContent(v *validator.Validator, path string, block LessonBlock) {
+	text := func(key, value string, max int) {
+		v.Check(len(value) <= max, key, fmt.Sprintf("must not be more than %d characters", max))
+	}
+	list := func(key string, items []string) {
+		v.Check(len(items) <= 100, key, "must not contain more than 100 items")
+		for i, item := range items {
+			text(fmt.Sprintf("%s.%d", key, i), item, 1000)
+		}
+	}
+	duration := func(key string, minutes *int) {
+		if minutes != nil {
+			v.Check(*minutes > 0 && *minutes <= 600, key, "must be between 1 and 600 minutes")
+		}
+	}
+
+	switch content := block.Content.(type) {
+	case []string:
+		list(path, content)
+	case WarmupContent:
+		text(path+".description", content.Description, 5000)
+		duration(path+".duration_minutes", content.DurationMinutes)
+	case []ActivityStep:
+		v.Check(len(content) <= 100, path, "must not contain more than 100 steps")
+		for i, step := range content {
+			text(fmt.Sprintf("%s.%d.text", path, i), step.Text, 5000)
+		}
+	case AssessmentContent:
+		text(path+".type", content.Type, 100)
+		text(path+".description", content.Description, 5000)
+	case DifferentiationContent:
+		v.Check(len(content.Support) > 0 || len(content.Challenge) > 0, path, "must give support or challenge")
+		list(path+".support", content.Support)
+		list(path+".challenge", content.Challenge)
+	case MediaContent:
+		u, err := url.Parse(content.URL)
+		v.Check(content.URL != "", path+".url", "must be provided")
+		v.Check(content.URL == "" || (err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""),
+			path+".url", "must be an http or https URL")
+		v.Check(validator.PermittedValue(content.Kind, MediaKinds...), path+".kind",
+			"must be one of "+strings.Join(MediaKinds, ", "))
+		text(path+".caption", content.Caption, 500)
+	case string:
+		text(path, content, 10000)
+	}
+}
@@ -0,0 +1,217 @@
+package data
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+)
+
+func TestDecodeLessonDocument(t *testing.T) {
+	ten := 10
+
+	tests := []struct {
+		name    string
+		content string
+		want    *LessonDocument
+		err     string
+	}{
+		{
+			name: "fills in defaults",
+			content: `{"blocks":[
+				{"type":"objectives","content":["Add fractions"]},
+				{"id":"w","type":"warmup","title":"Pizza","content":{"description":"Slice it","duration_minutes":10}},
+				{"type":"activity","visibility":"fellow","content":[{"step":7,"text":"Cut"},{"text":"Share"}]},
+				{"type":"fellow_notes","content":"Bring plates"}
+			]}`,
+			want: &LessonDocument{Version: 1, Blocks: []LessonBlock{
+				{ID: "block-1", Type: BlockObjectives, Visibility: VisibilityPublic, Content: []string{"Add fractions"}},
+				{ID: "w", Type: BlockWarmup, Title: "Pizza", Visibility: VisibilityPublic,
+					Content: WarmupContent{Description: "Slice it", DurationMinutes: &ten}},
+				{ID: "block-3", Type: BlockActivity, Visibility: VisibilityFellow,
+					Content: []ActivityStep{{Step: 1, Text: "Cut"}, {Step: 2, Text: "Share"}}},
+				{ID: "block-4", Type: BlockFellowNotes, Visibility: VisibilityFellow, Content: "Bring plates"},
+			}},
+		},
+		{
+			name:    "empty content",
+			content: `{"version":1,"blocks":[{"type":"materials"},{"type":"differentiation","content":null}]}`,
+			want: &LessonDocument{Version: 1, Blocks: []LessonBlock{
+				{ID: "block-1", Type: BlockMaterials, Visibility: VisibilityPublic, Content: []string{}},
+				{ID: "block-2", Type: BlockDifferentiation, Visibility: VisibilityPublic,
+					Content: DifferentiationContent{Support: []string{}, Challenge: []string{}}},
+			}},
+		},
+		{
+			name:    "every other type",
+			content: `{"version":1,"blocks":[{"type":"assessment","content":{"type":"quiz","description":"Five questions"}},{"type":"media","content":{"url":"https://example.com/a.png","kind":"image"}},{"type":"extension","content":["Bake a pie"]}]}`,
+			want: &LessonDocument{Version: 1, Blocks: []LessonBlock{
+				{ID: "block-1", Type: BlockAssessment, Visibility: VisibilityPublic,
+					Content: AssessmentContent{Type: "quiz", Description: "Five questions"}},
+				{ID: "block-2", Type: BlockMedia, Visibility: VisibilityPublic,
+					Content: MediaContent{URL: "https://example.com/a.png", Kind: "image"}},
+				{ID: "block-3", Type: BlockExtension, Visibility: VisibilityPublic, Content: []string{"Bake a pie"}},
+			}},
+		},
+		{name: "nothing", content: ``, err: "must not be empty"},
+		{name: "not JSON", content: `{"blocks":[}`, err: "is not valid JSON (at character 12)"},
+		{name: "two values", content: `{"blocks":[]} {}`, err: "must be a single JSON value"},
+		{name: "plain text", content: `Count to ten`, err: "is not valid JSON (at character 1)"},
+		{name: "no blocks", content: `{"version":1}`, err: "must be a document with a blocks array"},
+		{name: "array", content: `[]`, err: "has the wrong type: must be an object"},
+		{name: "unknown document field", content: `{"blocks":[],"theme":"space"}`, err: `has unknown field "theme"`},
+		{name: "unknown block field", content: `{"blocks":[{"type":"materials","colour":"red"}]}`,
+			err: `has unknown field "colour"`},
+		{name: "missing type", content: `{"blocks":[{"content":[]}]}`, err: "blocks.0: type must be provided"},
+		{name: "unknown type", content: `{"blocks":[{"type":"materials"},{"type":"quiz"}]}`,
+			err: `blocks.1: type "quiz" is not a known block type`},
+		{name: "content of the wrong type", content: `{"blocks":[{"type":"objectives","content":"Add fractions"}]}`,
+			err: "blocks.0: content has the wrong type: must be an array"},
+		{name: "content field of the wrong type", content: `{"blocks":[{"type":"warmup","content":{"description":5}}]}`,
+			err: "blocks.0: content has the wrong type for description: must be a string"},
+		{name: "unknown content field", content: `{"blocks":[{"type":"media","content":{"url":"x","alt":"y"}}]}`,
+			err: `blocks.0: content has unknown field "alt"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := DecodeLessonDocument([]byte(tt.content))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(doc, tt.want) {
+				t.Errorf("document = %#v\nwant %#v", doc, tt.want)
+			}
+		})
+	}
+}
+
+// Encoding a decoded document and decoding it again changes nothing
+func TestLessonDocumentRoundTrip(t *testing.T) {
+	content := `{"blocks":[{"type":"warmup","content":{"description":"Slice it"}},{"type":"activity","content":[{"text":"Cut"}]}]}`
+
+	doc, err := DecodeLessonDocument([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := doc.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := DecodeLessonDocument([]byte(encoded))
+	if err != nil {
+		t.Fatalf("decoding %s: %v", encoded, err)
+	}
+	if !reflect.DeepEqual(doc, again) {
+		t.Errorf("round trip changed the document:\n%#v\n%#v", doc, again)
+	}
+}
+
+func TestValidateLessonDocument(t *testing.T) {
+	zero := 0
+	long := strings.Repeat("x", 1001)
+
+	tests := []struct {
+		name   string
+		blocks []LessonBlock
+		want   map[string]string
+	}{
+		{
+			name: "valid",
+			blocks: []LessonBlock{
+				{ID: "a", Type: BlockObjectives, Visibility: VisibilityPublic, Content: []string{"Add fractions"}},
+				{ID: "b", Type: BlockMedia, Visibility: VisibilityFellow,
+					Content: MediaContent{URL: "https://example.com/v", Kind: "video"}},
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "no blocks",
+			want: map[string]string{"content.blocks": "must contain at least one block"},
+		},
+		{
+			name: "duplicate ids",
+			blocks: []LessonBlock{
+				{ID: "a", Type: BlockFellowNotes, Visibility: VisibilityFellow, Content: "one"},
+				{ID: "a", Type: BlockFellowNotes, Visibility: VisibilityFellow, Content: "two"},
+			},
+			want: map[string]string{"content.blocks.1.id": "must be unique, block 0 has the same id"},
+		},
+		{
+			name: "bad visibility",
+			blocks: []LessonBlock{
+				{ID: "a", Type: BlockMaterials, Visibility: "private", Content: []string{}},
+			},
+			want: map[string]string{"content.blocks.0.visibility": "must be public or fellow"},
+		},
+		{
+			name: "media",
+			blocks: []LessonBlock{
+				{ID: "a", Type: BlockMedia, Visibility: VisibilityPublic, Content: MediaContent{URL: "ftp://example.com/v", Kind: "hologram"}},
+				{ID: "b", Type: BlockMedia, Visibility: VisibilityPublic, Content: MediaContent{Kind: "image"}},
+			},
+			want: map[string]string{
+				"content.blocks.0.content.url":  "must be an http or https URL",
+				"content.blocks.0.content.kind": "must be one of " + strings.Join(MediaKinds, ", "),
+				"content.blocks.1.content.url":  "must be provided",
+			},
+		},
+		{
+			name: "warmup duration",
+			blocks: []LessonBlock{
+				{ID: "a", Type: BlockWarmup, Visibility: VisibilityPublic, Content: WarmupContent{DurationMinutes: &zero}},
+			},
+			want: map[string]string{"content.blocks.0.content.duration_minutes": "must be between 1 and 600 minutes"},
+		},
+		{
+			name: "empty differentiation",
+			blocks: []LessonBlock{
+				{ID: "a", Type: BlockDifferentiation, Visibility: VisibilityPublic,
+					Content: DifferentiationContent{Support: []string{}, Challenge: []string{}}},
+			},
+			want: map[string]string{"content.blocks.0.content": "must give support or challenge"},
+		},
+		{
+			name: "long list item",
+			blocks: []LessonBlock{
+				{ID: "a", Type: BlockMaterials, Visibility: VisibilityPublic, Content: []string{"Rulers", long}},
+			},
+			want: map[string]string{"content.blocks.0.content.1": "must not be more than 1000 characters"},
+		},
+		{
+			name: "long activity step",
+			blocks: []LessonBlock{
+				{ID: "a", Type: BlockActivity, Visibility: VisibilityPublic,
+					Content: []ActivityStep{{Step: 1, Text: strings.Repeat("x", 5001)}}},
+			},
+			want: map[string]string{"content.blocks.0.content.0.text": "must not be more than 5000 characters"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateLessonDocument(v, "content", &LessonDocument{Version: LessonDocumentVersion, Blocks: tt.blocks})
+			if !reflect.DeepEqual(v.Errors, tt.want) {
+				t.Errorf("errors = %v\nwant %v", v.Errors, tt.want)
+			}
+		})
+	}
+
+	t.Run("wrong version", func(t *testing.T) {
+		v := validator.New()
+		ValidateLessonDocument(v, "content", &LessonDocument{Version: 2, Blocks: []LessonBlock{
+			{ID: "a", Type: BlockFellowNotes, Visibility: VisibilityFellow, Content: "notes"},
+		}})
+		if got := v.Errors["content.version"]; got != "must be 1" {
+			t.Errorf("version error = %q, want %q", got, "must be 1")
+		}
+	})
+}
@@ -0,0 +1,221 @@
+//filename: internal/data/lesson_diff.go
+
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// Kinds of block change in a LessonDiff
+const (
+	BlockAdded    = "added"
+	BlockRemoved  = "removed"
+	BlockModified = "modified"
+	BlockMoved    = "moved"
+)
+
+// LessonDiff is what changed in a lesson's content between two versions.
+// Content written by the lesson builder is a document holding a "blocks"
+// array, and is compared block by block; anything else in the document is
+// compared field by field.  Older plain-text content is compared as a whole.
+type LessonDiff struct {
+	From   int           `json:"from"`
+	To     int           `json:"to"`
+	Blocks []BlockChange `json:"blocks"`
+	Fields []PathChange  `json:"fields"`
+}
+
+// BlockChange is one block that was added, removed, moved or edited.
+// Blocks are matched by their id, or by position if they have none.
+// FromIndex and ToIndex are the block's positions, as used by the
+// block_index of review comments.
+type BlockChange struct {
+	Change    string       `json:"change"`
+	BlockID   string       `json:"block_id,omitempty"`
+	Type      string       `json:"type,omitempty"`
+	FromIndex *int         `json:"from_index,omitempty"`
+	ToIndex   *int         `json:"to_index,omitempty"`
+	Fields    []PathChange `json:"fields,omitempty"`
+	Block     any          `json:"block,omitempty"` // the whole block, when added or removed
+}
+
+// PathChange is one value that differs, named by its path within the block
+// or document, such as "items.2" or "title"
+type PathChange struct {
+	Path string `json:"path"`
+	From any    `json:"from"`
+	To   any    `json:"to"`
+}
+
+// DiffLessonContent compares the content of two lesson versions
+func DiffLessonContent(from, to *LessonVersion) LessonDiff {
+	diff := LessonDiff{From: from.VersionNumber, To: to.VersionNumber, Blocks: []BlockChange{}, Fields: []PathChange{}}
+
+	fromDoc, fromOK := parseLessonDocument(from.Content)
+	toDoc, toOK := parseLessonDocument(to.Content)
+	if !fromOK || !toOK {
+		if from.Content != to.Content {
+			diff.Fields = append(diff.Fields, PathChange{Path: "content", From: from.Content, To: to.Content})
+		}
+		return diff
+	}
+
+	fromBlocks, toBlocks := lessonBlocks(fromDoc), lessonBlocks(toDoc)
+	delete(fromDoc, "blocks")
+	delete(toDoc, "blocks")
+	diff.Fields = diffValues("", fromDoc, toDoc, diff.Fields)
+	diff.Blocks = diffBlocks(fromBlocks, toBlocks)
+
+	return diff
+}
+
+// parseLessonDocument reads content as a JSON object.  A bare array of
+// blocks is treated as a document holding just those blocks.
+func parseLessonDocument(content string) (map[string]any, bool) {
+	var value any
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return nil, false
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		return v, true
+	case []any:
+		return map[string]any{"blocks": v}, true
+	}
+	return nil, false
+}
+
+// lessonBlocks returns the document's blocks.  Anything in the array that is
+// not an object is kept as a block with no id.
+func lessonBlocks(doc map[string]any) []any {
+	blocks, _ := doc["blocks"].([]any)
+	return blocks
+}
+
+// blockKey identifies a block across versions: its id, or its position
+func blockKey(block any, index int) string {
+	if object, ok := block.(map[string]any); ok {
+		if id, ok := object["id"]; ok && id != nil {
+			return "id:" + fmt.Sprint(id)
+		}
+	}
+	return "index:" + strconv.Itoa(index)
+}
+
+func diffBlocks(from, to []any) []BlockChange {
+	changes := []BlockChange{}
+
+	fromIndex := make(map[string]int, len(from))
+	for i, block := range from {
+		fromIndex[blockKey(block, i)] = i
+	}
+	matched := make(map[int]bool, len(from))
+
+	for j, block := range to {
+		i, ok := fromIndex[blockKey(block, j)]
+		if !ok {
+			changes = append(changes, newBlockChange(BlockAdded, block, nil, &j))
+			continue
+		}
+		matched[i] = true
+
+		fields := diffValues("", from[i], block, nil)
+		switch {
+		case len(fields) > 0:
+			change := newBlockChange(BlockModified, block, &i, &j)
+			change.Fields = fields
+			change.Block = nil
+			changes = append(changes, change)
+		case i != j:
+			change := newBlockChange(BlockMoved, block, &i, &j)
+			change.Block = nil
+			changes = append(changes, change)
+		}
+	}
+
+	for i, block := range from {
+		if !matched[i] {
+			changes = append(changes, newBlockChange(BlockRemoved, block, &i, nil))
+		}
+	}
+
+	// Removed blocks were added last; put every change in document order
+	slices.SortStableFunc(changes, func(a, b BlockChange) int {
+		return blockPosition(a) - blockPosition(b)
+	})
+	return changes
+}
+
+func blockPosition(c BlockChange) int {
+	if c.ToIndex != nil {
+		return *c.ToIndex
+	}
+	return *c.FromIndex
+}
+
+func newBlockChange(kind string, block any, fromIndex, toIndex *int) BlockChange {
+	change := BlockChange{Change: kind, FromIndex: fromIndex, ToIndex: toIndex, Block: block}
+	if object, ok := block.(map[string]any); ok {
+		if id, ok := object["id"]; ok && id != nil {
+			change.BlockID = fmt.Sprint(id)
+		}
+		if blockType, ok := object["type"].(string); ok {
+			change.Type = blockType
+		}
+	}
+	return change
+}
+
+// diffValues appends the differences between two JSON values to changes.
+// Objects are compared key by key and arrays of the same length element by
+// element; anything else that differs is reported whole.
+func diffValues(path string, from, to any, changes []PathChange) []PathChange {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	switch f := from.(type) {
+	case map[string]any:
+		t, ok := to.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(f)+len(t))
+		for key := range f {
+			keys = append(keys, key)
+		}
+		for key := range t {
+			if _, ok := f[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			changes = diffValues(join(key), f[key], t[key], changes)
+		}
+		return changes
+
+	case []any:
+		t, ok := to.([]any)
+		if !ok || len(f) != len(t) {
+			break
+		}
+		for i := range f {
+			changes = diffValues(join(strconv.Itoa(i)), f[i], t[i], changes)
+		}
+		return changes
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		changes = append(changes, PathChange{Path: path, From: from, To: to})
+	}
+	return changes
+}
@@ -0,0 +1,125 @@
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffLessonContent(t *testing.T) {
+	index := func(i int) *int { return &i }
+	block := func(js string) any {
+		var v any
+		if err := json.Unmarshal([]byte(js), &v); err != nil {
+			panic(err)
+		}
+		return v
+	}
+
+	const (
+		objectives = `{"id":"a","type":"objectives","content":["Add fractions"]}`
+		warmup     = `{"id":"b","type":"warmup","content":{"description":"Pizza slices"}}`
+		media      = `{"id":"c","type":"media","content":{"url":"https://example.com/v","kind":"video"}}`
+		notes      = `{"id":"d","type":"fellow_notes","content":"Bring paper plates"}`
+	)
+
+	tests := []struct {
+		name   string
+		from   string
+		to     string
+		blocks []BlockChange
+		fields []PathChange
+	}{
+		{
+			name:   "unchanged document",
+			from:   `{"version":1,"blocks":[` + objectives + `]}`,
+			to:     `{"version":1,"blocks":[` + objectives + `]}`,
+			blocks: []BlockChange{},
+			fields: []PathChange{},
+		},
+		{
+			name:   "plain text",
+			from:   "Count to ten",
+			to:     "Count to twenty",
+			blocks: []BlockChange{},
+			fields: []PathChange{{Path: "content", From: "Count to ten", To: "Count to twenty"}},
+		},
+		{
+			name:   "plain text unchanged",
+			from:   "Count to ten",
+			to:     "Count to ten",
+			blocks: []BlockChange{},
+			fields: []PathChange{},
+		},
+		{
+			name:   "plain text to document",
+			from:   "Count to ten",
+			to:     `{"blocks":[]}`,
+			blocks: []BlockChange{},
+			fields: []PathChange{{Path: "content", From: "Count to ten", To: `{"blocks":[]}`}},
+		},
+		{
+			name:   "document fields",
+			from:   `{"version":1,"blocks":[]}`,
+			to:     `{"version":2,"theme":"space","blocks":[]}`,
+			blocks: []BlockChange{},
+			fields: []PathChange{
+				{Path: "theme", From: nil, To: "space"},
+				{Path: "version", From: 1.0, To: 2.0},
+			},
+		},
+		{
+			name: "added, removed, moved and modified",
+			from: `{"blocks":[` + objectives + `,` + warmup + `,` + media + `]}`,
+			to: `{"blocks":[` + warmup + `,` +
+				`{"id":"a","type":"objectives","content":["Add unlike fractions"]}` + `,` + notes + `]}`,
+			blocks: []BlockChange{
+				{Change: BlockMoved, BlockID: "b", Type: "warmup", FromIndex: index(1), ToIndex: index(0)},
+				{Change: BlockModified, BlockID: "a", Type: "objectives", FromIndex: index(0), ToIndex: index(1),
+					Fields: []PathChange{{Path: "content.0", From: "Add fractions", To: "Add unlike fractions"}}},
+				{Change: BlockAdded, BlockID: "d", Type: "fellow_notes", ToIndex: index(2), Block: block(notes)},
+				{Change: BlockRemoved, BlockID: "c", Type: "media", FromIndex: index(2), Block: block(media)},
+			},
+			fields: []PathChange{},
+		},
+		{
+			name: "blocks without ids match by position",
+			from: `[{"type":"materials","content":["Rulers"]}]`,
+			to:   `[{"type":"materials","content":["Rulers","Scissors"]}]`,
+			blocks: []BlockChange{
+				{Change: BlockModified, Type: "materials", FromIndex: index(0), ToIndex: index(0),
+					Fields: []PathChange{{Path: "content", From: []any{"Rulers"}, To: []any{"Rulers", "Scissors"}}}},
+			},
+			fields: []PathChange{},
+		},
+		{
+			name: "nested field",
+			from: `{"blocks":[` + warmup + `]}`,
+			to:   `{"blocks":[{"id":"b","type":"warmup","content":{"description":"Pizza slices","duration_minutes":10}}]}`,
+			blocks: []BlockChange{
+				{Change: BlockModified, BlockID: "b", Type: "warmup", FromIndex: index(0), ToIndex: index(0),
+					Fields: []PathChange{{Path: "content.duration_minutes", From: nil, To: 10.0}}},
+			},
+			fields: []PathChange{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffLessonContent(&LessonVersion{VersionNumber: 1, Content: tt.from},
+				&LessonVersion{VersionNumber: 2, Content: tt.to})
+
+			if diff.From != 1 || diff.To != 2 {
+				t.Errorf("diff is from %d to %d, want 1 to 2", diff.From, diff.To)
+			}
+			if !reflect.DeepEqual(diff.Blocks, tt.blocks) {
+				got, _ := json.Marshal(diff.Blocks)
+				want, _ := json.Marshal(tt.blocks)
+				t.Errorf("blocks = %s\nwant %s", got, want)
+			}
+			if !reflect.DeepEqual(diff.Fields, tt.fields) {
+				t.Errorf("fields = %#v, want %#v", diff.Fields, tt.fields)
+			}
+		})
+	}
+}
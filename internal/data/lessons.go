@@ -5,6 +5,7 @@ package data
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
@@ -22,6 +23,7 @@ type Lesson struct {
 	Assessment      *string        `json:"assessment,omitempty"`
 	Differentiation *string        `json:"differentiation,omitempty"`
 	CreatedAt       time.Time      `json:"created_at"`
+	Version         int32          `json:"version"`
 }
 
 type LessonVersion struct {
@@ -30,7 +32,7 @@ type LessonVersion struct {
 	VersionNumber     int       `json:"version_number"`
 	Content           string    `json:"content"`
 	ChangeDescription *string   `json:"change_description,omitempty"`
-	ChangedBy         int64     `json:"changed_by"`
+	ChangedBy         int64     `json:"changed_by,omitempty"`
 	ChangedAt         time.Time `json:"changed_at"`
 }
 
@@ -38,12 +40,32 @@ type LessonModel struct {
 	DB *sql.DB
 }
 
-// Insert a new lesson
-func (m LessonModel) Insert(lesson *Lesson) error {
+// Insert a new lesson and record its content as version 1, credited to
+// changedBy
+func (m LessonModel) Insert(ctx context.Context, lesson *Lesson, changedBy int64) error {
+	ctx, cancel := context.WithTimeout(ctx, TransactionTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = insertLesson(ctx, tx, lesson, changedBy); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertLesson inserts a lesson and its first version as part of a larger
+// transaction
+func insertLesson(ctx context.Context, tx *sql.Tx, lesson *Lesson, changedBy int64) error {
 	query := `
 		INSERT INTO lessons (resource_id, lesson_number, title, duration_minutes, objectives, materials, content, assessment, differentiation)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING lesson_id, created_at`
+		RETURNING lesson_id, created_at, version`
 
 	args := []any{
 		lesson.ResourceID,
@@ -57,26 +79,37 @@ func (m LessonModel) Insert(lesson *Lesson) error {
 		lesson.Differentiation,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&lesson.ID, &lesson.CreatedAt, &lesson.Version)
+	if err != nil {
+		switch {
+		case isDuplicateLessonNumber(err):
+			return ErrDuplicateLessonNumber
+		default:
+			return err
+		}
+	}
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&lesson.ID, &lesson.CreatedAt)
+	return insertLessonVersion(ctx, tx, lesson.ID, changedBy, "")
 }
 
-// Get a lesson by ID
-func (m LessonModel) Get(id int64) (*Lesson, error) {
+// Get a lesson by ID.  Lessons in the trash, or whose resource is, are not
+// found.
+func (m LessonModel) Get(ctx context.Context, id int64) (*Lesson, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
+	// A lesson whose resource is in the trash is treated as trashed with it
 	query := `
-		SELECT lesson_id, resource_id, lesson_number, title, duration_minutes, objectives, materials, content, assessment, differentiation, created_at
-		FROM lessons
-		WHERE lesson_id = $1`
+		SELECT l.lesson_id, l.resource_id, l.lesson_number, l.title, l.duration_minutes, l.objectives, l.materials,
+		       l.content, l.assessment, l.differentiation, l.created_at, l.version
+		FROM lessons l
+		JOIN resources r ON r.resource_id = l.resource_id AND r.deleted_at IS NULL
+		WHERE l.lesson_id = $1 AND l.deleted_at IS NULL`
 
 	var lesson Lesson
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, id).Scan(
@@ -91,6 +124,7 @@ func (m LessonModel) Get(id int64) (*Lesson, error) {
 		&lesson.Assessment,
 		&lesson.Differentiation,
 		&lesson.CreatedAt,
+		&lesson.Version,
 	)
 
 	if err != nil {
@@ -106,14 +140,14 @@ func (m LessonModel) Get(id int64) (*Lesson, error) {
 }
 
 // GetByResource returns all lessons for a resource
-func (m LessonModel) GetByResource(resourceID int64) ([]*Lesson, error) {
+func (m LessonModel) GetByResource(ctx context.Context, resourceID int64) ([]*Lesson, error) {
 	query := `
-		SELECT lesson_id, resource_id, lesson_number, title, duration_minutes, objectives, materials, content, assessment, differentiation, created_at
+		SELECT lesson_id, resource_id, lesson_number, title, duration_minutes, objectives, materials, content, assessment, differentiation, created_at, version
 		FROM lessons
-		WHERE resource_id = $1
+		WHERE resource_id = $1 AND deleted_at IS NULL
 		ORDER BY lesson_number`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	rows, err := m.DB.QueryContext(ctx, query, resourceID)
@@ -137,6 +171,7 @@ func (m LessonModel) GetByResource(resourceID int64) ([]*Lesson, error) {
 			&lesson.Assessment,
 			&lesson.Differentiation,
 			&lesson.CreatedAt,
+			&lesson.Version,
 		)
 		if err != nil {
 			return nil, err
@@ -147,15 +182,28 @@ func (m LessonModel) GetByResource(resourceID int64) ([]*Lesson, error) {
 	return lessons, rows.Err()
 }
 
-// Update a lesson
-func (m LessonModel) Update(lesson *Lesson) error {
+// isDuplicateLessonNumber reports whether err is a write giving a lesson the
+// number of another lesson of the same resource
+func isDuplicateLessonNumber(err error) bool {
+	return strings.Contains(err.Error(), "duplicate key value violates unique constraint") &&
+		strings.Contains(err.Error(), "uq_lessons_resource_number")
+}
+
+// Update a lesson.  The update only applies if the lesson is still at
+// lesson.Version; otherwise ErrEditConflict is returned.  ErrDuplicateLessonNumber
+// is returned if another lesson of the resource has its number.  A change to
+// the content is recorded as a new version, credited to changedBy and
+// described by description.
+func (m LessonModel) Update(ctx context.Context, lesson *Lesson, changedBy int64, description string) error {
 	query := `
 		UPDATE lessons
-		SET title = $1, duration_minutes = $2, objectives = $3, materials = $4, content = $5, assessment = $6, differentiation = $7
-		WHERE lesson_id = $8
-		RETURNING lesson_id`
+		SET lesson_number = $1, title = $2, duration_minutes = $3, objectives = $4, materials = $5, content = $6,
+		    assessment = $7, differentiation = $8, version = version + 1
+		WHERE lesson_id = $9 AND version = $10 AND deleted_at IS NULL
+		RETURNING version`
 
 	args := []any{
+		lesson.LessonNumber,
 		lesson.Title,
 		lesson.DurationMinutes,
 		lesson.Objectives,
@@ -164,36 +212,53 @@ func (m LessonModel) Update(lesson *Lesson) error {
 		lesson.Assessment,
 		lesson.Differentiation,
 		lesson.ID,
+		lesson.Version,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, TransactionTimeout)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&lesson.ID)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&lesson.Version)
 	if err != nil {
 		switch {
 		case err == sql.ErrNoRows:
-			return ErrRecordNotFound
+			return ErrEditConflict
+		case isDuplicateLessonNumber(err):
+			return ErrDuplicateLessonNumber
 		default:
 			return err
 		}
 	}
 
-	return nil
+	if err = insertLessonVersion(ctx, tx, lesson.ID, changedBy, description); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// Delete a lesson
-func (m LessonModel) Delete(id int64) error {
+// Delete moves a lesson to the trash.  It stays in the database, hidden,
+// until it is restored or purged.
+func (m LessonModel) Delete(ctx context.Context, id, deletedBy int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
-	query := `DELETE FROM lessons WHERE lesson_id = $1`
+	query := `
+		UPDATE lessons
+		SET deleted_at = NOW(), deleted_by = NULLIF($2, 0)
+		WHERE lesson_id = $1 AND deleted_at IS NULL`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	result, err := m.DB.ExecContext(ctx, query, id, deletedBy)
 	if err != nil {
 		return err
 	}
@@ -211,7 +276,7 @@ func (m LessonModel) Delete(id int64) error {
 }
 
 // CreateVersion creates a new version of a lesson
-func (m LessonModel) CreateVersion(version *LessonVersion) error {
+func (m LessonModel) CreateVersion(ctx context.Context, version *LessonVersion) error {
 	query := `
 		INSERT INTO lesson_versions (lesson_id, version_number, content, change_description, changed_by)
 		VALUES ($1, $2, $3, $4, $5)
@@ -225,21 +290,41 @@ func (m LessonModel) CreateVersion(version *LessonVersion) error {
 		version.ChangedBy,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	return m.DB.QueryRowContext(ctx, query, args...).Scan(&version.ID, &version.ChangedAt)
 }
 
-// GetVersions returns all versions of a lesson
-func (m LessonModel) GetVersions(lessonID int64) ([]*LessonVersion, error) {
+// insertLessonVersion records the lesson's content in tx as its next
+// version, unless it is the same as the latest version
+func insertLessonVersion(ctx context.Context, tx *sql.Tx, lessonID, changedBy int64, description string) error {
+	query := `
+		WITH latest AS (
+			SELECT version_number, content
+			FROM lesson_versions
+			WHERE lesson_id = $1
+			ORDER BY version_number DESC
+			LIMIT 1
+		)
+		INSERT INTO lesson_versions (lesson_id, version_number, content, change_description, changed_by)
+		SELECT l.lesson_id, COALESCE((SELECT version_number FROM latest), 0) + 1, l.content, NULLIF($3, ''), NULLIF($2, 0)
+		FROM lessons l
+		WHERE l.lesson_id = $1 AND l.content IS DISTINCT FROM (SELECT content FROM latest)`
+
+	_, err := tx.ExecContext(ctx, query, lessonID, changedBy, description)
+	return err
+}
+
+// GetVersions returns all versions of a lesson, newest first
+func (m LessonModel) GetVersions(ctx context.Context, lessonID int64) ([]*LessonVersion, error) {
 	query := `
-		SELECT version_id, lesson_id, version_number, content, change_description, changed_by, changed_at
+		SELECT version_id, lesson_id, version_number, content, change_description, COALESCE(changed_by, 0), changed_at
 		FROM lesson_versions
 		WHERE lesson_id = $1
 		ORDER BY version_number DESC`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	rows, err := m.DB.QueryContext(ctx, query, lessonID)
@@ -268,3 +353,44 @@ func (m LessonModel) GetVersions(lessonID int64) ([]*LessonVersion, error) {
 
 	return versions, rows.Err()
 }
+
+// GetVersion returns one version of a lesson.  A version number of 0 means
+// the latest.
+func (m LessonModel) GetVersion(ctx context.Context, lessonID int64, number int) (*LessonVersion, error) {
+	if number < 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT version_id, lesson_id, version_number, content, change_description, COALESCE(changed_by, 0), changed_at
+		FROM lesson_versions
+		WHERE lesson_id = $1 AND ($2 = 0 OR version_number = $2)
+		ORDER BY version_number DESC
+		LIMIT 1`
+
+	var version LessonVersion
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, lessonID, number).Scan(
+		&version.ID,
+		&version.LessonID,
+		&version.VersionNumber,
+		&version.Content,
+		&version.ChangeDescription,
+		&version.ChangedBy,
+		&version.ChangedAt,
+	)
+
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &version, nil
+}
@@ -0,0 +1,90 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// A trashed lesson gives up its number, and cannot be restored while another
+// lesson has it
+func TestTrashedLessonNumber(t *testing.T) {
+	db := newTestDB(t)
+	userID, resourceID := insertTestResource(t, db)
+
+	ctx := context.Background()
+	lessons := LessonModel{DB: db}
+	trash := TrashModel{DB: db}
+
+	insert := func(number int) (*Lesson, error) {
+		lesson := &Lesson{ResourceID: resourceID, LessonNumber: number, Title: "Lesson",
+			Content: `{"version":1,"blocks":[]}`}
+		return lesson, lessons.Insert(ctx, lesson, userID)
+	}
+
+	trashed, err := insert(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = insert(1); !errors.Is(err, ErrDuplicateLessonNumber) {
+		t.Errorf("second live lesson 1: error = %v, want ErrDuplicateLessonNumber", err)
+	}
+
+	if err = lessons.Delete(ctx, trashed.ID, userID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The number is free once the lesson is in the trash
+	replacement, err := insert(1)
+	if err != nil {
+		t.Fatalf("recreating lesson 1 after trashing it: %v", err)
+	}
+
+	err = trash.Restore(ctx, TrashLesson, trashed.ID, nil)
+	if !errors.Is(err, ErrDuplicateLessonNumber) {
+		t.Fatalf("restore while the number is taken: error = %v, want ErrDuplicateLessonNumber", err)
+	}
+
+	other, err := insert(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.LessonNumber = 1
+	if err = lessons.Update(ctx, other, userID, ""); !errors.Is(err, ErrDuplicateLessonNumber) {
+		t.Errorf("renumbering onto a live lesson: error = %v, want ErrDuplicateLessonNumber", err)
+	}
+
+	// Renumbering the replacement frees the number for the trashed lesson
+	replacement.LessonNumber = 3
+	if err = lessons.Update(ctx, replacement, userID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err = trash.Restore(ctx, TrashLesson, trashed.ID, nil); err != nil {
+		t.Fatalf("restore once the number is free: %v", err)
+	}
+
+	restored, err := lessons.Get(ctx, trashed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.LessonNumber != 1 {
+		t.Errorf("restored lesson number = %d, want 1", restored.LessonNumber)
+	}
+}
+
+func TestIsDuplicateLessonNumber(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New(`pq: duplicate key value violates unique constraint "uq_lessons_resource_number"`), true},
+		{errors.New(`pq: duplicate key value violates unique constraint "resources_slug_key"`), false},
+		{errors.New(`pq: insert or update on table "lessons" violates foreign key constraint "fk_lessons_resource"`), false},
+	}
+
+	for _, tt := range tests {
+		if got := isDuplicateLessonNumber(tt.err); got != tt.want {
+			t.Errorf("isDuplicateLessonNumber(%q) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+//filename: internal/data/login_throttles.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Failed sign-ins are counted against the account and against the client IP
+const (
+	ThrottleScopeAccount = "account"
+	ThrottleScopeIP      = "ip"
+)
+
+// ThrottlePolicy describes how failed sign-ins are punished.  The first
+// FreeAttempts failures cost nothing; after that each failure doubles the wait
+// (1s, 2s, 4s, ...) up to MaxBackoff.  Reaching LockoutAfter failures locks
+// the subject out for LockoutDuration.  Failures older than Window are forgotten.
+type ThrottlePolicy struct {
+	FreeAttempts    int
+	MaxBackoff      time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+// AccountThrottlePolicy applies to a single email address.  It is strict
+// because it is aimed at guessing one user's password.
+var AccountThrottlePolicy = ThrottlePolicy{
+	FreeAttempts:    3,
+	MaxBackoff:      5 * time.Minute,
+	LockoutAfter:    10,
+	LockoutDuration: 30 * time.Minute,
+	Window:          24 * time.Hour,
+}
+
+// IPThrottlePolicy applies to a client IP across every account.  It is looser
+// because a whole school can share one address, and it never locks out.
+var IPThrottlePolicy = ThrottlePolicy{
+	FreeAttempts: 20,
+	MaxBackoff:   15 * time.Minute,
+	Window:       time.Hour,
+}
+
+// Delay returns how long a subject must wait after its nth failure
+func (p ThrottlePolicy) Delay(failedCount int) time.Duration {
+	if p.LockoutAfter > 0 && failedCount >= p.LockoutAfter {
+		return p.LockoutDuration
+	}
+	if failedCount <= p.FreeAttempts {
+		return 0
+	}
+
+	delay := time.Second
+	for i := p.FreeAttempts + 1; i < failedCount && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxBackoff)
+}
+
+// LoginThrottle is the failed sign-in record for one account or IP
+type LoginThrottle struct {
+	Scope        string     `json:"-"`
+	Subject      string     `json:"-"`
+	FailedCount  int        `json:"failed_count"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	BlockedUntil *time.Time `json:"blocked_until,omitempty"`
+	Locked       bool       `json:"locked"`
+}
+
+// RetryAfter returns how long is left before the subject may try again
+func (l *LoginThrottle) RetryAfter() time.Duration {
+	if l == nil || l.BlockedUntil == nil {
+		return 0
+	}
+	return max(time.Until(*l.BlockedUntil), 0)
+}
+
+// ThrottleSubject normalises the subject so that "Ann@x.org" and "ann@x.org"
+// share a record
+func ThrottleSubject(scope, subject string) string {
+	if scope == ThrottleScopeAccount {
+		return strings.ToLower(strings.TrimSpace(subject))
+	}
+	return subject
+}
+
+type LoginThrottleModel struct {
+	DB *sql.DB
+}
+
+// Get returns the failed sign-in record for a subject
+func (m LoginThrottleModel) Get(ctx context.Context, scope, subject string, policy ThrottlePolicy) (*LoginThrottle, error) {
+	query := `
+		SELECT scope, subject, failed_count, last_failed_at, blocked_until
+		FROM login_throttles
+		WHERE scope = $1 AND subject = $2`
+
+	var throttle LoginThrottle
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, scope, ThrottleSubject(scope, subject)).Scan(
+		&throttle.Scope,
+		&throttle.Subject,
+		&throttle.FailedCount,
+		&throttle.LastFailedAt,
+		&throttle.BlockedUntil,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	throttle.Locked = policy.LockoutAfter > 0 && throttle.FailedCount >= policy.LockoutAfter &&
+		throttle.RetryAfter() > 0
+
+	return &throttle, nil
+}
+
+// RecordFailure counts a failed sign-in and sets the resulting backoff.
+// lockedNow is true only for the failure that first triggers a lockout, so
+// callers can notify the user exactly once.
+func (m LoginThrottleModel) RecordFailure(ctx context.Context, scope, subject string, policy ThrottlePolicy) (throttle *LoginThrottle, lockedNow bool, err error) {
+	query := `
+		INSERT INTO login_throttles (scope, subject, failed_count, last_failed_at)
+		VALUES ($1, $2, 1, NOW())
+		ON CONFLICT (scope, subject) DO UPDATE
+		SET failed_count = CASE
+		        WHEN login_throttles.last_failed_at < NOW() - make_interval(secs => $3) THEN 1
+		        ELSE login_throttles.failed_count + 1
+		    END,
+		    last_failed_at = NOW()
+		RETURNING failed_count`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	subject = ThrottleSubject(scope, subject)
+	throttle = &LoginThrottle{Scope: scope, Subject: subject, LastFailedAt: time.Now()}
+
+	err = tx.QueryRowContext(ctx, query, scope, subject, policy.Window.Seconds()).Scan(&throttle.FailedCount)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if delay := policy.Delay(throttle.FailedCount); delay > 0 {
+		blockedUntil := time.Now().Add(delay)
+		throttle.BlockedUntil = &blockedUntil
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE login_throttles SET blocked_until = $3 WHERE scope = $1 AND subject = $2",
+			scope, subject, blockedUntil)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	throttle.Locked = policy.LockoutAfter > 0 && throttle.FailedCount >= policy.LockoutAfter
+	lockedNow = policy.LockoutAfter > 0 && throttle.FailedCount == policy.LockoutAfter
+
+	return throttle, lockedNow, nil
+}
+
+// Clear forgets a subject's failed sign-ins, lifting any backoff or lockout.
+// audit is set when an admin lifts a lockout.
+func (m LoginThrottleModel) Clear(ctx context.Context, scope, subject string, audit *AuditEvent) error {
+	query := `
+		DELETE FROM login_throttles
+		WHERE scope = $1 AND subject = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query, scope, ThrottleSubject(scope, subject))
+	if err != nil {
+		return err
+	}
+
+	if err = insertAuditEvent(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,59 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottlePolicyDelay(t *testing.T) {
+	capped := ThrottlePolicy{MaxBackoff: 3 * time.Second}
+
+	tests := []struct {
+		name   string
+		policy ThrottlePolicy
+		failed int
+		want   time.Duration
+	}{
+		{"account: no failures", AccountThrottlePolicy, 0, 0},
+		{"account: first free failure", AccountThrottlePolicy, 1, 0},
+		{"account: last free failure", AccountThrottlePolicy, 3, 0},
+		{"account: first paid failure", AccountThrottlePolicy, 4, time.Second},
+		{"account: doubles", AccountThrottlePolicy, 5, 2 * time.Second},
+		{"account: doubles again", AccountThrottlePolicy, 6, 4 * time.Second},
+		{"account: last before lockout", AccountThrottlePolicy, 9, 32 * time.Second},
+		{"account: lockout", AccountThrottlePolicy, 10, 30 * time.Minute},
+		{"account: past lockout", AccountThrottlePolicy, 25, 30 * time.Minute},
+		{"ip: last free failure", IPThrottlePolicy, 20, 0},
+		{"ip: first paid failure", IPThrottlePolicy, 21, time.Second},
+		{"ip: below the cap", IPThrottlePolicy, 30, 512 * time.Second},
+		{"ip: capped", IPThrottlePolicy, 31, 15 * time.Minute},
+		{"ip: never locks out", IPThrottlePolicy, 1000, 15 * time.Minute},
+		{"no free attempts", capped, 1, time.Second},
+		{"cap between doublings", capped, 3, 3 * time.Second},
+		{"cap holds", capped, 100, 3 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Delay(tt.failed); got != tt.want {
+				t.Errorf("Delay(%d) = %v, want %v", tt.failed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThrottleSubject(t *testing.T) {
+	tests := []struct {
+		scope, subject, want string
+	}{
+		{ThrottleScopeAccount, " Ann@Example.org ", "ann@example.org"},
+		{ThrottleScopeIP, "203.0.113.7", "203.0.113.7"},
+		{ThrottleScopeIP, "2001:DB8::1", "2001:DB8::1"},
+	}
+
+	for _, tt := range tests {
+		if got := ThrottleSubject(tt.scope, tt.subject); got != tt.want {
+			t.Errorf("ThrottleSubject(%q, %q) = %q, want %q", tt.scope, tt.subject, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+//filename: internal/data/mfa.go
+
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+)
+
+// RecoveryCodeCount is how many recovery codes are issued at a time
+const RecoveryCodeCount = 10
+
+// MFA holds a user's TOTP enrollment.  The secret is only returned to the
+// user while they are enrolling.
+type MFA struct {
+	UserID       int64      `json:"-"`
+	Secret       string     `json:"-"`
+	Enabled      bool       `json:"enabled"`
+	LastUsedStep int64      `json:"-"`
+	EnabledAt    *time.Time `json:"enabled_at,omitempty"`
+}
+
+// GenerateRecoveryCodes returns new single-use recovery codes in the form
+// "abcde-fghij", together with the hashes that are stored
+func GenerateRecoveryCodes() ([]string, [][]byte, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	codes := make([]string, 0, RecoveryCodeCount)
+	hashes := make([][]byte, 0, RecoveryCodeCount)
+	for range RecoveryCodeCount {
+		randomBytes := make([]byte, 7)
+		_, err := rand.Read(randomBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(randomBytes))[:10]
+		code := raw[:5] + "-" + raw[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode normalises a recovery code as typed by the user and hashes it
+func hashRecoveryCode(code string) []byte {
+	code = strings.ToLower(strings.TrimSpace(code))
+	hash := sha256.Sum256([]byte(code))
+	return hash[:]
+}
+
+type MFAModel struct {
+	DB *sql.DB
+}
+
+// Get returns a user's TOTP enrollment
+func (m MFAModel) Get(ctx context.Context, userID int64) (*MFA, error) {
+	query := `
+		SELECT user_id, totp_secret, enabled, last_used_step, enabled_at
+		FROM user_mfa
+		WHERE user_id = $1`
+
+	var mfa MFA
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(
+		&mfa.UserID,
+		&mfa.Secret,
+		&mfa.Enabled,
+		&mfa.LastUsedStep,
+		&mfa.EnabledAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &mfa, nil
+}
+
+// SetSecret starts (or restarts) enrollment with a new secret.  MFA stays
+// disabled until Enable is called with a verified code.
+func (m MFAModel) SetSecret(ctx context.Context, userID int64, secret string) error {
+	query := `
+		INSERT INTO user_mfa (user_id, totp_secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET totp_secret = EXCLUDED.totp_secret, enabled = FALSE,
+		    last_used_step = 0, enabled_at = NULL, created_at = NOW()`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, secret)
+	return err
+}
+
+// Enable turns MFA on and stores the user's first set of recovery codes
+func (m MFAModel) Enable(ctx context.Context, userID int64, recoveryCodeHashes [][]byte) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE user_mfa SET enabled = TRUE, enabled_at = NOW() WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	if err = replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Disable removes a user's TOTP enrollment and recovery codes
+func (m MFAModel) Disable(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_mfa WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseStep records that a TOTP code from the given time step was accepted.
+// It returns false if that step (or a later one) was already used, which
+// stops a code from being replayed within its validity window.
+func (m MFAModel) UseStep(ctx context.Context, userID, step int64) (bool, error) {
+	query := `
+		UPDATE user_mfa
+		SET last_used_step = $2
+		WHERE user_id = $1 AND last_used_step < $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// ReplaceRecoveryCodes discards a user's recovery codes and stores new ones
+func (m MFAModel) ReplaceRecoveryCodes(ctx context.Context, userID int64, recoveryCodeHashes [][]byte) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64, recoveryCodeHashes [][]byte) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range recoveryCodeHashes {
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)",
+			userID, hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks a matching unused recovery code as used.  It returns
+// false if the code is wrong or was already used.
+func (m MFAModel) UseRecoveryCode(ctx context.Context, userID int64, code string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE code_id = (
+			SELECT code_id FROM mfa_recovery_codes
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+			LIMIT 1
+			FOR UPDATE
+		)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, hashRecoveryCode(code))
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left
+func (m MFAModel) CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM mfa_recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	var count int
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
@@ -4,19 +4,38 @@ package data
 
 import (
 	"database/sql"
+	"time"
+)
+
+// Every model method takes the caller's context, so a cancelled request stops
+// its queries, and bounds it with one of these timeouts.  Transactions that
+// write several tables get the longer one; the trash purge, which can delete
+// a backlog of rows, gets a minute and exports, which stream every matching
+// row to the client, get as long as the server allows for a response.
+const (
+	QueryTimeout       = 3 * time.Second
+	TransactionTimeout = 5 * time.Second
+	PurgeTimeout       = time.Minute
+	ExportTimeout      = 10 * time.Minute
 )
 
 // Models struct wraps all the data models
 type Models struct {
 	Users                 *UserModel
 	Roles                 RoleModelInterface
+	Permissions           PermissionModelInterface
 	Fellows               FellowModelInterface
 	FellowApplications    FellowApplicationModelInterface
 	Resources             ResourceModelInterface
 	ResourceReviews       *ResourceReviewModel
+	ResourceMembers       *ResourceMemberModel
 	ResourceAccess        *ResourceAccessModel
 	Contributions         ContributionModelInterface
 	Tokens                *TokenModel
+	MFA                   *MFAModel
+	LoginThrottles        LoginThrottleModelInterface
+	Audit                 *AuditModel
+	Trash                 *TrashModel
 	Notifications         NotificationModelInterface
 	Lessons               *LessonModel
 	VideoMetadata         *VideoModel
@@ -31,13 +50,19 @@ func NewModels(db *sql.DB) *Models {
 	return &Models{
 		Users:                 &UserModel{DB: db},
 		Roles:                 &RoleModel{DB: db},
+		Permissions:           &PermissionModel{DB: db},
 		Fellows:               &FellowModel{DB: db},
 		FellowApplications:    &FellowApplicationModel{DB: db},
 		Resources:             &ResourceModel{DB: db},
 		ResourceReviews:       &ResourceReviewModel{DB: db},
+		ResourceMembers:       &ResourceMemberModel{DB: db},
 		ResourceAccess:        &ResourceAccessModel{DB: db},
 		Contributions:         &ContributionModel{DB: db},
 		Tokens:                &TokenModel{DB: db},
+		MFA:                   &MFAModel{DB: db},
+		LoginThrottles:        &LoginThrottleModel{DB: db},
+		Audit:                 &AuditModel{DB: db},
+		Trash:                 &TrashModel{DB: db},
 		Notifications:         &NotificationModel{DB: db},
 		Lessons:               &LessonModel{DB: db},
 		VideoMetadata:         &VideoModel{DB: db},
@@ -55,13 +80,19 @@ func NewTestModels() *Models {
 	return &Models{
 		Users:                 &UserModel{DB: nil},
 		Roles:                 &RoleModel{DB: nil},
+		Permissions:           &PermissionModel{DB: nil},
 		Fellows:               &FellowModel{DB: nil},
 		FellowApplications:    &FellowApplicationModel{DB: nil},
 		Resources:             &ResourceModel{DB: nil},
 		ResourceReviews:       &ResourceReviewModel{DB: nil},
+		ResourceMembers:       &ResourceMemberModel{DB: nil},
 		ResourceAccess:        &ResourceAccessModel{DB: nil},
 		Contributions:         &ContributionModel{DB: nil},
 		Tokens:                &TokenModel{DB: nil},
+		MFA:                   &MFAModel{DB: nil},
+		LoginThrottles:        &LoginThrottleModel{DB: nil},
+		Audit:                 &AuditModel{DB: nil},
+		Trash:                 &TrashModel{DB: nil},
 		Notifications:         &NotificationModel{DB: nil},
 		Lessons:               &LessonModel{DB: nil},
 		VideoMetadata:         &VideoModel{DB: nil},
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -20,23 +21,23 @@ type NotificationModel struct {
 	DB *sql.DB
 }
 
-func (m *NotificationModel) Insert(n *Notification) error {
+func (m *NotificationModel) Insert(ctx context.Context, n *Notification) error {
 	query := `INSERT INTO notifications (user_id, message, channel) VALUES ($1,$2,$3) RETURNING notification_id, sent_at`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	return m.DB.QueryRowContext(ctx, query, n.UserID, n.Message, n.Channel).Scan(&n.ID, &n.SentAt)
 }
 
-func (m *NotificationModel) Get(id int) (*Notification, error) {
+func (m *NotificationModel) Get(ctx context.Context, id int) (*Notification, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 	query := `SELECT notification_id, user_id, message, channel, sent_at, read FROM notifications WHERE notification_id = $1`
 
 	var n Notification
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, id).Scan(&n.ID, &n.UserID, &n.Message, &n.Channel, &n.SentAt, &n.Read)
@@ -51,38 +52,64 @@ func (m *NotificationModel) Get(id int) (*Notification, error) {
 	return &n, nil
 }
 
-func (m *NotificationModel) GetByUser(userID int) ([]*Notification, error) {
-	query := `SELECT notification_id, user_id, message, channel, sent_at, read FROM notifications WHERE user_id = $1 ORDER BY sent_at DESC`
+// NotificationFields are the fields a user's notifications can be filtered
+// and sorted on
+var NotificationFields = Fields{
+	"notification_id": {Column: "notification_id", Type: IntField, Sortable: true},
+	"sent_at":         {Column: "sent_at", Type: TimeField, Ops: []Op{OpGte, OpLte}, Sortable: true},
+	"read":            {Column: "read", Type: BoolField, Ops: []Op{OpEq}},
+	"channel":         {Column: "channel", Type: TextField, Ops: []Op{OpEq, OpIn}},
+}
+
+// GetByUser returns a page of a user's notifications
+func (m *NotificationModel) GetByUser(ctx context.Context, userID int, filters Filters) ([]*Notification, Metadata, error) {
+	b := &queryBuilder{}
+	b.add("user_id = %s", userID)
+	b.filter(NotificationFields, filters.Conditions)
+	page := filters.paginate(b, filters.sortKeys(NotificationFields), "notification_id")
+
+	query := fmt.Sprintf(`
+		SELECT %s, notification_id, user_id, message, channel, sent_at, read
+		FROM notifications
+		WHERE %s
+		ORDER BY %s
+		%s`, page.count, b.where(), page.orderBy, page.limit)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query, userID)
+	rows, err := m.DB.QueryContext(ctx, query, b.args...)
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 	defer rows.Close()
 
+	totalRecords := 0
 	out := []*Notification{}
 	for rows.Next() {
 		var n Notification
-		if err := rows.Scan(&n.ID, &n.UserID, &n.Message, &n.Channel, &n.SentAt, &n.Read); err != nil {
-			return nil, err
+		if err := rows.Scan(&totalRecords, &n.ID, &n.UserID, &n.Message, &n.Channel, &n.SentAt, &n.Read); err != nil {
+			return nil, Metadata{}, err
 		}
 		out = append(out, &n)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
-	return out, nil
+
+	out, metadata := pageResults(filters, page, out, totalRecords, func(n *Notification) (map[string]any, int64) {
+		return map[string]any{"notification_id": n.ID, "sent_at": n.SentAt}, int64(n.ID)
+	})
+
+	return out, metadata, nil
 }
 
-func (m *NotificationModel) Delete(id int) error {
+func (m *NotificationModel) Delete(ctx context.Context, id int) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 	query := `DELETE FROM notifications WHERE notification_id = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 	res, err := m.DB.ExecContext(ctx, query, id)
 	if err != nil {
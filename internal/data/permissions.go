@@ -0,0 +1,133 @@
+// Filename: internal/data/permissions.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Permission struct represents a single grantable action, e.g. "resource:delete"
+type Permission struct {
+	ID          int     `json:"id"`
+	Code        string  `json:"code"`
+	Description *string `json:"description,omitempty"`
+}
+
+// Permissions holds the permission codes granted to a role
+type Permissions []string
+
+// Include reports whether the given permission code is in the slice
+func (p Permissions) Include(code string) bool {
+	return slices.Contains(p, code)
+}
+
+// ValidatePermissionCodes checks that every requested code is a known permission
+func ValidatePermissionCodes(v *validator.Validator, codes []string, known []*Permission) {
+	knownCodes := make([]string, 0, len(known))
+	for _, p := range known {
+		knownCodes = append(knownCodes, p.Code)
+	}
+	for _, code := range codes {
+		v.Check(validator.PermittedValue(code, knownCodes...), "permissions", "contains an unknown permission: "+code)
+	}
+}
+
+// PermissionModel wraps a database connection pool
+type PermissionModel struct {
+	DB *sql.DB
+}
+
+// GetAll retrieves every permission defined in the system
+func (m *PermissionModel) GetAll(ctx context.Context) ([]*Permission, error) {
+	query := `
+		SELECT permission_id, code, description
+		FROM permissions
+		ORDER BY code`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []*Permission{}
+	for rows.Next() {
+		var permission Permission
+		if err := rows.Scan(&permission.ID, &permission.Code, &permission.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, &permission)
+	}
+
+	return permissions, rows.Err()
+}
+
+// GetAllForRole returns the permission codes granted to a role
+func (m *PermissionModel) GetAllForRole(ctx context.Context, roleID int) (Permissions, error) {
+	query := `
+		SELECT p.code
+		FROM permissions p
+		INNER JOIN role_permissions rp ON rp.permission_id = p.permission_id
+		WHERE rp.role_id = $1
+		ORDER BY p.code`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, roleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := Permissions{}
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, code)
+	}
+
+	return permissions, rows.Err()
+}
+
+// SetForRole replaces all permissions granted to a role with the given codes
+func (m *PermissionModel) SetForRole(ctx context.Context, roleID int, codes []string, audit *AuditEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM role_permissions WHERE role_id = $1", roleID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1, permission_id FROM permissions
+		WHERE code = ANY($2)`
+
+	_, err = tx.ExecContext(ctx, query, roleID, pq.Array(codes))
+	if err != nil {
+		return err
+	}
+
+	if err = insertAuditEvent(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
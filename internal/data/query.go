@@ -0,0 +1,253 @@
+//filename: internal/data/query.go
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Op is a filter operator, written field[op]=value in a query string
+type Op string
+
+const (
+	OpEq       Op = "eq"
+	OpIn       Op = "in" // comma-separated alternatives
+	OpGte      Op = "gte"
+	OpLte      Op = "lte"
+	OpContains Op = "contains" // case-insensitive substring
+)
+
+// FieldType says how a filter value is parsed
+type FieldType int
+
+const (
+	TextField FieldType = iota
+	IntField
+	BoolField
+	// TimeField takes an RFC 3339 timestamp or a YYYY-MM-DD date.  A date
+	// given to lte covers the whole day, so gte/lte make an inclusive range.
+	TimeField
+)
+
+// Field is something a list can be filtered or sorted on.  Column is the SQL
+// expression it maps to.  Ops lists the operators it accepts; the first one
+// is used when the query string gives none (field=value).  A field without
+// Ops can only be sorted on.
+type Field struct {
+	Column   string
+	Type     FieldType
+	Ops      []Op
+	Sortable bool
+}
+
+// Fields maps the field names a list exposes in its API to their columns
+type Fields map[string]Field
+
+// Condition is one filter on a list, such as created_at[gte]=2024-01-01
+type Condition struct {
+	Field string
+	Op    Op
+	Value string
+}
+
+// ValidateQuery checks the pagination, sort and filter conditions of a list
+// against the fields it exposes
+func ValidateQuery(v *validator.Validator, f Filters, fields Fields) {
+	validatePage(v, f)
+	validateConditions(v, f, fields)
+}
+
+// ValidateExportQuery checks the sort and filter conditions of an export,
+// which is not paged
+func ValidateExportQuery(v *validator.Validator, f Filters, fields Fields) {
+	validateConditions(v, f, fields)
+}
+
+// validateConditions checks the sort fields and filter conditions
+func validateConditions(v *validator.Validator, f Filters, fields Fields) {
+	for _, name := range f.sortFields() {
+		field, ok := fields[strings.TrimPrefix(name, "-")]
+		v.Check(ok && field.Sortable, "sort", fmt.Sprintf("cannot sort by %q", name))
+	}
+
+	for _, c := range f.Conditions {
+		field, ok := fields[c.Field]
+		switch {
+		case !ok:
+			v.AddError(c.Field, "is not a supported filter")
+		case !slices.Contains(field.Ops, c.Op):
+			v.AddError(c.Field, fmt.Sprintf("does not support %q", c.Op))
+		default:
+			if _, _, err := field.operand(c.Op, c.Value); err != nil {
+				v.AddError(c.Field, err.Error())
+			}
+		}
+	}
+}
+
+// operand returns the SQL that follows the column for a condition, with a %s
+// where the parameter goes, and the parameter itself
+func (f Field) operand(op Op, raw string) (string, any, error) {
+	if op == OpIn {
+		parts := strings.Split(raw, ",")
+		switch f.Type {
+		case IntField:
+			ids := make([]int64, len(parts))
+			for i, part := range parts {
+				id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+				if err != nil {
+					return "", nil, errors.New("must be a comma-separated list of integers")
+				}
+				ids[i] = id
+			}
+			return "= ANY(%s)", pq.Array(ids), nil
+		default:
+			for i := range parts {
+				parts[i] = strings.TrimSpace(parts[i])
+			}
+			return "= ANY(%s)", pq.Array(parts), nil
+		}
+	}
+
+	value, dateOnly, err := f.parse(raw)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch op {
+	case OpGte:
+		return ">= %s", value, nil
+	case OpLte:
+		if dateOnly {
+			return "< %s", value.(time.Time).AddDate(0, 0, 1), nil
+		}
+		return "<= %s", value, nil
+	case OpContains:
+		return "ILIKE '%%' || %s || '%%'", likeEscaper.Replace(raw), nil
+	default:
+		return "= %s", value, nil
+	}
+}
+
+// likeEscaper stops % and _ in a contains value from acting as wildcards
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// parse converts a raw filter value to the field's type.  dateOnly reports a
+// time given as a bare date.
+func (f Field) parse(raw string) (value any, dateOnly bool, err error) {
+	switch f.Type {
+	case IntField:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, false, errors.New("must be an integer value")
+		}
+		return n, false, nil
+	case BoolField:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, false, errors.New("must be true or false")
+		}
+		return b, false, nil
+	case TimeField:
+		if t, err := time.Parse(time.DateOnly, raw); err == nil {
+			return t, true, nil
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, false, errors.New("must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+		}
+		return t, false, nil
+	default:
+		return raw, false, nil
+	}
+}
+
+// queryBuilder collects the WHERE conditions of a list query and their
+// parameters, numbering the placeholders as it goes
+type queryBuilder struct {
+	args       []any
+	conditions []string
+}
+
+// param adds a parameter and returns its placeholder
+func (b *queryBuilder) param(value any) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// add appends a condition.  Each %s in format is replaced by the placeholder
+// for the matching value.
+func (b *queryBuilder) add(format string, values ...any) {
+	placeholders := make([]any, len(values))
+	for i, value := range values {
+		placeholders[i] = b.param(value)
+	}
+	b.conditions = append(b.conditions, fmt.Sprintf(format, placeholders...))
+}
+
+// filter adds the conditions given in the query string.  They must already
+// have passed ValidateQuery.
+func (b *queryBuilder) filter(fields Fields, conditions []Condition) {
+	for _, c := range conditions {
+		field, ok := fields[c.Field]
+		if !ok {
+			continue
+		}
+		operand, value, err := field.operand(c.Op, c.Value)
+		if err != nil {
+			continue
+		}
+		b.add(field.Column+" "+operand, value)
+	}
+}
+
+// where returns the conditions joined for a WHERE clause
+func (b *queryBuilder) where() string {
+	if len(b.conditions) == 0 {
+		return "TRUE"
+	}
+	return strings.Join(b.conditions, "\n\t\tAND ")
+}
+
+// sortKey is one column of a list's sort order
+type sortKey struct {
+	field  string
+	column string
+	desc   bool
+}
+
+// sortKeys resolves the sort fields against the list's columns
+func (f Filters) sortKeys(fields Fields) []sortKey {
+	var keys []sortKey
+	for _, name := range f.sortFields() {
+		field, ok := fields[strings.TrimPrefix(name, "-")]
+		if !ok || !field.Sortable {
+			continue
+		}
+		keys = append(keys, sortKey{
+			field:  strings.TrimPrefix(name, "-"),
+			column: field.Column,
+			desc:   strings.HasPrefix(name, "-"),
+		})
+	}
+	return keys
+}
+
+// sortFields splits a sort such as "-created_at,title" into its fields
+func (f Filters) sortFields() []string {
+	var names []string
+	for _, name := range strings.Split(f.Sort, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
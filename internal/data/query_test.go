@@ -0,0 +1,200 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+	"github.com/lib/pq"
+)
+
+func TestFieldOperand(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	instant := time.Date(2024, 5, 1, 13, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		field   Field
+		op      Op
+		raw     string
+		operand string
+		value   any
+		err     string
+	}{
+		{name: "text eq", field: Field{Type: TextField}, op: OpEq, raw: "Math", operand: "= %s", value: "Math"},
+		{name: "text in", field: Field{Type: TextField}, op: OpIn, raw: "Math, Science", operand: "= ANY(%s)",
+			value: pq.Array([]string{"Math", "Science"})},
+		{name: "int eq", field: Field{Type: IntField}, op: OpEq, raw: "42", operand: "= %s", value: int64(42)},
+		{name: "int eq not a number", field: Field{Type: IntField}, op: OpEq, raw: "4x", err: "must be an integer value"},
+		{name: "int in", field: Field{Type: IntField}, op: OpIn, raw: "1, 2,3", operand: "= ANY(%s)",
+			value: pq.Array([]int64{1, 2, 3})},
+		{name: "int in not numbers", field: Field{Type: IntField}, op: OpIn, raw: "1,two",
+			err: "must be a comma-separated list of integers"},
+		{name: "int gte", field: Field{Type: IntField}, op: OpGte, raw: "5", operand: ">= %s", value: int64(5)},
+		{name: "bool eq", field: Field{Type: BoolField}, op: OpEq, raw: "true", operand: "= %s", value: true},
+		{name: "bool eq not a bool", field: Field{Type: BoolField}, op: OpEq, raw: "yes", err: "must be true or false"},
+		{name: "date gte", field: Field{Type: TimeField}, op: OpGte, raw: "2024-05-01", operand: ">= %s", value: day},
+		{name: "date lte covers the day", field: Field{Type: TimeField}, op: OpLte, raw: "2024-05-01",
+			operand: "< %s", value: day.AddDate(0, 0, 1)},
+		{name: "timestamp lte", field: Field{Type: TimeField}, op: OpLte, raw: "2024-05-01T13:30:00Z",
+			operand: "<= %s", value: instant},
+		{name: "time not a date", field: Field{Type: TimeField}, op: OpGte, raw: "May 1",
+			err: "must be a date (YYYY-MM-DD) or RFC 3339 timestamp"},
+		{name: "contains", field: Field{Type: TextField}, op: OpContains, raw: "robot",
+			operand: "ILIKE '%%' || %s || '%%'", value: "robot"},
+		{name: "contains escapes wildcards", field: Field{Type: TextField}, op: OpContains, raw: `50%_off\now`,
+			operand: "ILIKE '%%' || %s || '%%'", value: `50\%\_off\\now`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operand, value, err := tt.field.operand(tt.op, tt.raw)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if operand != tt.operand {
+				t.Errorf("operand = %q, want %q", operand, tt.operand)
+			}
+			if !reflect.DeepEqual(value, tt.value) {
+				t.Errorf("value = %#v, want %#v", value, tt.value)
+			}
+		})
+	}
+}
+
+func TestFieldParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    Field
+		raw      string
+		value    any
+		dateOnly bool
+		wantErr  bool
+	}{
+		{name: "text", field: Field{Type: TextField}, raw: " as is ", value: " as is "},
+		{name: "int", field: Field{Type: IntField}, raw: "-3", value: int64(-3)},
+		{name: "int overflow", field: Field{Type: IntField}, raw: "9223372036854775808", wantErr: true},
+		{name: "bool", field: Field{Type: BoolField}, raw: "0", value: false},
+		{name: "date", field: Field{Type: TimeField}, raw: "2024-02-29",
+			value: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), dateOnly: true},
+		{name: "timestamp with offset", field: Field{Type: TimeField}, raw: "2024-02-29T08:00:00-06:00",
+			value: time.Date(2024, 2, 29, 14, 0, 0, 0, time.UTC)},
+		{name: "impossible date", field: Field{Type: TimeField}, raw: "2023-02-29", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, dateOnly, err := tt.field.parse(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got, ok := value.(time.Time); ok {
+				if !got.Equal(tt.value.(time.Time)) {
+					t.Errorf("value = %v, want %v", got, tt.value)
+				}
+			} else if value != tt.value {
+				t.Errorf("value = %#v, want %#v", value, tt.value)
+			}
+			if dateOnly != tt.dateOnly {
+				t.Errorf("dateOnly = %v, want %v", dateOnly, tt.dateOnly)
+			}
+		})
+	}
+}
+
+func TestValidateConditions(t *testing.T) {
+	fields := Fields{
+		"title":      {Column: "r.title", Type: TextField, Ops: []Op{OpContains, OpEq}, Sortable: true},
+		"created_at": {Column: "r.created_at", Type: TimeField, Ops: []Op{OpGte, OpLte}, Sortable: true},
+		"status":     {Column: "r.status", Type: TextField, Ops: []Op{OpEq, OpIn}},
+	}
+
+	tests := []struct {
+		name       string
+		sort       string
+		conditions []Condition
+		want       map[string]string
+	}{
+		{
+			name: "valid",
+			sort: "-created_at,title",
+			conditions: []Condition{
+				{Field: "title", Op: OpContains, Value: "robot"},
+				{Field: "created_at", Op: OpLte, Value: "2024-05-01"},
+				{Field: "status", Op: OpIn, Value: "Draft,Published"},
+			},
+			want: map[string]string{},
+		},
+		{
+			name:       "unsupported field",
+			conditions: []Condition{{Field: "password_hash", Op: OpEq, Value: "x"}},
+			want:       map[string]string{"password_hash": "is not a supported filter"},
+		},
+		{
+			name:       "unsupported operator",
+			conditions: []Condition{{Field: "status", Op: OpContains, Value: "Dra"}},
+			want:       map[string]string{"status": `does not support "contains"`},
+		},
+		{
+			name:       "bad value",
+			conditions: []Condition{{Field: "created_at", Op: OpGte, Value: "yesterday"}},
+			want:       map[string]string{"created_at": "must be a date (YYYY-MM-DD) or RFC 3339 timestamp"},
+		},
+		{
+			name: "field that cannot be sorted on",
+			sort: "status",
+			want: map[string]string{"sort": `cannot sort by "status"`},
+		},
+		{
+			name: "unknown sort field",
+			sort: "-rank",
+			want: map[string]string{"sort": `cannot sort by "-rank"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			validateConditions(v, Filters{Sort: tt.sort, Conditions: tt.conditions}, fields)
+			if !reflect.DeepEqual(v.Errors, tt.want) {
+				t.Errorf("errors = %v, want %v", v.Errors, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryBuilderFilter(t *testing.T) {
+	fields := Fields{
+		"created_at": {Column: "r.created_at", Type: TimeField, Ops: []Op{OpGte, OpLte}},
+		"status":     {Column: "r.status", Type: TextField, Ops: []Op{OpEq, OpIn}},
+	}
+
+	b := &queryBuilder{}
+	b.add("r.deleted_at IS NULL")
+	b.filter(fields, []Condition{
+		{Field: "status", Op: OpEq, Value: "Draft"},
+		{Field: "created_at", Op: OpLte, Value: "2024-05-01"},
+		{Field: "unknown", Op: OpEq, Value: "skipped"},
+	})
+
+	want := "r.deleted_at IS NULL\n\t\tAND r.status = $1\n\t\tAND r.created_at < $2"
+	if got := b.where(); got != want {
+		t.Errorf("where = %q, want %q", got, want)
+	}
+	if len(b.args) != 2 {
+		t.Fatalf("args = %#v, want 2", b.args)
+	}
+	if got := b.args[1].(time.Time); !got.Equal(time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("lte bound = %v, want the start of the next day", got)
+	}
+}
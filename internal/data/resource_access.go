@@ -5,6 +5,7 @@ package data
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
 )
 
@@ -20,7 +21,7 @@ type ResourceAccessModel struct {
 }
 
 // Insert a new resource access record into the database
-func (m ResourceAccessModel) Insert(access *ResourceAccess) error {
+func (m ResourceAccessModel) Insert(ctx context.Context, access *ResourceAccess) error {
 	query := `
 		INSERT INTO resource_access (resource_id, user_id)
 		VALUES ($1, $2)
@@ -31,14 +32,14 @@ func (m ResourceAccessModel) Insert(access *ResourceAccess) error {
 		access.UserID,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	return m.DB.QueryRowContext(ctx, query, args...).Scan(&access.ID, &access.AccessedAt)
 }
 
 // Get a resource access record by ID
-func (m ResourceAccessModel) Get(id int64) (*ResourceAccess, error) {
+func (m ResourceAccessModel) Get(ctx context.Context, id int64) (*ResourceAccess, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -50,7 +51,7 @@ func (m ResourceAccessModel) Get(id int64) (*ResourceAccess, error) {
 
 	var access ResourceAccess
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, id).Scan(
@@ -72,21 +73,51 @@ func (m ResourceAccessModel) Get(id int64) (*ResourceAccess, error) {
 	return &access, nil
 }
 
+// ResourceAccessFields are the fields access records can be filtered and
+// sorted on
+var ResourceAccessFields = Fields{
+	"access_id":   {Column: "access_id", Type: IntField, Sortable: true},
+	"accessed_at": {Column: "accessed_at", Type: TimeField, Ops: []Op{OpGte, OpLte}, Sortable: true},
+	"resource_id": {Column: "resource_id", Type: IntField, Ops: []Op{OpEq, OpIn}},
+	"user_id":     {Column: "user_id", Type: IntField, Ops: []Op{OpEq, OpIn}},
+}
+
 // Get all access records for a specific resource
-func (m ResourceAccessModel) GetByResourceID(resourceID int64, filters Filters) ([]*ResourceAccess, Metadata, error) {
-	query := `
-		SELECT COUNT(*) OVER(), access_id, resource_id, user_id, accessed_at
+func (m ResourceAccessModel) GetByResourceID(ctx context.Context, resourceID int64, filters Filters) ([]*ResourceAccess, Metadata, error) {
+	b := &queryBuilder{}
+	b.add("resource_id = %s", resourceID)
+	return m.list(ctx, b, filters)
+}
+
+// Get all access records for a specific user
+func (m ResourceAccessModel) GetByUserID(ctx context.Context, userID int64, filters Filters) ([]*ResourceAccess, Metadata, error) {
+	b := &queryBuilder{}
+	b.add("user_id = %s", userID)
+	return m.list(ctx, b, filters)
+}
+
+// Get all access records
+func (m ResourceAccessModel) GetAll(ctx context.Context, filters Filters) ([]*ResourceAccess, Metadata, error) {
+	return m.list(ctx, &queryBuilder{}, filters)
+}
+
+// list returns a page of access records matching the builder's conditions
+// and the filters' own
+func (m ResourceAccessModel) list(ctx context.Context, b *queryBuilder, filters Filters) ([]*ResourceAccess, Metadata, error) {
+	b.filter(ResourceAccessFields, filters.Conditions)
+	page := filters.paginate(b, filters.sortKeys(ResourceAccessFields), "access_id")
+
+	query := fmt.Sprintf(`
+		SELECT %s, access_id, resource_id, user_id, accessed_at
 		FROM resource_access
-		WHERE resource_id = $1
-		ORDER BY accessed_at DESC
-		LIMIT $2 OFFSET $3`
+		WHERE %s
+		ORDER BY %s
+		%s`, page.count, b.where(), page.orderBy, page.limit)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	args := []any{resourceID, filters.limit(), filters.offset()}
-
-	rows, err := m.DB.QueryContext(ctx, query, args...)
+	rows, err := m.DB.QueryContext(ctx, query, b.args...)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
@@ -114,60 +145,42 @@ func (m ResourceAccessModel) GetByResourceID(resourceID int64, filters Filters)
 		return nil, Metadata{}, err
 	}
 
-	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	accessRecords, metadata := pageResults(filters, page, accessRecords, totalRecords, func(a *ResourceAccess) (map[string]any, int64) {
+		return map[string]any{"access_id": a.ID, "accessed_at": a.AccessedAt}, a.ID
+	})
 
 	return accessRecords, metadata, nil
 }
 
-// Get all access records for a specific user
-func (m ResourceAccessModel) GetByUserID(userID int64, filters Filters) ([]*ResourceAccess, Metadata, error) {
-	query := `
-		SELECT COUNT(*) OVER(), access_id, resource_id, user_id, accessed_at
-		FROM resource_access
-		WHERE user_id = $1
-		ORDER BY accessed_at DESC
-		LIMIT $2 OFFSET $3`
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
-	args := []any{userID, filters.limit(), filters.offset()}
+// Export calls fn for every access record matching the filter conditions,
+// in the same order as GetAll but without paging
+func (m ResourceAccessModel) Export(ctx context.Context, filters Filters, fn func(*ResourceAccess) error) error {
+	b := &queryBuilder{}
+	b.filter(ResourceAccessFields, filters.Conditions)
 
-	rows, err := m.DB.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, Metadata{}, err
-	}
-	defer rows.Close()
-
-	totalRecords := 0
-	accessRecords := []*ResourceAccess{}
+	query := fmt.Sprintf(`
+		SELECT access_id, resource_id, user_id, accessed_at
+		FROM resource_access
+		WHERE %s
+		ORDER BY %s`, b.where(), orderClause(withIDKey(filters.sortKeys(ResourceAccessFields), "access_id")))
 
-	for rows.Next() {
+	return exportRows(ctx, m.DB, query, b.args, func(rows *sql.Rows) error {
 		var access ResourceAccess
 		err := rows.Scan(
-			&totalRecords,
 			&access.ID,
 			&access.ResourceID,
 			&access.UserID,
 			&access.AccessedAt,
 		)
 		if err != nil {
-			return nil, Metadata{}, err
+			return err
 		}
-		accessRecords = append(accessRecords, &access)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, Metadata{}, err
-	}
-
-	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
-
-	return accessRecords, metadata, nil
+		return fn(&access)
+	})
 }
 
 // Delete a resource access record
-func (m ResourceAccessModel) Delete(id int64) error {
+func (m ResourceAccessModel) Delete(ctx context.Context, id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
@@ -176,7 +189,7 @@ func (m ResourceAccessModel) Delete(id int64) error {
 		DELETE FROM resource_access
 		WHERE access_id = $1`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	result, err := m.DB.ExecContext(ctx, query, id)
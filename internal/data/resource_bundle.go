@@ -0,0 +1,380 @@
+//filename: internal/data/resource_bundle.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ResourceBundle is a resource together with the rows that hang off it.  It
+// is written as a unit so a failure part way through leaves nothing behind.
+// Audit, if set, is recorded in the same transaction.  Every write also adds
+// a revision of the resource, credited to RevisionBy and described by
+// RevisionNote.  DryRun runs every check and write and then rolls the
+// transaction back, so callers can find out whether a change would succeed
+// without making it.
+type ResourceBundle struct {
+	Resource      *Resource
+	Subjects      []string
+	GradeLevels   []string
+	Lessons       []*Lesson
+	VideoMetadata *VideoMetadata
+	Audit         *AuditEvent
+	RevisionBy    int64
+	RevisionNote  string
+	DryRun        bool
+}
+
+// UnknownValuesError reports subjects or grade levels that are not in their
+// lookup tables.  It matches ErrUnknownSubject when any subject is unknown.
+type UnknownValuesError struct {
+	Subjects    []string
+	GradeLevels []string
+}
+
+func (e *UnknownValuesError) Error() string {
+	var parts []string
+	if len(e.Subjects) > 0 {
+		parts = append(parts, "unknown subjects: "+strings.Join(e.Subjects, ", "))
+	}
+	if len(e.GradeLevels) > 0 {
+		parts = append(parts, "unknown grade levels: "+strings.Join(e.GradeLevels, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *UnknownValuesError) Is(target error) bool {
+	return target == ErrUnknownSubject && len(e.Subjects) > 0
+}
+
+// Create inserts a resource with its subjects, grade levels, lessons and video
+// metadata in one transaction.  Lessons without a number are numbered in
+// order from 1.  Unknown subjects or grade levels return an
+// *UnknownValuesError and nothing is written.
+func (m ResourceModel) Create(ctx context.Context, bundle ResourceBundle) error {
+	ctx, cancel := context.WithTimeout(ctx, TransactionTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = m.createBundle(ctx, tx, bundle); err != nil {
+		return err
+	}
+
+	return commitBundle(ctx, tx, bundle)
+}
+
+// CreateBatch inserts many resources in one transaction.  Each bundle is
+// written under its own savepoint, so one that fails is undone on its own and
+// the rest still go in.  The returned slice holds each bundle's error, or nil;
+// the error is for failures that stop the whole batch.  An audit event with
+// no target is pointed at the new resource.  With dryRun set the transaction
+// is rolled back at the end.
+func (m ResourceModel) CreateBatch(ctx context.Context, bundles []ResourceBundle, dryRun bool) ([]error, error) {
+	ctx, cancel := context.WithTimeout(ctx, TransactionTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	errs := make([]error, len(bundles))
+	for i, bundle := range bundles {
+		if _, err = tx.ExecContext(ctx, "SAVEPOINT bundle"); err != nil {
+			return nil, err
+		}
+
+		errs[i] = m.createBundle(ctx, tx, bundle)
+		if errs[i] == nil {
+			if bundle.Audit != nil && bundle.Audit.TargetID == 0 {
+				bundle.Audit.TargetID = bundle.Resource.ID
+			}
+			errs[i] = insertAuditEvent(ctx, tx, bundle.Audit)
+		}
+
+		release := "RELEASE SAVEPOINT bundle"
+		if errs[i] != nil {
+			release = "ROLLBACK TO SAVEPOINT bundle"
+		}
+		if _, err = tx.ExecContext(ctx, release); err != nil {
+			return nil, err
+		}
+	}
+
+	if dryRun {
+		return errs, tx.Rollback()
+	}
+	return errs, tx.Commit()
+}
+
+// createBundle writes a new resource and everything attached to it
+func (m ResourceModel) createBundle(ctx context.Context, tx *sql.Tx, bundle ResourceBundle) error {
+	if err := checkLookupValues(ctx, tx, bundle.Subjects, bundle.GradeLevels); err != nil {
+		return err
+	}
+
+	resource := bundle.Resource
+	if err := insertResource(ctx, tx, resource); err != nil {
+		return err
+	}
+
+	if err := replaceResourceSubjects(ctx, tx, resource.ID, bundle.Subjects); err != nil {
+		return err
+	}
+	if err := replaceResourceGradeLevels(ctx, tx, resource.ID, bundle.GradeLevels); err != nil {
+		return err
+	}
+
+	for i, lesson := range bundle.Lessons {
+		lesson.ResourceID = resource.ID
+		if lesson.LessonNumber == 0 {
+			lesson.LessonNumber = i + 1
+		}
+		if err := insertLesson(ctx, tx, lesson, bundle.RevisionBy); err != nil {
+			return err
+		}
+	}
+
+	if bundle.VideoMetadata != nil {
+		bundle.VideoMetadata.ResourceID = resource.ID
+		if err := (VideoModel{DB: m.DB}).InsertTx(ctx, tx, bundle.VideoMetadata); err != nil {
+			return err
+		}
+	}
+
+	return insertResourceRevision(ctx, tx, resource.ID, bundle.RevisionBy, bundle.RevisionNote)
+}
+
+// UpdateBundle updates a resource and replaces its subjects and grade levels
+// in one transaction.  A nil Subjects or GradeLevels leaves that list as it
+// is; Lessons and VideoMetadata are not touched.  Status changes are checked
+// as in Update.
+func (m ResourceModel) UpdateBundle(ctx context.Context, bundle ResourceBundle) error {
+	ctx, cancel := context.WithTimeout(ctx, TransactionTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = checkLookupValues(ctx, tx, bundle.Subjects, bundle.GradeLevels); err != nil {
+		return err
+	}
+
+	resource := bundle.Resource
+	if err = updateResource(ctx, tx, resource); err != nil {
+		return err
+	}
+
+	if bundle.Subjects != nil {
+		if err = replaceResourceSubjects(ctx, tx, resource.ID, bundle.Subjects); err != nil {
+			return err
+		}
+	}
+	if bundle.GradeLevels != nil {
+		if err = replaceResourceGradeLevels(ctx, tx, resource.ID, bundle.GradeLevels); err != nil {
+			return err
+		}
+	}
+
+	if err = insertResourceRevision(ctx, tx, resource.ID, bundle.RevisionBy, bundle.RevisionNote); err != nil {
+		return err
+	}
+
+	return commitBundle(ctx, tx, bundle)
+}
+
+// commitBundle records the bundle's audit event and commits, or rolls back a
+// dry run
+func commitBundle(ctx context.Context, tx *sql.Tx, bundle ResourceBundle) error {
+	if err := insertAuditEvent(ctx, tx, bundle.Audit); err != nil {
+		return err
+	}
+	if bundle.DryRun {
+		return tx.Rollback()
+	}
+	return tx.Commit()
+}
+
+// checkLookupValues returns an *UnknownValuesError naming every subject and
+// grade level missing from the lookup tables
+func checkLookupValues(ctx context.Context, tx *sql.Tx, subjects, gradeLevels []string) error {
+	unknownSubjects, err := unknownValues(ctx, tx, "subjects", "subject", subjects)
+	if err != nil {
+		return err
+	}
+	unknownGrades, err := unknownValues(ctx, tx, "grade_levels", "grade_level", gradeLevels)
+	if err != nil {
+		return err
+	}
+
+	if len(unknownSubjects) > 0 || len(unknownGrades) > 0 {
+		return &UnknownValuesError{Subjects: unknownSubjects, GradeLevels: unknownGrades}
+	}
+	return nil
+}
+
+// unknownValues returns the values that have no row in a lookup table
+func unknownValues(ctx context.Context, tx *sql.Tx, table, column string, values []string) ([]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT DISTINCT v.value
+		FROM unnest($1::text[]) AS v(value)
+		WHERE NOT EXISTS (SELECT 1 FROM %s l WHERE l.%s = v.value)
+		ORDER BY v.value`, table, column)
+
+	rows, err := tx.QueryContext(ctx, query, pq.Array(values))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var unknown []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		unknown = append(unknown, value)
+	}
+
+	return unknown, rows.Err()
+}
+
+func insertResource(ctx context.Context, tx *sql.Tx, resource *Resource) error {
+	query := `
+		INSERT INTO resources (title, category, slug, summary, drive_link, status, published_url, contributor_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING resource_id, created_at, updated_at, version`
+
+	args := []any{
+		resource.Title,
+		resource.Category,
+		resource.Slug,
+		resource.Summary,
+		resource.DriveLink,
+		resource.Status,
+		resource.PublishedURL,
+		resource.ContributorID,
+	}
+
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&resource.ID, &resource.CreatedAt, &resource.UpdatedAt, &resource.Version)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "duplicate key value violates unique constraint") && strings.Contains(err.Error(), "resources_slug_key"):
+			return ErrDuplicateSlug
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateResource locks the resource's current status, checks the change
+// against the lifecycle and writes the new values.  The write only applies if
+// the resource is still at resource.Version.
+func updateResource(ctx context.Context, tx *sql.Tx, resource *Resource) error {
+	var currentStatus string
+	err := tx.QueryRowContext(ctx,
+		"SELECT status FROM resources WHERE resource_id = $1 AND deleted_at IS NULL FOR UPDATE",
+		resource.ID).Scan(&currentStatus)
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if err = checkStatusEdge(currentStatus, resource.Status); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE resources
+		SET title = $1, category = $2, slug = $3, summary = $4, drive_link = $5, status = $6, published_url = $7,
+		    contributor_id = $8, version = version + 1
+		WHERE resource_id = $9 AND version = $10
+		RETURNING version`
+
+	args := []any{
+		resource.Title,
+		resource.Category,
+		resource.Slug,
+		resource.Summary,
+		resource.DriveLink,
+		resource.Status,
+		resource.PublishedURL,
+		resource.ContributorID,
+		resource.ID,
+		resource.Version,
+	}
+
+	// The row is locked and exists, so no match means another request
+	// updated it after the caller read it
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&resource.Version)
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			return ErrEditConflict
+		case strings.Contains(err.Error(), "duplicate key value violates unique constraint") && strings.Contains(err.Error(), "resources_slug_key"):
+			return ErrDuplicateSlug
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func replaceResourceSubjects(ctx context.Context, tx *sql.Tx, resourceID int64, subjects []string) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM resource_subjects WHERE resource_id = $1", resourceID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO resource_subjects (resource_id, subject)
+		SELECT $1, unnest($2::text[])`,
+		resourceID, pq.Array(uniqueStrings(subjects)))
+	return err
+}
+
+func replaceResourceGradeLevels(ctx context.Context, tx *sql.Tx, resourceID int64, gradeLevels []string) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM resource_grade_levels WHERE resource_id = $1", resourceID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO resource_grade_levels (resource_id, grade_level)
+		SELECT $1, unnest($2::text[])`,
+		resourceID, pq.Array(uniqueStrings(gradeLevels)))
+	return err
+}
+
+// uniqueStrings returns the values sorted with duplicates removed
+func uniqueStrings(values []string) []string {
+	unique := slices.Clone(values)
+	slices.Sort(unique)
+	return slices.Compact(unique)
+}
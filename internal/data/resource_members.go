@@ -0,0 +1,215 @@
+//filename: internal/data/resource_members.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// ResourceMembers lists everyone who works on a resource besides readers:
+// the contributor who owns it, their co-authors and the assigned reviewers.
+type ResourceMembers struct {
+	ResourceID    int64   `json:"resource_id"`
+	ContributorID int64   `json:"contributor_id"`
+	CoAuthorIDs   []int64 `json:"coauthor_ids"`
+	ReviewerIDs   []int64 `json:"reviewer_ids"`
+}
+
+// Membership describes how a single user relates to a resource
+type Membership struct {
+	IsContributor      bool
+	IsCoAuthor         bool
+	IsAssignedReviewer bool
+}
+
+type ResourceMemberModel struct {
+	DB *sql.DB
+}
+
+// Get returns the contributor, co-authors and assigned reviewers of a resource
+func (m ResourceMemberModel) Get(ctx context.Context, resourceID int64) (*ResourceMembers, error) {
+	if resourceID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT r.resource_id, r.contributor_id,
+		       ARRAY(SELECT user_id FROM resource_coauthors
+		             WHERE resource_id = r.resource_id ORDER BY user_id),
+		       ARRAY(SELECT reviewer_id FROM resource_reviewer_assignments
+		             WHERE resource_id = r.resource_id ORDER BY reviewer_id)
+		FROM resources r
+		WHERE r.resource_id = $1 AND r.deleted_at IS NULL`
+
+	var members ResourceMembers
+	var coAuthors, reviewers pq.Int64Array
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, resourceID).Scan(
+		&members.ResourceID,
+		&members.ContributorID,
+		&coAuthors,
+		&reviewers,
+	)
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	members.CoAuthorIDs = []int64(coAuthors)
+	members.ReviewerIDs = []int64(reviewers)
+
+	return &members, nil
+}
+
+// GetMembership reports how the given user relates to a resource
+func (m ResourceMemberModel) GetMembership(ctx context.Context, resourceID, userID int64) (*Membership, error) {
+	query := `
+		SELECT r.contributor_id = $2,
+		       EXISTS (SELECT 1 FROM resource_coauthors
+		               WHERE resource_id = r.resource_id AND user_id = $2),
+		       EXISTS (SELECT 1 FROM resource_reviewer_assignments
+		               WHERE resource_id = r.resource_id AND reviewer_id = $2)
+		FROM resources r
+		WHERE r.resource_id = $1 AND r.deleted_at IS NULL`
+
+	var membership Membership
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, resourceID, userID).Scan(
+		&membership.IsContributor,
+		&membership.IsCoAuthor,
+		&membership.IsAssignedReviewer,
+	)
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &membership, nil
+}
+
+// AddCoAuthor grants a user co-author membership on a resource.
+// Adding an existing co-author is a no-op.
+func (m ResourceMemberModel) AddCoAuthor(ctx context.Context, resourceID, userID int64) error {
+	query := `
+		INSERT INTO resource_coauthors (resource_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, resourceID, userID)
+	return err
+}
+
+// RemoveCoAuthor revokes a user's co-author membership on a resource
+func (m ResourceMemberModel) RemoveCoAuthor(ctx context.Context, resourceID, userID int64) error {
+	query := `
+		DELETE FROM resource_coauthors
+		WHERE resource_id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, resourceID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// AssignReviewer assigns a reviewer to a resource.
+// Re-assigning an existing reviewer is a no-op.
+func (m ResourceMemberModel) AssignReviewer(ctx context.Context, resourceID, reviewerID, assignedBy int64) error {
+	query := `
+		INSERT INTO resource_reviewer_assignments (resource_id, reviewer_id, assigned_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, resourceID, reviewerID, assignedBy)
+	return err
+}
+
+// UnassignReviewer removes a reviewer assignment from a resource
+func (m ResourceMemberModel) UnassignReviewer(ctx context.Context, resourceID, reviewerID int64) error {
+	query := `
+		DELETE FROM resource_reviewer_assignments
+		WHERE resource_id = $1 AND reviewer_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, resourceID, reviewerID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetReviewCommentAuthor returns the resource a review comment belongs to and
+// the reviewer who wrote it, so that policies can be applied before it is changed.
+func (m ResourceMemberModel) GetReviewCommentAuthor(ctx context.Context, commentID int64) (resourceID, reviewerID int64, err error) {
+	if commentID < 1 {
+		return 0, 0, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT resource_id, reviewer_id
+		FROM review_comments
+		WHERE comment_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, commentID).Scan(&resourceID, &reviewerID)
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			return 0, 0, ErrRecordNotFound
+		default:
+			return 0, 0, err
+		}
+	}
+
+	return resourceID, reviewerID, nil
+}
@@ -5,6 +5,7 @@ package data
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
 )
 
@@ -23,7 +24,7 @@ type ResourceReviewModel struct {
 }
 
 // Insert a new resource review into the database
-func (m ResourceReviewModel) Insert(review *ResourceReview) error {
+func (m ResourceReviewModel) Insert(ctx context.Context, review *ResourceReview) error {
 	query := `
 		INSERT INTO resource_reviews (resource_id, reviewer_id, reviewer_role_id, decision, comment_summary)
 		VALUES ($1, $2, $3, $4, $5)
@@ -37,14 +38,72 @@ func (m ResourceReviewModel) Insert(review *ResourceReview) error {
 		review.CommentSummary,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	return m.DB.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.ReviewedAt)
 }
 
+// InsertDecision records a review and moves its resource through
+// statusPath, the status changes the decision implies, in one transaction.
+// Each step is checked against the lifecycle table as ResourceModel.Update
+// checks it, and a single revision credited to the reviewer records the
+// outcome.  If any step fails nothing is recorded: an illegal step returns a
+// *StatusTransitionError and a resource changed since it was read returns
+// ErrEditConflict.  On success resource holds the new status and version.
+func (m ResourceReviewModel) InsertDecision(ctx context.Context, review *ResourceReview, resource *Resource, statusPath []string) error {
+	query := `
+		INSERT INTO resource_reviews (resource_id, reviewer_id, reviewer_role_id, decision, comment_summary)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING review_id, reviewed_at`
+
+	args := []any{
+		review.ResourceID,
+		review.ReviewerID,
+		review.ReviewerRoleID,
+		review.Decision,
+		review.CommentSummary,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, TransactionTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.ReviewedAt)
+	if err != nil {
+		return err
+	}
+
+	updated := *resource
+	for _, next := range statusPath {
+		updated.Status = next
+		if err = updateResource(ctx, tx, &updated); err != nil {
+			return err
+		}
+	}
+
+	if len(statusPath) > 0 {
+		err = insertResourceRevision(ctx, tx, resource.ID, review.ReviewerID, "Review decision: "+review.Decision)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	*resource = updated
+	return nil
+}
+
 // Get a resource review by ID
-func (m ResourceReviewModel) Get(id int64) (*ResourceReview, error) {
+func (m ResourceReviewModel) Get(ctx context.Context, id int64) (*ResourceReview, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -56,7 +115,7 @@ func (m ResourceReviewModel) Get(id int64) (*ResourceReview, error) {
 
 	var review ResourceReview
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, id).Scan(
@@ -82,14 +141,14 @@ func (m ResourceReviewModel) Get(id int64) (*ResourceReview, error) {
 }
 
 // Get all reviews for a specific resource
-func (m ResourceReviewModel) GetByResourceID(resourceID int64) ([]*ResourceReview, error) {
+func (m ResourceReviewModel) GetByResourceID(ctx context.Context, resourceID int64) ([]*ResourceReview, error) {
 	query := `
 		SELECT review_id, resource_id, reviewer_id, reviewer_role_id, decision, comment_summary, reviewed_at
 		FROM resource_reviews
 		WHERE resource_id = $1
 		ORDER BY reviewed_at DESC`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	rows, err := m.DB.QueryContext(ctx, query, resourceID)
@@ -124,8 +183,46 @@ func (m ResourceReviewModel) GetByResourceID(resourceID int64) ([]*ResourceRevie
 	return reviews, nil
 }
 
+// ResourceReviewFields are the fields reviews can be filtered and sorted on
+var ResourceReviewFields = Fields{
+	"review_id":   {Column: "review_id", Type: IntField, Sortable: true},
+	"resource_id": {Column: "resource_id", Type: IntField, Ops: []Op{OpEq, OpIn}},
+	"reviewer_id": {Column: "reviewer_id", Type: IntField, Ops: []Op{OpEq, OpIn}},
+	"decision":    {Column: "decision::text", Type: TextField, Ops: []Op{OpEq, OpIn}},
+	"reviewed_at": {Column: "reviewed_at", Type: TimeField, Ops: []Op{OpGte, OpLte}, Sortable: true},
+}
+
+// Export calls fn for every review matching the filter conditions
+func (m ResourceReviewModel) Export(ctx context.Context, filters Filters, fn func(*ResourceReview) error) error {
+	b := &queryBuilder{}
+	b.filter(ResourceReviewFields, filters.Conditions)
+
+	query := fmt.Sprintf(`
+		SELECT review_id, resource_id, reviewer_id, reviewer_role_id, decision, COALESCE(comment_summary, ''), reviewed_at
+		FROM resource_reviews
+		WHERE %s
+		ORDER BY %s`, b.where(), orderClause(withIDKey(filters.sortKeys(ResourceReviewFields), "review_id")))
+
+	return exportRows(ctx, m.DB, query, b.args, func(rows *sql.Rows) error {
+		var review ResourceReview
+		err := rows.Scan(
+			&review.ID,
+			&review.ResourceID,
+			&review.ReviewerID,
+			&review.ReviewerRoleID,
+			&review.Decision,
+			&review.CommentSummary,
+			&review.ReviewedAt,
+		)
+		if err != nil {
+			return err
+		}
+		return fn(&review)
+	})
+}
+
 // Update a resource review
-func (m ResourceReviewModel) Update(review *ResourceReview) error {
+func (m ResourceReviewModel) Update(ctx context.Context, review *ResourceReview) error {
 	query := `
 		UPDATE resource_reviews
 		SET decision = $1, comment_summary = $2
@@ -138,7 +235,7 @@ func (m ResourceReviewModel) Update(review *ResourceReview) error {
 		review.ID,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&review.ID)
@@ -155,7 +252,7 @@ func (m ResourceReviewModel) Update(review *ResourceReview) error {
 }
 
 // Delete a resource review
-func (m ResourceReviewModel) Delete(id int64) error {
+func (m ResourceReviewModel) Delete(ctx context.Context, id int64, audit *AuditEvent) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
@@ -164,10 +261,16 @@ func (m ResourceReviewModel) Delete(id int64) error {
 		DELETE FROM resource_reviews
 		WHERE review_id = $1`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -181,5 +284,9 @@ func (m ResourceReviewModel) Delete(id int64) error {
 		return ErrRecordNotFound
 	}
 
-	return nil
+	if err = insertAuditEvent(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
@@ -0,0 +1,229 @@
+//filename: internal/data/resource_revisions.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// ResourceSnapshot is the state of a resource kept in a revision: its own
+// fields and its subjects and grade levels.  Lessons keep their own versions.
+type ResourceSnapshot struct {
+	Title         string   `json:"title"`
+	Category      string   `json:"category"`
+	Slug          *string  `json:"slug"`
+	Summary       *string  `json:"summary"`
+	DriveLink     *string  `json:"drive_link"`
+	Status        string   `json:"status"`
+	PublishedURL  *string  `json:"published_url"`
+	ContributorID int64    `json:"contributor_id"`
+	Subjects      []string `json:"subjects"`
+	GradeLevels   []string `json:"grade_levels"`
+}
+
+// ResourceRevision is a numbered snapshot of a resource, taken each time it
+// is written
+type ResourceRevision struct {
+	ID                int64            `json:"revision_id"`
+	ResourceID        int64            `json:"resource_id"`
+	RevisionNumber    int              `json:"revision_number"`
+	Snapshot          ResourceSnapshot `json:"snapshot"`
+	ChangeDescription string           `json:"change_description,omitempty"`
+	ChangedBy         int64            `json:"changed_by,omitempty"`
+	ChangedByUsername string           `json:"changed_by_username,omitempty"`
+	CreatedAt         time.Time        `json:"created_at"`
+}
+
+// FieldChange is one field that differs between two revisions.  For subjects
+// and grade levels Added and Removed list what changed.
+type FieldChange struct {
+	Field   string   `json:"field"`
+	From    any      `json:"from"`
+	To      any      `json:"to"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// insertResourceRevision snapshots the resource as it stands in tx as its
+// next revision.  Nothing is written if nothing changed since the latest
+// revision.  The snapshot is built in SQL, the same way migration 037 built
+// the first revision of existing resources.
+func insertResourceRevision(ctx context.Context, tx *sql.Tx, resourceID, changedBy int64, description string) error {
+	query := `
+		WITH current AS (
+			SELECT jsonb_build_object(
+				'title', r.title,
+				'category', r.category,
+				'slug', r.slug,
+				'summary', r.summary,
+				'drive_link', r.drive_link,
+				'status', r.status,
+				'published_url', r.published_url,
+				'contributor_id', r.contributor_id,
+				'subjects', COALESCE((SELECT jsonb_agg(rs.subject ORDER BY rs.subject)
+				                      FROM resource_subjects rs WHERE rs.resource_id = r.resource_id), '[]'),
+				'grade_levels', COALESCE((SELECT jsonb_agg(rgl.grade_level ORDER BY rgl.grade_level)
+				                          FROM resource_grade_levels rgl WHERE rgl.resource_id = r.resource_id), '[]')
+			) AS snapshot
+			FROM resources r
+			WHERE r.resource_id = $1
+		), latest AS (
+			SELECT revision_number, snapshot
+			FROM resource_revisions
+			WHERE resource_id = $1
+			ORDER BY revision_number DESC
+			LIMIT 1
+		)
+		INSERT INTO resource_revisions (resource_id, revision_number, snapshot, change_description, changed_by)
+		SELECT $1, COALESCE((SELECT revision_number FROM latest), 0) + 1, c.snapshot, NULLIF($3, ''), NULLIF($2, 0)
+		FROM current c
+		WHERE c.snapshot IS DISTINCT FROM (SELECT snapshot FROM latest)`
+
+	_, err := tx.ExecContext(ctx, query, resourceID, changedBy, description)
+	return err
+}
+
+const resourceRevisionColumns = `
+		rv.revision_id, rv.resource_id, rv.revision_number, rv.snapshot, COALESCE(rv.change_description, ''),
+		COALESCE(rv.changed_by, 0), COALESCE(u.username, ''), rv.created_at`
+
+func scanResourceRevision(scan func(...any) error) (*ResourceRevision, error) {
+	var revision ResourceRevision
+	var snapshot []byte
+
+	err := scan(
+		&revision.ID,
+		&revision.ResourceID,
+		&revision.RevisionNumber,
+		&snapshot,
+		&revision.ChangeDescription,
+		&revision.ChangedBy,
+		&revision.ChangedByUsername,
+		&revision.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(snapshot, &revision.Snapshot); err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// GetRevisions returns a resource's revisions, newest first
+func (m ResourceModel) GetRevisions(ctx context.Context, resourceID int64) ([]*ResourceRevision, error) {
+	query := `
+		SELECT` + resourceRevisionColumns + `
+		FROM resource_revisions rv
+		LEFT JOIN users u ON u.user_id = rv.changed_by
+		WHERE rv.resource_id = $1
+		ORDER BY rv.revision_number DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*ResourceRevision{}
+	for rows.Next() {
+		revision, err := scanResourceRevision(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// GetRevision returns one revision of a resource.  A revision number of 0
+// means the latest.
+func (m ResourceModel) GetRevision(ctx context.Context, resourceID int64, number int) (*ResourceRevision, error) {
+	if number < 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT` + resourceRevisionColumns + `
+		FROM resource_revisions rv
+		LEFT JOIN users u ON u.user_id = rv.changed_by
+		WHERE rv.resource_id = $1 AND ($2 = 0 OR rv.revision_number = $2)
+		ORDER BY rv.revision_number DESC
+		LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	revision, err := scanResourceRevision(m.DB.QueryRowContext(ctx, query, resourceID, number).Scan)
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return revision, nil
+}
+
+// DiffSnapshots lists the fields that differ from one snapshot to another
+func DiffSnapshots(from, to ResourceSnapshot) []FieldChange {
+	changes := []FieldChange{}
+
+	compare := func(field string, a, b any, same bool) {
+		if !same {
+			changes = append(changes, FieldChange{Field: field, From: a, To: b})
+		}
+	}
+	compare("title", from.Title, to.Title, from.Title == to.Title)
+	compare("category", from.Category, to.Category, from.Category == to.Category)
+	compare("slug", from.Slug, to.Slug, equalStringPtr(from.Slug, to.Slug))
+	compare("summary", from.Summary, to.Summary, equalStringPtr(from.Summary, to.Summary))
+	compare("drive_link", from.DriveLink, to.DriveLink, equalStringPtr(from.DriveLink, to.DriveLink))
+	compare("status", from.Status, to.Status, from.Status == to.Status)
+	compare("published_url", from.PublishedURL, to.PublishedURL, equalStringPtr(from.PublishedURL, to.PublishedURL))
+	compare("contributor_id", from.ContributorID, to.ContributorID, from.ContributorID == to.ContributorID)
+
+	list := func(field string, a, b []string) {
+		added, removed := listChanges(a, b), listChanges(b, a)
+		if len(added) > 0 || len(removed) > 0 {
+			changes = append(changes, FieldChange{Field: field, From: a, To: b, Added: added, Removed: removed})
+		}
+	}
+	list("subjects", from.Subjects, to.Subjects)
+	list("grade_levels", from.GradeLevels, to.GradeLevels)
+
+	return changes
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// listChanges returns the values in b that are not in a
+func listChanges(a, b []string) []string {
+	var values []string
+	for _, value := range b {
+		if !slices.Contains(a, value) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,182 @@
+//filename: internal/data/resource_status.go
+
+package data
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+)
+
+// Resource status values.  These mirror the resource_status ENUM.
+const (
+	StatusDraft         = "Draft"
+	StatusSubmitted     = "Submitted"
+	StatusUnderReview   = "UnderReview"
+	StatusNeedsRevision = "NeedsRevision"
+	StatusRejected      = "Rejected"
+	StatusApproved      = "Approved"
+	StatusDesignCurate  = "DesignCurate"
+	StatusPublished     = "Published"
+	StatusIndexed       = "Indexed"
+	StatusArchived      = "Archived"
+)
+
+// ResourceStatuses lists every resource_status value in lifecycle order.
+var ResourceStatuses = []string{
+	StatusDraft,
+	StatusSubmitted,
+	StatusUnderReview,
+	StatusNeedsRevision,
+	StatusRejected,
+	StatusApproved,
+	StatusDesignCurate,
+	StatusPublished,
+	StatusIndexed,
+	StatusArchived,
+}
+
+// RoleSystem identifies transitions made by the application itself (for
+// example the YouTube uploader publishing an approved video) rather than by
+// a signed-in user.
+const RoleSystem = "system"
+
+// statusTransitionRoles are the roles that may perform every legal transition,
+// in addition to the roles listed on the transition itself.
+var statusTransitionRoles = []string{"admin", "DSC", RoleSystem}
+
+// statusTransition is one edge of the resource lifecycle graph.  Review
+// edges record a reviewer's judgement and are limited to reviewers whose
+// subject expertise covers the resource.
+type statusTransition struct {
+	to     string
+	roles  []string
+	review bool
+}
+
+// resourceStatusTransitions is the central lifecycle table:
+//
+//	Draft → Submitted → UnderReview ──→ NeedsRevision → UnderReview
+//	                                 └→ Rejected → Archived
+//	                                 └→ Approved → DesignCurate → Published → Indexed → Archived
+//
+// Each edge lists the roles (besides admin, DSC and the system) that may take it.
+var resourceStatusTransitions = map[string][]statusTransition{
+	StatusDraft: {
+		{to: StatusSubmitted, roles: []string{"Fellow"}},
+	},
+	StatusSubmitted: {
+		{to: StatusDraft, roles: []string{"Fellow"}},
+		{to: StatusUnderReview, roles: []string{"SubjectExpert", "TeamLead"}, review: true},
+	},
+	StatusUnderReview: {
+		{to: StatusApproved, roles: []string{"SubjectExpert", "TeamLead"}, review: true},
+		{to: StatusNeedsRevision, roles: []string{"SubjectExpert", "TeamLead"}, review: true},
+		{to: StatusRejected, roles: []string{"SubjectExpert", "TeamLead"}, review: true},
+	},
+	StatusNeedsRevision: {
+		// A contributor saving their revisions sends the resource back to review.
+		{to: StatusUnderReview, roles: []string{"Fellow"}},
+	},
+	StatusRejected: {
+		{to: StatusUnderReview, roles: []string{"TeamLead"}, review: true},
+		{to: StatusArchived},
+	},
+	StatusApproved: {
+		{to: StatusDesignCurate, roles: []string{"TeamLead"}},
+		// Approved videos are published directly once uploaded to YouTube.
+		{to: StatusPublished},
+	},
+	StatusDesignCurate: {
+		{to: StatusPublished, roles: []string{"TeamLead"}},
+	},
+	StatusPublished: {
+		{to: StatusIndexed},
+		{to: StatusArchived},
+	},
+	StatusIndexed: {
+		{to: StatusArchived},
+	},
+	StatusArchived: {
+		{to: StatusPublished},
+	},
+}
+
+// StatusTransitionError is returned when a resource cannot move from one
+// status to another.  Allowed lists the statuses that are reachable instead.
+type StatusTransitionError struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Allowed []string `json:"allowed"`
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("cannot change resource status from %s to %s", e.From, e.To)
+}
+
+// ValidateResourceStatus checks that status is one of the resource_status values.
+func ValidateResourceStatus(v *validator.Validator, status string) {
+	v.Check(status != "", "status", "must be provided")
+	v.Check(status == "" || validator.PermittedValue(status, ResourceStatuses...), "status", "must be a valid resource status")
+}
+
+// NextStatuses returns every status reachable from the given status,
+// regardless of role.
+func NextStatuses(from string) []string {
+	next := []string{}
+	for _, t := range resourceStatusTransitions[from] {
+		next = append(next, t.to)
+	}
+	return next
+}
+
+// AllowedStatusTransitions returns the statuses a user with the given role may
+// move a resource to from its current status.
+func AllowedStatusTransitions(from, roleName string) []string {
+	allowed := []string{}
+	for _, t := range resourceStatusTransitions[from] {
+		if slices.Contains(statusTransitionRoles, roleName) || slices.Contains(t.roles, roleName) {
+			allowed = append(allowed, t.to)
+		}
+	}
+	return allowed
+}
+
+// CheckStatusTransition verifies that a user with the given role may move a
+// resource from one status to another.  Keeping the same status is always
+// permitted.
+func CheckStatusTransition(from, to, roleName string) error {
+	if from == to {
+		return nil
+	}
+	allowed := AllowedStatusTransitions(from, roleName)
+	if !slices.Contains(allowed, to) {
+		return &StatusTransitionError{From: from, To: to, Allowed: allowed}
+	}
+	return nil
+}
+
+// IsReviewTransition reports whether moving from one status to another is a
+// review decision, which requires subject expertise for the resource.
+func IsReviewTransition(from, to string) bool {
+	for _, t := range resourceStatusTransitions[from] {
+		if t.to == to {
+			return t.review
+		}
+	}
+	return false
+}
+
+// checkStatusEdge verifies that to is reachable from from in the lifecycle
+// graph, independent of who is making the change.
+func checkStatusEdge(from, to string) error {
+	if from == to {
+		return nil
+	}
+	next := NextStatuses(from)
+	if !slices.Contains(next, to) {
+		return &StatusTransitionError{From: from, To: to, Allowed: next}
+	}
+	return nil
+}
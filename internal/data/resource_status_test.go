@@ -0,0 +1,140 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCheckStatusTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		role    string
+		allowed []string // set when the transition is refused
+	}{
+		{name: "same status", from: StatusPublished, to: StatusPublished, role: "Fellow"},
+		{name: "fellow submits a draft", from: StatusDraft, to: StatusSubmitted, role: "Fellow"},
+		{name: "fellow withdraws a submission", from: StatusSubmitted, to: StatusDraft, role: "Fellow"},
+		{name: "fellow cannot approve", from: StatusUnderReview, to: StatusApproved, role: "Fellow",
+			allowed: []string{}},
+		{name: "expert approves", from: StatusUnderReview, to: StatusApproved, role: "SubjectExpert"},
+		{name: "expert asks for revision", from: StatusUnderReview, to: StatusNeedsRevision, role: "SubjectExpert"},
+		{name: "fellow resubmits a revision", from: StatusNeedsRevision, to: StatusUnderReview, role: "Fellow"},
+		{name: "expert cannot reopen a rejection", from: StatusRejected, to: StatusUnderReview, role: "SubjectExpert",
+			allowed: []string{}},
+		{name: "team lead reopens a rejection", from: StatusRejected, to: StatusUnderReview, role: "TeamLead"},
+		{name: "team lead curates", from: StatusApproved, to: StatusDesignCurate, role: "TeamLead"},
+		{name: "team lead cannot publish an approval directly", from: StatusApproved, to: StatusPublished, role: "TeamLead",
+			allowed: []string{StatusDesignCurate}},
+		{name: "system publishes an approved video", from: StatusApproved, to: StatusPublished, role: RoleSystem},
+		{name: "admin archives", from: StatusIndexed, to: StatusArchived, role: "admin"},
+		{name: "DSC restores an archive", from: StatusArchived, to: StatusPublished, role: "DSC"},
+		{name: "no role", from: StatusDraft, to: StatusSubmitted, role: "", allowed: []string{}},
+		{name: "admin cannot skip the lifecycle", from: StatusDraft, to: StatusPublished, role: "admin",
+			allowed: []string{StatusSubmitted}},
+		{name: "unknown status", from: "Lost", to: StatusDraft, role: "admin", allowed: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckStatusTransition(tt.from, tt.to, tt.role)
+			if tt.allowed == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var transitionErr *StatusTransitionError
+			if !errors.As(err, &transitionErr) {
+				t.Fatalf("error = %v, want a *StatusTransitionError", err)
+			}
+			if transitionErr.From != tt.from || transitionErr.To != tt.to {
+				t.Errorf("error is for %s → %s, want %s → %s", transitionErr.From, transitionErr.To, tt.from, tt.to)
+			}
+			if !reflect.DeepEqual(transitionErr.Allowed, tt.allowed) {
+				t.Errorf("allowed = %v, want %v", transitionErr.Allowed, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestCheckStatusEdge(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		allowed []string // set when the edge does not exist
+	}{
+		{name: "same status", from: StatusDraft, to: StatusDraft},
+		{name: "draft to submitted", from: StatusDraft, to: StatusSubmitted},
+		{name: "review decision", from: StatusUnderReview, to: StatusRejected},
+		{name: "approved straight to published", from: StatusApproved, to: StatusPublished},
+		{name: "draft to published", from: StatusDraft, to: StatusPublished, allowed: []string{StatusSubmitted}},
+		{name: "published back to draft", from: StatusPublished, to: StatusDraft,
+			allowed: []string{StatusIndexed, StatusArchived}},
+		{name: "unknown status", from: "Lost", to: StatusDraft, allowed: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkStatusEdge(tt.from, tt.to)
+			if tt.allowed == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var transitionErr *StatusTransitionError
+			if !errors.As(err, &transitionErr) {
+				t.Fatalf("error = %v, want a *StatusTransitionError", err)
+			}
+			if !reflect.DeepEqual(transitionErr.Allowed, tt.allowed) {
+				t.Errorf("allowed = %v, want %v", transitionErr.Allowed, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestIsReviewTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusSubmitted, StatusUnderReview, true},
+		{StatusUnderReview, StatusApproved, true},
+		{StatusUnderReview, StatusNeedsRevision, true},
+		{StatusRejected, StatusUnderReview, true},
+		{StatusNeedsRevision, StatusUnderReview, false},
+		{StatusDraft, StatusSubmitted, false},
+		{StatusDraft, StatusPublished, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsReviewTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("IsReviewTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// Every status in the lifecycle table must be a real resource_status value
+func TestStatusTransitionsUseKnownStatuses(t *testing.T) {
+	known := make(map[string]bool, len(ResourceStatuses))
+	for _, status := range ResourceStatuses {
+		known[status] = true
+	}
+
+	for from, transitions := range resourceStatusTransitions {
+		if !known[from] {
+			t.Errorf("transitions from unknown status %q", from)
+		}
+		for _, tr := range transitions {
+			if !known[tr.to] {
+				t.Errorf("transition %s → %q goes to an unknown status", from, tr.to)
+			}
+		}
+	}
+}
@@ -5,7 +5,12 @@ package data
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"html"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type Resource struct {
@@ -20,10 +25,13 @@ type Resource struct {
 	ContributorID   int64     `json:"contributor_id"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
+	Version         int32     `json:"version"`
 	Subjects        []string  `json:"subjects,omitempty"`
 	GradeLevels     []string  `json:"grade_levels,omitempty"`
 	ContributorName string    `json:"contributor_name,omitempty"`
 	ViewCount       int64     `json:"view_count"`
+	SearchRank      float64   `json:"search_rank,omitempty"`
+	Snippet         string    `json:"snippet,omitempty"`
 }
 
 type ResourceModel struct {
@@ -31,11 +39,11 @@ type ResourceModel struct {
 }
 
 // Insert a new resource into the database
-func (m ResourceModel) Insert(resource *Resource) error {
+func (m ResourceModel) Insert(ctx context.Context, resource *Resource) error {
 	query := `
 		INSERT INTO resources (title, category, slug, summary, drive_link, status, published_url, contributor_id)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING resource_id, created_at, updated_at`
+		RETURNING resource_id, created_at, updated_at, version`
 
 	args := []any{
 		resource.Title,
@@ -48,76 +56,30 @@ func (m ResourceModel) Insert(resource *Resource) error {
 		resource.ContributorID,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&resource.ID, &resource.CreatedAt, &resource.UpdatedAt)
-}
-
-// InsertWithVideoMetadata inserts a resource and its VideoMetadata atomically
-// in a single transaction.  Use this whenever Category == "Video".
-func (m ResourceModel) InsertWithVideoMetadata(resource *Resource, vm *VideoMetadata) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	tx, err := m.DB.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	// Insert the resource row first to obtain the resource_id.
-	resourceQuery := `
-		INSERT INTO resources (title, category, slug, summary, drive_link, status, published_url, contributor_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING resource_id, created_at, updated_at`
-
-	resourceArgs := []any{
-		resource.Title,
-		resource.Category,
-		resource.Slug,
-		resource.Summary,
-		resource.DriveLink,
-		resource.Status,
-		resource.PublishedURL,
-		resource.ContributorID,
-	}
-
-	err = tx.QueryRowContext(ctx, resourceQuery, resourceArgs...).Scan(
-		&resource.ID, &resource.CreatedAt, &resource.UpdatedAt,
-	)
-	if err != nil {
-		return err
-	}
-
-	// Bind the new resource_id to the video metadata and insert it.
-	vm.ResourceID = resource.ID
-	vmModel := VideoModel{DB: m.DB}
-	if err = vmModel.InsertTx(ctx, tx, vm); err != nil {
-		return err
-	}
-
-	return tx.Commit()
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&resource.ID, &resource.CreatedAt, &resource.UpdatedAt, &resource.Version)
 }
 
 // Get a resource by ID
-func (m ResourceModel) Get(id int64) (*Resource, error) {
+func (m ResourceModel) Get(ctx context.Context, id int64) (*Resource, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
 	query := `
-		SELECT r.resource_id, r.title, r.category, r.slug, r.summary, r.drive_link, r.status, r.published_url, r.contributor_id, r.created_at, r.updated_at,
+		SELECT r.resource_id, r.title, r.category, r.slug, r.summary, r.drive_link, r.status, r.published_url, r.contributor_id, r.created_at, r.updated_at, r.version,
 			COALESCE(f.first_name || ' ' || f.last_name, u.username, 'Unknown') AS contributor_name,
 			(SELECT COUNT(*) FROM resource_access ra WHERE ra.resource_id = r.resource_id) AS view_count
 		FROM resources r
 		LEFT JOIN fellows f ON f.user_id = r.contributor_id
 		LEFT JOIN users u ON u.user_id = r.contributor_id
-		WHERE r.resource_id = $1`
+		WHERE r.resource_id = $1 AND r.deleted_at IS NULL`
 
 	var resource Resource
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, id).Scan(
@@ -132,6 +94,7 @@ func (m ResourceModel) Get(id int64) (*Resource, error) {
 		&resource.ContributorID,
 		&resource.CreatedAt,
 		&resource.UpdatedAt,
+		&resource.Version,
 		&resource.ContributorName,
 		&resource.ViewCount,
 	)
@@ -146,12 +109,12 @@ func (m ResourceModel) Get(id int64) (*Resource, error) {
 	}
 
 	// Load subjects and grade levels
-	resource.Subjects, err = m.GetSubjects(id)
+	resource.Subjects, err = m.GetSubjects(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	resource.GradeLevels, err = m.GetGradeLevels(id)
+	resource.GradeLevels, err = m.GetGradeLevels(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -160,23 +123,23 @@ func (m ResourceModel) Get(id int64) (*Resource, error) {
 }
 
 // GetBySlug retrieves a resource by its slug
-func (m ResourceModel) GetBySlug(slug string) (*Resource, error) {
+func (m ResourceModel) GetBySlug(ctx context.Context, slug string) (*Resource, error) {
 	if slug == "" {
 		return nil, ErrRecordNotFound
 	}
 
 	query := `
-		SELECT r.resource_id, r.title, r.category, r.slug, r.summary, r.drive_link, r.status, r.published_url, r.contributor_id, r.created_at, r.updated_at,
+		SELECT r.resource_id, r.title, r.category, r.slug, r.summary, r.drive_link, r.status, r.published_url, r.contributor_id, r.created_at, r.updated_at, r.version,
 			COALESCE(f.first_name || ' ' || f.last_name, u.username, 'Unknown') AS contributor_name,
 			(SELECT COUNT(*) FROM resource_access ra WHERE ra.resource_id = r.resource_id) AS view_count
 		FROM resources r
 		LEFT JOIN fellows f ON f.user_id = r.contributor_id
 		LEFT JOIN users u ON u.user_id = r.contributor_id
-		WHERE r.slug = $1`
+		WHERE r.slug = $1 AND r.deleted_at IS NULL`
 
 	var resource Resource
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, slug).Scan(
@@ -191,6 +154,7 @@ func (m ResourceModel) GetBySlug(slug string) (*Resource, error) {
 		&resource.ContributorID,
 		&resource.CreatedAt,
 		&resource.UpdatedAt,
+		&resource.Version,
 		&resource.ContributorName,
 		&resource.ViewCount,
 	)
@@ -205,12 +169,12 @@ func (m ResourceModel) GetBySlug(slug string) (*Resource, error) {
 	}
 
 	// Load subjects and grade levels
-	resource.Subjects, err = m.GetSubjects(resource.ID)
+	resource.Subjects, err = m.GetSubjects(ctx, resource.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	resource.GradeLevels, err = m.GetGradeLevels(resource.ID)
+	resource.GradeLevels, err = m.GetGradeLevels(ctx, resource.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -218,89 +182,420 @@ func (m ResourceModel) GetBySlug(slug string) (*Resource, error) {
 	return &resource, nil
 }
 
-// Get all resources with optional filters
-func (m ResourceModel) GetAll(status string, subject string, gradeLevel string, filters Filters) ([]*Resource, Metadata, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// ResourceFilter narrows a resource listing.  Values within one field are
+// alternatives (any of them may match); different fields must all match.
+type ResourceFilter struct {
+	Search         string
+	Statuses       []string
+	Subjects       []string
+	GradeLevels    []string
+	Categories     []string
+	ContributorIDs []int64
+}
+
+// Facet names, also used to leave a field out of ResourceFilter.where
+const (
+	FacetSubjects     = "subjects"
+	FacetGradeLevels  = "grade_levels"
+	FacetCategories   = "categories"
+	FacetStatuses     = "statuses"
+	FacetContributors = "contributors"
+)
+
+// resourceSearchJoin makes the search document (s) and the parsed search
+// words (q) available to a resource query.  $1 is always the search text.
+const resourceSearchJoin = `
+		LEFT JOIN resource_search s ON s.resource_id = r.resource_id
+		CROSS JOIN (
+			SELECT plainto_tsquery('english', $1) AS all_terms,
+			       replace(plainto_tsquery('english', $1)::text, '&', '|')::tsquery AS any_terms
+		) q`
+
+// apply adds the filter's conditions to b.  The field named by skip is left
+// out so a facet can count the options the user has not picked yet.  b must
+// already hold the search text as $1.
+func (f ResourceFilter) apply(b *queryBuilder, skip string) {
+	b.conditions = append(b.conditions, "r.deleted_at IS NULL", "($1 = '' OR s.document @@ q.any_terms)")
+
+	if len(f.Statuses) > 0 && skip != FacetStatuses {
+		b.add("r.status::text = ANY(%s)", pq.Array(f.Statuses))
+	}
+	if len(f.Categories) > 0 && skip != FacetCategories {
+		b.add("r.category::text = ANY(%s)", pq.Array(f.Categories))
+	}
+	if len(f.ContributorIDs) > 0 && skip != FacetContributors {
+		b.add("r.contributor_id = ANY(%s)", pq.Array(f.ContributorIDs))
+	}
+	if len(f.Subjects) > 0 && skip != FacetSubjects {
+		b.add(`EXISTS (
+			SELECT 1 FROM resource_subjects rs
+			WHERE rs.resource_id = r.resource_id AND rs.subject = ANY(%s))`, pq.Array(f.Subjects))
+	}
+	if len(f.GradeLevels) > 0 && skip != FacetGradeLevels {
+		b.add(`EXISTS (
+			SELECT 1 FROM resource_grade_levels rgl
+			WHERE rgl.resource_id = r.resource_id AND rgl.grade_level::text = ANY(%s))`, pq.Array(f.GradeLevels))
+	}
+}
+
+// ResourceFields are the fields resources can be sorted on, or filtered on
+// beyond what ResourceFilter covers
+var ResourceFields = Fields{
+	"resource_id": {Column: "r.resource_id", Type: IntField, Sortable: true},
+	"title":       {Column: "r.title", Type: TextField, Ops: []Op{OpContains, OpEq}, Sortable: true},
+	"created_at":  {Column: "r.created_at", Type: TimeField, Ops: []Op{OpGte, OpLte}, Sortable: true},
+	"updated_at":  {Column: "r.updated_at", Type: TimeField, Ops: []Op{OpGte, OpLte}, Sortable: true},
+}
+
+// resourceSearchRank scores a resource against the search in $1.  Matching
+// any word counts, matching every word adds a full point.
+const resourceSearchRank = `CASE WHEN $1 = '' THEN 0
+			     ELSE ts_rank(s.document, q.any_terms) + CASE WHEN s.document @@ q.all_terms THEN 1 ELSE 0 END
+			END`
+
+// GetAll returns resources matching the filter in the requested sort order.
+// When a search is given it is matched against the full-text document of each
+// resource (title, summary, subjects, grade levels and lessons, with English
+// stemming) and results are ordered by rank instead.  Any search word may
+// match, but resources matching every word rank first, so "photosynthesis
+// grade 5" still finds lessons that never say "grade".  Search results carry a
+// rank and a highlighted snippet.
+func (m ResourceModel) GetAll(ctx context.Context, filter ResourceFilter, filters Filters) ([]*Resource, Metadata, error) {
+	b := &queryBuilder{args: []any{filter.Search}}
+	filter.apply(b, "")
+	b.filter(ResourceFields, filters.Conditions)
+
+	keys := filters.sortKeys(ResourceFields)
+	if filter.Search != "" {
+		keys = []sortKey{{field: "search_rank", column: "(" + resourceSearchRank + ")", desc: true}}
+	}
+	page := filters.paginate(b, keys, "r.resource_id")
+
+	query := fmt.Sprintf(`
+		SELECT %s, r.resource_id, r.title, r.category, r.slug, r.summary, r.drive_link, r.status, r.published_url, r.contributor_id, r.created_at, r.updated_at, r.version,
+			COALESCE(f.first_name || ' ' || f.last_name, u.username, 'Unknown') AS contributor_name,
+			(SELECT COUNT(*) FROM resource_access ra WHERE ra.resource_id = r.resource_id) AS view_count,
+			%s AS search_rank
+		FROM resources r
+		LEFT JOIN fellows f ON f.user_id = r.contributor_id
+		LEFT JOIN users u ON u.user_id = r.contributor_id%s
+		WHERE %s
+		ORDER BY %s
+		%s`, page.count, resourceSearchRank, resourceSearchJoin, b.where(), page.orderBy, page.limit)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	var totalRecords int
-	var query string
-	var countQuery string
-	var args []any
+	rows, err := m.DB.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
 
-	if subject != "" || gradeLevel != "" {
-		// Count query with joins
-		countQuery = `
-			SELECT COUNT(DISTINCT r.resource_id)
-			FROM resources r
-			LEFT JOIN resource_subjects rs ON r.resource_id = rs.resource_id
-			LEFT JOIN resource_grade_levels rgl ON r.resource_id = rgl.resource_id
-			WHERE ($1 = '' OR r.status = $1::resource_status)
-			AND ($2 = '' OR rs.subject::text = $2)
-			AND ($3 = '' OR rgl.grade_level::text = $3)`
-
-		// Main query with joins
-		query = `
-			SELECT DISTINCT r.resource_id, r.title, r.category, r.slug, r.summary, r.drive_link, r.status, r.published_url, r.contributor_id, r.created_at, r.updated_at,
-				COALESCE(f.first_name || ' ' || f.last_name, u.username, 'Unknown') AS contributor_name,
-				(SELECT COUNT(*) FROM resource_access ra WHERE ra.resource_id = r.resource_id) AS view_count
-			FROM resources r
-			LEFT JOIN fellows f ON f.user_id = r.contributor_id
-			LEFT JOIN users u ON u.user_id = r.contributor_id
-			LEFT JOIN resource_subjects rs ON r.resource_id = rs.resource_id
-			LEFT JOIN resource_grade_levels rgl ON r.resource_id = rgl.resource_id
-			WHERE ($1 = '' OR r.status = $1::resource_status)
-			AND ($2 = '' OR rs.subject::text = $2)
-			AND ($3 = '' OR rgl.grade_level::text = $3)
-			ORDER BY r.created_at DESC
-			LIMIT $4 OFFSET $5`
-
-		args = []any{status, subject, gradeLevel, filters.limit(), filters.offset()}
-
-		// Get total count
-		err := m.DB.QueryRowContext(ctx, countQuery, status, subject, gradeLevel).Scan(&totalRecords)
+	totalRecords := 0
+	resources := []*Resource{}
+
+	for rows.Next() {
+		var resource Resource
+		err := rows.Scan(
+			&totalRecords,
+			&resource.ID,
+			&resource.Title,
+			&resource.Category,
+			&resource.Slug,
+			&resource.Summary,
+			&resource.DriveLink,
+			&resource.Status,
+			&resource.PublishedURL,
+			&resource.ContributorID,
+			&resource.CreatedAt,
+			&resource.UpdatedAt,
+			&resource.Version,
+			&resource.ContributorName,
+			&resource.ViewCount,
+			&resource.SearchRank,
+		)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
-	} else {
-		// Simple count query
-		countQuery = `
-			SELECT COUNT(*)
-			FROM resources
-			WHERE ($1 = '' OR status = $1::resource_status)`
-
-		// Simple main query
-		query = `
-			SELECT r.resource_id, r.title, r.category, r.slug, r.summary, r.drive_link, r.status, r.published_url, r.contributor_id, r.created_at, r.updated_at,
-				COALESCE(f.first_name || ' ' || f.last_name, u.username, 'Unknown') AS contributor_name,
-				(SELECT COUNT(*) FROM resource_access ra WHERE ra.resource_id = r.resource_id) AS view_count
-			FROM resources r
-			LEFT JOIN fellows f ON f.user_id = r.contributor_id
-			LEFT JOIN users u ON u.user_id = r.contributor_id
-			WHERE ($1 = '' OR r.status = $1::resource_status)
-			ORDER BY r.created_at DESC
-			LIMIT $2 OFFSET $3`
 
-		args = []any{status, filters.limit(), filters.offset()}
+		// Load subjects and grade levels for each resource
+		resource.Subjects, _ = m.GetSubjects(ctx, resource.ID)
+		resource.GradeLevels, _ = m.GetGradeLevels(ctx, resource.ID)
+
+		resources = append(resources, &resource)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	resources, metadata := pageResults(filters, page, resources, totalRecords, func(r *Resource) (map[string]any, int64) {
+		return map[string]any{
+			"search_rank": r.SearchRank,
+			"resource_id": r.ID,
+			"title":       r.Title,
+			"created_at":  r.CreatedAt,
+			"updated_at":  r.UpdatedAt,
+		}, r.ID
+	})
 
-		// Get total count
-		err := m.DB.QueryRowContext(ctx, countQuery, status).Scan(&totalRecords)
+	if filter.Search != "" && len(resources) > 0 {
+		err = m.addSearchSnippets(ctx, resources, filter.Search)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
 	}
 
+	return resources, metadata, nil
+}
+
+// Export calls fn for every resource matching the filter, in the same order
+// as GetAll but without paging.  Subjects and grade levels are read with each
+// row instead of a query per resource.
+func (m ResourceModel) Export(ctx context.Context, filter ResourceFilter, filters Filters, fn func(*Resource) error) error {
+	b := &queryBuilder{args: []any{filter.Search}}
+	filter.apply(b, "")
+	b.filter(ResourceFields, filters.Conditions)
+
+	keys := filters.sortKeys(ResourceFields)
+	if filter.Search != "" {
+		keys = []sortKey{{field: "search_rank", column: "(" + resourceSearchRank + ")", desc: true}}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT r.resource_id, r.title, r.category, r.slug, r.summary, r.drive_link, r.status, r.published_url, r.contributor_id, r.created_at, r.updated_at, r.version,
+			COALESCE(f.first_name || ' ' || f.last_name, u.username, 'Unknown') AS contributor_name,
+			(SELECT COUNT(*) FROM resource_access ra WHERE ra.resource_id = r.resource_id) AS view_count,
+			%s AS search_rank,
+			ARRAY(SELECT rs.subject FROM resource_subjects rs WHERE rs.resource_id = r.resource_id ORDER BY rs.subject) AS subjects,
+			ARRAY(SELECT rgl.grade_level::text FROM resource_grade_levels rgl WHERE rgl.resource_id = r.resource_id ORDER BY rgl.grade_level) AS grade_levels
+		FROM resources r
+		LEFT JOIN fellows f ON f.user_id = r.contributor_id
+		LEFT JOIN users u ON u.user_id = r.contributor_id%s
+		WHERE %s
+		ORDER BY %s`, resourceSearchRank, resourceSearchJoin, b.where(), orderClause(withIDKey(keys, "r.resource_id")))
+
+	return exportRows(ctx, m.DB, query, b.args, func(rows *sql.Rows) error {
+		var resource Resource
+		err := rows.Scan(
+			&resource.ID,
+			&resource.Title,
+			&resource.Category,
+			&resource.Slug,
+			&resource.Summary,
+			&resource.DriveLink,
+			&resource.Status,
+			&resource.PublishedURL,
+			&resource.ContributorID,
+			&resource.CreatedAt,
+			&resource.UpdatedAt,
+			&resource.Version,
+			&resource.ContributorName,
+			&resource.ViewCount,
+			&resource.SearchRank,
+			pq.Array(&resource.Subjects),
+			pq.Array(&resource.GradeLevels),
+		)
+		if err != nil {
+			return err
+		}
+		return fn(&resource)
+	})
+}
+
+// Markers ts_headline puts around matched words.  They are swapped for <mark>
+// tags only after the snippet is HTML escaped, so text written by
+// contributors can't inject markup.
+const (
+	snippetStart = "@@mark@@"
+	snippetStop  = "@@/mark@@"
+)
+
+// addSearchSnippets fills in a highlighted extract of each resource's summary
+// and lesson text around the search words.  It runs after the page has been
+// chosen so only the returned resources pay for ts_headline.
+func (m ResourceModel) addSearchSnippets(ctx context.Context, resources []*Resource, search string) error {
+	query := `
+		SELECT r.resource_id,
+			ts_headline('english',
+				COALESCE(r.summary, '') || ' ' || COALESCE((
+					SELECT string_agg(lesson_content_text(l.content), ' ' ORDER BY l.lesson_number)
+					FROM lessons l WHERE l.resource_id = r.resource_id AND l.deleted_at IS NULL), ''),
+				replace(plainto_tsquery('english', $2)::text, '&', '|')::tsquery,
+				'StartSel="` + snippetStart + `", StopSel="` + snippetStop + `", MinWords=15, MaxWords=35, MaxFragments=2, FragmentDelimiter=" … "')
+		FROM resources r
+		WHERE r.resource_id = ANY($1)`
+
+	ids := make([]int64, len(resources))
+	byID := make(map[int64]*Resource, len(resources))
+	for i, resource := range resources {
+		ids[i] = resource.ID
+		byID[resource.ID] = resource
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids), search)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var snippet string
+		if err := rows.Scan(&id, &snippet); err != nil {
+			return err
+		}
+
+		snippet = html.EscapeString(strings.TrimSpace(snippet))
+		snippet = strings.ReplaceAll(snippet, snippetStart, "<mark>")
+		snippet = strings.ReplaceAll(snippet, snippetStop, "</mark>")
+		byID[id].Snippet = snippet
+	}
+
+	return rows.Err()
+}
+
+// FacetBucket is one option of a facet and how many resources have it
+type FacetBucket struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int    `json:"count"`
+}
+
+// ResourceFacets holds the facet buckets for a resource listing
+type ResourceFacets struct {
+	Subjects     []FacetBucket `json:"subjects"`
+	GradeLevels  []FacetBucket `json:"grade_levels"`
+	Categories   []FacetBucket `json:"categories"`
+	Statuses     []FacetBucket `json:"statuses"`
+	Contributors []FacetBucket `json:"contributors"`
+}
+
+// maxContributorBuckets caps the contributor facet to its largest buckets
+const maxContributorBuckets = 20
+
+// GetFacets counts, for each facet, how many resources under the filter have
+// each option.  A facet ignores its own field of the filter, so picking
+// Science still shows how many resources Robotics would add.  Only the
+// conditions of filters are used; it is not paginated.
+func (m ResourceModel) GetFacets(ctx context.Context, filter ResourceFilter, filters Filters) (*ResourceFacets, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	facets := &ResourceFacets{}
+
+	specs := []struct {
+		name    string
+		join    string
+		value   string
+		label   string
+		limit   int
+		buckets *[]FacetBucket
+	}{
+		{FacetSubjects, "JOIN resource_subjects rs ON rs.resource_id = r.resource_id",
+			"rs.subject", "''", 0, &facets.Subjects},
+		{FacetGradeLevels, "JOIN resource_grade_levels rgl ON rgl.resource_id = r.resource_id",
+			"rgl.grade_level::text", "''", 0, &facets.GradeLevels},
+		{FacetCategories, "", "r.category::text", "''", 0, &facets.Categories},
+		{FacetStatuses, "", "r.status::text", "''", 0, &facets.Statuses},
+		{FacetContributors, `LEFT JOIN fellows f ON f.user_id = r.contributor_id
+		LEFT JOIN users u ON u.user_id = r.contributor_id`,
+			"r.contributor_id::text", "MIN(COALESCE(f.first_name || ' ' || f.last_name, u.username, 'Unknown'))",
+			maxContributorBuckets, &facets.Contributors},
+	}
+
+	for _, spec := range specs {
+		b := &queryBuilder{args: []any{filter.Search}}
+		filter.apply(b, spec.name)
+		b.filter(ResourceFields, filters.Conditions)
+
+		limit := ""
+		if spec.limit > 0 {
+			limit = fmt.Sprintf("LIMIT %d", spec.limit)
+		}
+
+		query := fmt.Sprintf(`
+			SELECT %s AS value, %s AS label, COUNT(DISTINCT r.resource_id) AS count
+			FROM resources r
+			%s%s
+			WHERE %s
+			GROUP BY 1
+			ORDER BY count DESC, value ASC
+			%s`, spec.value, spec.label, spec.join, resourceSearchJoin, b.where(), limit)
+
+		buckets, err := m.scanFacet(ctx, query, b.args)
+		if err != nil {
+			return nil, err
+		}
+		*spec.buckets = buckets
+	}
+
+	return facets, nil
+}
+
+func (m ResourceModel) scanFacet(ctx context.Context, query string, args []any) ([]FacetBucket, error) {
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []FacetBucket{}
+	for rows.Next() {
+		var bucket FacetBucket
+		if err := rows.Scan(&bucket.Value, &bucket.Label, &bucket.Count); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
+
+// GetReviewQueue returns resources in the given statuses, oldest first.  When
+// expertID is non-zero only resources sharing at least one subject with that
+// user's expertise are included.
+func (m ResourceModel) GetReviewQueue(ctx context.Context, expertID int64, statuses []string, subject, gradeLevel string, filters Filters) ([]*Resource, Metadata, error) {
+	query := `
+		SELECT COUNT(*) OVER(), r.resource_id, r.title, r.category, r.slug, r.summary, r.drive_link, r.status, r.published_url, r.contributor_id, r.created_at, r.updated_at, r.version,
+			COALESCE(f.first_name || ' ' || f.last_name, u.username, 'Unknown') AS contributor_name,
+			(SELECT COUNT(*) FROM resource_access ra WHERE ra.resource_id = r.resource_id) AS view_count
+		FROM resources r
+		LEFT JOIN fellows f ON f.user_id = r.contributor_id
+		LEFT JOIN users u ON u.user_id = r.contributor_id
+		WHERE r.status::text = ANY($1) AND r.deleted_at IS NULL
+		AND ($2 = '' OR EXISTS (
+			SELECT 1 FROM resource_subjects rs
+			WHERE rs.resource_id = r.resource_id AND rs.subject = $2))
+		AND ($3 = '' OR EXISTS (
+			SELECT 1 FROM resource_grade_levels rgl
+			WHERE rgl.resource_id = r.resource_id AND rgl.grade_level::text = $3))
+		AND ($4 = 0 OR EXISTS (
+			SELECT 1 FROM resource_subjects rs
+			INNER JOIN user_subjects us ON us.subject = rs.subject
+			WHERE rs.resource_id = r.resource_id AND us.user_id = $4))
+		ORDER BY r.created_at ASC, r.resource_id ASC
+		LIMIT $5 OFFSET $6`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	args := []any{pq.Array(statuses), subject, gradeLevel, expertID, filters.limit(), filters.offset()}
+
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
 	defer rows.Close()
 
+	totalRecords := 0
 	resources := []*Resource{}
 
 	for rows.Next() {
 		var resource Resource
 		err := rows.Scan(
+			&totalRecords,
 			&resource.ID,
 			&resource.Title,
 			&resource.Category,
@@ -312,6 +607,7 @@ func (m ResourceModel) GetAll(status string, subject string, gradeLevel string,
 			&resource.ContributorID,
 			&resource.CreatedAt,
 			&resource.UpdatedAt,
+			&resource.Version,
 			&resource.ContributorName,
 			&resource.ViewCount,
 		)
@@ -319,9 +615,8 @@ func (m ResourceModel) GetAll(status string, subject string, gradeLevel string,
 			return nil, Metadata{}, err
 		}
 
-		// Load subjects and grade levels for each resource
-		resource.Subjects, _ = m.GetSubjects(resource.ID)
-		resource.GradeLevels, _ = m.GetGradeLevels(resource.ID)
+		resource.Subjects, _ = m.GetSubjects(ctx, resource.ID)
+		resource.GradeLevels, _ = m.GetGradeLevels(ctx, resource.ID)
 
 		resources = append(resources, &resource)
 	}
@@ -335,55 +630,57 @@ func (m ResourceModel) GetAll(status string, subject string, gradeLevel string,
 	return resources, metadata, nil
 }
 
-// Update a resource
-func (m ResourceModel) Update(resource *Resource) error {
-	query := `
-		UPDATE resources
-		SET title = $1, category = $2, slug = $3, summary = $4, drive_link = $5, status = $6, published_url = $7
-		WHERE resource_id = $8
-		RETURNING resource_id`
+// Update a resource.  A status change must follow the lifecycle table in
+// resource_status.go; the current status is locked while the update runs so
+// two concurrent changes cannot both pass the check.  An illegal change
+// returns a *StatusTransitionError, and a resource changed since it was read
+// (its version no longer matches) returns ErrEditConflict.  The change is
+// recorded as a revision credited to changedBy, 0 for changes the application
+// makes itself, and described by description.
+func (m ResourceModel) Update(ctx context.Context, resource *Resource, changedBy int64, description string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
 
-	args := []any{
-		resource.Title,
-		resource.Category,
-		resource.Slug,
-		resource.Summary,
-		resource.DriveLink,
-		resource.Status,
-		resource.PublishedURL,
-		resource.ID,
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	if err = updateResource(ctx, tx, resource); err != nil {
+		return err
+	}
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&resource.ID)
-	if err != nil {
-		switch {
-		case err == sql.ErrNoRows:
-			return ErrRecordNotFound
-		default:
-			return err
-		}
+	if err = insertResourceRevision(ctx, tx, resource.ID, changedBy, description); err != nil {
+		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-// Delete a resource
-func (m ResourceModel) Delete(id int64) error {
+// Delete moves a resource to the trash.  Its lessons, reviews and history
+// are kept so a restore brings it back whole; they only go when the trash is
+// purged.
+func (m ResourceModel) Delete(ctx context.Context, id, deletedBy int64, audit *AuditEvent) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
 	query := `
-		DELETE FROM resources
-		WHERE resource_id = $1`
+		UPDATE resources
+		SET deleted_at = NOW(), deleted_by = NULLIF($2, 0)
+		WHERE resource_id = $1 AND deleted_at IS NULL`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, id, deletedBy)
 	if err != nil {
 		return err
 	}
@@ -397,7 +694,11 @@ func (m ResourceModel) Delete(id int64) error {
 		return ErrRecordNotFound
 	}
 
-	return nil
+	if err = insertAuditEvent(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // GetSubjects returns all subjects for a resource
@@ -412,7 +713,7 @@ type ResourceStatusCounts struct {
 
 // GetStatusCounts returns a single-row summary of resource counts grouped by
 // review-relevant status values.  The query runs in one round-trip.
-func (m ResourceModel) GetStatusCounts() (*ResourceStatusCounts, error) {
+func (m ResourceModel) GetStatusCounts(ctx context.Context) (*ResourceStatusCounts, error) {
 	query := `
 		SELECT
 			COUNT(*) FILTER (WHERE status = 'Submitted')     AS submitted,
@@ -420,9 +721,10 @@ func (m ResourceModel) GetStatusCounts() (*ResourceStatusCounts, error) {
 			COUNT(*) FILTER (WHERE status = 'NeedsRevision') AS needs_revision,
 			COUNT(*) FILTER (WHERE status = 'Approved')      AS approved,
 			COUNT(*) FILTER (WHERE status = 'Published')     AS published
-		FROM resources`
+		FROM resources
+		WHERE deleted_at IS NULL`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	var c ResourceStatusCounts
@@ -439,13 +741,13 @@ func (m ResourceModel) GetStatusCounts() (*ResourceStatusCounts, error) {
 	return &c, nil
 }
 
-func (m ResourceModel) GetSubjects(resourceID int64) ([]string, error) {
+func (m ResourceModel) GetSubjects(ctx context.Context, resourceID int64) ([]string, error) {
 	query := `
 		SELECT subject FROM resource_subjects
 		WHERE resource_id = $1
 		ORDER BY subject`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	rows, err := m.DB.QueryContext(ctx, query, resourceID)
@@ -467,13 +769,13 @@ func (m ResourceModel) GetSubjects(resourceID int64) ([]string, error) {
 }
 
 // GetGradeLevels returns all grade levels for a resource
-func (m ResourceModel) GetGradeLevels(resourceID int64) ([]string, error) {
+func (m ResourceModel) GetGradeLevels(ctx context.Context, resourceID int64) ([]string, error) {
 	query := `
 		SELECT grade_level FROM resource_grade_levels
 		WHERE resource_id = $1
 		ORDER BY grade_level`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	rows, err := m.DB.QueryContext(ctx, query, resourceID)
@@ -494,63 +796,47 @@ func (m ResourceModel) GetGradeLevels(resourceID int64) ([]string, error) {
 	return gradeLevels, rows.Err()
 }
 
-// SetSubjects replaces all subjects for a resource
-func (m ResourceModel) SetSubjects(resourceID int64, subjects []string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// SetSubjects replaces all subjects for a resource.  Unknown subjects return
+// an *UnknownValuesError.
+func (m ResourceModel) SetSubjects(ctx context.Context, resourceID int64, subjects []string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	// Start transaction
 	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Delete existing subjects
-	_, err = tx.ExecContext(ctx, "DELETE FROM resource_subjects WHERE resource_id = $1", resourceID)
-	if err != nil {
+	if err = checkLookupValues(ctx, tx, subjects, nil); err != nil {
 		return err
 	}
 
-	// Insert new subjects
-	for _, subject := range subjects {
-		_, err = tx.ExecContext(ctx,
-			"INSERT INTO resource_subjects (resource_id, subject) VALUES ($1, $2)",
-			resourceID, subject)
-		if err != nil {
-			return err
-		}
+	if err = replaceResourceSubjects(ctx, tx, resourceID, subjects); err != nil {
+		return err
 	}
 
 	return tx.Commit()
 }
 
-// SetGradeLevels replaces all grade levels for a resource
-func (m ResourceModel) SetGradeLevels(resourceID int64, gradeLevels []string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// SetGradeLevels replaces all grade levels for a resource.  Unknown grade
+// levels return an *UnknownValuesError.
+func (m ResourceModel) SetGradeLevels(ctx context.Context, resourceID int64, gradeLevels []string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	// Start transaction
 	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Delete existing grade levels
-	_, err = tx.ExecContext(ctx, "DELETE FROM resource_grade_levels WHERE resource_id = $1", resourceID)
-	if err != nil {
+	if err = checkLookupValues(ctx, tx, nil, gradeLevels); err != nil {
 		return err
 	}
 
-	// Insert new grade levels
-	for _, gradeLevel := range gradeLevels {
-		_, err = tx.ExecContext(ctx,
-			"INSERT INTO resource_grade_levels (resource_id, grade_level) VALUES ($1, $2)",
-			resourceID, gradeLevel)
-		if err != nil {
-			return err
-		}
+	if err = replaceResourceGradeLevels(ctx, tx, resourceID, gradeLevels); err != nil {
+		return err
 	}
 
 	return tx.Commit()
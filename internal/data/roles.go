@@ -5,15 +5,15 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"time"
 
 	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
 )
 
 // Role struct represents a system role
 type Role struct {
-	ID       int    `json:"id"`
-	RoleName string `json:"role_name"`
+	ID          int    `json:"id"`
+	RoleName    string `json:"role_name"`
+	MFARequired bool   `json:"mfa_required"`
 }
 
 // ValidateRole validates a role struct
@@ -28,37 +28,38 @@ type RoleModel struct {
 }
 
 // Insert a new role record in the database
-func (r *RoleModel) Insert(role *Role) error {
+func (r *RoleModel) Insert(ctx context.Context, role *Role) error {
 	query := `
-		INSERT INTO roles (name)
-		VALUES ($1)
+		INSERT INTO roles (name, mfa_required)
+		VALUES ($1, $2)
 		RETURNING role_id`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	return r.DB.QueryRowContext(ctx, query, role.RoleName).Scan(&role.ID)
+	return r.DB.QueryRowContext(ctx, query, role.RoleName, role.MFARequired).Scan(&role.ID)
 }
 
 // Get retrieves a specific role based on its ID
-func (r *RoleModel) Get(id int) (*Role, error) {
+func (r *RoleModel) Get(ctx context.Context, id int) (*Role, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
 	query := `
-		SELECT role_id, name
+		SELECT role_id, name, mfa_required
 		FROM roles
 		WHERE role_id = $1`
 
 	var role Role
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := r.DB.QueryRowContext(ctx, query, id).Scan(
 		&role.ID,
 		&role.RoleName,
+		&role.MFARequired,
 	)
 
 	if err != nil {
@@ -74,20 +75,21 @@ func (r *RoleModel) Get(id int) (*Role, error) {
 }
 
 // GetByName retrieves a role by its name (useful for authentication)
-func (r *RoleModel) GetByName(name string) (*Role, error) {
+func (r *RoleModel) GetByName(ctx context.Context, name string) (*Role, error) {
 	query := `
-		SELECT role_id, name
+		SELECT role_id, name, mfa_required
 		FROM roles
 		WHERE name = $1`
 
 	var role Role
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := r.DB.QueryRowContext(ctx, query, name).Scan(
 		&role.ID,
 		&role.RoleName,
+		&role.MFARequired,
 	)
 
 	if err != nil {
@@ -103,13 +105,13 @@ func (r *RoleModel) GetByName(name string) (*Role, error) {
 }
 
 // GetAll retrieves all roles from the database
-func (r *RoleModel) GetAll() ([]*Role, error) {
+func (r *RoleModel) GetAll(ctx context.Context) ([]*Role, error) {
 	query := `
-		SELECT role_id, name
+		SELECT role_id, name, mfa_required
 		FROM roles
 		ORDER BY name`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	rows, err := r.DB.QueryContext(ctx, query)
@@ -126,6 +128,7 @@ func (r *RoleModel) GetAll() ([]*Role, error) {
 		err := rows.Scan(
 			&role.ID,
 			&role.RoleName,
+			&role.MFARequired,
 		)
 		if err != nil {
 			return nil, err
@@ -142,36 +145,57 @@ func (r *RoleModel) GetAll() ([]*Role, error) {
 }
 
 // Update an existing role record in the database
-func (r *RoleModel) Update(role *Role) error {
+func (r *RoleModel) Update(ctx context.Context, role *Role, audit *AuditEvent) error {
 	query := `
 		UPDATE roles
-		SET name = $2
+		SET name = $2, mfa_required = $3
 		WHERE role_id = $1`
 
 	args := []interface{}{
 		role.ID,
 		role.RoleName,
+		role.MFARequired,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	_, err := r.DB.ExecContext(ctx, query, args...)
-	return err
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if err = insertAuditEvent(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // Delete removes a role record from the database
-func (r *RoleModel) Delete(id int) error {
+func (r *RoleModel) Delete(ctx context.Context, id int, audit *AuditEvent) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
 	query := `DELETE FROM roles WHERE role_id = $1`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	result, err := r.DB.ExecContext(ctx, query, id)
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -185,5 +209,9 @@ func (r *RoleModel) Delete(id int) error {
 		return ErrRecordNotFound
 	}
 
-	return nil
+	if err = insertAuditEvent(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
@@ -6,6 +6,8 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/base32"
+	"encoding/hex"
+	"errors"
 	"time"
 
 	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
@@ -14,6 +16,9 @@ import (
 // Purpose of the token
 const ScopeActivation = "activation"
 const ScopeAuthentication = "authentication"
+const ScopePasswordReset = "password-reset"
+const ScopeRefresh = "refresh"
+const ScopeMFAPending = "mfa-pending"
 
 // Define our token
 type Token struct {
@@ -22,6 +27,9 @@ type Token struct {
     UserID    int64       `json:"-"`
     Expiry    time.Time   `json:"expiry"`
     Scope     string      `json:"-"`
+    FamilyID  string      `json:"-"`
+    IPAddress string      `json:"-"`
+    UserAgent string      `json:"-"`
 }
 
 
@@ -62,40 +70,359 @@ type TokenModel struct {
 }
 // The New() method creates and returns a new token. It calls Insert() as a 
 // helper method
-func (t TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
+func (t TokenModel) New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error) {
 	token, err := generateToken(userID, ttl, scope)
 	if err != nil {
 		return nil, err
 	}
 
-	err = t.Insert(token)
+	err = t.Insert(ctx, token)
 	return token, err
 }
 
 // Do the actual insert in to the database table
-func (t TokenModel) Insert(token *Token) error {
-    query := `
-              INSERT INTO auth_tokens (token, user_id, expires_at, scope) 
-              VALUES ($1, $2, $3, $4)
-            `
-	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope}
-	
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (t TokenModel) Insert(ctx context.Context, token *Token) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	_, err := t.DB.ExecContext(ctx, query, args...)
+	return insertToken(ctx, t.DB, token)
+}
+
+// insertToken stores a token using either the pool or a transaction
+func insertToken(ctx context.Context, db interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+}, token *Token) error {
+	query := `
+		INSERT INTO auth_tokens (token, user_id, expires_at, scope, family_id, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''))`
+
+	args := []any{token.Hash, token.UserID, token.Expiry, token.Scope, token.FamilyID, token.IPAddress, token.UserAgent}
+
+	_, err := db.ExecContext(ctx, query, args...)
 	return err
 }
 
 // Delete a token based on the type and the user
-func (t TokenModel) DeleteAllForUser(scope string, userID int64) error {
+func (t TokenModel) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
 	query := `
             DELETE FROM auth_tokens 
             WHERE scope = $1 AND user_id = $2
 			`
-    ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+    ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
     defer cancel()
 
     _, err := t.DB.ExecContext(ctx, query, scope, userID)
     return err
 }
+
+// PurgeForUser is DeleteAllForUser for admins: the purge is recorded in
+// the audit log in the same transaction
+func (t TokenModel) PurgeForUser(ctx context.Context, scope string, userID int64, audit *AuditEvent) error {
+	query := `
+		DELETE FROM auth_tokens
+		WHERE scope = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := t.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query, scope, userID)
+	if err != nil {
+		return err
+	}
+
+	if err = insertAuditEvent(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// generateFamilyID returns a random identifier shared by every token issued
+// from one sign-in
+func generateFamilyID() (string, error) {
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(randomBytes), nil
+}
+
+// newTokenPair generates (without storing) an access and a refresh token in
+// the given family, recording the client they were issued to
+func newTokenPair(userID int64, familyID string, accessTTL, refreshTTL time.Duration, ipAddress, userAgent string) (*Token, *Token, error) {
+	access, err := generateToken(userID, accessTTL, ScopeAuthentication)
+	if err != nil {
+		return nil, nil, err
+	}
+	refresh, err := generateToken(userID, refreshTTL, ScopeRefresh)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, token := range []*Token{access, refresh} {
+		token.FamilyID = familyID
+		token.IPAddress = ipAddress
+		token.UserAgent = userAgent
+	}
+
+	return access, refresh, nil
+}
+
+// NewPair issues a short-lived access token and a long-lived refresh token
+// that start a new token family
+func (t TokenModel) NewPair(ctx context.Context, userID int64, accessTTL, refreshTTL time.Duration, ipAddress, userAgent string) (*Token, *Token, error) {
+	familyID, err := generateFamilyID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	access, refresh, err := newTokenPair(userID, familyID, accessTTL, refreshTTL, ipAddress, userAgent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := t.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if err = insertToken(ctx, tx, access); err != nil {
+		return nil, nil, err
+	}
+	if err = insertToken(ctx, tx, refresh); err != nil {
+		return nil, nil, err
+	}
+
+	return access, refresh, tx.Commit()
+}
+
+// Rotate exchanges a refresh token for a new access/refresh pair in the same
+// family.  The presented refresh token is marked as used rather than deleted
+// so that a second attempt to use it can be recognised: in that case the
+// whole family is revoked and ErrTokenReused is returned.  An unknown or
+// expired token returns ErrRecordNotFound.
+func (t TokenModel) Rotate(ctx context.Context, refreshPlaintext string, accessTTL, refreshTTL time.Duration, ipAddress, userAgent string) (*Token, *Token, error) {
+	hash := sha256.Sum256([]byte(refreshPlaintext))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := t.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT token_id, user_id, COALESCE(family_id, ''), expires_at, used_at
+		FROM auth_tokens
+		WHERE token = $1 AND scope = $2
+		FOR UPDATE`
+
+	var (
+		tokenID  int64
+		userID   int64
+		familyID string
+		expiry   time.Time
+		usedAt   sql.NullTime
+	)
+
+	err = tx.QueryRowContext(ctx, query, hash[:], ScopeRefresh).Scan(&tokenID, &userID, &familyID, &expiry, &usedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	if usedAt.Valid {
+		_, err = tx.ExecContext(ctx, "DELETE FROM auth_tokens WHERE family_id = $1", familyID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = tx.Commit(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrTokenReused
+	}
+
+	if !expiry.After(time.Now()) {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE auth_tokens SET used_at = NOW() WHERE token_id = $1", tokenID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	access, refresh, err := newTokenPair(userID, familyID, accessTTL, refreshTTL, ipAddress, userAgent)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = insertToken(ctx, tx, access); err != nil {
+		return nil, nil, err
+	}
+	if err = insertToken(ctx, tx, refresh); err != nil {
+		return nil, nil, err
+	}
+
+	return access, refresh, tx.Commit()
+}
+
+// DeleteFamily revokes every token issued from the same sign-in
+func (t TokenModel) DeleteFamily(ctx context.Context, familyID string) error {
+	query := `
+		DELETE FROM auth_tokens
+		WHERE family_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := t.DB.ExecContext(ctx, query, familyID)
+	return err
+}
+
+// Session is one sign-in: every access and refresh token in a token family
+type Session struct {
+	ID         string     `json:"id"`
+	IPAddress  string     `json:"ip_address,omitempty"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Current    bool       `json:"current"`
+}
+
+// GetSessionsForUser lists a user's live sessions, most recently used first.
+// The IP address and user agent are those of the latest token in the session.
+func (t TokenModel) GetSessionsForUser(ctx context.Context, userID int64) ([]*Session, error) {
+	query := `
+		SELECT family_id,
+		       COALESCE((ARRAY_AGG(ip_address ORDER BY token_id DESC))[1], ''),
+		       COALESCE((ARRAY_AGG(user_agent ORDER BY token_id DESC))[1], ''),
+		       MIN(created_at),
+		       MAX(last_used_at),
+		       MAX(expires_at) FILTER (WHERE used_at IS NULL)
+		FROM auth_tokens
+		WHERE user_id = $1
+		AND scope IN ($2, $3)
+		AND family_id IS NOT NULL
+		GROUP BY family_id
+		HAVING MAX(expires_at) FILTER (WHERE used_at IS NULL) > NOW()
+		ORDER BY MAX(last_used_at) DESC NULLS LAST, MIN(created_at) DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := t.DB.QueryContext(ctx, query, userID, ScopeAuthentication, ScopeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(
+			&session.ID,
+			&session.IPAddress,
+			&session.UserAgent,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.ExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// DeleteSessionForUser revokes one of the user's sessions.  ErrRecordNotFound
+// is returned if the session does not exist or belongs to someone else.
+func (t TokenModel) DeleteSessionForUser(ctx context.Context, userID int64, sessionID string) error {
+	query := `
+		DELETE FROM auth_tokens
+		WHERE user_id = $1 AND family_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := t.DB.ExecContext(ctx, query, userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetFamilyID returns the session a token belongs to
+func (t TokenModel) GetFamilyID(ctx context.Context, tokenPlaintext string) (string, error) {
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+		SELECT COALESCE(family_id, '')
+		FROM auth_tokens
+		WHERE token = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	var familyID string
+	err := t.DB.QueryRowContext(ctx, query, hash[:]).Scan(&familyID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", ErrRecordNotFound
+		default:
+			return "", err
+		}
+	}
+
+	return familyID, nil
+}
+
+// DeleteByPlaintext deletes a single token
+func (t TokenModel) DeleteByPlaintext(ctx context.Context, tokenPlaintext string) error {
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := t.DB.ExecContext(ctx, "DELETE FROM auth_tokens WHERE token = $1", hash[:])
+	return err
+}
+
+// Touch records that a token was just used
+func (t TokenModel) Touch(ctx context.Context, tokenPlaintext string) error {
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := t.DB.ExecContext(ctx, "UPDATE auth_tokens SET last_used_at = NOW() WHERE token = $1", hash[:])
+	return err
+}
@@ -0,0 +1,255 @@
+//filename: internal/data/trash.go
+
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Things that can be in the trash
+const (
+	TrashResource = "resource"
+	TrashLesson   = "lesson"
+	TrashComment  = "comment"
+)
+
+// TrashTypes lists the trash item types in the order they are purged
+var TrashTypes = []string{TrashComment, TrashLesson, TrashResource}
+
+// trashTables maps each trash item type to its table and key
+var trashTables = map[string]struct{ table, idColumn string }{
+	TrashResource: {"resources", "resource_id"},
+	TrashLesson:   {"lessons", "lesson_id"},
+	TrashComment:  {"resource_comments", "comment_id"},
+}
+
+// TrashItem is a soft-deleted resource, lesson or comment.  Title is the
+// resource or lesson title, or the start of a comment.
+type TrashItem struct {
+	Type              string    `json:"type"`
+	ID                int64     `json:"id"`
+	ResourceID        int64     `json:"resource_id"`
+	Title             string    `json:"title"`
+	DeletedAt         time.Time `json:"deleted_at"`
+	DeletedBy         int64     `json:"deleted_by,omitempty"`
+	DeletedByUsername string    `json:"deleted_by_username,omitempty"`
+}
+
+// TrashPurge counts what a purge removed
+type TrashPurge struct {
+	Resources int64 `json:"resources"`
+	Lessons   int64 `json:"lessons"`
+	Comments  int64 `json:"comments"`
+}
+
+type TrashModel struct {
+	DB *sql.DB
+}
+
+// TrashFields are the fields the trash can be filtered and sorted on
+var TrashFields = Fields{
+	"type":        {Column: "t.type", Type: TextField, Ops: []Op{OpEq, OpIn}},
+	"resource_id": {Column: "t.resource_id", Type: IntField, Ops: []Op{OpEq, OpIn}},
+	"deleted_by":  {Column: "t.deleted_by", Type: IntField, Ops: []Op{OpEq, OpIn}},
+	"deleted_at":  {Column: "t.deleted_at", Type: TimeField, Ops: []Op{OpGte, OpLte}, Sortable: true},
+}
+
+// GetAll returns a page of soft-deleted items of every type
+func (m TrashModel) GetAll(ctx context.Context, filters Filters) ([]*TrashItem, Metadata, error) {
+	b := &queryBuilder{}
+	b.filter(TrashFields, filters.Conditions)
+
+	// IDs are only unique within a type, so the type breaks ties before the
+	// ID does, sorting the same way
+	keys := filters.sortKeys(TrashFields)
+	typeDesc := len(keys) > 0 && keys[len(keys)-1].desc
+	keys = append(keys, sortKey{field: "type", column: "t.type", desc: typeDesc})
+	page := filters.paginate(b, keys, "t.id")
+
+	query := fmt.Sprintf(`
+		SELECT %s, t.type, t.id, t.resource_id, t.title, t.deleted_at,
+		       COALESCE(t.deleted_by, 0), COALESCE(u.username, '')
+		FROM (
+			SELECT 'resource'::text AS type, resource_id AS id, resource_id, title,
+			       deleted_at, deleted_by
+			FROM resources WHERE deleted_at IS NOT NULL
+			UNION ALL
+			SELECT 'lesson', lesson_id, resource_id, title, deleted_at, deleted_by
+			FROM lessons WHERE deleted_at IS NOT NULL
+			UNION ALL
+			SELECT 'comment', c.comment_id, c.resource_id, left(c.content, 80), c.deleted_at, c.deleted_by
+			FROM resource_comments c
+			LEFT JOIN resource_comments p ON p.comment_id = c.parent_comment_id
+			WHERE c.deleted_at IS NOT NULL AND p.deleted_at IS DISTINCT FROM c.deleted_at
+		) t
+		LEFT JOIN users u ON u.user_id = t.deleted_by
+		WHERE %s
+		ORDER BY %s
+		%s`, page.count, b.where(), page.orderBy, page.limit)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, b.args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	items := []*TrashItem{}
+
+	for rows.Next() {
+		var item TrashItem
+		err := rows.Scan(
+			&totalRecords,
+			&item.Type,
+			&item.ID,
+			&item.ResourceID,
+			&item.Title,
+			&item.DeletedAt,
+			&item.DeletedBy,
+			&item.DeletedByUsername,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	items, metadata := pageResults(filters, page, items, totalRecords, func(t *TrashItem) (map[string]any, int64) {
+		return map[string]any{"deleted_at": t.DeletedAt, "type": t.Type}, t.ID
+	})
+
+	return items, metadata, nil
+}
+
+// Restore takes an item out of the trash.  A comment comes back with the
+// replies that were trashed with it.  ErrRecordNotFound is returned if there
+// is no such item in the trash, ErrParentCommentTrashed for a reply whose
+// comment is still in the trash and ErrDuplicateLessonNumber for a lesson
+// whose number has been given to another lesson since it was trashed.
+func (m TrashModel) Restore(ctx context.Context, itemType string, id int64, audit *AuditEvent) error {
+	t, ok := trashTables[itemType]
+	if !ok || id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET deleted_at = NULL, deleted_by = NULL
+		WHERE %s = $1 AND deleted_at IS NOT NULL`, t.table, t.idColumn)
+
+	if itemType == TrashComment {
+		query = `
+			WITH RECURSIVE thread AS (
+				SELECT comment_id, deleted_at FROM resource_comments
+				WHERE comment_id = $1 AND deleted_at IS NOT NULL
+				UNION ALL
+				SELECT c.comment_id, c.deleted_at FROM resource_comments c
+				JOIN thread t ON c.parent_comment_id = t.comment_id
+				WHERE c.deleted_at = t.deleted_at
+			)
+			UPDATE resource_comments
+			SET deleted_at = NULL, deleted_by = NULL
+			WHERE comment_id IN (SELECT comment_id FROM thread)`
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// A reply restored without its comment would be live under a hidden parent
+	if itemType == TrashComment {
+		var parentTrashed bool
+		err = tx.QueryRowContext(ctx, `
+			SELECT p.deleted_at IS NOT NULL
+			FROM resource_comments c
+			LEFT JOIN resource_comments p ON p.comment_id = c.parent_comment_id
+			WHERE c.comment_id = $1`, id).Scan(&parentTrashed)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if parentTrashed {
+			return ErrParentCommentTrashed
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		switch {
+		case itemType == TrashLesson && isDuplicateLessonNumber(err):
+			return ErrDuplicateLessonNumber
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	if err = insertAuditEvent(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Purge permanently deletes everything that went into the trash before the
+// given time.  Purging a resource also removes its lessons, reviews, comments
+// and history through their foreign keys.  A comment's replies are trashed
+// with it, so the cascade to them only removes replies that are in the trash.
+func (m TrashModel) Purge(ctx context.Context, before time.Time) (*TrashPurge, error) {
+	ctx, cancel := context.WithTimeout(ctx, PurgeTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var purge TrashPurge
+	counts := map[string]*int64{
+		TrashResource: &purge.Resources,
+		TrashLesson:   &purge.Lessons,
+		TrashComment:  &purge.Comments,
+	}
+
+	for _, itemType := range TrashTypes {
+		t := trashTables[itemType]
+		query := fmt.Sprintf(`DELETE FROM %s WHERE deleted_at < $1`, t.table)
+
+		result, err := tx.ExecContext(ctx, query, before)
+		if err != nil {
+			return nil, err
+		}
+		if *counts[itemType], err = result.RowsAffected(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &purge, nil
+}
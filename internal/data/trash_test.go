@@ -0,0 +1,142 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// A comment's replies go into the trash, come back out and are purged with
+// it, so none is left live under a hidden parent or purged unseen
+func TestTrashCommentWithReplies(t *testing.T) {
+	db := newTestDB(t)
+	userID, resourceID := insertTestResource(t, db)
+
+	ctx := context.Background()
+	comments := ResourceCommentModel{DB: db}
+	trash := TrashModel{DB: db}
+
+	post := func(parent *ResourceComment) *ResourceComment {
+		t.Helper()
+		comment := &ResourceComment{ResourceID: resourceID, UserID: userID, Content: "comment"}
+		if parent != nil {
+			comment.ParentCommentID = &parent.ID
+		}
+		if err := comments.Insert(ctx, comment); err != nil {
+			t.Fatal(err)
+		}
+		return comment
+	}
+	live := func() []int64 {
+		t.Helper()
+		page, _, err := comments.GetByResource(ctx, resourceID, Filters{Page: 1, PageSize: 20, Sort: "comment_id"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int64{}
+		for _, c := range page {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+	trashed := func() []int64 {
+		t.Helper()
+		items, _, err := trash.GetAll(ctx, Filters{Page: 1, PageSize: 20, Sort: "deleted_at", Conditions: []Condition{
+			{Field: "resource_id", Op: OpEq, Value: strconv.FormatInt(resourceID, 10)},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int64{}
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		return ids
+	}
+	check := func(step string, gotLive, wantLive, gotTrash, wantTrash []int64) {
+		t.Helper()
+		if !reflect.DeepEqual(gotLive, wantLive) {
+			t.Errorf("%s: live comments = %v, want %v", step, gotLive, wantLive)
+		}
+		if !reflect.DeepEqual(gotTrash, wantTrash) {
+			t.Errorf("%s: trash = %v, want %v", step, gotTrash, wantTrash)
+		}
+	}
+
+	parent := post(nil)
+	reply := post(parent)
+	nested := post(reply)
+	other := post(nil)
+
+	// Deleting the parent trashes the thread, which is listed once
+	if err := comments.Delete(ctx, parent.ID, userID); err != nil {
+		t.Fatal(err)
+	}
+	check("after delete", live(), []int64{other.ID}, trashed(), []int64{parent.ID})
+
+	if _, err := comments.Get(ctx, reply.ID); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("Get reply of a trashed comment: error = %v, want ErrRecordNotFound", err)
+	}
+	if err := comments.Insert(ctx, &ResourceComment{ResourceID: resourceID, UserID: userID,
+		ParentCommentID: &parent.ID, Content: "late reply"}); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("reply to a trashed comment: error = %v, want ErrRecordNotFound", err)
+	}
+
+	// A reply cannot come back without its comment
+	if err := trash.Restore(ctx, TrashComment, reply.ID, nil); !errors.Is(err, ErrParentCommentTrashed) {
+		t.Errorf("restore reply: error = %v, want ErrParentCommentTrashed", err)
+	}
+
+	// Restoring the parent brings the thread back
+	if err := trash.Restore(ctx, TrashComment, parent.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	check("after restore", live(), []int64{parent.ID, reply.ID, nested.ID, other.ID}, trashed(), []int64{})
+
+	// A reply trashed on its own stays in the trash when its parent, trashed
+	// later, is restored
+	if err := comments.Delete(ctx, reply.ID, userID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := comments.Delete(ctx, parent.ID, userID); err != nil {
+		t.Fatal(err)
+	}
+	check("after separate deletes", live(), []int64{other.ID}, trashed(), []int64{parent.ID, reply.ID})
+
+	if err := trash.Restore(ctx, TrashComment, parent.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	check("after parent restore", live(), []int64{parent.ID, other.ID}, trashed(), []int64{reply.ID})
+
+	if err := trash.Restore(ctx, TrashComment, reply.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	check("after reply restore", live(), []int64{parent.ID, reply.ID, nested.ID, other.ID}, trashed(), []int64{})
+
+	// Purging the parent removes exactly the thread that was in the trash.
+	// The thread is backdated so that only it is old enough to be purged.
+	if err := comments.Delete(ctx, parent.ID, userID); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE resource_comments SET deleted_at = '2000-01-01'
+		WHERE resource_id = $1 AND deleted_at IS NOT NULL`, resourceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	purge, err := trash.Purge(ctx, time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purge.Comments != 3 {
+		t.Errorf("purged %d comments, want the 3 in the thread", purge.Comments)
+	}
+	check("after purge", live(), []int64{other.ID}, trashed(), []int64{})
+}
@@ -0,0 +1,103 @@
+//filename: internal/data/user_subjects.go
+
+package data
+
+import (
+	"context"
+	"slices"
+
+	"github.com/lib/pq"
+)
+
+// GetSubjects returns the subjects a user is an expert in
+func (u *UserModel) GetSubjects(ctx context.Context, userID int64) ([]string, error) {
+	query := `
+		SELECT subject FROM user_subjects
+		WHERE user_id = $1
+		ORDER BY subject`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := u.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subjects := []string{}
+	for rows.Next() {
+		var subject string
+		if err := rows.Scan(&subject); err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, subject)
+	}
+
+	return subjects, rows.Err()
+}
+
+// SetSubjects replaces a user's subject expertise.  ErrUnknownSubject is
+// returned (and nothing is changed) if any subject is not in the subjects table.
+func (u *UserModel) SetSubjects(ctx context.Context, userID int64, subjects []string, audit *AuditEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM user_subjects WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	unique := slices.Clone(subjects)
+	slices.Sort(unique)
+	unique = slices.Compact(unique)
+
+	query := `
+		INSERT INTO user_subjects (user_id, subject)
+		SELECT $1, subject FROM subjects
+		WHERE subject = ANY($2)`
+
+	result, err := tx.ExecContext(ctx, query, userID, pq.Array(unique))
+	if err != nil {
+		return err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if int(inserted) != len(unique) {
+		return ErrUnknownSubject
+	}
+
+	if err = insertAuditEvent(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// HasExpertiseFor reports whether any of the user's subjects matches one of
+// the resource's subjects
+func (u *UserModel) HasExpertiseFor(ctx context.Context, userID, resourceID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM resource_subjects rs
+			INNER JOIN user_subjects us ON us.subject = rs.subject
+			WHERE rs.resource_id = $1 AND us.user_id = $2
+		)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	var ok bool
+	err := u.DB.QueryRowContext(ctx, query, resourceID, userID).Scan(&ok)
+	return ok, err
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/amilcar-vasquez/501SteamHub/internal/validator"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -30,6 +31,11 @@ type User struct {
 	CreatedBy int        `json:"created_by,omitempty"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	UpdatedBy int        `json:"updated_by,omitempty"`
+	Version   int32      `json:"version"`
+
+	// MFARequired and MFAEnabled are only loaded for authenticated users
+	MFARequired bool `json:"-"`
+	MFAEnabled  bool `json:"-"`
 }
 
 // define the password type (the plaintext + hashed password)
@@ -105,14 +111,14 @@ type UserModel struct {
 }
 
 // Insert a new user record in the database
-func (u *UserModel) Insert(user *User) error {
+func (u *UserModel) Insert(ctx context.Context, user *User) error {
 	query := `
 		INSERT INTO users (
 			username, email, password_hash, role_id, is_active, last_login, created_by, updated_by
 		) VALUES (
 			$1, $2, $3, $4, $5, $6, $7, $8
 		)
-		RETURNING user_id, created_at, updated_at
+		RETURNING user_id, created_at, updated_at, version
 	`
 
 	var lastLogin interface{}
@@ -147,10 +153,10 @@ func (u *UserModel) Insert(user *User) error {
 		updatedBy,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	err := u.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	err := u.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Version)
 	if err != nil {
 		// detect duplicate email error
 		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") && strings.Contains(err.Error(), "users_email_key") {
@@ -162,11 +168,11 @@ func (u *UserModel) Insert(user *User) error {
 }
 
 // Get a user from the database based on their email provided
-func (u *UserModel) GetByEmail(email string) (*User, error) {
+func (u *UserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
 	query := `
 		SELECT u.user_id, u.username, u.email, u.password_hash, u.role_id,
 		       r.name AS role_name,
-		       u.is_active, u.last_login, u.created_at, u.created_by, u.updated_at, u.updated_by
+		       u.is_active, u.last_login, u.created_at, u.created_by, u.updated_at, u.updated_by, u.version
 		FROM users u
 		LEFT JOIN roles r ON u.role_id = r.role_id
 		WHERE u.email = $1
@@ -174,7 +180,7 @@ func (u *UserModel) GetByEmail(email string) (*User, error) {
 
 	var user User
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	var lastLogin sql.NullTime
@@ -195,6 +201,7 @@ func (u *UserModel) GetByEmail(email string) (*User, error) {
 		&createdBy,
 		&user.UpdatedAt,
 		&updatedBy,
+		&user.Version,
 	)
 
 	if lastLogin.Valid {
@@ -221,14 +228,18 @@ func (u *UserModel) GetByEmail(email string) (*User, error) {
 	return &user, nil
 }
 
-// Update an existing user record in the database
-func (m *UserModel) Update(user *User) error {
+// Update an existing user record in the database.  The update only applies
+// if the row is still at user.Version; otherwise ErrEditConflict is returned.
+// Changes to a user's role or activation pass an audit event, which is
+// recorded in the same transaction.
+func (m *UserModel) Update(ctx context.Context, user *User, audit *AuditEvent) error {
 	query := `
 		UPDATE users
 		SET username = $1, email = $2, password_hash = $3, role_id = $4,
-			is_active = $5, last_login = $6, updated_at = NOW(), updated_by = $7
-		WHERE user_id = $8
-		RETURNING updated_at
+			is_active = $5, last_login = $6, updated_at = NOW(), updated_by = $7,
+			version = version + 1
+		WHERE user_id = $8 AND version = $9
+		RETURNING updated_at, version
 	`
 
 	var lastLogin interface{}
@@ -254,12 +265,19 @@ func (m *UserModel) Update(user *User) error {
 		lastLogin,
 		updatedBy,
 		user.ID,
+		user.Version,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.UpdatedAt)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&user.UpdatedAt, &user.Version)
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") && strings.Contains(err.Error(), "users_email_key") {
 			return ErrDuplicateEmail
@@ -270,24 +288,98 @@ func (m *UserModel) Update(user *User) error {
 		return err
 	}
 
-	return nil
+	if err = insertAuditEvent(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ResetPassword consumes a password-reset token, stores user.Password as the
+// password of the user the token belongs to and signs them out everywhere by
+// revoking their authentication, refresh and pending two-factor tokens, all
+// in one transaction.  user.ID is set from the token.  ErrRecordNotFound is
+// returned if the token is unknown, expired or has already been used.
+func (m *UserModel) ResetPassword(ctx context.Context, tokenPlaintext string, user *User) error {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Deleting the token claims it, so two requests cannot both use it
+	query := `
+		DELETE FROM auth_tokens
+		WHERE token = $1 AND scope = $2 AND expires_at > $3
+		RETURNING user_id`
+
+	err = tx.QueryRowContext(ctx, query, tokenHash[:], ScopePasswordReset, time.Now()).Scan(&user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	query = `
+		UPDATE users
+		SET password_hash = $1, updated_at = NOW(), version = version + 1
+		WHERE user_id = $2
+		RETURNING updated_at`
+
+	err = tx.QueryRowContext(ctx, query, user.Password.hash, user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	// Any other reset tokens and every session may belong to whoever knew
+	// the old password
+	query = `
+		DELETE FROM auth_tokens
+		WHERE user_id = $1 AND scope = ANY($2)`
+
+	scopes := []string{ScopePasswordReset, ScopeAuthentication, ScopeRefresh, ScopeMFAPending}
+	if _, err = tx.ExecContext(ctx, query, user.ID, pq.Array(scopes)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // UpdateActivation updates only the is_active field for a user
-// This is used when activating a user account via email token
-func (m *UserModel) UpdateActivation(userID int64, isActive bool) error {
+// This is used when activating a user account via email token, and by admins
+// toggling an account (who pass an audit event)
+func (m *UserModel) UpdateActivation(ctx context.Context, userID int64, isActive bool, audit *AuditEvent) error {
 	query := `
 		UPDATE users
-		SET is_active = $1, updated_at = NOW()
+		SET is_active = $1, updated_at = NOW(), version = version + 1
 		WHERE user_id = $2
 		RETURNING updated_at
 	`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	var updatedAt time.Time
-	err := m.DB.QueryRowContext(ctx, query, isActive, userID).Scan(&updatedAt)
+	err = tx.QueryRowContext(ctx, query, isActive, userID).Scan(&updatedAt)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -297,11 +389,15 @@ func (m *UserModel) UpdateActivation(userID int64, isActive bool) error {
 		}
 	}
 
-	return nil
+	if err = insertAuditEvent(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // Get retrieves a specific user based on its ID
-func (u *UserModel) Get(id int) (*User, error) {
+func (u *UserModel) Get(ctx context.Context, id int) (*User, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -309,14 +405,14 @@ func (u *UserModel) Get(id int) (*User, error) {
 	query := `
 		SELECT u.user_id, u.username, u.email, u.password_hash, u.role_id,
 		       r.name AS role_name,
-		       u.is_active, u.last_login, u.created_at, u.created_by, u.updated_at, u.updated_by
+		       u.is_active, u.last_login, u.created_at, u.created_by, u.updated_at, u.updated_by, u.version
 		FROM users u
 		LEFT JOIN roles r ON u.role_id = r.role_id
 		WHERE u.user_id = $1`
 
 	var user User
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	var lastLogin sql.NullTime
@@ -336,6 +432,7 @@ func (u *UserModel) Get(id int) (*User, error) {
 		&createdBy,
 		&user.UpdatedAt,
 		&updatedBy,
+		&user.Version,
 	)
 
 	if lastLogin.Valid {
@@ -363,70 +460,38 @@ func (u *UserModel) Get(id int) (*User, error) {
 	return &user, nil
 }
 
-// GetAll retrieves all users with filtering and pagination
-func (u *UserModel) GetAll(regionID, formationID, rankID int, isActive *bool, lastName string, username string, filters Filters) ([]*User, Metadata, error) {
-	query := `
-		SELECT count(*) OVER(), u.user_id, u.username, u.email, u.role_id, r.name as role_name, u.is_active, u.last_login, u.created_at, u.created_by, u.updated_at, u.updated_by
-		FROM users u
-		LEFT JOIN roles r ON u.role_id = r.role_id
-		WHERE 1=1`
-
-	args := []interface{}{}
-	argCount := 0
-
-	// Add filters
-	if regionID > 0 {
-		argCount++
-		query += ` AND region_id = $` + fmt.Sprintf("%d", argCount)
-		args = append(args, regionID)
-	}
-
-	if formationID > 0 {
-		argCount++
-		query += ` AND formation_id = $` + fmt.Sprintf("%d", argCount)
-		args = append(args, formationID)
-	}
-
-	if rankID > 0 {
-		argCount++
-		query += ` AND rank_id = $` + fmt.Sprintf("%d", argCount)
-		args = append(args, rankID)
-	}
-
-	if lastName != "" {
-		argCount++
-		query += ` AND last_name ILIKE '%' || $` + fmt.Sprintf("%d", argCount) + ` || '%'`
-		args = append(args, lastName)
-	}
-
-	if username != "" {
-		argCount++
-		query += ` AND username ILIKE '%' || $` + fmt.Sprintf("%d", argCount) + ` || '%'`
-		args = append(args, username)
-	}
-
-	if isActive != nil {
-		argCount++
-		query += ` AND is_active = $` + fmt.Sprintf("%d", argCount)
-		args = append(args, *isActive)
-	}
-
-	// Add sorting
-	query += fmt.Sprintf(" ORDER BY %s %s", filters.sortColumn(), filters.sortDirection())
+// UserFields are the fields the user list can be filtered and sorted on.  id
+// is kept as a shorter name for user_id.
+var UserFields = Fields{
+	"id":         {Column: "u.user_id", Type: IntField, Ops: []Op{OpEq, OpIn}, Sortable: true},
+	"user_id":    {Column: "u.user_id", Type: IntField, Ops: []Op{OpEq, OpIn}, Sortable: true},
+	"username":   {Column: "u.username", Type: TextField, Ops: []Op{OpContains, OpEq}, Sortable: true},
+	"email":      {Column: "u.email", Type: TextField, Ops: []Op{OpContains, OpEq}, Sortable: true},
+	"role_id":    {Column: "u.role_id", Type: IntField, Ops: []Op{OpEq, OpIn}},
+	"role":       {Column: "r.name", Type: TextField, Ops: []Op{OpEq, OpIn}},
+	"is_active":  {Column: "u.is_active", Type: BoolField, Ops: []Op{OpEq}},
+	"created_at": {Column: "u.created_at", Type: TimeField, Ops: []Op{OpGte, OpLte}, Sortable: true},
+	"last_login": {Column: "u.last_login", Type: TimeField, Ops: []Op{OpGte, OpLte}},
+}
 
-	// Add pagination
-	argCount++
-	limitArg := argCount
-	argCount++
-	offsetArg := argCount
+// GetAll retrieves users matching the filter conditions, a page at a time
+func (u *UserModel) GetAll(ctx context.Context, filters Filters) ([]*User, Metadata, error) {
+	b := &queryBuilder{}
+	b.filter(UserFields, filters.Conditions)
+	page := filters.paginate(b, filters.sortKeys(UserFields), "u.user_id")
 
-	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", limitArg, offsetArg)
-	args = append(args, filters.limit(), filters.offset())
+	query := fmt.Sprintf(`
+		SELECT %s, u.user_id, u.username, u.email, u.role_id, r.name as role_name, u.is_active, u.last_login, u.created_at, u.created_by, u.updated_at, u.updated_by, u.version
+		FROM users u
+		LEFT JOIN roles r ON u.role_id = r.role_id
+		WHERE %s
+		ORDER BY %s
+		%s`, page.count, b.where(), page.orderBy, page.limit)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	rows, err := u.DB.QueryContext(ctx, query, args...)
+	rows, err := u.DB.QueryContext(ctx, query, b.args...)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
@@ -455,6 +520,7 @@ func (u *UserModel) GetAll(regionID, formationID, rankID int, isActive *bool, la
 			&createdBy,
 			&user.UpdatedAt,
 			&updatedBy,
+			&user.Version,
 		)
 
 		if err != nil {
@@ -481,13 +547,64 @@ func (u *UserModel) GetAll(regionID, formationID, rankID int, isActive *bool, la
 		return nil, Metadata{}, err
 	}
 
-	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	users, metadata := pageResults(filters, page, users, totalRecords, func(user *User) (map[string]any, int64) {
+		return map[string]any{
+			"id":         user.ID,
+			"user_id":    user.ID,
+			"username":   user.Username,
+			"email":      user.Email,
+			"created_at": user.CreatedAt,
+		}, user.ID
+	})
 
 	return users, metadata, nil
 }
 
+// Export calls fn for every user matching the filter conditions, in the
+// same order as GetAll but without paging
+func (u *UserModel) Export(ctx context.Context, filters Filters, fn func(*User) error) error {
+	b := &queryBuilder{}
+	b.filter(UserFields, filters.Conditions)
+
+	query := fmt.Sprintf(`
+		SELECT u.user_id, u.username, u.email, u.role_id, COALESCE(r.name, ''), u.is_active, u.last_login, u.created_at,
+		       COALESCE(u.created_by, 0), u.updated_at, COALESCE(u.updated_by, 0), u.version
+		FROM users u
+		LEFT JOIN roles r ON u.role_id = r.role_id
+		WHERE %s
+		ORDER BY %s`, b.where(), orderClause(withIDKey(filters.sortKeys(UserFields), "u.user_id")))
+
+	return exportRows(ctx, u.DB, query, b.args, func(rows *sql.Rows) error {
+		var user User
+		var lastLogin sql.NullTime
+
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.Email,
+			&user.RoleID,
+			&user.RoleName,
+			&user.IsActive,
+			&lastLogin,
+			&user.CreatedAt,
+			&user.CreatedBy,
+			&user.UpdatedAt,
+			&user.UpdatedBy,
+			&user.Version,
+		)
+		if err != nil {
+			return err
+		}
+
+		if lastLogin.Valid {
+			user.LastLogin = &lastLogin.Time
+		}
+		return fn(&user)
+	})
+}
+
 // Delete removes a user record from the database
-func (u *UserModel) Delete(id int) error {
+func (u *UserModel) Delete(ctx context.Context, id int, audit *AuditEvent) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
@@ -496,10 +613,16 @@ func (u *UserModel) Delete(id int) error {
 		DELETE FROM users
 		WHERE user_id = $1`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	result, err := u.DB.ExecContext(ctx, query, id)
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -513,18 +636,24 @@ func (u *UserModel) Delete(id int) error {
 		return ErrRecordNotFound
 	}
 
-	return nil
+	if err = insertAuditEvent(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // Verify token to user. We need to hash the passed in token
-func (u *UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+func (u *UserModel) GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 
 	// We will do a join- I hope you still remember how to do a join
 	query := `
         SELECT users.user_id, users.created_at, users.username,
                users.email, users.password_hash, users.is_active, 
-               users.role_id, roles.name
+               users.role_id, roles.name, roles.mfa_required,
+               EXISTS (SELECT 1 FROM user_mfa m
+                       WHERE m.user_id = users.user_id AND m.enabled)
         FROM users
         INNER JOIN auth_tokens as tokens
         ON users.user_id = tokens.user_id
@@ -536,7 +665,7 @@ func (u *UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error
        `
 	args := []any{tokenHash[:], tokenScope, time.Now()}
 	var user User
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 	err := u.DB.QueryRowContext(ctx, query, args...).Scan(
 		&user.ID,
@@ -547,6 +676,8 @@ func (u *UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error
 		&user.IsActive,
 		&user.RoleID,
 		&user.RoleName,
+		&user.MFARequired,
+		&user.MFAEnabled,
 	)
 	if err != nil {
 		switch {
@@ -566,10 +697,10 @@ func (u *User) IsAnonymous() bool {
 }
 
 // CountUsers returns the total number of users in the database
-func (u *UserModel) CountUsers() (int, error) {
+func (u *UserModel) CountUsers(ctx context.Context) (int, error) {
 	query := `SELECT COUNT(*) FROM users`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	var count int
@@ -0,0 +1,269 @@
+// filename: internal/lessonplan/lessonplan.go
+
+// Package lessonplan renders lesson plans for printing and sharing offline,
+// as HTML, Markdown or PDF.  Everything it needs, templates and fonts alike,
+// is built in, so it works without network access.
+package lessonplan
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"regexp"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/amilcar-vasquez/501SteamHub/internal/data"
+)
+
+// the templates are built into the binary, as the mailer's are
+//
+//go:embed templates
+var templateFS embed.FS
+
+// Formats a lesson plan can be rendered in
+const (
+	FormatHTML     = "html"
+	FormatMarkdown = "md"
+	FormatPDF      = "pdf"
+)
+
+// Formats lists every format, in the order they are offered
+var Formats = []string{FormatHTML, FormatMarkdown, FormatPDF}
+
+// ContentType returns the media type of a rendered format
+func ContentType(format string) string {
+	switch format {
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatPDF:
+		return "application/pdf"
+	default:
+		return "text/html; charset=utf-8"
+	}
+}
+
+// Plan is what is rendered: a resource and the lessons to print from it
+type Plan struct {
+	Title       string
+	Summary     string
+	Category    string
+	Subjects    []string
+	GradeLevels []string
+	Lessons     []Lesson
+	GeneratedAt time.Time
+}
+
+// Lesson is one lesson of a plan.  Blocks holds its content if that is a
+// lesson document; older content that is not is kept whole in Text.
+type Lesson struct {
+	Number          int
+	Title           string
+	DurationMinutes int
+	Objectives      []string
+	Materials       []string
+	Assessment      string
+	Differentiation string
+	Blocks          []data.LessonBlock
+	Text            string
+}
+
+// NewPlan builds the plan for resource and the given lessons.  Blocks only
+// fellows may see are left out unless fellowBlocks is set.
+func NewPlan(resource *data.Resource, lessons []*data.Lesson, fellowBlocks bool) Plan {
+	plan := Plan{
+		Title:       resource.Title,
+		Category:    resource.Category,
+		Subjects:    resource.Subjects,
+		GradeLevels: resource.GradeLevels,
+		Lessons:     make([]Lesson, 0, len(lessons)),
+		GeneratedAt: time.Now(),
+	}
+	if resource.Summary != nil {
+		plan.Summary = *resource.Summary
+	}
+
+	for _, l := range lessons {
+		lesson := Lesson{
+			Number:     l.LessonNumber,
+			Title:      l.Title,
+			Objectives: l.Objectives,
+			Materials:  l.Materials,
+		}
+		if l.DurationMinutes != nil {
+			lesson.DurationMinutes = *l.DurationMinutes
+		}
+		if l.Assessment != nil {
+			lesson.Assessment = *l.Assessment
+		}
+		if l.Differentiation != nil {
+			lesson.Differentiation = *l.Differentiation
+		}
+
+		doc, err := data.DecodeLessonDocument([]byte(l.Content))
+		if err != nil {
+			lesson.Text = l.Content
+		} else {
+			for _, block := range doc.Blocks {
+				if block.Visibility == data.VisibilityFellow && !fellowBlocks {
+					continue
+				}
+				lesson.Blocks = append(lesson.Blocks, block)
+			}
+		}
+
+		plan.Lessons = append(plan.Lessons, lesson)
+	}
+
+	return plan
+}
+
+// Render renders the plan in format, one of Formats
+func Render(format string, plan Plan) ([]byte, error) {
+	switch format {
+	case FormatMarkdown:
+		return renderMarkdown(plan, markdownFuncs)
+	case FormatPDF:
+		// The PDF is laid out from the Markdown, rendered without escaping
+		layout, err := renderMarkdown(plan, pdfFuncs)
+		if err != nil {
+			return nil, err
+		}
+		return writePDF(plan.Title, layout)
+	default:
+		return renderHTML(plan)
+	}
+}
+
+func renderHTML(plan Plan) ([]byte, error) {
+	funcs := htmltemplate.FuncMap(commonFuncs())
+	tmpl, err := htmltemplate.New("lesson_plan").Funcs(funcs).ParseFS(templateFS, "templates/lesson_plan.html.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	out := new(bytes.Buffer)
+	if err = tmpl.ExecuteTemplate(out, "plan", plan); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+func renderMarkdown(plan Plan, modeFuncs texttemplate.FuncMap) ([]byte, error) {
+	funcs := texttemplate.FuncMap(commonFuncs())
+	for name, fn := range modeFuncs {
+		funcs[name] = fn
+	}
+
+	tmpl, err := texttemplate.New("lesson_plan").Funcs(funcs).ParseFS(templateFS, "templates/lesson_plan.md.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	out := new(bytes.Buffer)
+	if err = tmpl.ExecuteTemplate(out, "plan", plan); err != nil {
+		return nil, err
+	}
+	// The templates are written for reading, not for tidy blank lines
+	return append(bytes.TrimSpace(blankLines.ReplaceAll(out.Bytes(), []byte("\n\n"))), '\n'), nil
+}
+
+// blockTitles are the headings of blocks that have no title of their own,
+// as the lesson builder labels them
+var blockTitles = map[string]string{
+	data.BlockObjectives:      "Learning Objectives",
+	data.BlockMaterials:       "Materials",
+	data.BlockWarmup:          "Warm-up",
+	data.BlockActivity:        "Activity",
+	data.BlockAssessment:      "Assessment",
+	data.BlockDifferentiation: "Differentiation",
+	data.BlockExtension:       "Extension",
+	data.BlockMedia:           "Media",
+	data.BlockFellowNotes:     "Fellow Notes",
+}
+
+// commonFuncs are the template functions every format shares
+func commonFuncs() map[string]any {
+	return map[string]any{
+		"join": strings.Join,
+		"blockTitle": func(block data.LessonBlock) string {
+			if block.Title != "" {
+				return block.Title
+			}
+			return blockTitles[block.Type]
+		},
+		"minutes": func(minutes any) string {
+			var n int
+			switch m := minutes.(type) {
+			case int:
+				n = m
+			case *int:
+				if m != nil {
+					n = *m
+				}
+			}
+			if n == 1 {
+				return "1 minute"
+			}
+			return strconv.Itoa(n) + " minutes"
+		},
+		"date": func(t time.Time) string {
+			return t.Format("2 January 2006")
+		},
+	}
+}
+
+// markdownFuncs escape text for Markdown.  Line breaks within a value are
+// kept as hard breaks.
+var markdownFuncs = texttemplate.FuncMap{
+	"md": markdownEscape,
+	"link": func(text, url string) string {
+		if text == "" {
+			return "<" + url + ">"
+		}
+		return "[" + markdownEscape(text) + "](" + url + ")"
+	},
+}
+
+// pdfFuncs leave text as it is for the PDF layout, marking the lines after a
+// line break in a value so they are not read as layout
+var pdfFuncs = texttemplate.FuncMap{
+	"md": func(text string) string {
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+		return strings.ReplaceAll(text, "\n", "\n"+pdfContinuation)
+	},
+	"link": func(text, url string) string {
+		if text == "" {
+			return url
+		}
+		return text + " (" + url + ")"
+	},
+}
+
+var (
+	markdownSpecial = strings.NewReplacer(
+		`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`,
+		`<`, `\<`, `>`, `\>`, `#`, `\#`, `|`, `\|`,
+	)
+	markdownListStart = regexp.MustCompile(`^(\s*)([-+]|\d+\.)(\s)`)
+)
+
+func markdownEscape(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(markdownSpecial.Replace(text), "\n")
+	for i, line := range lines {
+		// A line that would start a list is escaped as text
+		lines[i] = markdownListStart.ReplaceAllStringFunc(line, func(marker string) string {
+			m := markdownListStart.FindStringSubmatch(marker)
+			if m[2] == "-" || m[2] == "+" {
+				return m[1] + `\` + m[2] + m[3]
+			}
+			return m[1] + strings.TrimSuffix(m[2], ".") + `\.` + m[3]
+		})
+	}
+	return strings.Join(lines, "  \n")
+}
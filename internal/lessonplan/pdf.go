@@ -0,0 +1,343 @@
+// filename: internal/lessonplan/pdf.go
+
+package lessonplan
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The PDF is written directly, with nothing outside the standard library.
+// It uses the Helvetica fonts every PDF reader has built in, so no font is
+// embedded, and lays the page out from the lesson plan's Markdown: headings,
+// bulleted and numbered items, and paragraphs.
+
+// pdfContinuation starts a line that continues the previous heading, item or
+// paragraph, after a line break in the text itself
+const pdfContinuation = "\x00"
+
+// US Letter, with 3/4 inch margins
+const (
+	pageWidth    = 612.0
+	pageHeight   = 792.0
+	pageMargin   = 54.0
+	footerHeight = 24.0
+	textWidth    = pageWidth - 2*pageMargin
+)
+
+const (
+	fontRegular = iota
+	fontBold
+)
+
+// pdfStyle is how a kind of line is set
+type pdfStyle struct {
+	font        int
+	size        float64
+	spaceBefore float64
+}
+
+var (
+	styleTitle     = pdfStyle{fontBold, 20, 0}
+	styleHeading   = pdfStyle{fontBold, 15, 18}
+	styleSubhead   = pdfStyle{fontBold, 12, 12}
+	styleParagraph = pdfStyle{fontRegular, 11, 6}
+)
+
+// pdfLine is one line of text placed on a page
+type pdfLine struct {
+	font int
+	size float64
+	x, y float64
+	text string // WinAnsi encoded
+}
+
+// pdfLayout flows lines onto pages
+type pdfLayout struct {
+	pages [][]pdfLine
+	y     float64
+	gap   float64 // space still owed before the next line
+}
+
+func (l *pdfLayout) newPage() {
+	l.pages = append(l.pages, nil)
+	l.y = pageHeight - pageMargin
+	l.gap = 0
+}
+
+// place puts the text of one item on the page, wrapped to width.  marker,
+// if given, is set at x in front of the first line and the text indented
+// by indent.
+func (l *pdfLayout) place(style pdfStyle, x, indent float64, marker, text string, spaceBefore float64) {
+	leading := style.size * 1.3
+	lines := wrapText(text, style.font, style.size, textWidth-x-indent)
+
+	for i, line := range lines {
+		space := 0.0
+		if i == 0 {
+			space = max(l.gap, spaceBefore)
+		}
+		// A heading is not left alone at the foot of a page
+		need := leading
+		if style.font == fontBold && i == len(lines)-1 {
+			need += 3 * styleParagraph.size * 1.3
+		}
+		if len(l.pages) == 0 || l.y-space-need < pageMargin+footerHeight {
+			l.newPage()
+			space = 0
+		}
+
+		l.y -= space + style.size
+		page := len(l.pages) - 1
+		if i == 0 && marker != "" {
+			l.pages[page] = append(l.pages[page], pdfLine{style.font, style.size, pageMargin + x, l.y, marker})
+		}
+		l.pages[page] = append(l.pages[page], pdfLine{style.font, style.size, pageMargin + x + indent, l.y, line})
+		l.y -= leading - style.size
+		l.gap = 0
+	}
+}
+
+var (
+	numberedItem = regexp.MustCompile(`^(\d+)\. (.*)$`)
+	boldMarks    = strings.NewReplacer("**", "")
+)
+
+// layoutMarkdown lays out the Markdown the lesson plan template writes
+func layoutMarkdown(markdown []byte) [][]pdfLine {
+	var layout pdfLayout
+
+	// what a continuation line continues
+	style, x, indent := styleParagraph, 0.0, 0.0
+
+	for _, line := range strings.Split(string(markdown), "\n") {
+		if strings.HasPrefix(line, pdfContinuation) {
+			text := winAnsi(boldMarks.Replace(strings.TrimPrefix(line, pdfContinuation)))
+			layout.place(style, x, indent, "", text, 0)
+			continue
+		}
+
+		line = strings.TrimRight(line, " ")
+		if line == "" {
+			layout.gap = styleParagraph.spaceBefore
+			continue
+		}
+		text := boldMarks.Replace(line)
+
+		switch {
+		case strings.HasPrefix(line, "# "):
+			style, x, indent = styleTitle, 0, 0
+			text = strings.TrimPrefix(text, "# ")
+		case strings.HasPrefix(line, "## "):
+			style, x, indent = styleHeading, 0, 0
+			text = strings.TrimPrefix(text, "## ")
+		case strings.HasPrefix(line, "### "):
+			style, x, indent = styleSubhead, 0, 0
+			text = strings.TrimPrefix(text, "### ")
+		case strings.HasPrefix(line, "- "):
+			style, x, indent = styleParagraph, 10, 12
+			layout.place(style, x, indent, "\x95", winAnsi(strings.TrimPrefix(text, "- ")), 2)
+			continue
+		case numberedItem.MatchString(line):
+			m := numberedItem.FindStringSubmatch(text)
+			style, x, indent = styleParagraph, 10, 18
+			layout.place(style, x, indent, m[1]+".", winAnsi(m[2]), 2)
+			continue
+		default:
+			style, x, indent = styleParagraph, 0, 0
+			layout.place(style, x, indent, "", winAnsi(text), 0)
+			continue
+		}
+
+		layout.place(style, x, indent, "", winAnsi(text), style.spaceBefore)
+	}
+
+	if len(layout.pages) == 0 {
+		layout.newPage()
+	}
+	return layout.pages
+}
+
+// writePDF lays out the Markdown and writes it as a PDF document
+func writePDF(title string, markdown []byte) ([]byte, error) {
+	pages := layoutMarkdown(markdown)
+
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1 to 4 are the catalog, page tree, fonts and document info;
+	// each page is then a page object followed by its content stream.
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>" +
+		" /F2 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >> >>")
+	object(fmt.Sprintf("<< /Title %s /Producer (501 STEAM Hub) >>", pdfString(winAnsi(title))))
+
+	for i, lines := range pages {
+		var content bytes.Buffer
+		for _, line := range lines {
+			fmt.Fprintf(&content, "BT /F%d %.1f Tf %.2f %.2f Td %s Tj ET\n",
+				line.font+1, line.size, line.x, line.y, pdfString(line.text))
+		}
+		footer := fmt.Sprintf("Page %d of %d", i+1, len(pages))
+		fmt.Fprintf(&content, "BT /F1 9.0 Tf %.2f %.2f Td %s Tj ET\n",
+			pageWidth-pageMargin-textWidthOf(footer, fontRegular, 9), pageMargin, pdfString(footer))
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(content.Bytes()); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font 3 0 R >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes(), nil
+}
+
+// pdfString quotes WinAnsi text as a PDF literal string
+func pdfString(text string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// winAnsiExtra maps the characters of WinAnsiEncoding above Latin-1's
+// control range that are likely in lesson text
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// winAnsi encodes text for the built-in fonts.  Latin-1 characters are kept
+// and anything the fonts cannot show becomes '?'.
+func winAnsi(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\t':
+			b.WriteByte(' ')
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		case winAnsiExtra[r] != 0:
+			b.WriteByte(winAnsiExtra[r])
+		case r < 0x20 || r == 0x7f:
+			// control characters are dropped
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// wrapText breaks WinAnsi text into lines no wider than width, at spaces
+// where it can and within a word that is wider than a line on its own
+func wrapText(text string, font int, size, width float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if textWidthOf(candidate, font, size) <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		for textWidthOf(word, font, size) > width {
+			n := 1
+			for n < len(word) && textWidthOf(word[:n+1], font, size) <= width {
+				n++
+			}
+			lines = append(lines, word[:n])
+			word = word[n:]
+		}
+		line = word
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// textWidthOf measures WinAnsi text in points
+func textWidthOf(text string, font int, size float64) float64 {
+	widths := &helveticaWidths
+	if font == fontBold {
+		widths = &helveticaBoldWidths
+	}
+
+	units := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c >= 32 && c <= 126:
+			units += int(widths[c-32])
+		case c == 0x95:
+			units += 350
+		case c == 0x97 || c == 0x85:
+			units += 1000
+		default:
+			units += 556
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+// Glyph widths of the printable ASCII characters, space to tilde, in
+// thousandths of the font size, from the fonts' Adobe metrics
+var helveticaWidths = [95]uint16{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]uint16{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
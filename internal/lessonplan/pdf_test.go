@@ -0,0 +1,294 @@
+package lessonplan
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWrapText(t *testing.T) {
+	// At 10pt Helvetica "a" is 5.56pt wide, a space 2.78pt, "i" 2.22pt and "m" 8.33pt
+	tests := []struct {
+		name  string
+		text  string
+		width float64
+		want  []string
+	}{
+		{name: "empty", text: "", width: 100, want: []string{""}},
+		{name: "only spaces", text: "   ", width: 100, want: []string{""}},
+		{name: "fits", text: "aa aa aa", width: 100, want: []string{"aa aa aa"}},
+		{name: "collapses spaces", text: "  aa \t aa  ", width: 100, want: []string{"aa aa"}},
+		{name: "one word a line", text: "aa aa aa", width: 12, want: []string{"aa", "aa", "aa"}},
+		{name: "two words a line", text: "aa aa aa", width: 26, want: []string{"aa aa", "aa"}},
+		{name: "exact fit", text: "aa aa", width: 25.02, want: []string{"aa aa"}},
+		{name: "long word", text: "aaaaa", width: 12, want: []string{"aa", "aa", "a"}},
+		{name: "long word after a short one", text: "i aaaaa", width: 12, want: []string{"i", "aa", "aa", "a"}},
+		{name: "long word then more", text: "aaaaa i", width: 12, want: []string{"aa", "aa", "a i"}},
+		{name: "narrower than a letter", text: "mm", width: 5, want: []string{"m", "m"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrapText(tt.text, fontRegular, 10, tt.width)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wrapText(%q, %v) = %q, want %q", tt.text, tt.width, got, tt.want)
+			}
+		})
+	}
+}
+
+// Wrapped lines fit the width and keep every word, in order
+func TestWrapTextFits(t *testing.T) {
+	text := winAnsi("Students measure the classroom with rulers, record each length in a table " +
+		"and compare their results with a partner before presenting “findings” to the class.")
+
+	for _, font := range []int{fontRegular, fontBold} {
+		for _, width := range []float64{60, 150, 300, textWidth} {
+			lines := wrapText(text, font, 11, width)
+			for _, line := range lines {
+				if w := textWidthOf(line, font, 11); w > width {
+					t.Errorf("font %d width %v: line %q is %vpt wide", font, width, line, w)
+				}
+			}
+			if got := strings.Join(lines, " "); got != strings.Join(strings.Fields(text), " ") {
+				t.Errorf("font %d width %v: lines %q do not join back to the text", font, width, lines)
+			}
+		}
+	}
+}
+
+func TestTextWidthOf(t *testing.T) {
+	tests := []struct {
+		text string
+		font int
+		size float64
+		want float64
+	}{
+		{"", fontRegular, 10, 0},
+		{"Hello", fontRegular, 10, 22.78},
+		{"Hello", fontBold, 10, 24.45},
+		{"Hello", fontRegular, 20, 45.56},
+		{"\x95", fontRegular, 10, 3.5},
+		{"\x97", fontRegular, 10, 10},
+		{"\xe9", fontRegular, 10, 5.56},
+	}
+
+	for _, tt := range tests {
+		got := textWidthOf(tt.text, tt.font, tt.size)
+		if diff := got - tt.want; diff > 0.001 || diff < -0.001 {
+			t.Errorf("textWidthOf(%q, %d, %v) = %v, want %v", tt.text, tt.font, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestWinAnsi(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Plain ASCII", "Plain ASCII"},
+		{"café", "caf\xe9"},
+		{"“quoted” – done…", "\x93quoted\x94 \x96 done\x85"},
+		{"tab\there", "tab here"},
+		{"bell\x07", "bell"},
+		{"€5 • 🚀", "\x805 \x95 ?"},
+		{"日本", "??"},
+	}
+
+	for _, tt := range tests {
+		if got := winAnsi(tt.text); got != tt.want {
+			t.Errorf("winAnsi(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPDFString(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Fractions", "(Fractions)"},
+		{"(a) and (b)", `(\(a\) and \(b\))`},
+		{`C:\lessons`, `(C:\\lessons)`},
+		{"", "()"},
+	}
+
+	for _, tt := range tests {
+		if got := pdfString(tt.text); got != tt.want {
+			t.Errorf("pdfString(%q) = %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	long := new(strings.Builder)
+	long.WriteString("# A Long Plan\n\n## Lesson 1\n\n")
+	for i := 1; i <= 120; i++ {
+		fmt.Fprintf(long, "%d. Step %d of the activity, which has (parentheses) and a backslash \\ in it\n", i, i)
+	}
+
+	tests := []struct {
+		name     string
+		title    string
+		markdown string
+		pages    int
+	}{
+		{name: "empty", title: "Nothing", markdown: "", pages: 1},
+		{name: "short", title: "Fractions (Grade 4)",
+			markdown: "# Fractions\n\n## Lesson 1: Halves\n\n- Paper plates\n- Crayons\n\nCut the plate in half.\n", pages: 1},
+		{name: "several pages", title: "A Long Plan", markdown: long.String(), pages: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdf, err := writePDF(tt.title, []byte(tt.markdown))
+			if err != nil {
+				t.Fatal(err)
+			}
+			objects := checkPDFStructure(t, pdf)
+
+			// 4 fixed objects, then a page and its content stream per page
+			if got := (len(objects) - 4) / 2; got != tt.pages {
+				t.Errorf("%d pages, want %d", got, tt.pages)
+			}
+			if !bytes.Contains(objects[2], []byte(fmt.Sprintf("/Count %d", tt.pages))) {
+				t.Errorf("page tree %q does not count %d pages", objects[2], tt.pages)
+			}
+			if !bytes.Contains(objects[4], []byte(pdfString(winAnsi(tt.title)))) {
+				t.Errorf("document info %q does not hold the title", objects[4])
+			}
+
+			for page := 1; page <= tt.pages; page++ {
+				content := pdfStream(t, objects[4+2*page])
+				footer := pdfString(fmt.Sprintf("Page %d of %d", page, tt.pages))
+				if !strings.Contains(content, footer) {
+					t.Errorf("page %d has no footer %s", page, footer)
+				}
+			}
+		})
+	}
+}
+
+var (
+	pdfStartXref = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`)
+	pdfXrefEntry = regexp.MustCompile(`^(\d{10}) (\d{5}) ([nf]) $`)
+	pdfSize      = regexp.MustCompile(`/Size (\d+)`)
+)
+
+// checkPDFStructure checks that the cross-reference table points at each
+// object and returns the objects' bodies, indexed by object number
+func checkPDFStructure(t *testing.T, pdf []byte) map[int][]byte {
+	t.Helper()
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) {
+		t.Fatalf("missing PDF header: %q", pdf[:min(len(pdf), 16)])
+	}
+
+	m := pdfStartXref.FindSubmatch(pdf)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+
+	lines := strings.Split(string(pdf[xref:]), "\n")
+	var first, count int
+	if _, err := fmt.Sscanf(lines[1], "%d %d", &first, &count); err != nil || first != 0 {
+		t.Fatalf("bad xref subsection header %q", lines[1])
+	}
+
+	size := pdfSize.FindSubmatch(pdf[xref:])
+	if size == nil || string(size[1]) != strconv.Itoa(count) {
+		t.Errorf("trailer /Size %q does not match the %d xref entries", size, count)
+	}
+
+	objects := make(map[int][]byte, count)
+	for n := 0; n < count; n++ {
+		entry := pdfXrefEntry.FindStringSubmatch(lines[2+n])
+		if entry == nil {
+			t.Fatalf("xref entry %d is malformed: %q", n, lines[2+n])
+		}
+		if n == 0 {
+			if entry[3] != "f" || entry[2] != "65535" {
+				t.Errorf("xref entry 0 = %q, want the free list head", lines[2])
+			}
+			continue
+		}
+
+		offset, _ := strconv.Atoi(entry[1])
+		header := fmt.Sprintf("%d 0 obj\n", n)
+		if !bytes.HasPrefix(pdf[offset:], []byte(header)) {
+			t.Fatalf("xref offset %d of object %d points at %q", offset, n, pdf[offset:min(len(pdf), offset+20)])
+		}
+		body := pdf[offset+len(header):]
+		end := bytes.Index(body, []byte("\nendobj\n"))
+		if end < 0 {
+			t.Fatalf("object %d has no endobj", n)
+		}
+		objects[n] = body[:end]
+	}
+
+	return objects
+}
+
+var pdfStreamLength = regexp.MustCompile(`^<< /Length (\d+) /Filter /FlateDecode >>\nstream\n`)
+
+// pdfStream checks a content stream's length and returns it decompressed
+func pdfStream(t *testing.T, object []byte) string {
+	t.Helper()
+
+	m := pdfStreamLength.FindSubmatch(object)
+	if m == nil {
+		t.Fatalf("not a content stream: %q", object[:min(len(object), 60)])
+	}
+	length, _ := strconv.Atoi(string(m[1]))
+	data := object[len(m[0]):]
+	if !bytes.HasSuffix(data, []byte("\nendstream")) || len(data)-len("\nendstream") != length {
+		t.Fatalf("stream is %d bytes, /Length says %d", len(data)-len("\nendstream"), length)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data[:length]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// Every line is set inside the margins, above the footer
+func TestLayoutMarkdownMargins(t *testing.T) {
+	markdown := "# " + strings.Repeat("Title ", 30) + "\n\n" +
+		strings.Repeat("- "+strings.Repeat("word ", 60)+"\n", 20) +
+		"1. " + strings.Repeat("x", 400) + "\n" + pdfContinuation + "continued\n"
+
+	pages := layoutMarkdown([]byte(markdown))
+	if len(pages) < 2 {
+		t.Fatalf("%d pages, want the text to run over several", len(pages))
+	}
+
+	for i, page := range pages {
+		if len(page) == 0 {
+			t.Errorf("page %d is empty", i+1)
+		}
+		for _, line := range page {
+			right := line.x + textWidthOf(line.text, line.font, line.size)
+			if line.x < pageMargin || right > pageWidth-pageMargin+0.001 {
+				t.Errorf("page %d: %q runs from %v to %v, outside the margins", i+1, line.text, line.x, right)
+			}
+			if line.y < pageMargin+footerHeight || line.y > pageHeight-pageMargin {
+				t.Errorf("page %d: %q is set at y %v, outside the text area", i+1, line.text, line.y)
+			}
+		}
+	}
+}
@@ -169,7 +169,7 @@ func (u *YouTubeUploader) UploadResourceToYouTube(resource *data.Resource) {
 		var madeForKids bool
 
 		// Apply VideoMetadata overrides when available.
-		vm, vmErr := u.Models.VideoMetadata.GetByResource(resourceID)
+		vm, vmErr := u.Models.VideoMetadata.GetByResource(ctx, resourceID)
 		if vmErr == nil {
 			if vm.YouTubeTitle != "" {
 				videoTitle = vm.YouTubeTitle
@@ -227,7 +227,7 @@ func (u *YouTubeUploader) UploadResourceToYouTube(resource *data.Resource) {
 		youtubeURL := "https://www.youtube.com/watch?v=" + uploaded.Id
 
 		// Re-fetch the resource to avoid overwriting concurrent edits.
-		dbResource, err := u.Models.Resources.Get(resourceID)
+		dbResource, err := u.Models.Resources.Get(ctx, resourceID)
 		if err != nil {
 			u.Logger.Error("youtube upload: failed to re-fetch resource for update",
 				"resource_id", resourceID, "error", err)
@@ -237,7 +237,9 @@ func (u *YouTubeUploader) UploadResourceToYouTube(resource *data.Resource) {
 		dbResource.PublishedURL = &youtubeURL
 		dbResource.Status = "Published"
 
-		if err := u.Models.Resources.Update(dbResource); err != nil {
+		// The uploader acts as the system, not as the user who approved it
+		err = u.Models.Resources.Update(ctx, dbResource, 0, "Published to YouTube")
+		if err != nil {
 			u.Logger.Error("youtube upload: failed to update resource after upload",
 				"resource_id", resourceID, "youtube_url", youtubeURL, "error", err)
 			return
@@ -0,0 +1,139 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 test key of RFC 6238 appendix B, "12345678901234567890",
+// base32 encoded
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC 6238 SHA-1 test vectors.  The RFC gives 8 digit codes; a 6 digit
+// code is the same value modulo 10^6, so it is the last 6 digits.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCode(t *testing.T) {
+	for _, tt := range rfcVectors {
+		step := Step(time.Unix(tt.unix, 0))
+		got, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatalf("Code at %d: %v", tt.unix, err)
+		}
+		if got != tt.code {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestCodeLowercaseSecret(t *testing.T) {
+	got, err := Code(strings.ToLower(rfcSecret), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "287082" {
+		t.Errorf("Code = %s, want 287082", got)
+	}
+}
+
+func TestCodeBadSecret(t *testing.T) {
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("expected an error for a secret that is not base32")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0) // step 37037037, code 050471
+	current := Step(now)
+
+	previous, _ := Code(rfcSecret, current-1)
+	next, _ := Code(rfcSecret, current+1)
+	tooOld, _ := Code(rfcSecret, current-2)
+	tooNew, _ := Code(rfcSecret, current+2)
+
+	tests := []struct {
+		name string
+		code string
+		step int64
+		ok   bool
+	}{
+		{name: "current step", code: "050471", step: current, ok: true},
+		{name: "with spaces", code: "050 471", step: current, ok: true},
+		{name: "previous step", code: previous, step: current - 1, ok: true},
+		{name: "next step", code: next, step: current + 1, ok: true},
+		{name: "two steps behind", code: tooOld, ok: false},
+		{name: "two steps ahead", code: tooNew, ok: false},
+		{name: "wrong code", code: "123456", ok: false},
+		{name: "too short", code: "50471", ok: false},
+		{name: "eight digits", code: "07081804", ok: false},
+		{name: "empty", code: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, now)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && step != tt.step {
+				t.Errorf("step = %d, want %d", step, tt.step)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret is %d bytes, want 20", len(key))
+	}
+
+	other, _ := GenerateSecret()
+	if other == secret {
+		t.Error("two generated secrets are the same")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("501 STEAM Hub", "fellow@example.com", rfcSecret)
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("URI %q is not an otpauth://totp URI", uri)
+	}
+	if want := "/501 STEAM Hub:fellow@example.com"; u.Path != want {
+		t.Errorf("label = %q, want %q", u.Path, want)
+	}
+
+	q := u.Query()
+	want := map[string]string{
+		"secret": rfcSecret, "issuer": "501 STEAM Hub", "algorithm": "SHA1", "digits": "6", "period": "30",
+	}
+	for key, value := range want {
+		if q.Get(key) != value {
+			t.Errorf("%s = %q, want %q", key, q.Get(key), value)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+-- DOWN: Drop the permission matrix
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
@@ -0,0 +1,90 @@
+-- UP: Permissions and the role → permission matrix
+CREATE TABLE IF NOT EXISTS permissions (
+    permission_id SERIAL PRIMARY KEY,
+    code          VARCHAR(100) UNIQUE NOT NULL,
+    description   TEXT
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id       INT NOT NULL,
+    permission_id INT NOT NULL,
+    PRIMARY KEY (role_id, permission_id),
+    CONSTRAINT fk_role_permissions_role
+        FOREIGN KEY (role_id) REFERENCES roles(role_id) ON DELETE CASCADE,
+    CONSTRAINT fk_role_permissions_permission
+        FOREIGN KEY (permission_id) REFERENCES permissions(permission_id) ON DELETE CASCADE
+);
+
+INSERT INTO permissions (code, description) VALUES
+    ('user:read',                 'List and view any user account'),
+    ('user:manage',               'Create users and change roles or activation status'),
+    ('user:delete',               'Delete user accounts'),
+    ('role:manage',               'Create, edit and delete roles and their permissions'),
+    ('token:manage',              'Revoke tokens belonging to other users'),
+    ('fellow:delete',             'Delete Fellow profiles'),
+    ('fellow_application:review', 'Approve or reject Fellow applications'),
+    ('resource:create',           'Submit new resources'),
+    ('resource:delete',           'Delete resources'),
+    ('resource:status_override',  'Set a resource status through the admin override'),
+    ('resource:metrics',          'View per-status resource counts'),
+    ('lesson:delete',             'Delete lessons'),
+    ('review:create',             'Record review decisions and inline review comments'),
+    ('review:delete',             'Delete review decisions'),
+    ('resource_access:read',      'View resource access logs'),
+    ('resource_access:delete',    'Delete resource access records'),
+    ('contribution:manage',       'Create and update contribution scores'),
+    ('contribution:delete',       'Delete contribution scores'),
+    ('notification:create',       'Send notifications to users'),
+    ('admin:metrics',             'View platform-wide metrics')
+ON CONFLICT (code) DO NOTHING;
+
+-- Default matrix for the seeded roles (see 020_seed_roles)
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM (VALUES
+    ('admin',         'user:read'),
+    ('admin',         'user:manage'),
+    ('admin',         'user:delete'),
+    ('admin',         'role:manage'),
+    ('admin',         'token:manage'),
+    ('admin',         'fellow:delete'),
+    ('admin',         'fellow_application:review'),
+    ('admin',         'resource:create'),
+    ('admin',         'resource:delete'),
+    ('admin',         'resource:status_override'),
+    ('admin',         'resource:metrics'),
+    ('admin',         'lesson:delete'),
+    ('admin',         'review:create'),
+    ('admin',         'review:delete'),
+    ('admin',         'resource_access:read'),
+    ('admin',         'resource_access:delete'),
+    ('admin',         'contribution:manage'),
+    ('admin',         'contribution:delete'),
+    ('admin',         'notification:create'),
+    ('admin',         'admin:metrics'),
+    ('DSC',           'user:read'),
+    ('DSC',           'user:manage'),
+    ('DSC',           'fellow:delete'),
+    ('DSC',           'fellow_application:review'),
+    ('DSC',           'resource:create'),
+    ('DSC',           'resource:delete'),
+    ('DSC',           'resource:status_override'),
+    ('DSC',           'resource:metrics'),
+    ('DSC',           'lesson:delete'),
+    ('DSC',           'review:create'),
+    ('DSC',           'resource_access:read'),
+    ('DSC',           'contribution:manage'),
+    ('DSC',           'notification:create'),
+    ('DSC',           'admin:metrics'),
+    ('TeamLead',      'user:read'),
+    ('TeamLead',      'fellow:delete'),
+    ('TeamLead',      'resource:metrics'),
+    ('TeamLead',      'review:create'),
+    ('SubjectExpert', 'resource:metrics'),
+    ('SubjectExpert', 'review:create'),
+    ('Fellow',        'resource:create'),
+    ('Secretary',     'notification:create')
+) AS m(role_name, code)
+JOIN roles r ON r.name = m.role_name
+JOIN permissions p ON p.code = m.code
+ON CONFLICT DO NOTHING;
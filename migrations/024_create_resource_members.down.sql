@@ -0,0 +1,4 @@
+-- DOWN: Drop resource co-authors and reviewer assignments
+DELETE FROM permissions WHERE code IN ('resource:edit_any', 'review:assign', 'comment:moderate');
+DROP TABLE IF EXISTS resource_reviewer_assignments;
+DROP TABLE IF EXISTS resource_coauthors;
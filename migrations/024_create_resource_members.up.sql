@@ -0,0 +1,50 @@
+-- UP: Resource co-authors and reviewer assignments (used by the authorization policies)
+CREATE TABLE IF NOT EXISTS resource_coauthors (
+    resource_id INT NOT NULL,
+    user_id     INT NOT NULL,
+    added_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (resource_id, user_id),
+    CONSTRAINT fk_resource_coauthors_resource
+        FOREIGN KEY (resource_id) REFERENCES resources(resource_id) ON DELETE CASCADE,
+    CONSTRAINT fk_resource_coauthors_user
+        FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_resource_coauthors_user ON resource_coauthors (user_id);
+
+CREATE TABLE IF NOT EXISTS resource_reviewer_assignments (
+    resource_id INT NOT NULL,
+    reviewer_id INT NOT NULL,
+    assigned_by INT,
+    assigned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (resource_id, reviewer_id),
+    CONSTRAINT fk_rra_resource
+        FOREIGN KEY (resource_id) REFERENCES resources(resource_id) ON DELETE CASCADE,
+    CONSTRAINT fk_rra_reviewer
+        FOREIGN KEY (reviewer_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    CONSTRAINT fk_rra_assigned_by
+        FOREIGN KEY (assigned_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_resource_reviewer_assignments_reviewer ON resource_reviewer_assignments (reviewer_id);
+
+INSERT INTO permissions (code, description) VALUES
+    ('resource:edit_any', 'Edit any resource, lesson or review comment regardless of ownership'),
+    ('review:assign',     'Assign reviewers to resources'),
+    ('comment:moderate',  'Edit or delete other users'' comments')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM (VALUES
+    ('admin',    'resource:edit_any'),
+    ('admin',    'review:assign'),
+    ('admin',    'comment:moderate'),
+    ('DSC',      'resource:edit_any'),
+    ('DSC',      'review:assign'),
+    ('DSC',      'comment:moderate'),
+    ('TeamLead', 'review:assign')
+) AS m(role_name, code)
+JOIN roles r ON r.name = m.role_name
+JOIN permissions p ON p.code = m.code
+ON CONFLICT DO NOTHING;
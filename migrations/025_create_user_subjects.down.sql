@@ -0,0 +1,3 @@
+-- DOWN: Drop reviewer subject expertise
+DELETE FROM permissions WHERE code IN ('expertise:manage', 'review:any_subject');
+DROP TABLE IF EXISTS user_subjects;
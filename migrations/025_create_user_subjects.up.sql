@@ -0,0 +1,30 @@
+-- UP: Subject expertise for reviewers
+CREATE TABLE IF NOT EXISTS user_subjects (
+    user_id INT NOT NULL,
+    subject VARCHAR(150) NOT NULL,
+    PRIMARY KEY (user_id, subject),
+    CONSTRAINT fk_user_subjects_user
+        FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    CONSTRAINT fk_user_subjects_subject
+        FOREIGN KEY (subject) REFERENCES subjects(subject) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_subjects_subject ON user_subjects (subject);
+
+INSERT INTO permissions (code, description) VALUES
+    ('expertise:manage',   'Assign subject expertise to users'),
+    ('review:any_subject', 'Review resources outside of your own subject expertise')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM (VALUES
+    ('admin',    'expertise:manage'),
+    ('admin',    'review:any_subject'),
+    ('DSC',      'expertise:manage'),
+    ('DSC',      'review:any_subject'),
+    ('TeamLead', 'review:any_subject')
+) AS m(role_name, code)
+JOIN roles r ON r.name = m.role_name
+JOIN permissions p ON p.code = m.code
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,6 @@
+-- DOWN: Remove token families
+DROP INDEX IF EXISTS idx_auth_tokens_family_id;
+
+ALTER TABLE auth_tokens
+    DROP COLUMN IF EXISTS used_at,
+    DROP COLUMN IF EXISTS family_id;
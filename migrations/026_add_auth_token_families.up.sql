@@ -0,0 +1,9 @@
+-- UP: Token families for rotating refresh tokens
+-- Every access/refresh pair issued from one sign-in shares a family_id.
+-- used_at marks a refresh token that has been rotated; presenting it again
+-- revokes the whole family.
+ALTER TABLE auth_tokens
+    ADD COLUMN IF NOT EXISTS family_id VARCHAR(32),
+    ADD COLUMN IF NOT EXISTS used_at   TIMESTAMP(0) WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_auth_tokens_family_id ON auth_tokens (family_id);
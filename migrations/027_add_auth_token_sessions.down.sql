@@ -0,0 +1,5 @@
+-- DOWN: Remove session details from auth tokens
+ALTER TABLE auth_tokens
+    DROP COLUMN IF EXISTS last_used_at,
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS ip_address;
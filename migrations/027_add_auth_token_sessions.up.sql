@@ -0,0 +1,12 @@
+-- UP: Session details on auth tokens
+-- A session is a token family (one sign-in); these columns let users see
+-- where they are signed in and when each session was last used.
+ALTER TABLE auth_tokens
+    ADD COLUMN IF NOT EXISTS ip_address   VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS user_agent   TEXT,
+    ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP(0) WITH TIME ZONE;
+
+-- Tokens issued before token families existed each become their own session
+UPDATE auth_tokens
+SET family_id = md5(token_id::text || random()::text)
+WHERE family_id IS NULL AND scope = 'authentication';
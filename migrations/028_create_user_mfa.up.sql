@@ -0,0 +1,26 @@
+-- UP: TOTP two-factor authentication
+-- Roles with mfa_required must complete MFA enrollment before they can use
+-- any permission-protected route.
+ALTER TABLE roles
+    ADD COLUMN IF NOT EXISTS mfa_required BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE roles SET mfa_required = TRUE WHERE name IN ('admin', 'DSC');
+
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id        INT PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    totp_secret    VARCHAR(64) NOT NULL,
+    enabled        BOOLEAN NOT NULL DEFAULT FALSE,
+    -- last accepted TOTP time step, so a code cannot be replayed
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at     TIMESTAMP(0) WITH TIME ZONE DEFAULT NOW(),
+    enabled_at     TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    code_id   SERIAL PRIMARY KEY,
+    user_id   INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    code_hash BYTEA NOT NULL,
+    used_at   TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes (user_id);
@@ -0,0 +1,2 @@
+-- DOWN: Remove failed sign-in tracking
+DROP TABLE IF EXISTS login_throttles;
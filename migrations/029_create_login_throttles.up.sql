@@ -0,0 +1,12 @@
+-- UP: Failed sign-in tracking
+-- One row per account (lowercased email) and per client IP.  Failures older
+-- than the policy window are forgotten; blocked_until holds the current
+-- backoff or lockout.
+CREATE TABLE IF NOT EXISTS login_throttles (
+    scope          VARCHAR(10) NOT NULL,
+    subject        TEXT NOT NULL,
+    failed_count   INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    blocked_until  TIMESTAMP(0) WITH TIME ZONE,
+    PRIMARY KEY (scope, subject)
+);
@@ -0,0 +1,3 @@
+-- DOWN: Drop the audit log
+DELETE FROM permissions WHERE code = 'audit:read';
+DROP TABLE IF EXISTS audit_events;
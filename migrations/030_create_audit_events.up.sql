@@ -0,0 +1,33 @@
+-- UP: Audit log of privileged actions
+-- actor_id is kept (as NULL) if the acting user is later deleted so the
+-- history survives.
+CREATE TABLE IF NOT EXISTS audit_events (
+    audit_id     BIGSERIAL PRIMARY KEY,
+    actor_id     INT REFERENCES users(user_id) ON DELETE SET NULL,
+    action       VARCHAR(50) NOT NULL,
+    target_type  VARCHAR(50) NOT NULL,
+    target_id    BIGINT NOT NULL,
+    before_state JSONB,
+    after_state  JSONB,
+    ip_address   VARCHAR(45),
+    request_id   VARCHAR(64),
+    created_at   TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX idx_audit_events_target ON audit_events (target_type, target_id);
+
+INSERT INTO permissions (code, description) VALUES
+    ('audit:read', 'View the audit log of privileged actions')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM (VALUES
+    ('admin', 'audit:read'),
+    ('DSC',   'audit:read')
+) AS m(role_name, code)
+JOIN roles r ON r.name = m.role_name
+JOIN permissions p ON p.code = m.code
+ON CONFLICT DO NOTHING;
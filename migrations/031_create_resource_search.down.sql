@@ -0,0 +1,9 @@
+-- DOWN: Remove full-text search over resources
+DROP TRIGGER IF EXISTS lessons_search ON lessons;
+DROP TRIGGER IF EXISTS resource_grade_levels_search ON resource_grade_levels;
+DROP TRIGGER IF EXISTS resource_subjects_search ON resource_subjects;
+DROP TRIGGER IF EXISTS resources_search ON resources;
+DROP FUNCTION IF EXISTS resource_search_trigger();
+DROP FUNCTION IF EXISTS refresh_resource_search(INT);
+DROP FUNCTION IF EXISTS lesson_content_text(TEXT);
+DROP TABLE IF EXISTS resource_search;
@@ -0,0 +1,96 @@
+-- UP: Full-text search over resources
+-- The search document for a resource spans its title, summary, subjects,
+-- grade levels and lessons, so it lives in its own table kept current by
+-- triggers rather than as a generated column (which cannot read other tables).
+-- Keeping it out of resources also means lesson edits don't bump
+-- resources.updated_at.
+CREATE TABLE IF NOT EXISTS resource_search (
+    resource_id INT PRIMARY KEY REFERENCES resources(resource_id) ON DELETE CASCADE,
+    document    TSVECTOR NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_resource_search_document ON resource_search USING GIN (document);
+
+-- Lesson content is stored as JSON blocks by the lesson builder; pull out the
+-- text a teacher would read (skipping block ids and types).  Older lessons
+-- hold plain text, which is returned unchanged.
+CREATE OR REPLACE FUNCTION lesson_content_text(p_content TEXT)
+RETURNS TEXT AS $$
+BEGIN
+    RETURN (
+        WITH RECURSIVE walk(key, value) AS (
+            SELECT NULL::TEXT, p_content::JSONB
+          UNION ALL
+            SELECT e.key, e.value
+            FROM walk w, LATERAL (
+                SELECT o.key, o.value
+                FROM jsonb_each(CASE WHEN jsonb_typeof(w.value) = 'object' THEN w.value END) o
+              UNION ALL
+                SELECT w.key, a.value
+                FROM jsonb_array_elements(CASE WHEN jsonb_typeof(w.value) = 'array' THEN w.value END) a
+            ) e
+        )
+        SELECT string_agg(value #>> '{}', ' ')
+        FROM walk
+        WHERE jsonb_typeof(value) = 'string'
+        AND key IS DISTINCT FROM 'id' AND key IS DISTINCT FROM 'type'
+    );
+EXCEPTION WHEN invalid_text_representation THEN
+    RETURN p_content;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- Title and subjects weigh most, then summary and grade levels, then lessons
+CREATE OR REPLACE FUNCTION refresh_resource_search(p_resource_id INT)
+RETURNS VOID AS $$
+BEGIN
+    INSERT INTO resource_search (resource_id, document)
+    SELECT r.resource_id,
+        setweight(to_tsvector('english', COALESCE(r.title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(rs.subject, ' ') FROM resource_subjects rs
+             WHERE rs.resource_id = r.resource_id), '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(r.summary, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(rgl.grade_level, ' ') FROM resource_grade_levels rgl
+             WHERE rgl.resource_id = r.resource_id), '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(concat_ws(' ', l.title, array_to_string(l.objectives, ' '),
+                                         lesson_content_text(l.content)), ' ')
+             FROM lessons l WHERE l.resource_id = r.resource_id), '')), 'C')
+    FROM resources r
+    WHERE r.resource_id = p_resource_id
+    ON CONFLICT (resource_id) DO UPDATE SET document = EXCLUDED.document;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION resource_search_trigger()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM refresh_resource_search(OLD.resource_id);
+    ELSE
+        PERFORM refresh_resource_search(NEW.resource_id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER resources_search
+AFTER INSERT OR UPDATE OF title, summary ON resources
+FOR EACH ROW EXECUTE FUNCTION resource_search_trigger();
+
+CREATE TRIGGER resource_subjects_search
+AFTER INSERT OR UPDATE OR DELETE ON resource_subjects
+FOR EACH ROW EXECUTE FUNCTION resource_search_trigger();
+
+CREATE TRIGGER resource_grade_levels_search
+AFTER INSERT OR UPDATE OR DELETE ON resource_grade_levels
+FOR EACH ROW EXECUTE FUNCTION resource_search_trigger();
+
+CREATE TRIGGER lessons_search
+AFTER INSERT OR UPDATE OR DELETE ON lessons
+FOR EACH ROW EXECUTE FUNCTION resource_search_trigger();
+
+-- Index the resources that already exist
+SELECT refresh_resource_search(resource_id) FROM resources;
@@ -0,0 +1,8 @@
+-- DOWN: Remove cursor pagination indexes
+DROP INDEX IF EXISTS idx_users_created_at;
+DROP INDEX IF EXISTS idx_notifications_user_sent_at;
+DROP INDEX IF EXISTS idx_resource_comments_resource_time;
+DROP INDEX IF EXISTS idx_resource_access_user_time;
+DROP INDEX IF EXISTS idx_resource_access_resource_time;
+DROP INDEX IF EXISTS idx_resource_access_accessed_at;
+DROP INDEX IF EXISTS idx_resources_created_at;
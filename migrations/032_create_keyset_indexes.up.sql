@@ -0,0 +1,10 @@
+-- UP: Indexes for cursor pagination
+-- Each list seeks on (sort column, id) within its parent, so a deep page is
+-- an index range scan instead of counting through an offset.
+CREATE INDEX IF NOT EXISTS idx_resources_created_at           ON resources (created_at, resource_id);
+CREATE INDEX IF NOT EXISTS idx_resource_access_accessed_at    ON resource_access (accessed_at, access_id);
+CREATE INDEX IF NOT EXISTS idx_resource_access_resource_time  ON resource_access (resource_id, accessed_at, access_id);
+CREATE INDEX IF NOT EXISTS idx_resource_access_user_time      ON resource_access (user_id, accessed_at, access_id);
+CREATE INDEX IF NOT EXISTS idx_resource_comments_resource_time ON resource_comments (resource_id, created_at, comment_id);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_sent_at     ON notifications (user_id, sent_at, notification_id);
+CREATE INDEX IF NOT EXISTS idx_users_created_at               ON users (created_at, user_id);
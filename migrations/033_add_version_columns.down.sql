@@ -0,0 +1,4 @@
+-- DOWN: Remove version numbers
+ALTER TABLE users     DROP COLUMN IF EXISTS version;
+ALTER TABLE lessons   DROP COLUMN IF EXISTS version;
+ALTER TABLE resources DROP COLUMN IF EXISTS version;
@@ -0,0 +1,6 @@
+-- UP: Version numbers for optimistic concurrency control
+-- Each update bumps the version and only applies if the row is still at the
+-- version the client read, so concurrent edits cannot overwrite each other.
+ALTER TABLE resources ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+ALTER TABLE lessons   ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+ALTER TABLE users     ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
@@ -0,0 +1,38 @@
+-- DOWN: Remove soft deletion
+-- Anything still in the trash is deleted for good first.
+DELETE FROM permissions WHERE code = 'trash:manage';
+
+DELETE FROM resource_comments WHERE deleted_at IS NOT NULL;
+DELETE FROM lessons WHERE deleted_at IS NOT NULL;
+DELETE FROM resources WHERE deleted_at IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION refresh_resource_search(p_resource_id INT)
+RETURNS VOID AS $$
+BEGIN
+    INSERT INTO resource_search (resource_id, document)
+    SELECT r.resource_id,
+        setweight(to_tsvector('english', COALESCE(r.title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(rs.subject, ' ') FROM resource_subjects rs
+             WHERE rs.resource_id = r.resource_id), '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(r.summary, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(rgl.grade_level, ' ') FROM resource_grade_levels rgl
+             WHERE rgl.resource_id = r.resource_id), '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(concat_ws(' ', l.title, array_to_string(l.objectives, ' '),
+                                         lesson_content_text(l.content)), ' ')
+             FROM lessons l WHERE l.resource_id = r.resource_id), '')), 'C')
+    FROM resources r
+    WHERE r.resource_id = p_resource_id
+    ON CONFLICT (resource_id) DO UPDATE SET document = EXCLUDED.document;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_resource_comments_deleted_at;
+DROP INDEX IF EXISTS idx_lessons_deleted_at;
+DROP INDEX IF EXISTS idx_resources_deleted_at;
+
+ALTER TABLE resource_comments DROP COLUMN IF EXISTS deleted_by, DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE lessons           DROP COLUMN IF EXISTS deleted_by, DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE resources         DROP COLUMN IF EXISTS deleted_by, DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,56 @@
+-- UP: Soft deletion for resources, lessons and comments
+-- Deleted rows stay in place with deleted_at set until the purge job removes
+-- them, so an admin can restore them from the trash in the meantime.
+ALTER TABLE resources
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS deleted_by INT REFERENCES users(user_id) ON DELETE SET NULL;
+ALTER TABLE lessons
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS deleted_by INT REFERENCES users(user_id) ON DELETE SET NULL;
+ALTER TABLE resource_comments
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS deleted_by INT REFERENCES users(user_id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_resources_deleted_at
+    ON resources (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_lessons_deleted_at
+    ON lessons (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_resource_comments_deleted_at
+    ON resource_comments (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Deleted lessons no longer count towards their resource's search document
+CREATE OR REPLACE FUNCTION refresh_resource_search(p_resource_id INT)
+RETURNS VOID AS $$
+BEGIN
+    INSERT INTO resource_search (resource_id, document)
+    SELECT r.resource_id,
+        setweight(to_tsvector('english', COALESCE(r.title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(rs.subject, ' ') FROM resource_subjects rs
+             WHERE rs.resource_id = r.resource_id), '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(r.summary, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(rgl.grade_level, ' ') FROM resource_grade_levels rgl
+             WHERE rgl.resource_id = r.resource_id), '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(concat_ws(' ', l.title, array_to_string(l.objectives, ' '),
+                                         lesson_content_text(l.content)), ' ')
+             FROM lessons l WHERE l.resource_id = r.resource_id AND l.deleted_at IS NULL), '')), 'C')
+    FROM resources r
+    WHERE r.resource_id = p_resource_id
+    ON CONFLICT (resource_id) DO UPDATE SET document = EXCLUDED.document;
+END;
+$$ LANGUAGE plpgsql;
+
+INSERT INTO permissions (code, description) VALUES
+    ('trash:manage', 'View and restore deleted resources, lessons and comments')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM (VALUES
+    ('admin', 'trash:manage')
+) AS m(role_name, code)
+JOIN roles r ON r.name = m.role_name
+JOIN permissions p ON p.code = m.code
+ON CONFLICT DO NOTHING;
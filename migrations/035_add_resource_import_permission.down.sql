@@ -0,0 +1,2 @@
+-- DOWN: Remove the resource import permission
+DELETE FROM permissions WHERE code = 'resource:import';
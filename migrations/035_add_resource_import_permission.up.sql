@@ -0,0 +1,14 @@
+-- UP: Permission to bulk import resources from CSV or NDJSON
+INSERT INTO permissions (code, description) VALUES
+    ('resource:import', 'Bulk import resources from CSV or NDJSON files')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM (VALUES
+    ('admin', 'resource:import'),
+    ('DSC',   'resource:import')
+) AS m(role_name, code)
+JOIN roles r ON r.name = m.role_name
+JOIN permissions p ON p.code = m.code
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+-- DOWN: Remove the data export permission
+DELETE FROM permissions WHERE code = 'data:export';
@@ -0,0 +1,14 @@
+-- UP: Permission to export resources, users, reviews and access logs
+INSERT INTO permissions (code, description) VALUES
+    ('data:export', 'Export resources, users, reviews and access logs as CSV, JSON or NDJSON')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM (VALUES
+    ('admin', 'data:export'),
+    ('DSC',   'data:export')
+) AS m(role_name, code)
+JOIN roles r ON r.name = m.role_name
+JOIN permissions p ON p.code = m.code
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+-- DOWN: Drop resource revision history
+DROP TABLE IF EXISTS resource_revisions;
@@ -0,0 +1,34 @@
+-- UP: Snapshots of a resource's metadata, subjects and grade levels, one per
+-- write, so edits can be compared and reverted.  changed_by is kept (as NULL)
+-- if the editing user is later deleted.
+CREATE TABLE IF NOT EXISTS resource_revisions (
+    revision_id        BIGSERIAL PRIMARY KEY,
+    resource_id        INT NOT NULL REFERENCES resources(resource_id) ON DELETE CASCADE,
+    revision_number    INT NOT NULL,
+    snapshot           JSONB NOT NULL,
+    change_description TEXT,
+    changed_by         INT REFERENCES users(user_id) ON DELETE SET NULL,
+    created_at         TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_resource_revisions UNIQUE (resource_id, revision_number)
+);
+
+-- Existing resources start from their current state
+INSERT INTO resource_revisions (resource_id, revision_number, snapshot, change_description)
+SELECT r.resource_id, 1,
+       jsonb_build_object(
+           'title', r.title,
+           'category', r.category,
+           'slug', r.slug,
+           'summary', r.summary,
+           'drive_link', r.drive_link,
+           'status', r.status,
+           'published_url', r.published_url,
+           'contributor_id', r.contributor_id,
+           'subjects', COALESCE((SELECT jsonb_agg(rs.subject ORDER BY rs.subject)
+                                 FROM resource_subjects rs WHERE rs.resource_id = r.resource_id), '[]'),
+           'grade_levels', COALESCE((SELECT jsonb_agg(rgl.grade_level ORDER BY rgl.grade_level)
+                                     FROM resource_grade_levels rgl WHERE rgl.resource_id = r.resource_id), '[]')
+       ),
+       'Revision history started'
+FROM resources r
+ON CONFLICT DO NOTHING;
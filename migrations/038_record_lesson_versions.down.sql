@@ -0,0 +1,8 @@
+-- DOWN: Versions without an author cannot be kept once changed_by is required again
+DELETE FROM lesson_versions WHERE changed_by IS NULL;
+
+ALTER TABLE lesson_versions DROP CONSTRAINT fk_lesson_versions_user;
+ALTER TABLE lesson_versions ADD CONSTRAINT fk_lesson_versions_user
+    FOREIGN KEY (changed_by) REFERENCES users(user_id);
+
+ALTER TABLE lesson_versions ALTER COLUMN changed_by SET NOT NULL;
@@ -0,0 +1,21 @@
+-- UP: Lesson versions are now written on every content change, including
+-- the first, which may have no known author.  changed_by is kept (as NULL)
+-- if the editing user is later deleted.
+ALTER TABLE lesson_versions ALTER COLUMN changed_by DROP NOT NULL;
+
+ALTER TABLE lesson_versions DROP CONSTRAINT fk_lesson_versions_user;
+ALTER TABLE lesson_versions ADD CONSTRAINT fk_lesson_versions_user
+    FOREIGN KEY (changed_by) REFERENCES users(user_id) ON DELETE SET NULL;
+
+-- Every lesson's current content becomes its latest version
+INSERT INTO lesson_versions (lesson_id, version_number, content, change_description)
+SELECT l.lesson_id, COALESCE(v.version_number, 0) + 1, l.content, 'Version history started'
+FROM lessons l
+LEFT JOIN LATERAL (
+    SELECT version_number, content
+    FROM lesson_versions
+    WHERE lesson_id = l.lesson_id
+    ORDER BY version_number DESC
+    LIMIT 1
+) v ON TRUE
+WHERE v.content IS DISTINCT FROM l.content;
@@ -0,0 +1,2 @@
+-- DOWN: Normalized content is already a valid document and is kept
+DROP TABLE IF EXISTS lesson_content_problems;
@@ -0,0 +1,98 @@
+-- UP: Lesson content is a versioned document of typed blocks (see
+-- internal/data/lesson_content.go).  Existing content is brought into that
+-- shape where it can be: a bare array of blocks becomes a document, and a
+-- missing version, block id or block visibility is filled in the way the API
+-- fills it in.  Content that cannot be read as a document is left as it is
+-- and listed in lesson_content_problems, to be fixed by hand.
+CREATE TABLE IF NOT EXISTS lesson_content_problems (
+    lesson_id   INT PRIMARY KEY,
+    problem     TEXT NOT NULL,
+    found_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_lesson_content_problems_lesson
+        FOREIGN KEY (lesson_id) REFERENCES lessons(lesson_id) ON DELETE CASCADE
+);
+
+DO $$
+DECLARE
+    rec     RECORD;
+    doc     JSONB;
+    problem TEXT;
+BEGIN
+    FOR rec IN SELECT lesson_id, content FROM lessons ORDER BY lesson_id LOOP
+        problem := NULL;
+        doc := NULL;
+
+        BEGIN
+            doc := rec.content::JSONB;
+        EXCEPTION WHEN invalid_text_representation THEN
+            problem := 'content is not JSON';
+        END;
+
+        IF problem IS NULL AND jsonb_typeof(doc) = 'array' THEN
+            doc := jsonb_build_object('blocks', doc);
+        END IF;
+
+        IF problem IS NOT NULL THEN
+            NULL;
+        ELSIF jsonb_typeof(doc) <> 'object' OR jsonb_typeof(doc->'blocks') IS DISTINCT FROM 'array' THEN
+            problem := 'content has no blocks array';
+        ELSIF EXISTS (SELECT 1 FROM jsonb_object_keys(doc) k WHERE k NOT IN ('version', 'blocks')) THEN
+            problem := 'content has fields other than version and blocks';
+        ELSIF doc ? 'version' AND doc->'version' <> '1'::JSONB THEN
+            problem := 'content is not version 1';
+        ELSIF EXISTS (
+            SELECT 1 FROM jsonb_array_elements(doc->'blocks') b
+            WHERE jsonb_typeof(b) <> 'object'
+            OR COALESCE(b->>'type', '') <> ALL (ARRAY['objectives', 'materials', 'warmup', 'activity', 'assessment',
+                                                      'differentiation', 'extension', 'media', 'fellow_notes'])
+        ) THEN
+            problem := 'content has a block with a missing or unknown type';
+        ELSIF EXISTS (
+            SELECT 1 FROM jsonb_array_elements(doc->'blocks') b, jsonb_object_keys(b) k
+            WHERE k NOT IN ('id', 'type', 'title', 'visibility', 'content')
+        ) THEN
+            problem := 'content has a block with unknown fields';
+        ELSIF EXISTS (
+            SELECT 1 FROM jsonb_array_elements(doc->'blocks') b
+            WHERE jsonb_typeof(b->'content') IS DISTINCT FROM 'null'
+            AND jsonb_typeof(b->'content') IS DISTINCT FROM CASE
+                WHEN b->>'type' IN ('objectives', 'materials', 'extension', 'activity') THEN 'array'
+                WHEN b->>'type' = 'fellow_notes' THEN 'string'
+                ELSE 'object'
+            END
+        ) THEN
+            problem := 'content has a block whose content is the wrong type';
+        END IF;
+
+        IF problem IS NOT NULL THEN
+            INSERT INTO lesson_content_problems (lesson_id, problem)
+            VALUES (rec.lesson_id, problem)
+            ON CONFLICT (lesson_id) DO UPDATE SET problem = EXCLUDED.problem, found_at = NOW();
+            RAISE NOTICE 'lesson %: %', rec.lesson_id, problem;
+            CONTINUE;
+        END IF;
+
+        doc := jsonb_build_object(
+            'version', 1,
+            'blocks', (
+                SELECT COALESCE(jsonb_agg(
+                    b
+                    || CASE WHEN COALESCE(b->>'id', '') = '' THEN jsonb_build_object('id', 'block-' || n) ELSE '{}' END
+                    || CASE WHEN COALESCE(b->>'visibility', '') = ''
+                            THEN jsonb_build_object('visibility', CASE WHEN b->>'type' = 'fellow_notes' THEN 'fellow' ELSE 'public' END)
+                            ELSE '{}' END
+                    ORDER BY n), '[]')
+                FROM jsonb_array_elements(doc->'blocks') WITH ORDINALITY AS e(b, n)
+            )
+        );
+
+        IF doc IS DISTINCT FROM rec.content::JSONB THEN
+            UPDATE lessons SET content = doc::TEXT WHERE lesson_id = rec.lesson_id;
+
+            INSERT INTO lesson_versions (lesson_id, version_number, content, change_description)
+            SELECT rec.lesson_id, COALESCE(MAX(version_number), 0) + 1, doc::TEXT, 'Normalized to lesson document version 1'
+            FROM lesson_versions
+            WHERE lesson_id = rec.lesson_id;
+        END IF;
+    END LOOP;
+END $$;
@@ -0,0 +1,42 @@
+-- DOWN: Go back to refreshing search documents for every changed row
+DROP TRIGGER IF EXISTS lessons_search_delete ON lessons;
+DROP TRIGGER IF EXISTS lessons_search_update ON lessons;
+DROP TRIGGER IF EXISTS lessons_search_insert ON lessons;
+DROP TRIGGER IF EXISTS resource_grade_levels_search_delete ON resource_grade_levels;
+DROP TRIGGER IF EXISTS resource_grade_levels_search_update ON resource_grade_levels;
+DROP TRIGGER IF EXISTS resource_grade_levels_search_insert ON resource_grade_levels;
+DROP TRIGGER IF EXISTS resource_subjects_search_delete ON resource_subjects;
+DROP TRIGGER IF EXISTS resource_subjects_search_update ON resource_subjects;
+DROP TRIGGER IF EXISTS resource_subjects_search_insert ON resource_subjects;
+DROP TRIGGER IF EXISTS resources_search_update ON resources;
+DROP TRIGGER IF EXISTS resources_search_insert ON resources;
+DROP FUNCTION IF EXISTS resources_search_update_trigger();
+DROP FUNCTION IF EXISTS resource_search_statement_trigger();
+
+CREATE OR REPLACE FUNCTION resource_search_trigger()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM refresh_resource_search(OLD.resource_id);
+    ELSE
+        PERFORM refresh_resource_search(NEW.resource_id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER resources_search
+AFTER INSERT OR UPDATE OF title, summary ON resources
+FOR EACH ROW EXECUTE FUNCTION resource_search_trigger();
+
+CREATE TRIGGER resource_subjects_search
+AFTER INSERT OR UPDATE OR DELETE ON resource_subjects
+FOR EACH ROW EXECUTE FUNCTION resource_search_trigger();
+
+CREATE TRIGGER resource_grade_levels_search
+AFTER INSERT OR UPDATE OR DELETE ON resource_grade_levels
+FOR EACH ROW EXECUTE FUNCTION resource_search_trigger();
+
+CREATE TRIGGER lessons_search
+AFTER INSERT OR UPDATE OR DELETE ON lessons
+FOR EACH ROW EXECUTE FUNCTION resource_search_trigger();
@@ -0,0 +1,95 @@
+-- UP: Refresh resource search documents once per statement
+DROP TRIGGER IF EXISTS lessons_search ON lessons;
+DROP TRIGGER IF EXISTS resource_grade_levels_search ON resource_grade_levels;
+DROP TRIGGER IF EXISTS resource_subjects_search ON resource_subjects;
+DROP TRIGGER IF EXISTS resources_search ON resources;
+DROP FUNCTION IF EXISTS resource_search_trigger();
+
+-- The search document is refreshed once per statement for each resource the
+-- statement touched, read from its transition tables.  Saving a resource
+-- replaces its subjects and grade levels row by row, so row-level triggers
+-- rebuilt the same document once for every row deleted and inserted.
+CREATE OR REPLACE FUNCTION resource_search_statement_trigger()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM refresh_resource_search(t.resource_id)
+        FROM (SELECT DISTINCT resource_id FROM new_rows) t;
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM refresh_resource_search(t.resource_id)
+        FROM (SELECT DISTINCT resource_id FROM old_rows) t;
+    ELSE
+        PERFORM refresh_resource_search(t.resource_id)
+        FROM (SELECT resource_id FROM new_rows UNION SELECT resource_id FROM old_rows) t;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- An UPDATE trigger with transition tables cannot name columns, so only the
+-- resources whose title or summary changed are refreshed
+CREATE OR REPLACE FUNCTION resources_search_update_trigger()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM refresh_resource_search(n.resource_id)
+    FROM new_rows n
+    JOIN old_rows o ON o.resource_id = n.resource_id
+    WHERE n.title IS DISTINCT FROM o.title OR n.summary IS DISTINCT FROM o.summary;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER resources_search_insert
+AFTER INSERT ON resources
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER resources_search_update
+AFTER UPDATE ON resources
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resources_search_update_trigger();
+
+CREATE TRIGGER resource_subjects_search_insert
+AFTER INSERT ON resource_subjects
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER resource_subjects_search_update
+AFTER UPDATE ON resource_subjects
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER resource_subjects_search_delete
+AFTER DELETE ON resource_subjects
+REFERENCING OLD TABLE AS old_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER resource_grade_levels_search_insert
+AFTER INSERT ON resource_grade_levels
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER resource_grade_levels_search_update
+AFTER UPDATE ON resource_grade_levels
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER resource_grade_levels_search_delete
+AFTER DELETE ON resource_grade_levels
+REFERENCING OLD TABLE AS old_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER lessons_search_insert
+AFTER INSERT ON lessons
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER lessons_search_update
+AFTER UPDATE ON lessons
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER lessons_search_delete
+AFTER DELETE ON lessons
+REFERENCING OLD TABLE AS old_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
@@ -0,0 +1,5 @@
+-- DOWN: Replies trashed with their comment are live again
+UPDATE resource_comments c
+SET deleted_at = NULL, deleted_by = NULL
+FROM resource_comments p
+WHERE p.comment_id = c.parent_comment_id AND c.deleted_at = p.deleted_at;
@@ -0,0 +1,21 @@
+-- UP: Replies go into the trash with the comment they answer
+-- Deleting a comment used to leave its replies live under a hidden parent,
+-- and purging the parent then removed them through the ON DELETE CASCADE
+-- without them ever being in the trash.  Replies are now trashed with their
+-- comment, at the same time and by the same user, and are restored with it.
+-- Replies already left live under a trashed comment are trashed with it here.
+WITH RECURSIVE orphaned AS (
+    SELECT c.comment_id, p.deleted_at, p.deleted_by
+    FROM resource_comments c
+    JOIN resource_comments p ON p.comment_id = c.parent_comment_id
+    WHERE c.deleted_at IS NULL AND p.deleted_at IS NOT NULL
+    UNION ALL
+    SELECT c.comment_id, o.deleted_at, o.deleted_by
+    FROM resource_comments c
+    JOIN orphaned o ON c.parent_comment_id = o.comment_id
+    WHERE c.deleted_at IS NULL
+)
+UPDATE resource_comments c
+SET deleted_at = o.deleted_at, deleted_by = o.deleted_by
+FROM orphaned o
+WHERE c.comment_id = o.comment_id;
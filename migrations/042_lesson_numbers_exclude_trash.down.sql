@@ -0,0 +1,17 @@
+-- DOWN: Lesson numbers are unique across the trash again
+-- Where a number is held twice, the live lesson, or else the most recently
+-- trashed one, keeps it and the trashed lessons it clashes with are deleted
+-- for good.
+DELETE FROM lessons t
+USING lessons l
+WHERE t.deleted_at IS NOT NULL
+  AND l.lesson_id <> t.lesson_id
+  AND l.resource_id = t.resource_id
+  AND l.lesson_number = t.lesson_number
+  AND (l.deleted_at IS NULL OR l.deleted_at > t.deleted_at
+       OR (l.deleted_at = t.deleted_at AND l.lesson_id > t.lesson_id));
+
+DROP INDEX IF EXISTS uq_lessons_resource_number;
+
+ALTER TABLE lessons
+    ADD CONSTRAINT uq_lessons_resource_number UNIQUE (resource_id, lesson_number);
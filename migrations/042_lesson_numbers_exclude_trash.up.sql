@@ -0,0 +1,8 @@
+-- UP: Lesson numbers only need to be unique among lessons not in the trash
+-- A trashed lesson kept its number until it was purged, so the number could
+-- not be given to a new lesson in the meantime.  Restoring a lesson whose
+-- number has been reused is refused instead.
+ALTER TABLE lessons DROP CONSTRAINT IF EXISTS uq_lessons_resource_number;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_lessons_resource_number
+    ON lessons (resource_id, lesson_number) WHERE deleted_at IS NULL;
@@ -53,7 +53,9 @@ CREATE TYPE review_decision AS ENUM (
 CREATE TABLE IF NOT EXISTS roles (
     role_id     SERIAL PRIMARY KEY,
     name        VARCHAR(50) UNIQUE NOT NULL,
-    description TEXT
+    description TEXT,
+    -- members must enroll in TOTP before using permission-protected routes
+    mfa_required BOOLEAN NOT NULL DEFAULT FALSE
 );
 
 CREATE TABLE IF NOT EXISTS subjects (
@@ -80,9 +82,12 @@ CREATE TABLE IF NOT EXISTS users (
     created_at    TIMESTAMP DEFAULT NOW(),
     created_by    INT REFERENCES users(user_id) ON DELETE SET NULL,
     updated_at    TIMESTAMP DEFAULT NOW(),
-    updated_by    INT REFERENCES users(user_id) ON DELETE SET NULL
+    updated_by    INT REFERENCES users(user_id) ON DELETE SET NULL,
+    version       INT       NOT NULL DEFAULT 1
 );
 
+CREATE INDEX idx_users_created_at ON users (created_at, user_id);
+
 CREATE TABLE IF NOT EXISTS fellows (
     fellow_id              SERIAL PRIMARY KEY,
     user_id                INT UNIQUE NOT NULL,
@@ -119,6 +124,9 @@ CREATE TABLE IF NOT EXISTS resources (
     contributor_id INT NOT NULL,
     created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    version        INT NOT NULL DEFAULT 1,
+    deleted_at     TIMESTAMP,
+    deleted_by     INT REFERENCES users(user_id) ON DELETE SET NULL,
     CONSTRAINT fk_resources_contributor
         FOREIGN KEY (contributor_id) REFERENCES users(user_id),
     CONSTRAINT chk_published_url_required
@@ -127,6 +135,8 @@ CREATE TABLE IF NOT EXISTS resources (
 
 CREATE INDEX idx_resources_status         ON resources (status);
 CREATE INDEX idx_resources_contributor_id ON resources (contributor_id);
+CREATE INDEX idx_resources_deleted_at     ON resources (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_resources_created_at     ON resources (created_at, resource_id);
 
 CREATE TRIGGER resources_updated_at
 BEFORE UPDATE ON resources
@@ -219,6 +229,9 @@ CREATE TABLE IF NOT EXISTS resource_access (
 );
 
 CREATE INDEX idx_resource_access_resource_id ON resource_access (resource_id);
+CREATE INDEX idx_resource_access_accessed_at   ON resource_access (accessed_at, access_id);
+CREATE INDEX idx_resource_access_resource_time ON resource_access (resource_id, accessed_at, access_id);
+CREATE INDEX idx_resource_access_user_time     ON resource_access (user_id, accessed_at, access_id);
 
 CREATE TABLE IF NOT EXISTS resource_status_history (
     history_id  SERIAL PRIMARY KEY,
@@ -233,6 +246,47 @@ CREATE TABLE IF NOT EXISTS resource_status_history (
 
 CREATE INDEX idx_resource_status_history_resource ON resource_status_history (resource_id);
 
+CREATE TABLE IF NOT EXISTS resource_coauthors (
+    resource_id INT NOT NULL,
+    user_id     INT NOT NULL,
+    added_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (resource_id, user_id),
+    CONSTRAINT fk_resource_coauthors_resource
+        FOREIGN KEY (resource_id) REFERENCES resources(resource_id) ON DELETE CASCADE,
+    CONSTRAINT fk_resource_coauthors_user
+        FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_resource_coauthors_user ON resource_coauthors (user_id);
+
+CREATE TABLE IF NOT EXISTS resource_reviewer_assignments (
+    resource_id INT NOT NULL,
+    reviewer_id INT NOT NULL,
+    assigned_by INT,
+    assigned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (resource_id, reviewer_id),
+    CONSTRAINT fk_rra_resource
+        FOREIGN KEY (resource_id) REFERENCES resources(resource_id) ON DELETE CASCADE,
+    CONSTRAINT fk_rra_reviewer
+        FOREIGN KEY (reviewer_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    CONSTRAINT fk_rra_assigned_by
+        FOREIGN KEY (assigned_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_resource_reviewer_assignments_reviewer ON resource_reviewer_assignments (reviewer_id);
+
+CREATE TABLE IF NOT EXISTS user_subjects (
+    user_id INT NOT NULL,
+    subject VARCHAR(150) NOT NULL,
+    PRIMARY KEY (user_id, subject),
+    CONSTRAINT fk_user_subjects_user
+        FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    CONSTRAINT fk_user_subjects_subject
+        FOREIGN KEY (subject) REFERENCES subjects(subject) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_subjects_subject ON user_subjects (subject);
+
 
 -- =============================================================================
 -- CONTENT TABLES
@@ -250,26 +304,183 @@ CREATE TABLE IF NOT EXISTS lessons (
     assessment       TEXT,
     differentiation  TEXT,
     created_at       TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    version          INT NOT NULL DEFAULT 1,
+    deleted_at       TIMESTAMP,
+    deleted_by       INT REFERENCES users(user_id) ON DELETE SET NULL,
     CONSTRAINT fk_lessons_resource
-        FOREIGN KEY (resource_id) REFERENCES resources(resource_id) ON DELETE CASCADE,
-    CONSTRAINT uq_lessons_resource_number
-        UNIQUE (resource_id, lesson_number)
+        FOREIGN KEY (resource_id) REFERENCES resources(resource_id) ON DELETE CASCADE
 );
 
+-- Trashed lessons give up their number
+CREATE UNIQUE INDEX uq_lessons_resource_number
+    ON lessons (resource_id, lesson_number) WHERE deleted_at IS NULL;
+CREATE INDEX idx_lessons_deleted_at ON lessons (deleted_at) WHERE deleted_at IS NOT NULL;
+
 CREATE INDEX idx_lessons_resource ON lessons (resource_id);
 
+-- Full-text search document per resource (title, summary, subjects, grade
+-- levels and lessons), kept current by triggers
+CREATE TABLE IF NOT EXISTS resource_search (
+    resource_id INT PRIMARY KEY REFERENCES resources(resource_id) ON DELETE CASCADE,
+    document    TSVECTOR NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_resource_search_document ON resource_search USING GIN (document);
+
+-- Lesson content is stored as JSON blocks by the lesson builder; pull out the
+-- text a teacher would read (skipping block ids and types).  Older lessons
+-- hold plain text, which is returned unchanged.
+CREATE OR REPLACE FUNCTION lesson_content_text(p_content TEXT)
+RETURNS TEXT AS $$
+BEGIN
+    RETURN (
+        WITH RECURSIVE walk(key, value) AS (
+            SELECT NULL::TEXT, p_content::JSONB
+          UNION ALL
+            SELECT e.key, e.value
+            FROM walk w, LATERAL (
+                SELECT o.key, o.value
+                FROM jsonb_each(CASE WHEN jsonb_typeof(w.value) = 'object' THEN w.value END) o
+              UNION ALL
+                SELECT w.key, a.value
+                FROM jsonb_array_elements(CASE WHEN jsonb_typeof(w.value) = 'array' THEN w.value END) a
+            ) e
+        )
+        SELECT string_agg(value #>> '{}', ' ')
+        FROM walk
+        WHERE jsonb_typeof(value) = 'string'
+        AND key IS DISTINCT FROM 'id' AND key IS DISTINCT FROM 'type'
+    );
+EXCEPTION WHEN invalid_text_representation THEN
+    RETURN p_content;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+-- Title and subjects weigh most, then summary and grade levels, then lessons
+CREATE OR REPLACE FUNCTION refresh_resource_search(p_resource_id INT)
+RETURNS VOID AS $$
+BEGIN
+    INSERT INTO resource_search (resource_id, document)
+    SELECT r.resource_id,
+        setweight(to_tsvector('english', COALESCE(r.title, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(rs.subject, ' ') FROM resource_subjects rs
+             WHERE rs.resource_id = r.resource_id), '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(r.summary, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(rgl.grade_level, ' ') FROM resource_grade_levels rgl
+             WHERE rgl.resource_id = r.resource_id), '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(
+            (SELECT string_agg(concat_ws(' ', l.title, array_to_string(l.objectives, ' '),
+                                         lesson_content_text(l.content)), ' ')
+             FROM lessons l WHERE l.resource_id = r.resource_id AND l.deleted_at IS NULL), '')), 'C')
+    FROM resources r
+    WHERE r.resource_id = p_resource_id
+    ON CONFLICT (resource_id) DO UPDATE SET document = EXCLUDED.document;
+END;
+$$ LANGUAGE plpgsql;
+
+-- The search document is refreshed once per statement for each resource the
+-- statement touched, read from its transition tables.  Saving a resource
+-- replaces its subjects and grade levels row by row, so row-level triggers
+-- rebuilt the same document once for every row deleted and inserted.
+CREATE OR REPLACE FUNCTION resource_search_statement_trigger()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM refresh_resource_search(t.resource_id)
+        FROM (SELECT DISTINCT resource_id FROM new_rows) t;
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM refresh_resource_search(t.resource_id)
+        FROM (SELECT DISTINCT resource_id FROM old_rows) t;
+    ELSE
+        PERFORM refresh_resource_search(t.resource_id)
+        FROM (SELECT resource_id FROM new_rows UNION SELECT resource_id FROM old_rows) t;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- An UPDATE trigger with transition tables cannot name columns, so only the
+-- resources whose title or summary changed are refreshed
+CREATE OR REPLACE FUNCTION resources_search_update_trigger()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM refresh_resource_search(n.resource_id)
+    FROM new_rows n
+    JOIN old_rows o ON o.resource_id = n.resource_id
+    WHERE n.title IS DISTINCT FROM o.title OR n.summary IS DISTINCT FROM o.summary;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER resources_search_insert
+AFTER INSERT ON resources
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER resources_search_update
+AFTER UPDATE ON resources
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resources_search_update_trigger();
+
+CREATE TRIGGER resource_subjects_search_insert
+AFTER INSERT ON resource_subjects
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER resource_subjects_search_update
+AFTER UPDATE ON resource_subjects
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER resource_subjects_search_delete
+AFTER DELETE ON resource_subjects
+REFERENCING OLD TABLE AS old_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER resource_grade_levels_search_insert
+AFTER INSERT ON resource_grade_levels
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER resource_grade_levels_search_update
+AFTER UPDATE ON resource_grade_levels
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER resource_grade_levels_search_delete
+AFTER DELETE ON resource_grade_levels
+REFERENCING OLD TABLE AS old_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER lessons_search_insert
+AFTER INSERT ON lessons
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER lessons_search_update
+AFTER UPDATE ON lessons
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
+CREATE TRIGGER lessons_search_delete
+AFTER DELETE ON lessons
+REFERENCING OLD TABLE AS old_rows
+FOR EACH STATEMENT EXECUTE FUNCTION resource_search_statement_trigger();
+
 CREATE TABLE IF NOT EXISTS lesson_versions (
     version_id         SERIAL PRIMARY KEY,
     lesson_id          INT NOT NULL,
     version_number     INT NOT NULL,
     content            TEXT NOT NULL,
     change_description TEXT,
-    changed_by         INT NOT NULL,
+    changed_by         INT,
     changed_at         TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     CONSTRAINT fk_lesson_versions_lesson
         FOREIGN KEY (lesson_id) REFERENCES lessons(lesson_id) ON DELETE CASCADE,
     CONSTRAINT fk_lesson_versions_user
-        FOREIGN KEY (changed_by) REFERENCES users(user_id),
+        FOREIGN KEY (changed_by) REFERENCES users(user_id) ON DELETE SET NULL,
     CONSTRAINT uq_lesson_versions
         UNIQUE (lesson_id, version_number)
 );
@@ -277,6 +488,28 @@ CREATE TABLE IF NOT EXISTS lesson_versions (
 CREATE INDEX idx_lesson_versions_lesson     ON lesson_versions (lesson_id);
 CREATE INDEX idx_lesson_versions_changed_by ON lesson_versions (changed_by);
 
+-- Lessons whose content could not be read as a lesson document when
+-- migration 039 normalized existing content
+CREATE TABLE IF NOT EXISTS lesson_content_problems (
+    lesson_id   INT PRIMARY KEY,
+    problem     TEXT NOT NULL,
+    found_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_lesson_content_problems_lesson
+        FOREIGN KEY (lesson_id) REFERENCES lessons(lesson_id) ON DELETE CASCADE
+);
+
+-- Snapshots of a resource's metadata, subjects and grade levels, one per write
+CREATE TABLE IF NOT EXISTS resource_revisions (
+    revision_id        BIGSERIAL PRIMARY KEY,
+    resource_id        INT NOT NULL REFERENCES resources(resource_id) ON DELETE CASCADE,
+    revision_number    INT NOT NULL,
+    snapshot           JSONB NOT NULL,
+    change_description TEXT,
+    changed_by         INT REFERENCES users(user_id) ON DELETE SET NULL,
+    created_at         TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_resource_revisions UNIQUE (resource_id, revision_number)
+);
+
 CREATE TABLE IF NOT EXISTS video_metadata (
     id                  BIGSERIAL PRIMARY KEY,
     resource_id         BIGINT NOT NULL UNIQUE
@@ -305,6 +538,8 @@ CREATE TABLE IF NOT EXISTS resource_comments (
     content           TEXT NOT NULL,
     created_at        TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at        TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at        TIMESTAMP,
+    deleted_by        INT REFERENCES users(user_id) ON DELETE SET NULL,
     CONSTRAINT fk_resource_comments_resource
         FOREIGN KEY (resource_id) REFERENCES resources(resource_id) ON DELETE CASCADE,
     CONSTRAINT fk_resource_comments_user
@@ -316,6 +551,8 @@ CREATE TABLE IF NOT EXISTS resource_comments (
 CREATE INDEX idx_resource_comments_resource ON resource_comments (resource_id);
 CREATE INDEX idx_resource_comments_user     ON resource_comments (user_id);
 CREATE INDEX idx_resource_comments_parent   ON resource_comments (parent_comment_id);
+CREATE INDEX idx_resource_comments_deleted_at ON resource_comments (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_resource_comments_resource_time ON resource_comments (resource_id, created_at, comment_id);
 
 CREATE TRIGGER resource_comments_updated_at
 BEFORE UPDATE ON resource_comments
@@ -333,10 +570,66 @@ CREATE TABLE IF NOT EXISTS auth_tokens (
     token      BYTEA UNIQUE NOT NULL,
     scope      VARCHAR(100),
     expires_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
-    created_at TIMESTAMP(0) WITH TIME ZONE DEFAULT NOW()
+    created_at TIMESTAMP(0) WITH TIME ZONE DEFAULT NOW(),
+    -- refresh token rotation: tokens from one sign-in share a family;
+    -- used_at marks a rotated refresh token
+    family_id  VARCHAR(32),
+    used_at    TIMESTAMP(0) WITH TIME ZONE,
+    -- session details shown to the user under /v1/me/sessions
+    ip_address   VARCHAR(64),
+    user_agent   TEXT,
+    last_used_at TIMESTAMP(0) WITH TIME ZONE
 );
 
 CREATE INDEX IF NOT EXISTS idx_auth_tokens_user_id ON auth_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_auth_tokens_family_id ON auth_tokens (family_id);
+
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id        INT PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    totp_secret    VARCHAR(64) NOT NULL,
+    enabled        BOOLEAN NOT NULL DEFAULT FALSE,
+    -- last accepted TOTP time step, so a code cannot be replayed
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at     TIMESTAMP(0) WITH TIME ZONE DEFAULT NOW(),
+    enabled_at     TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    code_id   SERIAL PRIMARY KEY,
+    user_id   INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    code_hash BYTEA NOT NULL,
+    used_at   TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes (user_id);
+
+-- Failed sign-in tracking, per account (lowercased email) and per client IP
+CREATE TABLE IF NOT EXISTS login_throttles (
+    scope          VARCHAR(10) NOT NULL,
+    subject        TEXT NOT NULL,
+    failed_count   INT NOT NULL DEFAULT 0,
+    last_failed_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    blocked_until  TIMESTAMP(0) WITH TIME ZONE,
+    PRIMARY KEY (scope, subject)
+);
+
+-- Audit log of privileged actions, written in the same transaction as the change
+CREATE TABLE IF NOT EXISTS audit_events (
+    audit_id     BIGSERIAL PRIMARY KEY,
+    actor_id     INT REFERENCES users(user_id) ON DELETE SET NULL,
+    action       VARCHAR(50) NOT NULL,
+    target_type  VARCHAR(50) NOT NULL,
+    target_id    BIGINT NOT NULL,
+    before_state JSONB,
+    after_state  JSONB,
+    ip_address   VARCHAR(45),
+    request_id   VARCHAR(64),
+    created_at   TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events (target_type, target_id);
 
 CREATE TABLE IF NOT EXISTS notifications (
     notification_id SERIAL PRIMARY KEY,
@@ -348,6 +641,7 @@ CREATE TABLE IF NOT EXISTS notifications (
 );
 
 CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_sent_at ON notifications (user_id, sent_at, notification_id);
 
 
 -- =============================================================================
@@ -389,6 +683,27 @@ CREATE INDEX idx_fellow_applications_user_id ON fellow_applications (user_id);
 CREATE INDEX idx_fellow_applications_status  ON fellow_applications (status);
 
 
+-- =============================================================================
+-- PERMISSIONS
+-- =============================================================================
+
+CREATE TABLE IF NOT EXISTS permissions (
+    permission_id SERIAL PRIMARY KEY,
+    code          VARCHAR(100) UNIQUE NOT NULL,
+    description   TEXT
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id       INT NOT NULL,
+    permission_id INT NOT NULL,
+    PRIMARY KEY (role_id, permission_id),
+    CONSTRAINT fk_role_permissions_role
+        FOREIGN KEY (role_id) REFERENCES roles(role_id) ON DELETE CASCADE,
+    CONSTRAINT fk_role_permissions_permission
+        FOREIGN KEY (permission_id) REFERENCES permissions(permission_id) ON DELETE CASCADE
+);
+
+
 -- =============================================================================
 -- SEED DATA
 -- =============================================================================
@@ -446,3 +761,175 @@ VALUES (
     TRUE
 )
 ON CONFLICT (username) DO NOTHING;
+
+-- Permissions
+INSERT INTO permissions (code, description) VALUES
+    ('user:read',                 'List and view any user account'),
+    ('user:manage',               'Create users and change roles or activation status'),
+    ('user:delete',               'Delete user accounts'),
+    ('role:manage',               'Create, edit and delete roles and their permissions'),
+    ('token:manage',              'Revoke tokens belonging to other users'),
+    ('fellow:delete',             'Delete Fellow profiles'),
+    ('fellow_application:review', 'Approve or reject Fellow applications'),
+    ('resource:create',           'Submit new resources'),
+    ('resource:delete',           'Delete resources'),
+    ('resource:status_override',  'Set a resource status through the admin override'),
+    ('resource:metrics',          'View per-status resource counts'),
+    ('lesson:delete',             'Delete lessons'),
+    ('review:create',             'Record review decisions and inline review comments'),
+    ('review:delete',             'Delete review decisions'),
+    ('resource_access:read',      'View resource access logs'),
+    ('resource_access:delete',    'Delete resource access records'),
+    ('contribution:manage',       'Create and update contribution scores'),
+    ('contribution:delete',       'Delete contribution scores'),
+    ('notification:create',       'Send notifications to users'),
+    ('admin:metrics',             'View platform-wide metrics')
+ON CONFLICT (code) DO NOTHING;
+
+-- Default role → permission matrix
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM (VALUES
+    ('admin',         'user:read'),
+    ('admin',         'user:manage'),
+    ('admin',         'user:delete'),
+    ('admin',         'role:manage'),
+    ('admin',         'token:manage'),
+    ('admin',         'fellow:delete'),
+    ('admin',         'fellow_application:review'),
+    ('admin',         'resource:create'),
+    ('admin',         'resource:delete'),
+    ('admin',         'resource:status_override'),
+    ('admin',         'resource:metrics'),
+    ('admin',         'lesson:delete'),
+    ('admin',         'review:create'),
+    ('admin',         'review:delete'),
+    ('admin',         'resource_access:read'),
+    ('admin',         'resource_access:delete'),
+    ('admin',         'contribution:manage'),
+    ('admin',         'contribution:delete'),
+    ('admin',         'notification:create'),
+    ('admin',         'admin:metrics'),
+    ('DSC',           'user:read'),
+    ('DSC',           'user:manage'),
+    ('DSC',           'fellow:delete'),
+    ('DSC',           'fellow_application:review'),
+    ('DSC',           'resource:create'),
+    ('DSC',           'resource:delete'),
+    ('DSC',           'resource:status_override'),
+    ('DSC',           'resource:metrics'),
+    ('DSC',           'lesson:delete'),
+    ('DSC',           'review:create'),
+    ('DSC',           'resource_access:read'),
+    ('DSC',           'contribution:manage'),
+    ('DSC',           'notification:create'),
+    ('DSC',           'admin:metrics'),
+    ('TeamLead',      'user:read'),
+    ('TeamLead',      'fellow:delete'),
+    ('TeamLead',      'resource:metrics'),
+    ('TeamLead',      'review:create'),
+    ('SubjectExpert', 'resource:metrics'),
+    ('SubjectExpert', 'review:create'),
+    ('Fellow',        'resource:create'),
+    ('Secretary',     'notification:create')
+) AS m(role_name, code)
+JOIN roles r ON r.name = m.role_name
+JOIN permissions p ON p.code = m.code
+ON CONFLICT DO NOTHING;
+
+INSERT INTO permissions (code, description) VALUES
+    ('resource:edit_any', 'Edit any resource, lesson or review comment regardless of ownership'),
+    ('review:assign',     'Assign reviewers to resources'),
+    ('comment:moderate',  'Edit or delete other users'' comments')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM (VALUES
+    ('admin',    'resource:edit_any'),
+    ('admin',    'review:assign'),
+    ('admin',    'comment:moderate'),
+    ('DSC',      'resource:edit_any'),
+    ('DSC',      'review:assign'),
+    ('DSC',      'comment:moderate'),
+    ('TeamLead', 'review:assign')
+) AS m(role_name, code)
+JOIN roles r ON r.name = m.role_name
+JOIN permissions p ON p.code = m.code
+ON CONFLICT DO NOTHING;
+
+INSERT INTO permissions (code, description) VALUES
+    ('expertise:manage',   'Assign subject expertise to users'),
+    ('review:any_subject', 'Review resources outside of your own subject expertise')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM (VALUES
+    ('admin',    'expertise:manage'),
+    ('admin',    'review:any_subject'),
+    ('DSC',      'expertise:manage'),
+    ('DSC',      'review:any_subject'),
+    ('TeamLead', 'review:any_subject')
+) AS m(role_name, code)
+JOIN roles r ON r.name = m.role_name
+JOIN permissions p ON p.code = m.code
+ON CONFLICT DO NOTHING;
+
+-- Privileged roles must use two-factor authentication
+UPDATE roles SET mfa_required = TRUE WHERE name IN ('admin', 'DSC');
+
+INSERT INTO permissions (code, description) VALUES
+    ('audit:read', 'View the audit log of privileged actions')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM (VALUES
+    ('admin', 'audit:read'),
+    ('DSC',   'audit:read')
+) AS m(role_name, code)
+JOIN roles r ON r.name = m.role_name
+JOIN permissions p ON p.code = m.code
+ON CONFLICT DO NOTHING;
+
+INSERT INTO permissions (code, description) VALUES
+    ('trash:manage', 'View and restore deleted resources, lessons and comments')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM (VALUES
+    ('admin', 'trash:manage')
+) AS m(role_name, code)
+JOIN roles r ON r.name = m.role_name
+JOIN permissions p ON p.code = m.code
+ON CONFLICT DO NOTHING;
+
+INSERT INTO permissions (code, description) VALUES
+    ('resource:import', 'Bulk import resources from CSV or NDJSON files')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM (VALUES
+    ('admin', 'resource:import'),
+    ('DSC',   'resource:import')
+) AS m(role_name, code)
+JOIN roles r ON r.name = m.role_name
+JOIN permissions p ON p.code = m.code
+ON CONFLICT DO NOTHING;
+
+INSERT INTO permissions (code, description) VALUES
+    ('data:export', 'Export resources, users, reviews and access logs as CSV, JSON or NDJSON')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.role_id, p.permission_id
+FROM (VALUES
+    ('admin', 'data:export'),
+    ('DSC',   'data:export')
+) AS m(role_name, code)
+JOIN roles r ON r.name = m.role_name
+JOIN permissions p ON p.code = m.code
+ON CONFLICT DO NOTHING;
@@ -1,3 +1,6 @@
+import { get } from 'svelte/store';
+import { authToken, refreshToken, signOut } from '../stores/auth.js';
+
 // API configuration
 const API_BASE_URL = import.meta.env.VITE_API_URL || 'http://localhost:4000/v1';
 
@@ -10,7 +13,40 @@ class APIError extends Error {
   }
 }
 
-async function request(endpoint, options = {}) {
+// A refresh token can only be used once, so concurrent requests that all see
+// an expired access token must share a single refresh call.
+let refreshInFlight = null;
+
+async function refreshAccessToken() {
+  const token = get(refreshToken);
+  if (!token) return null;
+
+  if (!refreshInFlight) {
+    refreshInFlight = fetch(`${API_BASE_URL}/tokens/refresh`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ refresh_token: token }),
+    })
+      .then(async (response) => {
+        if (!response.ok) {
+          signOut();
+          return null;
+        }
+        const data = await response.json();
+        authToken.set(data.token.token);
+        refreshToken.set(data.refresh_token.token);
+        return data.token.token;
+      })
+      .catch(() => null)
+      .finally(() => {
+        refreshInFlight = null;
+      });
+  }
+
+  return refreshInFlight;
+}
+
+async function request(endpoint, options = {}, retried = false) {
   const url = `${API_BASE_URL}${endpoint}`;
   
   console.log('API Request:', {
@@ -30,6 +66,18 @@ async function request(endpoint, options = {}) {
 
   try {
     const response = await fetch(url, config);
+
+    // The access token is short-lived; renew it once and replay the request.
+    if (response.status === 401 && !retried && config.headers.Authorization) {
+      const newToken = await refreshAccessToken();
+      if (newToken) {
+        return request(endpoint, {
+          ...options,
+          headers: { ...options.headers, 'Authorization': `Bearer ${newToken}` },
+        }, true);
+      }
+    }
+
     const data = await response.json();
 
     console.log('API Response:', {
@@ -89,8 +137,100 @@ export const tokenAPI = {
       body: JSON.stringify({ email, password }),
     });
   },
+
+  // POST /v1/tokens/mfa — second sign-in step for accounts with 2FA
+  verifyMFA: async (mfaToken, code, isRecoveryCode = false) => {
+    return request('/tokens/mfa', {
+      method: 'POST',
+      body: JSON.stringify(isRecoveryCode
+        ? { mfa_token: mfaToken, recovery_code: code }
+        : { mfa_token: mfaToken, code }),
+    });
+  },
+
+  // DELETE /v1/tokens/authentication — revoke the current session
+  logout: async (authToken) => {
+    return request('/tokens/authentication', {
+      method: 'DELETE',
+      headers: { 'Authorization': `Bearer ${authToken}` },
+    });
+  },
+
+  // GET /v1/me/sessions — the signed-in user's active sessions
+  getSessions: async (authToken) => {
+    return request('/me/sessions', {
+      headers: { 'Authorization': `Bearer ${authToken}` },
+    });
+  },
+
+  // DELETE /v1/me/sessions/:id — sign out of another device
+  revokeSession: async (id, authToken) => {
+    return request(`/me/sessions/${id}`, {
+      method: 'DELETE',
+      headers: { 'Authorization': `Bearer ${authToken}` },
+    });
+  },
 };
 
+// Two-factor authentication API methods
+export const mfaAPI = {
+  // GET /v1/me/mfa — enrollment status and recovery codes left
+  getStatus: async (authToken) => {
+    return request('/me/mfa', {
+      headers: { 'Authorization': `Bearer ${authToken}` },
+    });
+  },
+
+  // POST /v1/me/mfa/totp — start enrollment, returns secret and otpauth_uri
+  startEnrollment: async (authToken) => {
+    return request('/me/mfa/totp', {
+      method: 'POST',
+      headers: { 'Authorization': `Bearer ${authToken}` },
+    });
+  },
+
+  // POST /v1/me/mfa/totp/verify — confirm a code, returns recovery codes
+  verifyEnrollment: async (code, authToken) => {
+    return request('/me/mfa/totp/verify', {
+      method: 'POST',
+      headers: { 'Authorization': `Bearer ${authToken}` },
+      body: JSON.stringify({ code }),
+    });
+  },
+
+  // POST /v1/me/mfa/recovery-codes — replace the recovery codes
+  regenerateRecoveryCodes: async (code, authToken) => {
+    return request('/me/mfa/recovery-codes', {
+      method: 'POST',
+      headers: { 'Authorization': `Bearer ${authToken}` },
+      body: JSON.stringify({ code }),
+    });
+  },
+
+  // DELETE /v1/me/mfa — turn two-factor authentication off
+  disable: async (code, authToken) => {
+    return request('/me/mfa', {
+      method: 'DELETE',
+      headers: { 'Authorization': `Bearer ${authToken}` },
+      body: JSON.stringify({ code }),
+    });
+  },
+};
+
+// Array values are sent as repeated parameters (subject=A&subject=B), which
+// the API treats as "any of"
+function resourceFilterParams(filters) {
+  const params = new URLSearchParams();
+  Object.entries(filters).forEach(([key, value]) => {
+    if (Array.isArray(value)) {
+      value.forEach(v => params.append(key, v));
+    } else if (value) {
+      params.append(key, value);
+    }
+  });
+  return params.toString();
+}
+
 // Resource API methods
 export const resourceAPI = {
   create: async (resourceData, authToken) => {
@@ -104,14 +244,17 @@ export const resourceAPI = {
   },
 
   getAll: async (filters = {}) => {
-    const params = new URLSearchParams();
-    Object.entries(filters).forEach(([key, value]) => {
-      if (value) params.append(key, value);
-    });
-    const queryString = params.toString();
+    const queryString = resourceFilterParams(filters);
     return request(`/resources${queryString ? `?${queryString}` : ''}`);
   },
 
+  // Counts per subject, grade level, category, contributor and status under
+  // the same filters as getAll
+  getFacets: async (filters = {}) => {
+    const queryString = resourceFilterParams(filters);
+    return request(`/resource-facets${queryString ? `?${queryString}` : ''}`);
+  },
+
   get: async (id) => {
     return request(`/resources/${id}`);
   },
@@ -159,6 +302,19 @@ export const reviewAPI = {
       body: JSON.stringify({ resource_id, reviewer_id, reviewer_role_id, decision, comment_summary }),
     });
   },
+
+  // GET /v1/review-queue — resources awaiting review, limited to the
+  // reviewer's subject expertise. status may be a comma-separated list.
+  getQueue: async (filters = {}, authToken) => {
+    const params = new URLSearchParams();
+    Object.entries(filters).forEach(([key, value]) => {
+      if (value) params.append(key, value);
+    });
+    const queryString = params.toString();
+    return request(`/review-queue${queryString ? `?${queryString}` : ''}`, {
+      headers: { 'Authorization': `Bearer ${authToken}` },
+    });
+  },
 };
 
 // Admin API methods
@@ -4,8 +4,11 @@ import { writable } from 'svelte/store';
 // Initialize from localStorage if available
 const storedToken = typeof window !== 'undefined' ? localStorage.getItem('authToken') : null;
 const storedUser = typeof window !== 'undefined' ? localStorage.getItem('authUser') : null;
+const storedRefreshToken = typeof window !== 'undefined' ? localStorage.getItem('refreshToken') : null;
 
 export const authToken = writable(storedToken);
+// Long-lived token used to obtain a new access token when it expires
+export const refreshToken = writable(storedRefreshToken);
 export const currentUser = writable(storedUser ? JSON.parse(storedUser) : null);
 
 // Subscribe to token changes and sync with localStorage
@@ -19,6 +22,17 @@ authToken.subscribe(value => {
   }
 });
 
+// Subscribe to refresh token changes and sync with localStorage
+refreshToken.subscribe(value => {
+  if (typeof window !== 'undefined') {
+    if (value) {
+      localStorage.setItem('refreshToken', value);
+    } else {
+      localStorage.removeItem('refreshToken');
+    }
+  }
+});
+
 // Subscribe to user changes and sync with localStorage
 currentUser.subscribe(value => {
   if (typeof window !== 'undefined') {
@@ -40,5 +54,6 @@ export function isAuthenticated() {
 // Helper function to sign out
 export function signOut() {
   authToken.set(null);
+  refreshToken.set(null);
   currentUser.set(null);
 }